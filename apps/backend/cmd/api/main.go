@@ -40,12 +40,15 @@ func main() {
 	vips.Startup(nil)
 	defer vips.Shutdown()
 
-	server := server.NewServer()
+	server, err := server.NewServer()
+	if err != nil {
+		log.Fatalf("failed to create the server: %v", err)
+	}
 
 	done := make(chan bool, 1)
 	go gracefulShutdown(server, done)
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"backend/internal/attachments"
+	"backend/internal/database"
+	"backend/internal/files"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reconcile deletes the stored files which nothing references anymore.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only list the orphaned files")
+	grace := flag.Duration("grace", 24*time.Hour, "Skip the files modified more recently than this")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	storage, err := files.NewStorageFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := attachments.Reconcile(ctx, database.New(), storage, *dryRun, *grace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile: %v\n", err)
+	}
+
+	if *dryRun {
+		fmt.Printf("scanned %d files, %d orphaned\n", report.Scanned, report.Orphaned)
+	} else {
+		fmt.Printf("scanned %d files, %d orphaned, %d deleted\n", report.Scanned, report.Orphaned, report.Deleted)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -185,7 +185,7 @@ func main() {
 			if _, ok := used[uid]; ok {
 				continue
 			}
-			if _, err := db.JoinServer(ctx, srv.ID, uid, i); err == nil {
+			if _, _, _, _, _, err := db.JoinServer(ctx, srv.ID, uid, i, ""); err == nil {
 				members = append(members, uid)
 				used[uid] = struct{}{}
 			}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	messages "backend/proto"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lxzan/gws"
+	"google.golang.org/protobuf/proto"
+)
+
+const heartbeatInterval = 10 * time.Second
+
+// voiceclient is a headless participant to try the voice signaling out: it joins a voice channel,
+// prints the rosters, signals, SFU sessions and errors it receives, and answers the offers made to
+// it with dummy session descriptions. It connects with a personal access token having the gateway
+// scope.
+func main() {
+	addr := flag.String("addr", "ws://localhost:8080", "Address of the API")
+	token := flag.String("token", os.Getenv("KYOB_TOKEN"), "Personal access token")
+	userID := flag.String("user", "", "ID of the token's user")
+	serverID := flag.String("server", "", "ID of the server")
+	channelID := flag.String("channel", "", "ID of the voice channel")
+	offerTo := flag.String("offer-to", "", "Send an offer to this participant once joined")
+	mute := flag.Bool("mute", false, "Join muted")
+	flag.Parse()
+
+	if *token == "" || *userID == "" || *serverID == "" || *channelID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := &client{serverID: *serverID, channelID: *channelID, offerTo: *offerTo, closed: make(chan error, 1)}
+	socket, _, err := gws.NewClient(client, &gws.ClientOption{
+		Addr:          strings.TrimSuffix(*addr, "/") + "/api/protected/ws/" + *userID,
+		RequestHeader: http.Header{"Authorization": []string{"Bearer " + *token}},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect: %v\n", err)
+		os.Exit(1)
+	}
+	go socket.ReadLoop()
+
+	join := client.request()
+	join.Action = &messages.VoiceRequest_Join{Join: &messages.VoiceJoin{SelfMute: *mute}}
+	client.send(socket, join)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = socket.WriteString("heartbeat")
+		case err := <-client.closed:
+			fmt.Fprintf(os.Stderr, "disconnected: %v\n", err)
+			os.Exit(1)
+		case <-ctx.Done():
+			leave := client.request()
+			leave.Action = &messages.VoiceRequest_Leave{Leave: &messages.VoiceLeave{}}
+			client.send(socket, leave)
+			socket.WriteClose(1000, nil)
+			return
+		}
+	}
+}
+
+type client struct {
+	gws.BuiltinEventHandler
+	serverID  string
+	channelID string
+	offerTo   string
+	offered   bool
+	closed    chan error
+}
+
+func (c *client) request() *messages.VoiceRequest {
+	return &messages.VoiceRequest{ServerId: c.serverID, ChannelId: c.channelID}
+}
+
+func (c *client) send(socket *gws.Conn, request *messages.VoiceRequest) {
+	data, err := proto.Marshal(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encode: %v\n", err)
+		return
+	}
+	_ = socket.WriteMessage(gws.OpcodeBinary, data)
+}
+
+func (c *client) signal(socket *gws.Conn, to, signalType, payload string) {
+	request := c.request()
+	request.Action = &messages.VoiceRequest_Signal{Signal: &messages.VoiceSignal{
+		ChannelId: c.channelID,
+		UserId:    to,
+		Type:      signalType,
+		Payload:   payload,
+	}}
+	c.send(socket, request)
+}
+
+func (c *client) OnClose(socket *gws.Conn, err error) {
+	c.closed <- err
+}
+
+func (c *client) OnMessage(socket *gws.Conn, message *gws.Message) {
+	defer message.Close()
+	if message.Opcode != gws.OpcodeBinary {
+		return
+	}
+
+	var msg messages.WSMessage
+	if err := proto.Unmarshal(message.Data.Bytes(), &msg); err != nil {
+		return
+	}
+
+	switch content := msg.Content.(type) {
+	case *messages.WSMessage_VoiceChannelStates:
+		roster := content.VoiceChannelStates
+		fmt.Printf("roster %s:", roster.ChannelId)
+		for _, state := range roster.States {
+			fmt.Printf(" %s(mute=%t deaf=%t server_mute=%t server_deaf=%t video=%t suppressed=%t tracks=%d)",
+				state.UserId, state.SelfMute, state.SelfDeaf, state.ServerMute, state.ServerDeaf, state.Video, state.Suppressed, len(state.Tracks))
+		}
+		fmt.Println()
+
+		if c.offerTo != "" && !c.offered && roster.ChannelId == c.channelID {
+			c.offered = true
+			c.signal(socket, c.offerTo, "offer", "v=0 headless offer")
+		}
+	case *messages.WSMessage_VoiceSignal:
+		signal := content.VoiceSignal
+		fmt.Printf("signal %s from %s: %s\n", signal.Type, signal.UserId, signal.Payload)
+
+		switch signal.Type {
+		case "offer":
+			c.signal(socket, signal.UserId, "answer", "v=0 headless answer")
+		case "answer":
+			c.signal(socket, signal.UserId, "candidate", "candidate:0 1 UDP 1 127.0.0.1 9 typ host")
+		}
+	case *messages.WSMessage_VoiceSession:
+		session := content.VoiceSession
+		fmt.Printf("session %s on %s, token valid until %s\n", session.ChannelId, session.Url, session.ExpiresAt.AsTime().Format(time.TimeOnly))
+	case *messages.WSMessage_VoiceError:
+		fmt.Printf("error %s: %s\n", content.VoiceError.Code, content.VoiceError.Message)
+	}
+}
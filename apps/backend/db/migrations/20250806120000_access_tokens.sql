@@ -0,0 +1,29 @@
+-- migrate:up
+ALTER TABLE users
+  ADD COLUMN bot BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN bot_owner_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_users_bot_owner_id ON users(bot_owner_id);
+
+CREATE TABLE access_tokens(
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_by VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(255) NOT NULL UNIQUE,
+  scopes VARCHAR(255) ARRAY NOT NULL DEFAULT '{}',
+  rate_limit INT NOT NULL DEFAULT 60,
+  last_used_at TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_access_tokens_user_id ON access_tokens(user_id);
+CREATE INDEX idx_access_tokens_created_by ON access_tokens(created_by);
+
+-- migrate:down
+DROP TABLE IF EXISTS access_tokens;
+
+ALTER TABLE users
+  DROP COLUMN IF EXISTS bot_owner_id,
+  DROP COLUMN IF EXISTS bot;
@@ -0,0 +1,35 @@
+-- migrate:up
+CREATE TABLE webhooks(
+  id VARCHAR(255) PRIMARY KEY,
+  server_id VARCHAR(255) NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+  created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+  url VARCHAR(2048) NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  events VARCHAR(255) ARRAY NOT NULL DEFAULT '{}',
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_webhooks_server_id ON webhooks(server_id);
+
+CREATE TABLE webhook_deliveries(
+  id VARCHAR(255) PRIMARY KEY,
+  webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  event VARCHAR(255) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_status_code INT,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  delivered_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- migrate:down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,20 @@
+-- migrate:up
+CREATE TABLE incoming_webhooks(
+  id VARCHAR(255) PRIMARY KEY,
+  server_id VARCHAR(255) NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+  channel_id VARCHAR(255) NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+  user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+  name VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_incoming_webhooks_server_id ON incoming_webhooks(server_id);
+
+ALTER TABLE messages ADD COLUMN author_override JSONB;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN IF EXISTS author_override;
+DROP TABLE IF EXISTS incoming_webhooks;
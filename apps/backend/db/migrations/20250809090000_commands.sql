@@ -0,0 +1,25 @@
+-- migrate:up
+CREATE TABLE commands(
+  id VARCHAR(255) PRIMARY KEY,
+  server_id VARCHAR(255) NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+  bot_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(32) NOT NULL,
+  description VARCHAR(100) NOT NULL,
+  options JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  UNIQUE(server_id, name)
+);
+
+CREATE INDEX idx_commands_bot_id ON commands(bot_id);
+
+CREATE TABLE interaction_endpoints(
+  bot_id VARCHAR(255) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  url VARCHAR(2048) NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS interaction_endpoints;
+DROP TABLE IF EXISTS commands;
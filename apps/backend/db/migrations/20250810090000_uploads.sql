@@ -0,0 +1,19 @@
+-- migrate:up
+CREATE TABLE uploads(
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  key VARCHAR(1024) NOT NULL,
+  file_name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(255) NOT NULL,
+  size BIGINT NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  message_id VARCHAR(255) REFERENCES messages(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_uploads_user_id ON uploads(user_id);
+CREATE INDEX idx_uploads_expires_at ON uploads(expires_at) WHERE status <> 'attached';
+
+-- migrate:down
+DROP TABLE IF EXISTS uploads;
@@ -0,0 +1,85 @@
+-- migrate:up
+CREATE TABLE attachments(
+  id VARCHAR(255) PRIMARY KEY,
+  key VARCHAR(1024) NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  message_id VARCHAR(255) REFERENCES messages(id) ON DELETE CASCADE,
+  user_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE,
+  server_id VARCHAR(255) REFERENCES servers(id) ON DELETE CASCADE,
+  emoji_id VARCHAR(255) REFERENCES emojis(id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_attachments_key ON attachments(key);
+CREATE INDEX idx_attachments_message_id ON attachments(message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX idx_attachments_user_id ON attachments(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_attachments_server_id ON attachments(server_id) WHERE server_id IS NOT NULL;
+CREATE INDEX idx_attachments_emoji_id ON attachments(emoji_id) WHERE emoji_id IS NOT NULL;
+
+CREATE TABLE file_deletions(
+  key VARCHAR(1024) PRIMARY KEY,
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+-- Attachments go away with their owner through the foreign keys, cascades included (a deleted
+-- account takes its servers and their messages along), so the file deletion is queued from here.
+-- The worker only deletes a file once no attachment references its key anymore.
+CREATE FUNCTION queue_file_deletion() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO file_deletions (key) VALUES (OLD.key) ON CONFLICT (key) DO NOTHING;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER attachments_queue_file_deletion
+AFTER DELETE ON attachments
+FOR EACH ROW EXECUTE FUNCTION queue_file_deletion();
+
+-- Track the files which existed before, keys are the last segment of their URL.
+INSERT INTO attachments (id, key, kind, message_id)
+SELECT gen_random_uuid()::text, regexp_replace(f->>'url', '^.*/', ''), 'message', m.id
+FROM messages m
+CROSS JOIN LATERAL jsonb_array_elements(CASE WHEN jsonb_typeof(m.attachments) = 'array' THEN m.attachments ELSE '[]'::jsonb END) f
+WHERE f->>'url' IS NOT NULL;
+
+INSERT INTO attachments (id, key, kind, user_id)
+SELECT gen_random_uuid()::text, regexp_replace(avatar, '^.*/', ''), 'user_avatar', id FROM users WHERE avatar IS NOT NULL AND avatar <> ''
+UNION ALL
+SELECT gen_random_uuid()::text, regexp_replace(banner, '^.*/', ''), 'user_banner', id FROM users WHERE banner IS NOT NULL AND banner <> '';
+
+INSERT INTO attachments (id, key, kind, server_id)
+SELECT gen_random_uuid()::text, regexp_replace(avatar, '^.*/', ''), 'server_avatar', id FROM servers WHERE avatar IS NOT NULL AND avatar <> ''
+UNION ALL
+SELECT gen_random_uuid()::text, regexp_replace(banner, '^.*/', ''), 'server_banner', id FROM servers WHERE banner IS NOT NULL AND banner <> '';
+
+INSERT INTO attachments (id, key, kind, emoji_id)
+SELECT gen_random_uuid()::text, regexp_replace(url, '^.*/', ''), 'emoji', id FROM emojis;
+
+-- GIFs point to their animated webp, the static one next to it has to be tracked too.
+INSERT INTO attachments (id, key, kind, message_id, user_id, server_id)
+SELECT gen_random_uuid()::text, regexp_replace(key, '-animated\.webp$', '.webp'), kind, message_id, user_id, server_id
+FROM attachments
+WHERE key LIKE '%-animated.webp';
+
+-- The default avatars are shared by every user who never uploaded one and are never deleted.
+INSERT INTO attachments (id, key, kind) VALUES
+  ('system-1', 'h5l2kgkgl5388cn02q4qfxsp.webp', 'system'),
+  ('system-2', 'avlu2a8o91gpvalk6gmgjx5r-avatar-dmplh84i8bft0ww7g60qrt15.webp', 'system'),
+  ('system-3', 'wn3yuwwghg7efv5an78bojyc.webp', 'system'),
+  ('system-4', 'ru3piutctxqesrv1xom8d8nn.webp', 'system'),
+  ('system-5', 'rx38ak222ydoxb5kevww01ce-avatar-nf3531et6izcz2k6uyk5nm84.webp', 'system'),
+  ('system-6', 'qilvsvq39rbmnybr4s24e86d-avatar-xtzdeamrkmtmh11odhtb9p2y.webp', 'system'),
+  ('system-7', 'vlxth3gbqa3bmcsysy581pzy-avatar-frh262c7w15ntm8jia4jjrud.webp', 'system'),
+  ('system-8', 'jnclk7c4el73kaqc726qlw7c-avatar-u0090mlv8r3zu5d850unedvu.webp', 'system'),
+  ('system-9', 'u8b6rr2uz0xn3ubx41o6o6wx.webp', 'system'),
+  ('system-10', 'f6983q524yum1ntx468j5gzu-avatar-mpkv59uvddg7jjf26gbigoas.webp', 'system'),
+  ('system-11', 'qvn4bem4ms537cnn3kjyyv0l-avatar-rmk4i0evo7ls9v75jllzeuw0.webp', 'system');
+
+-- migrate:down
+DROP TRIGGER IF EXISTS attachments_queue_file_deletion ON attachments;
+DROP FUNCTION IF EXISTS queue_file_deletion();
+DROP TABLE IF EXISTS file_deletions;
+DROP TABLE IF EXISTS attachments;
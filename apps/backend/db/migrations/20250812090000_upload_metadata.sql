@@ -0,0 +1,6 @@
+-- migrate:up
+-- Describes confirmed uploads the way they are attached to messages (dimensions, variants...).
+ALTER TABLE uploads ADD COLUMN metadata JSONB;
+
+-- migrate:down
+ALTER TABLE uploads DROP COLUMN IF EXISTS metadata;
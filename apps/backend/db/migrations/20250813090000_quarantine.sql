@@ -0,0 +1,18 @@
+-- migrate:up
+CREATE TABLE quarantined_files(
+  id VARCHAR(255) PRIMARY KEY,
+  key VARCHAR(1024) NOT NULL,
+  user_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+  server_id VARCHAR(255) REFERENCES servers(id) ON DELETE SET NULL,
+  channel_id VARCHAR(255) REFERENCES channels(id) ON DELETE SET NULL,
+  file_name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(255) NOT NULL,
+  size BIGINT NOT NULL,
+  signature VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_quarantined_files_server_id ON quarantined_files(server_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS quarantined_files;
@@ -0,0 +1,40 @@
+-- migrate:up
+-- Attachments record the bytes they count against the quotas of a user and of a server.
+ALTER TABLE attachments
+  ADD COLUMN size BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN quota_user_id VARCHAR(255),
+  ADD COLUMN quota_server_id VARCHAR(255);
+
+CREATE INDEX idx_attachments_quota_user_id ON attachments(quota_user_id);
+CREATE INDEX idx_attachments_quota_server_id ON attachments(quota_server_id);
+
+ALTER TABLE users ADD COLUMN storage_tier VARCHAR(20) NOT NULL DEFAULT 'free';
+ALTER TABLE servers ADD COLUMN storage_tier VARCHAR(20) NOT NULL DEFAULT 'free';
+
+UPDATE attachments a SET quota_user_id = m.author_id, quota_server_id = NULLIF(m.server_id, 'global')
+FROM messages m
+WHERE a.message_id = m.id;
+
+UPDATE attachments SET quota_user_id = user_id WHERE user_id IS NOT NULL;
+UPDATE attachments SET quota_server_id = server_id WHERE server_id IS NOT NULL;
+
+UPDATE attachments a SET quota_user_id = e.user_id
+FROM emojis e
+WHERE a.emoji_id = e.id;
+
+-- Only direct uploads kept their size, files sent through the API before this count for nothing.
+UPDATE attachments a SET size = u.size
+FROM uploads u
+WHERE a.key = u.key;
+
+-- migrate:down
+ALTER TABLE servers DROP COLUMN IF EXISTS storage_tier;
+ALTER TABLE users DROP COLUMN IF EXISTS storage_tier;
+
+DROP INDEX IF EXISTS idx_attachments_quota_server_id;
+DROP INDEX IF EXISTS idx_attachments_quota_user_id;
+
+ALTER TABLE attachments
+  DROP COLUMN IF EXISTS quota_server_id,
+  DROP COLUMN IF EXISTS quota_user_id,
+  DROP COLUMN IF EXISTS size;
@@ -0,0 +1,6 @@
+-- migrate:up
+-- Link previews, filled in the background once the message is posted.
+ALTER TABLE messages ADD COLUMN embeds JSONB NOT NULL DEFAULT '[]';
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN IF EXISTS embeds;
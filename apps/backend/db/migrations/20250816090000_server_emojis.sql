@@ -0,0 +1,25 @@
+-- migrate:up
+-- Emojis and stickers owned by a server, every member can use them.
+CREATE TABLE server_emojis(
+  id VARCHAR(255) PRIMARY KEY,
+  server_id VARCHAR(255) NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+  creator_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('emoji', 'sticker')),
+  shortcode VARCHAR(255) NOT NULL,
+  url VARCHAR(255) NOT NULL,
+  animated BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  UNIQUE (server_id, kind, shortcode)
+);
+
+ALTER TABLE attachments ADD COLUMN server_emoji_id VARCHAR(255) REFERENCES server_emojis(id) ON DELETE CASCADE;
+CREATE INDEX idx_attachments_server_emoji_id ON attachments(server_emoji_id) WHERE server_emoji_id IS NOT NULL;
+
+-- Personal emojis never expired.
+ALTER TABLE emojis DROP COLUMN expire_at;
+
+-- migrate:down
+ALTER TABLE emojis ADD COLUMN expire_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL;
+DROP INDEX IF EXISTS idx_attachments_server_emoji_id;
+ALTER TABLE attachments DROP COLUMN IF EXISTS server_emoji_id;
+DROP TABLE IF EXISTS server_emojis;
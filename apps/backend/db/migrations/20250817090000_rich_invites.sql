@@ -0,0 +1,34 @@
+-- migrate:up
+-- max_uses = 0 is unlimited and a NULL expire_at never expires. A server has at most one vanity
+-- invite, whose code is picked by its managers.
+ALTER TABLE invites
+  ADD COLUMN max_uses INT NOT NULL DEFAULT 0,
+  ADD COLUMN uses INT NOT NULL DEFAULT 0,
+  ADD COLUMN temporary BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN vanity BOOLEAN NOT NULL DEFAULT false,
+  ALTER COLUMN expire_at DROP NOT NULL,
+  ALTER COLUMN expire_at DROP DEFAULT;
+
+DELETE FROM invites a USING invites b
+WHERE lower(a.invite_id) = lower(b.invite_id) AND a.id > b.id;
+
+CREATE UNIQUE INDEX idx_invites_invite_id ON invites(lower(invite_id));
+CREATE UNIQUE INDEX idx_invites_vanity ON invites(server_id) WHERE vanity;
+CREATE INDEX idx_invites_server_id ON invites(server_id);
+
+-- Temporary members are removed when they disconnect, unless they were given a role meanwhile.
+ALTER TABLE server_members ADD COLUMN temporary BOOLEAN NOT NULL DEFAULT false;
+
+-- migrate:down
+ALTER TABLE server_members DROP COLUMN IF EXISTS temporary;
+DROP INDEX IF EXISTS idx_invites_server_id;
+DROP INDEX IF EXISTS idx_invites_vanity;
+DROP INDEX IF EXISTS idx_invites_invite_id;
+DELETE FROM invites WHERE expire_at IS NULL;
+ALTER TABLE invites
+  ALTER COLUMN expire_at SET DEFAULT NOW(),
+  ALTER COLUMN expire_at SET NOT NULL,
+  DROP COLUMN IF EXISTS vanity,
+  DROP COLUMN IF EXISTS temporary,
+  DROP COLUMN IF EXISTS uses,
+  DROP COLUMN IF EXISTS max_uses;
@@ -0,0 +1,19 @@
+-- migrate:up
+-- Public servers are listed in the discovery directory, under a category and a few tags.
+ALTER TABLE servers
+  ADD COLUMN category VARCHAR(32),
+  ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Search goes through the name and the text of the description, which is an editor document.
+CREATE INDEX idx_servers_discovery_search ON servers
+USING GIN (to_tsvector('simple', name || ' ' || COALESCE(jsonb_path_query_array(description, 'strict $.**.text')::text, '')))
+WHERE public;
+
+CREATE INDEX idx_servers_discovery_tags ON servers USING GIN (tags) WHERE public;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_servers_discovery_tags;
+DROP INDEX IF EXISTS idx_servers_discovery_search;
+ALTER TABLE servers
+  DROP COLUMN IF EXISTS tags,
+  DROP COLUMN IF EXISTS category;
@@ -0,0 +1,40 @@
+-- migrate:up
+-- Boards of the kanban channels, cards are ordered by their position inside their column.
+CREATE TABLE kanban_columns(
+  id VARCHAR(255) PRIMARY KEY,
+  channel_id VARCHAR(255) NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+  name VARCHAR(64) NOT NULL,
+  position INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_kanban_columns_channel_id ON kanban_columns(channel_id, position);
+
+CREATE TABLE kanban_cards(
+  id VARCHAR(255) PRIMARY KEY,
+  channel_id VARCHAR(255) NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+  column_id VARCHAR(255) NOT NULL REFERENCES kanban_columns(id) ON DELETE CASCADE,
+  author_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+  title VARCHAR(256) NOT NULL,
+  description JSONB,
+  assignees VARCHAR(255) ARRAY NOT NULL DEFAULT '{}',
+  labels VARCHAR(32) ARRAY NOT NULL DEFAULT '{}',
+  due_at TIMESTAMP WITH TIME ZONE,
+  position INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_kanban_cards_channel_id ON kanban_cards(channel_id);
+CREATE INDEX idx_kanban_cards_column_id ON kanban_cards(column_id, position);
+
+-- The comments of a card are messages of its channel.
+ALTER TABLE messages ADD COLUMN card_id VARCHAR(255) REFERENCES kanban_cards(id) ON DELETE CASCADE;
+CREATE INDEX idx_messages_card_id ON messages(card_id) WHERE card_id IS NOT NULL;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_messages_card_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS card_id;
+DROP TABLE IF EXISTS kanban_cards;
+DROP TABLE IF EXISTS kanban_columns;
@@ -0,0 +1,12 @@
+-- migrate:up
+-- The comments of a gallery post are messages of its channel, linked to the post.
+ALTER TABLE messages ADD COLUMN post_id VARCHAR(255) REFERENCES messages(id) ON DELETE CASCADE;
+CREATE INDEX idx_messages_post_id ON messages(post_id) WHERE post_id IS NOT NULL;
+
+-- Gallery listings only go through the posts.
+CREATE INDEX idx_messages_channel_posts ON messages(channel_id, created_at DESC) WHERE post_id IS NULL;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_messages_channel_posts;
+DROP INDEX IF EXISTS idx_messages_post_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS post_id;
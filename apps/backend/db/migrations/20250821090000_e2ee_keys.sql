@@ -0,0 +1,46 @@
+-- migrate:up
+-- Public keys of the devices of a user. The key agreement key is signed by the identity key of the
+-- device, the private keys never leave it.
+CREATE TABLE user_devices(
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(64) NOT NULL,
+  identity_key VARCHAR(64) NOT NULL,
+  public_key VARCHAR(64) NOT NULL,
+  signature VARCHAR(128) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  UNIQUE(user_id, identity_key)
+);
+
+CREATE INDEX idx_user_devices_user_id ON user_devices(user_id);
+
+-- Group keys of the encrypted channels, the current one has the highest epoch. The server only
+-- keeps them sealed to every device of the members.
+CREATE TABLE channel_keys(
+  id VARCHAR(255) PRIMARY KEY,
+  channel_id VARCHAR(255) NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+  epoch INT NOT NULL,
+  creator_device_id VARCHAR(255) REFERENCES user_devices(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  UNIQUE(channel_id, epoch)
+);
+
+CREATE TABLE channel_key_envelopes(
+  key_id VARCHAR(255) NOT NULL REFERENCES channel_keys(id) ON DELETE CASCADE,
+  device_id VARCHAR(255) NOT NULL REFERENCES user_devices(id) ON DELETE CASCADE,
+  sender_device_id VARCHAR(255) REFERENCES user_devices(id) ON DELETE SET NULL,
+  ciphertext TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  PRIMARY KEY (key_id, device_id)
+);
+
+CREATE INDEX idx_channel_key_envelopes_device_id ON channel_key_envelopes(device_id);
+
+-- Set when members or devices are removed, messages are refused until a new group key is shared.
+ALTER TABLE channels ADD COLUMN rekey_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- migrate:down
+ALTER TABLE channels DROP COLUMN IF EXISTS rekey_required;
+DROP TABLE IF EXISTS channel_key_envelopes;
+DROP TABLE IF EXISTS channel_keys;
+DROP TABLE IF EXISTS user_devices;
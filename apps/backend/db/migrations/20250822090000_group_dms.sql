@@ -0,0 +1,14 @@
+-- migrate:up
+-- Group direct messages are channels of the global server like the ones of friendships, with an
+-- owner who can remove their participants.
+ALTER TABLE channels ADD COLUMN owner_id VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_channels_global_users ON channels USING GIN (users) WHERE server_id = 'global';
+
+-- Who can add a user to a group direct message besides their friends: 'server_members' or 'friends'.
+ALTER TABLE users ADD COLUMN dm_privacy VARCHAR(20) NOT NULL DEFAULT 'server_members';
+
+-- migrate:down
+ALTER TABLE users DROP COLUMN IF EXISTS dm_privacy;
+DROP INDEX IF EXISTS idx_channels_global_users;
+ALTER TABLE channels DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,7 @@
+-- migrate:up
+-- A block is a blocked friends row going from the blocker to the blocked user. Blocking a friend turns
+-- their friendship into the block so their direct message stays, both users can block each other.
+CREATE UNIQUE INDEX idx_friends_blocks ON friends(sender_id, receiver_id) WHERE blocked;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_friends_blocks;
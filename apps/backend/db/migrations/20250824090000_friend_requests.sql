@@ -0,0 +1,17 @@
+-- migrate:up
+-- Who can send a friend request to a user: 'everyone', 'friends_of_friends', 'server_members' or 'nobody'.
+ALTER TABLE users ADD COLUMN friend_request_privacy VARCHAR(20) NOT NULL DEFAULT 'everyone';
+
+-- Two users have at most one friendship or pending request between them, whoever sent it.
+DELETE FROM friends a USING friends b
+WHERE NOT a.blocked AND NOT b.blocked
+  AND LEAST(a.sender_id, a.receiver_id) = LEAST(b.sender_id, b.receiver_id)
+  AND GREATEST(a.sender_id, a.receiver_id) = GREATEST(b.sender_id, b.receiver_id)
+  AND (a.accepted, a.created_at, a.id) < (b.accepted, b.created_at, b.id);
+
+CREATE UNIQUE INDEX idx_friends_pair ON friends(LEAST(sender_id, receiver_id), GREATEST(sender_id, receiver_id))
+WHERE NOT blocked;
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_friends_pair;
+ALTER TABLE users DROP COLUMN IF EXISTS friend_request_privacy;
@@ -0,0 +1,26 @@
+-- migrate:up
+-- A message can reply to another one of its channel, the author of which is notified.
+ALTER TABLE messages ADD COLUMN reply_to_id VARCHAR(255) REFERENCES messages(id) ON DELETE SET NULL;
+
+-- The notification feed of a user: mentions, role mentions and replies point to their message, friend
+-- requests to their friendship and invites to the invite sent by a friend. They go away with them.
+CREATE TABLE notifications(
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(20) NOT NULL,
+  actor_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE,
+  server_id VARCHAR(255) REFERENCES servers(id) ON DELETE CASCADE,
+  channel_id VARCHAR(255) REFERENCES channels(id) ON DELETE CASCADE,
+  message_id VARCHAR(255) REFERENCES messages(id) ON DELETE CASCADE,
+  friendship_id VARCHAR(255) REFERENCES friends(id) ON DELETE CASCADE,
+  invite_id VARCHAR(255) REFERENCES invites(id) ON DELETE CASCADE,
+  read_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_notifications_unread ON notifications(user_id, server_id) WHERE read_at IS NULL;
+
+-- migrate:down
+DROP TABLE IF EXISTS notifications;
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_id;
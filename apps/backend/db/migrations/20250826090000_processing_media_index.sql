@@ -0,0 +1,6 @@
+-- migrate:up
+-- Finds the messages whose media were still processing when the server stopped.
+CREATE INDEX idx_messages_processing ON messages(created_at) WHERE attachments @> '[{"processing": true}]';
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_messages_processing;
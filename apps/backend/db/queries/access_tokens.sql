@@ -0,0 +1,26 @@
+-- name: CreateAccessToken :one
+INSERT INTO access_tokens (
+  id, user_id, created_by, name, token_hash, scopes, rate_limit, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING id, user_id, name, scopes, rate_limit, expires_at, created_at;
+
+-- name: GetAccessTokenByHash :one
+SELECT * FROM access_tokens WHERE token_hash = $1;
+
+-- name: GetAccessTokens :many
+SELECT t.id, t.user_id, u.username, t.name, t.scopes, t.rate_limit, t.last_used_at, t.expires_at, t.created_at
+FROM access_tokens t
+JOIN users u ON u.id = t.user_id
+WHERE t.created_by = $1
+ORDER BY t.created_at DESC;
+
+-- name: GetAccessTokenHashes :many
+SELECT token_hash FROM access_tokens WHERE user_id = $1;
+
+-- name: TouchAccessToken :exec
+UPDATE access_tokens SET last_used_at = now() WHERE id = $1;
+
+-- name: DeleteAccessToken :one
+DELETE FROM access_tokens WHERE id = $1 AND created_by = $2 RETURNING token_hash;
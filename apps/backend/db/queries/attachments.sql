@@ -0,0 +1,46 @@
+-- name: CreateAttachments :exec
+INSERT INTO attachments (id, key, kind, message_id, user_id, server_id, emoji_id, server_emoji_id, size, quota_user_id, quota_server_id)
+SELECT
+  unnest(@ids::varchar[]),
+  unnest(@keys::varchar[]),
+  @kind::varchar,
+  sqlc.narg(message_id)::varchar,
+  sqlc.narg(user_id)::varchar,
+  sqlc.narg(server_id)::varchar,
+  sqlc.narg(emoji_id)::varchar,
+  sqlc.narg(server_emoji_id)::varchar,
+  unnest(@sizes::bigint[]),
+  sqlc.narg(quota_user_id)::varchar,
+  sqlc.narg(quota_server_id)::varchar;
+
+-- name: DeleteOwnerAttachments :exec
+DELETE FROM attachments
+WHERE kind = @kind::varchar AND COALESCE(message_id, user_id, server_id, emoji_id, server_emoji_id) = @owner_id::varchar;
+
+-- name: GetReferencedKeys :many
+SELECT key FROM attachments WHERE key = ANY(@keys::varchar[])
+UNION
+SELECT key FROM uploads WHERE key = ANY(@keys::varchar[]) AND status <> 'attached'
+UNION
+SELECT key FROM quarantined_files WHERE key = ANY(@keys::varchar[]);
+
+-- name: ClaimFileDeletions :many
+WITH due AS (
+  SELECT key FROM file_deletions
+  WHERE next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+UPDATE file_deletions f SET next_attempt_at = now() + interval '1 minute'
+FROM due
+WHERE f.key = due.key
+RETURNING f.key, f.attempts, EXISTS(SELECT 1 FROM attachments a WHERE a.key = f.key)::boolean AS referenced;
+
+-- name: DeleteFileDeletion :exec
+DELETE FROM file_deletions WHERE key = $1;
+
+-- name: RecordFileDeletionFailure :exec
+UPDATE file_deletions
+SET attempts = attempts + 1, last_error = @last_error::text, next_attempt_at = @next_attempt_at
+WHERE key = @key;
@@ -1,6 +1,24 @@
 -- name: GetChannel :one
 SELECT * FROM channels WHERE id = $1;
 
+-- name: LockChannel :exec
+-- Serializes the changes made to a channel which depend on its current state.
+SELECT id FROM channels WHERE id = $1 FOR UPDATE;
+
+-- name: GetReadableChannelIDs :many
+-- The channels of a server a member can read, with the rules of GetChannelMemberIDs.
+SELECT c.id FROM channels c
+INNER JOIN server_members sm ON sm.server_id = c.server_id AND sm.user_id = @user_id AND sm.ban = false
+WHERE c.server_id = @server_id
+  AND (
+    (COALESCE(cardinality(c.users), 0) = 0 AND COALESCE(cardinality(c.roles), 0) = 0)
+    OR sm.user_id = ANY(c.users)
+    OR sm.roles && c.roles
+  );
+
+-- name: GetCategory :one
+SELECT * FROM channel_categories WHERE id = $1;
+
 -- name: GetFriendChannels :many
 SELECT *
 FROM channels
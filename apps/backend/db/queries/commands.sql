@@ -0,0 +1,32 @@
+-- name: CreateCommand :one
+INSERT INTO commands (
+  id, server_id, bot_id, name, description, options
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: DeleteBotCommands :exec
+DELETE FROM commands WHERE server_id = $1 AND bot_id = $2;
+
+-- name: GetCommand :one
+SELECT * FROM commands WHERE id = $1;
+
+-- name: GetServerCommands :many
+SELECT c.id, c.server_id, c.bot_id, c.name, c.description, c.options, u.display_name AS bot_name, u.avatar AS bot_avatar
+FROM commands c
+JOIN users u ON u.id = c.bot_id
+WHERE c.server_id = $1
+ORDER BY c.name;
+
+-- name: UpsertInteractionEndpoint :exec
+INSERT INTO interaction_endpoints (bot_id, url, secret)
+VALUES ($1, $2, $3)
+ON CONFLICT (bot_id) DO UPDATE
+  SET url = EXCLUDED.url, secret = EXCLUDED.secret, updated_at = now();
+
+-- name: GetInteractionEndpoint :one
+SELECT * FROM interaction_endpoints WHERE bot_id = $1;
+
+-- name: DeleteInteractionEndpoint :execrows
+DELETE FROM interaction_endpoints WHERE bot_id = $1;
@@ -0,0 +1,95 @@
+-- name: GetUserDevices :many
+SELECT * FROM user_devices WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetUserDevice :one
+SELECT * FROM user_devices WHERE id = $1 AND user_id = $2;
+
+-- name: GetDevicesOfUsers :many
+SELECT * FROM user_devices WHERE user_id = ANY(@user_ids::varchar[]) ORDER BY user_id, created_at;
+
+-- name: CountUserDevices :one
+SELECT COUNT(*) FROM user_devices WHERE user_id = $1;
+
+-- name: CreateUserDevice :one
+INSERT INTO user_devices (
+  id, user_id, name, identity_key, public_key, signature
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: DeleteUserDevice :one
+DELETE FROM user_devices WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetChannelMemberIDs :many
+-- The users who can read a channel: the members of its server for public channels, the listed users
+-- and roles for private ones, the participants of direct messages.
+SELECT sm.user_id FROM channels c
+INNER JOIN server_members sm ON sm.server_id = c.server_id AND sm.ban = false
+WHERE c.id = @channel_id AND c.server_id != 'global'
+  AND (
+    (COALESCE(cardinality(c.users), 0) = 0 AND COALESCE(cardinality(c.roles), 0) = 0)
+    OR sm.user_id = ANY(c.users)
+    OR sm.roles && c.roles
+  )
+UNION
+SELECT unnest(c.users)::varchar FROM channels c WHERE c.id = @channel_id AND c.server_id = 'global';
+
+-- name: GetUserE2EEChannels :many
+SELECT c.* FROM channels c
+LEFT JOIN server_members sm ON sm.server_id = c.server_id AND sm.user_id = @user_id::varchar AND sm.ban = false
+WHERE c.e2ee AND (
+  @user_id::varchar = ANY(c.users)
+  OR (sm.id IS NOT NULL AND (
+    (COALESCE(cardinality(c.users), 0) = 0 AND COALESCE(cardinality(c.roles), 0) = 0)
+    OR sm.roles && c.roles
+  ))
+);
+
+-- name: EnableChannelE2EE :one
+UPDATE channels SET e2ee = true, rekey_required = true, updated_at = now() WHERE id = $1 AND NOT e2ee
+RETURNING *;
+
+-- name: RequireChannelsRekey :many
+UPDATE channels SET rekey_required = true WHERE id = ANY(@ids::varchar[]) AND e2ee
+RETURNING *;
+
+-- name: RequireServerRekey :many
+UPDATE channels SET rekey_required = true WHERE server_id = $1 AND e2ee
+RETURNING *;
+
+-- name: RequireRoleRekey :many
+-- The encrypted channels read through a role, once it was taken from some of its members.
+UPDATE channels SET rekey_required = true WHERE server_id = @server_id AND e2ee AND @role_id::varchar = ANY(roles)
+RETURNING *;
+
+-- name: GetCurrentChannelKey :one
+SELECT * FROM channel_keys WHERE channel_id = $1 ORDER BY epoch DESC LIMIT 1;
+
+-- name: GetChannelKey :one
+SELECT * FROM channel_keys WHERE id = $1 AND channel_id = $2;
+
+-- name: CreateChannelKey :one
+INSERT INTO channel_keys (
+  id, channel_id, epoch, creator_device_id
+) VALUES (
+  @id, @channel_id, (SELECT COALESCE(MAX(epoch), 0) + 1 FROM channel_keys WHERE channel_id = @channel_id), @creator_device_id
+)
+RETURNING *;
+
+-- name: ClearChannelRekey :exec
+UPDATE channels SET rekey_required = false WHERE id = $1;
+
+-- name: CreateChannelKeyEnvelopes :exec
+INSERT INTO channel_key_envelopes (key_id, device_id, sender_device_id, ciphertext)
+SELECT @key_id::varchar, unnest(@device_ids::varchar[]), @sender_device_id::varchar, unnest(@ciphertexts::text[])
+ON CONFLICT (key_id, device_id) DO NOTHING;
+
+-- name: GetChannelKeys :many
+-- The group keys of a channel sealed to a device, from the current one.
+SELECT k.id, k.epoch, k.created_at, e.sender_device_id, e.ciphertext
+FROM channel_keys k
+INNER JOIN channel_key_envelopes e ON e.key_id = k.id AND e.device_id = @device_id
+WHERE k.channel_id = @channel_id
+ORDER BY k.epoch DESC;
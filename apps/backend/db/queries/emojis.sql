@@ -13,3 +13,26 @@ INSERT INTO emojis (
 ) VALUES (
   $1, $2, $3, $4
 );
+
+-- name: GetServerEmojis :many
+SELECT * FROM server_emojis WHERE server_id = $1 ORDER BY created_at;
+
+-- name: GetMemberServerEmojis :many
+SELECT e.* FROM server_emojis e
+INNER JOIN server_members sm ON sm.server_id = e.server_id AND sm.user_id = $1 AND sm.ban = false
+ORDER BY e.server_id, e.created_at;
+
+-- name: CreateServerEmoji :copyfrom
+INSERT INTO server_emojis (
+  id, server_id, creator_id, kind, shortcode, url, animated
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: UpdateServerEmoji :one
+UPDATE server_emojis SET shortcode = $1 WHERE server_id = $2 AND id = $3
+RETURNING *;
+
+-- name: DeleteServerEmoji :one
+DELETE FROM server_emojis WHERE server_id = $1 AND id = $2
+RETURNING *;
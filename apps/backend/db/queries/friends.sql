@@ -6,19 +6,66 @@ INSERT INTO friends (
 )
 RETURNING *;
 
--- name: AcceptFriend :exec
-UPDATE friends SET accepted=true WHERE id=$1 AND receiver_id = $2;
+-- name: AcceptFriend :one
+UPDATE friends SET accepted=true, updated_at = NOW()
+WHERE id=$1 AND receiver_id = $2 AND NOT accepted AND NOT blocked
+RETURNING *;
+
+-- name: DeleteFriend :one
+DELETE FROM friends WHERE id=$1 AND (receiver_id = $2 OR sender_id = $2) AND accepted AND NOT blocked
+RETURNING *;
+
+-- name: DeclineFriendRequest :one
+DELETE FROM friends WHERE id=$1 AND receiver_id = $2 AND NOT accepted AND NOT blocked
+RETURNING *;
+
+-- name: CancelFriendRequest :one
+DELETE FROM friends WHERE id=$1 AND sender_id = $2 AND NOT accepted AND NOT blocked
+RETURNING *;
+
+-- name: GetIncomingFriendRequests :many
+SELECT u.id, u.display_name, u.avatar, u.banner, u.about_me, f.id AS friendship_id, f.created_at
+FROM friends f
+INNER JOIN users u ON u.id = f.sender_id
+WHERE f.receiver_id = $1 AND NOT f.accepted AND NOT f.blocked
+ORDER BY f.created_at DESC;
+
+-- name: GetOutgoingFriendRequests :many
+SELECT u.id, u.display_name, u.avatar, u.banner, u.about_me, f.id AS friendship_id, f.created_at
+FROM friends f
+INNER JOIN users u ON u.id = f.receiver_id
+WHERE f.sender_id = $1 AND NOT f.accepted AND NOT f.blocked
+ORDER BY f.created_at DESC;
 
--- name: DeleteFriend :exec
-DELETE FROM friends WHERE id=$1 AND receiver_id = $2 OR sender_id = $2;
+-- name: CanSendFriendRequest :one
+-- Whether the privacy settings of @receiver_id let @sender_id send them a friend request.
+SELECT (CASE u.friend_request_privacy
+  WHEN 'everyone' THEN true
+  WHEN 'friends_of_friends' THEN EXISTS (
+    SELECT 1 FROM friends a
+    INNER JOIN friends b ON b.accepted AND NOT b.blocked AND (
+      (b.sender_id = @sender_id::varchar AND b.receiver_id IN (a.sender_id, a.receiver_id))
+      OR (b.receiver_id = @sender_id::varchar AND b.sender_id IN (a.sender_id, a.receiver_id))
+    )
+    WHERE a.accepted AND NOT a.blocked AND (a.sender_id = u.id OR a.receiver_id = u.id)
+  )
+  WHEN 'server_members' THEN EXISTS (
+    SELECT 1 FROM server_members a
+    INNER JOIN server_members b ON b.server_id = a.server_id AND b.user_id = @sender_id::varchar AND b.ban = false
+    WHERE a.user_id = u.id AND a.ban = false AND a.server_id != 'global'
+  )
+  ELSE false
+END)::bool
+FROM users u
+WHERE u.id = @receiver_id::varchar;
 
 -- name: GetFriends :many
 SELECT u.id, u.display_name, u.avatar, u.banner, u.about_me, f.accepted, f.id AS friendship_id, 
        f.sender_id AS friendship_sender_id, c.id AS channel_id, 'offline' as status
 FROM users u
 INNER JOIN friends f ON u.id = f.receiver_id
-LEFT JOIN channels c ON $1::text = ANY(c.users) AND u.id::text = ANY(c.users)
-WHERE f.sender_id = $1
+LEFT JOIN channels c ON c.friendship_id = f.id
+WHERE f.sender_id = $1 AND NOT f.blocked
 
 UNION
 
@@ -26,21 +73,62 @@ SELECT u.id, u.display_name, u.avatar, u.banner, u.about_me, f.accepted, f.id AS
        f.sender_id AS friendship_sender_id, c.id AS channel_id, 'offline' as status
 FROM users u
 INNER JOIN friends f ON u.id = f.sender_id  
-LEFT JOIN channels c ON $1 = ANY(c.users) AND u.id::text = ANY(c.users)
-WHERE f.receiver_id = $1;
+LEFT JOIN channels c ON c.friendship_id = f.id
+WHERE f.receiver_id = $1 AND NOT f.blocked;
 
 -- name: GetFriendIDs :many
 SELECT u.id
 FROM users u
 INNER JOIN friends f ON u.id = f.receiver_id
-WHERE f.sender_id = $1
+WHERE f.sender_id = $1 AND NOT f.blocked
 
 UNION
 
 SELECT u.id
 FROM users u
 INNER JOIN friends f ON u.id = f.sender_id
-WHERE f.receiver_id = $1;
+WHERE f.receiver_id = $1 AND NOT f.blocked;
+
+-- name: GetFriendshipsBetween :many
+SELECT * FROM friends
+WHERE (sender_id = @user_id AND receiver_id = @other_id) OR (sender_id = @other_id AND receiver_id = @user_id);
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+  SELECT 1 FROM friends
+  WHERE blocked
+    AND ((sender_id = @user_id AND receiver_id = @other_id) OR (sender_id = @other_id AND receiver_id = @user_id))
+);
+
+-- name: BlockFriendship :one
+UPDATE friends SET sender_id = $2, receiver_id = $3, accepted = false, blocked = true, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateBlock :one
+INSERT INTO friends (
+  id, sender_id, receiver_id, blocked
+) VALUES (
+  $1, $2, $3, true
+)
+RETURNING *;
+
+-- name: DeleteBlock :one
+DELETE FROM friends WHERE sender_id = $1 AND receiver_id = $2 AND blocked
+RETURNING *;
+
+-- name: GetBlockedUsers :many
+SELECT u.id, u.display_name, u.avatar, f.id AS friendship_id, f.updated_at AS blocked_at
+FROM friends f
+INNER JOIN users u ON u.id = f.receiver_id
+WHERE f.sender_id = $1 AND f.blocked
+ORDER BY f.updated_at DESC;
+
+-- name: GetFriendshipChannelIDs :many
+SELECT id FROM channels WHERE friendship_id = $1;
+
+-- name: GetBlockedUserIDs :many
+SELECT receiver_id FROM friends WHERE sender_id = $1 AND blocked;
 
 -- name: GetExistingChannel :one
 -- UPDATE channels SET active = true
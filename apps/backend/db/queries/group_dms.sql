@@ -0,0 +1,57 @@
+-- name: GetGroupDMs :many
+SELECT * FROM channels
+WHERE server_id = 'global' AND type = 'group_dm' AND @user_id::varchar = ANY(users)
+ORDER BY updated_at DESC;
+
+-- name: GetGroupDM :one
+SELECT * FROM channels WHERE id = $1 AND server_id = 'global' AND type = 'group_dm';
+
+-- name: CountGroupDMs :one
+SELECT COUNT(*) FROM channels
+WHERE server_id = 'global' AND type = 'group_dm' AND @user_id::varchar = ANY(users);
+
+-- name: CreateGroupDM :one
+INSERT INTO channels (
+  id, server_id, name, type, users, owner_id
+) VALUES (
+  $1, 'global', $2, 'group_dm', $3, $4
+)
+RETURNING *;
+
+-- name: LockGroupDM :one
+SELECT * FROM channels WHERE id = $1 AND server_id = 'global' AND type = 'group_dm' FOR UPDATE;
+
+-- name: RenameGroupDM :one
+UPDATE channels SET name = $2, updated_at = now()
+WHERE id = $1 AND server_id = 'global' AND type = 'group_dm'
+RETURNING *;
+
+-- name: SetGroupDMUsers :one
+UPDATE channels SET users = $2, owner_id = $3, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetDMReachableUserIDs :many
+-- The users who can be added to a group direct message by @user_id: their friends, and the members
+-- of a server they share unless they only accept friends, as long as neither blocked the other.
+SELECT u.id FROM users u
+WHERE u.id = ANY(@user_ids::varchar[]) AND NOT EXISTS (
+  SELECT 1 FROM friends f
+  WHERE f.blocked AND (
+    (f.sender_id = @user_id::varchar AND f.receiver_id = u.id)
+    OR (f.receiver_id = @user_id::varchar AND f.sender_id = u.id)
+  )
+) AND (
+  EXISTS (
+    SELECT 1 FROM friends f
+    WHERE f.accepted AND (
+      (f.sender_id = @user_id::varchar AND f.receiver_id = u.id)
+      OR (f.receiver_id = @user_id::varchar AND f.sender_id = u.id)
+    )
+  )
+  OR (u.dm_privacy = 'server_members' AND EXISTS (
+    SELECT 1 FROM server_members a
+    INNER JOIN server_members b ON b.server_id = a.server_id AND b.user_id = u.id AND b.ban = false
+    WHERE a.user_id = @user_id::varchar AND a.ban = false AND a.server_id != 'global'
+  ))
+);
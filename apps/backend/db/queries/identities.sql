@@ -0,0 +1,22 @@
+-- name: CreateIdentity :one
+INSERT INTO user_identities (
+  id, user_id, provider, subject, email
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+SELECT * FROM users WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2);
+
+-- name: GetIdentity :one
+SELECT * FROM user_identities WHERE provider = $1 AND subject = $2;
+
+-- name: GetUserIdentities :many
+SELECT id, provider, email, created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at;
+
+-- name: CountUserIdentities :one
+SELECT count(id) FROM user_identities WHERE user_id = $1;
+
+-- name: DeleteIdentity :execrows
+DELETE FROM user_identities WHERE user_id = $1 AND provider = $2;
@@ -0,0 +1,27 @@
+-- name: CreateIncomingWebhook :one
+INSERT INTO incoming_webhooks (
+  id, server_id, channel_id, user_id, created_by, name, token_hash
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, server_id, channel_id, user_id, name, created_at;
+
+-- name: GetIncomingWebhook :one
+SELECT * FROM incoming_webhooks WHERE id = $1;
+
+-- name: GetServerIncomingWebhooks :many
+SELECT id, server_id, channel_id, user_id, created_by, name, created_at, updated_at
+FROM incoming_webhooks
+WHERE server_id = $1
+ORDER BY created_at;
+
+-- name: CountServerIncomingWebhooks :one
+SELECT count(id) FROM incoming_webhooks WHERE server_id = $1;
+
+-- name: RotateIncomingWebhookToken :execrows
+UPDATE incoming_webhooks
+  SET token_hash = $3, updated_at = now()
+WHERE id = $1 AND server_id = $2;
+
+-- name: DeleteIncomingWebhook :execrows
+DELETE FROM incoming_webhooks WHERE id = $1 AND server_id = $2;
@@ -1,22 +1,43 @@
--- name: GetOrCreateInvite :one
-WITH existing_invite AS (
-  SELECT invite_id 
-  FROM invites 
-  WHERE invites.creator_id = $2 
-    AND invites.server_id = $3 
-    AND invites.expire_at > NOW()
-  LIMIT 1
-),
-new_invite AS (
-  INSERT INTO invites (id, creator_id, server_id, invite_id, expire_at)
-  SELECT $1, $2, $3, $4, $5
-  WHERE NOT EXISTS (SELECT 1 FROM existing_invite)
-  RETURNING invite_id
-)
-SELECT COALESCE(
-  (SELECT invite_id FROM existing_invite),
-  (SELECT invite_id FROM new_invite)
-) as invite_id;
+-- name: CreateInvite :one
+INSERT INTO invites (id, creator_id, server_id, invite_id, expire_at, max_uses, temporary, vanity)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
 
 -- name: CheckInvite :one
-SELECT server_id FROM invites WHERE invite_id = $1 AND expire_at >= NOW();
+SELECT server_id, temporary FROM invites
+WHERE lower(invite_id) = lower(@invite_id)
+  AND (expire_at IS NULL OR expire_at > NOW())
+  AND (max_uses = 0 OR uses < max_uses);
+
+-- name: UseInvite :one
+UPDATE invites SET uses = uses + 1
+WHERE lower(invite_id) = lower(@invite_id)
+  AND (expire_at IS NULL OR expire_at > NOW())
+  AND (max_uses = 0 OR uses < max_uses)
+RETURNING server_id, temporary;
+
+-- name: GetInvite :one
+SELECT * FROM invites WHERE lower(invite_id) = lower(@invite_id);
+
+-- name: GetServerInvites :many
+SELECT i.id, i.invite_id, i.server_id, i.max_uses, i.uses, i.temporary, i.vanity, i.created_at, i.expire_at,
+  i.creator_id, u.username AS creator_username, u.display_name AS creator_display_name, u.avatar AS creator_avatar
+FROM invites i
+LEFT JOIN users u ON u.id = i.creator_id
+WHERE i.server_id = $1 AND (i.expire_at IS NULL OR i.expire_at > NOW())
+ORDER BY i.vanity DESC, i.created_at DESC;
+
+-- name: GetInvitePreview :one
+SELECT i.invite_id, i.expire_at, i.vanity, s.id AS server_id, s.name, s.avatar, s.banner, s.description, s.main_color,
+  (SELECT count(*) FROM server_members sm WHERE sm.server_id = s.id AND sm.ban = false) AS member_count
+FROM invites i
+JOIN servers s ON s.id = i.server_id
+WHERE lower(i.invite_id) = lower(@invite_id)
+  AND (i.expire_at IS NULL OR i.expire_at > NOW())
+  AND (i.max_uses = 0 OR i.uses < i.max_uses);
+
+-- name: DeleteInvite :exec
+DELETE FROM invites WHERE id = $1;
+
+-- name: DeleteVanityInvite :exec
+DELETE FROM invites WHERE server_id = $1 AND vanity;
@@ -0,0 +1,76 @@
+-- name: GetKanbanColumns :many
+SELECT * FROM kanban_columns WHERE channel_id = $1 ORDER BY position;
+
+-- name: GetKanbanColumn :one
+SELECT * FROM kanban_columns WHERE id = $1 AND channel_id = $2;
+
+-- name: CountKanbanColumns :one
+SELECT COUNT(*) FROM kanban_columns WHERE channel_id = $1;
+
+-- name: CreateKanbanColumn :one
+INSERT INTO kanban_columns (
+  id, channel_id, name, position
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: UpdateKanbanColumn :one
+UPDATE kanban_columns SET name = $1, updated_at = now() WHERE id = $2 AND channel_id = $3
+RETURNING *;
+
+-- name: SetKanbanColumnPosition :one
+UPDATE kanban_columns SET position = @position, updated_at = now() WHERE id = @id
+RETURNING *;
+
+-- name: ShiftKanbanColumns :exec
+UPDATE kanban_columns SET position = position + @delta::int
+WHERE channel_id = @channel_id AND position BETWEEN @from_position::int AND @to_position::int;
+
+-- name: DeleteKanbanColumn :one
+DELETE FROM kanban_columns WHERE id = $1 AND channel_id = $2
+RETURNING *;
+
+-- name: GetKanbanCards :many
+SELECT * FROM kanban_cards WHERE channel_id = $1 ORDER BY column_id, position;
+
+-- name: GetKanbanCard :one
+SELECT * FROM kanban_cards WHERE id = $1 AND channel_id = $2;
+
+-- name: CountKanbanCards :one
+SELECT COUNT(*) FROM kanban_cards WHERE column_id = @column_id AND id != @excluded_id;
+
+-- name: CreateKanbanCard :one
+INSERT INTO kanban_cards (
+  id, channel_id, column_id, author_id, title, description, assignees, labels, due_at, position
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING *;
+
+-- name: UpdateKanbanCard :one
+-- Unset fields are left as they are, the due date is only removed when asked to.
+UPDATE kanban_cards SET
+  title = COALESCE(sqlc.narg(title), title),
+  description = COALESCE(sqlc.narg(description), description),
+  assignees = COALESCE(sqlc.narg(assignees)::varchar[], assignees),
+  labels = COALESCE(sqlc.narg(labels)::varchar[], labels),
+  due_at = CASE WHEN @clear_due_at::bool THEN NULL ELSE COALESCE(sqlc.narg(due_at), due_at) END,
+  updated_at = now()
+WHERE id = @id AND channel_id = @channel_id
+RETURNING *;
+
+-- name: SetKanbanCardPosition :one
+UPDATE kanban_cards SET column_id = @column_id, position = @position, updated_at = now() WHERE id = @id
+RETURNING *;
+
+-- name: ShiftKanbanCards :exec
+UPDATE kanban_cards SET position = position + @delta::int
+WHERE column_id = @column_id AND id != @excluded_id AND position >= @from_position::int;
+
+-- name: DeleteKanbanCard :one
+DELETE FROM kanban_cards WHERE id = $1 AND channel_id = $2
+RETURNING *;
+
+-- name: GetServerMemberIDs :many
+SELECT user_id FROM server_members WHERE server_id = @server_id AND user_id = ANY(@user_ids::varchar[]) AND ban = false;
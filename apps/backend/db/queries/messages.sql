@@ -7,8 +7,9 @@ WITH base AS (
     (
       SELECT json_build_object(
         'id', u.id,
-        'avatar', u.avatar,
-        'display_name', u.display_name,
+        'avatar', COALESCE(m.author_override->>'avatar', u.avatar),
+        'display_name', COALESCE(m.author_override->>'username', u.display_name),
+        'bot', u.bot,
         'roles', sm.roles,
         'status', CASE 
             WHEN u.id = ANY($5::text[]) THEN 'online'
@@ -21,9 +22,16 @@ WITH base AS (
       AND sm.server_id = $2
       AND $2 != 'global'
       WHERE u.id = m.author_id
-    ) AS author
+    ) AS author,
+    -- The client collapses the messages of the users the reader blocked.
+    EXISTS (
+      SELECT 1 FROM friends f
+      WHERE f.blocked AND f.sender_id = $8::text AND f.receiver_id = m.author_id
+    ) AS author_blocked
   FROM messages m
   WHERE m.channel_id = $1
+    AND COALESCE(m.card_id, '') = $6::text
+    AND COALESCE(m.post_id, '') = $7::text
     AND (
       ($3::text = '') OR
       m.created_at < (SELECT created_at FROM messages WHERE id = $3)
@@ -41,6 +49,16 @@ SELECT *
 FROM base
 ORDER BY created_at DESC;
 
+-- name: GetGalleryPosts :many
+-- Posts are paginated as a whole, the media of an album are never split between two pages.
+SELECT m.id, m.author_id, m.content, m.attachments, m.created_at,
+  (SELECT COUNT(*) FROM messages c WHERE c.post_id = m.id) AS comments
+FROM messages m
+WHERE m.channel_id = @channel_id AND m.post_id IS NULL
+  AND (@before::text = '' OR m.created_at < (SELECT created_at FROM messages WHERE id = @before::text))
+ORDER BY m.created_at DESC
+LIMIT @max_posts;
+
 -- name: CheckChannelMembership :execresult
 SELECT c.id FROM channels c, server_members sm WHERE c.id = $1 and c.server_id = sm.server_id and sm.user_id = $2;
 
@@ -55,12 +73,25 @@ SELECT channel_id, last_read_message_id, unread_mention_ids FROM user_channel_re
 
 -- name: CreateMessage :one
 INSERT INTO messages (
-  id, author_id, server_id, channel_id, content, everyone, mentions_users, mentions_roles, mentions_channels, attachments
+  id, author_id, server_id, channel_id, content, everyone, mentions_users, mentions_roles, mentions_channels, attachments, author_override, card_id, post_id, reply_to_id
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
 )
 RETURNING *;
 
+-- name: UpdateMessageAttachments :one
+UPDATE messages SET attachments = $2 WHERE id = $1
+RETURNING *;
+
+-- name: GetProcessingMessages :many
+SELECT * FROM messages WHERE attachments @> '[{"processing": true}]'
+ORDER BY created_at;
+
+-- name: UpdateMessageEmbeds :one
+-- The content is compared so embeds of an edited message aren't overwritten by older ones.
+UPDATE messages SET embeds = @embeds WHERE id = @id AND content = @content
+RETURNING *;
+
 -- name: SaveUnreadMessagesState :exec
 WITH sync_data AS (
   SELECT 
@@ -0,0 +1,95 @@
+-- name: GetNotifications :many
+-- A page of the feed of @user_id, newest first. @before is the last notification of the previous page.
+-- The content of the message is left out once the user can't read its channel anymore, with the rules
+-- of GetReadableChannelIDs, or of the participants for direct messages.
+SELECT n.*, u.display_name AS actor_display_name, u.avatar AS actor_avatar, m.content AS message_content
+FROM notifications n
+LEFT JOIN users u ON u.id = n.actor_id
+LEFT JOIN messages m ON m.id = n.message_id AND EXISTS (
+  SELECT 1 FROM channels c
+  LEFT JOIN server_members sm ON sm.server_id = c.server_id AND sm.user_id = n.user_id AND sm.ban = false
+  WHERE c.id = m.channel_id
+    AND (
+      (c.server_id = 'global' AND n.user_id = ANY(c.users))
+      OR (sm.user_id IS NOT NULL AND (
+        (COALESCE(cardinality(c.users), 0) = 0 AND COALESCE(cardinality(c.roles), 0) = 0)
+        OR sm.user_id = ANY(c.users)
+        OR sm.roles && c.roles
+      ))
+    )
+)
+WHERE n.user_id = @user_id
+  AND (@before::varchar = '' OR (n.created_at, n.id) < (
+    SELECT b.created_at, b.id FROM notifications b WHERE b.id = @before::varchar AND b.user_id = @user_id
+  ))
+  AND (NOT @unread_only::bool OR n.read_at IS NULL)
+  AND (@server_id::varchar = '' OR n.server_id = @server_id::varchar)
+ORDER BY n.created_at DESC, n.id DESC
+LIMIT @max_notifications;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetMessageNotificationTargets :many
+-- The readers of the channel of a message to notify, each once: the author of the message it replies
+-- to, the users it mentions, the members of the roles it mentions, then every other reader when it
+-- mentions everyone. The author and the users who blocked them are left out.
+WITH readers AS (
+  SELECT sm.user_id, sm.roles FROM channels c
+  INNER JOIN server_members sm ON sm.server_id = c.server_id AND sm.ban = false
+  WHERE c.id = @channel_id AND c.server_id != 'global'
+    AND (
+      (COALESCE(cardinality(c.users), 0) = 0 AND COALESCE(cardinality(c.roles), 0) = 0)
+      OR sm.user_id = ANY(c.users)
+      OR sm.roles && c.roles
+    )
+  UNION
+  SELECT unnest(c.users)::varchar, NULL::varchar[] FROM channels c WHERE c.id = @channel_id AND c.server_id = 'global'
+)
+SELECT r.user_id,
+  (CASE
+    WHEN r.user_id = @reply_author_id::varchar THEN 'reply'
+    WHEN r.user_id = ANY(@mentions_users::varchar[]) THEN 'mention'
+    WHEN r.roles && @mentions_roles::varchar[] THEN 'role_mention'
+    ELSE 'everyone'
+  END)::varchar AS type
+FROM readers r
+WHERE r.user_id != @author_id::varchar
+  AND (
+    r.user_id = @reply_author_id::varchar
+    OR r.user_id = ANY(@mentions_users::varchar[])
+    OR r.roles && @mentions_roles::varchar[]
+    OR @everyone::boolean
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM friends f WHERE f.blocked AND f.sender_id = r.user_id AND f.receiver_id = @author_id::varchar
+  );
+
+-- name: CreateNotifications :many
+INSERT INTO notifications (id, user_id, type, actor_id, server_id, channel_id, message_id, friendship_id, invite_id)
+SELECT
+  unnest(@ids::varchar[]),
+  unnest(@user_ids::varchar[]),
+  unnest(@types::varchar[]),
+  sqlc.narg(actor_id)::varchar,
+  sqlc.narg(server_id)::varchar,
+  sqlc.narg(channel_id)::varchar,
+  sqlc.narg(message_id)::varchar,
+  sqlc.narg(friendship_id)::varchar,
+  sqlc.narg(invite_id)::varchar
+RETURNING *;
+
+-- name: ReadNotifications :many
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = @user_id AND id = ANY(@ids::varchar[]) AND read_at IS NULL
+RETURNING id;
+
+-- name: ReadAllNotifications :many
+-- Marks the whole feed as read, or only the notifications of @server_id when it is set.
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = @user_id AND read_at IS NULL
+  AND (@server_id::varchar = '' OR server_id = @server_id::varchar)
+RETURNING id;
+
+-- name: DeleteFriendshipNotifications :exec
+DELETE FROM notifications WHERE friendship_id = $1;
@@ -0,0 +1,15 @@
+-- name: CreateQuarantinedFile :one
+INSERT INTO quarantined_files (
+  id, key, user_id, server_id, channel_id, file_name, content_type, size, signature
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetServerModerators :many
+SELECT owner_id AS user_id FROM servers WHERE id = @server_id
+UNION
+SELECT sm.user_id
+FROM server_members sm
+JOIN roles r ON r.id = ANY(sm.roles)
+WHERE sm.server_id = @server_id AND r.abilities && ARRAY['MANAGE_MESSAGES', 'ADMINISTRATOR']::varchar[];
@@ -0,0 +1,18 @@
+-- name: GetUserStorageUsage :one
+-- Uploads waiting for their message already count, they are reserved when they are created.
+SELECT (
+  (SELECT COALESCE(SUM(size), 0) FROM attachments WHERE quota_user_id = @user_id::varchar)
+  + (SELECT COALESCE(SUM(size), 0) FROM uploads WHERE user_id = @user_id::varchar AND status <> 'attached')
+)::bigint AS used;
+
+-- name: GetServerStorageUsage :one
+SELECT s.storage_tier, COALESCE(SUM(a.size), 0)::bigint AS used
+FROM servers s
+LEFT JOIN attachments a ON a.quota_server_id = s.id
+WHERE s.id = $1
+GROUP BY s.id;
+
+-- name: GetUploadsSize :one
+SELECT COALESCE(SUM(size), 0)::bigint AS size
+FROM uploads
+WHERE id = ANY(@ids::varchar[]) AND user_id = @user_id;
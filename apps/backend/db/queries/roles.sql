@@ -61,7 +61,7 @@ WHERE r.name = 'Default Permissions' AND server_id = $1;
 
 -- name: GiveRole :exec
 UPDATE server_members 
-SET roles = array_append(roles, $1) -- role_name
+SET roles = array_append(roles, $1), temporary = false -- role_name
 WHERE server_id = $2 AND user_id = $3;
 
 -- name: DeleteRole :exec
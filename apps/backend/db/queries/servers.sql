@@ -127,11 +127,15 @@ SELECT
             ),
             'server_id', i.server_id,
             'invite_id', i.invite_id,
-            'expire_at', i.expire_at
+            'expire_at', i.expire_at,
+            'max_uses', i.max_uses,
+            'uses', i.uses,
+            'temporary', i.temporary,
+            'vanity', i.vanity
         ))
         FROM invites i
         LEFT JOIN users u ON i.creator_id = u.id
-        WHERE i.server_id = s.id
+        WHERE i.server_id = s.id AND (i.expire_at IS NULL OR i.expire_at > NOW())
     ) as invites,
     (
         SELECT smc.roles
@@ -173,8 +177,8 @@ RETURNING *;
 
 -- name: JoinServer :one
 WITH ins AS (
-  INSERT INTO server_members (id, user_id, server_id, position, roles)
-  VALUES ($1, $2, $3, $4, $5)
+  INSERT INTO server_members (id, user_id, server_id, position, roles, temporary)
+  VALUES ($1, $2, $3, $4, $5, $6)
   RETURNING *
 )
 SELECT s.*, ins.roles, ins.position, (SELECT COUNT(*) FROM server_members smc WHERE smc.server_id = ins.server_id AND ban=false) AS member_count
@@ -184,6 +188,10 @@ JOIN servers s ON s.id = ins.server_id;
 -- name: LeaveServer :exec
 DELETE FROM server_members WHERE user_id = $1 AND server_id = $2;
 
+-- name: DeleteTemporaryMemberships :many
+DELETE FROM server_members WHERE user_id = $1 AND temporary = true
+RETURNING server_id;
+
 -- name: UpdateServerAvatarNBanner :exec
 UPDATE servers
   set 
@@ -193,7 +201,39 @@ UPDATE servers
 WHERE id = $1;
 
 -- name: UpdateServerProfile :exec
-UPDATE servers SET name = $1, description = $2, public = $3, updated_at = now() WHERE id = $4;
+-- The category and tags are only changed when they are sent, an empty list of tags clears them.
+UPDATE servers
+SET name = @name, description = @description, public = @public,
+  category = COALESCE(sqlc.narg(category)::varchar, category),
+  tags = COALESCE(sqlc.narg(tags)::text[], tags),
+  updated_at = now()
+WHERE id = @id;
+
+-- name: DiscoverServers :many
+SELECT s.id, s.name, s.avatar, s.banner, s.description, s.main_color, s.category, s.tags, s.created_at,
+  (SELECT count(*) FROM server_members sm WHERE sm.server_id = s.id AND sm.ban = false) AS member_count,
+  EXISTS(SELECT 1 FROM server_members sm WHERE sm.server_id = s.id AND sm.user_id = @user_id AND sm.ban = false) AS joined
+FROM servers s
+WHERE s.public = true
+  AND (
+    sqlc.narg(query)::text IS NULL
+    OR to_tsvector('simple', s.name || ' ' || COALESCE(jsonb_path_query_array(s.description, 'strict $.**.text')::text, '')) @@ plainto_tsquery('simple', sqlc.narg(query)::text)
+    OR s.name ILIKE '%' || sqlc.narg(query)::text || '%'
+  )
+  AND (sqlc.narg(category)::varchar IS NULL OR s.category = sqlc.narg(category)::varchar)
+  AND (sqlc.narg(tag)::text IS NULL OR s.tags @> ARRAY[sqlc.narg(tag)::text])
+  -- Servers the user is banned from are left out.
+  AND NOT EXISTS(SELECT 1 FROM server_members sm WHERE sm.server_id = s.id AND sm.user_id = @user_id AND sm.ban = true)
+ORDER BY
+  CASE WHEN @sort::varchar = 'relevance' THEN ts_rank(
+    to_tsvector('simple', s.name || ' ' || COALESCE(jsonb_path_query_array(s.description, 'strict $.**.text')::text, '')),
+    plainto_tsquery('simple', COALESCE(sqlc.narg(query)::text, ''))
+  ) END DESC,
+  CASE WHEN @sort::varchar = 'newest' THEN s.created_at END DESC,
+  CASE WHEN @sort::varchar = 'name' THEN lower(s.name) END ASC,
+  member_count DESC,
+  s.id
+LIMIT @page_size::int OFFSET @page_offset::int;
 
 -- name: DeleteServer :exec
 DELETE FROM servers WHERE id = $1 AND owner_id = $2;
@@ -0,0 +1,39 @@
+-- name: CreateUpload :one
+INSERT INTO uploads (
+  id, user_id, key, file_name, content_type, size, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetUpload :one
+SELECT * FROM uploads WHERE id = $1 AND user_id = $2;
+
+-- name: CountPendingUploads :one
+SELECT count(id) FROM uploads WHERE user_id = $1 AND status = 'pending';
+
+-- name: ConfirmUpload :execrows
+-- The key moves from where the presigned URL writes to where the checked file is kept.
+UPDATE uploads SET status = 'confirmed', key = @key, metadata = @metadata
+WHERE id = @id AND user_id = @user_id AND status = 'pending';
+
+-- name: ClaimUploads :many
+UPDATE uploads SET status = 'attached'
+WHERE id = ANY(@ids::varchar[]) AND user_id = @user_id AND status = 'confirmed' AND expires_at > now()
+RETURNING *;
+
+-- name: SetUploadsMessage :exec
+UPDATE uploads SET message_id = @message_id
+WHERE id = ANY(@ids::varchar[]);
+
+-- name: GetOrphanedUploads :many
+SELECT id, key FROM uploads
+WHERE status <> 'attached' AND expires_at < now()
+LIMIT $1;
+
+-- name: PruneAttachedUploads :execrows
+DELETE FROM uploads
+WHERE status = 'attached' AND message_id IS NULL AND created_at < now() - interval '1 day';
+
+-- name: DeleteUpload :exec
+DELETE FROM uploads WHERE id = $1;
@@ -57,4 +57,36 @@ SELECT links FROM users WHERE id = $1;
 SELECT facts FROM users WHERE id = $1;
 
 -- name: GetUserPassword :one
-SELECT password FROM users WHERE id = $1;
\ No newline at end of file
+SELECT password FROM users WHERE id = $1;
+
+-- name: UsernameExists :one
+SELECT EXISTS(SELECT 1 FROM users WHERE username = $1);
+
+-- name: CreateBot :one
+INSERT INTO users (
+  id, email, username, display_name, avatar, password, bot, bot_owner_id
+) VALUES (
+  $1, $2, $3, $4, $5, '', TRUE, $6
+)
+RETURNING *;
+
+-- name: GetBot :one
+SELECT * FROM users WHERE id = $1 AND bot_owner_id = $2 AND bot;
+
+-- name: GetBots :many
+SELECT id, username, display_name, avatar, created_at FROM users WHERE bot_owner_id = $1 AND bot ORDER BY created_at;
+
+-- name: CountBots :one
+SELECT count(id) FROM users WHERE bot_owner_id = $1 AND bot;
+
+-- name: DeleteBot :execrows
+DELETE FROM users WHERE id = $1 AND bot_owner_id = $2 AND bot;
+
+-- name: UpdatePrivacy :one
+-- The settings left empty are kept.
+UPDATE users SET
+  dm_privacy = COALESCE(NULLIF(@dm_privacy::varchar, ''), dm_privacy),
+  friend_request_privacy = COALESCE(NULLIF(@friend_request_privacy::varchar, ''), friend_request_privacy),
+  updated_at = now()
+WHERE id = @id
+RETURNING *;
@@ -0,0 +1,71 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (
+  id, server_id, created_by, url, secret, events
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetServerWebhooks :many
+SELECT id, server_id, created_by, url, events, active, created_at, updated_at
+FROM webhooks
+WHERE server_id = $1
+ORDER BY created_at;
+
+-- name: CountServerWebhooks :one
+SELECT count(id) FROM webhooks WHERE server_id = $1;
+
+-- name: GetWebhooksForEvent :many
+SELECT id FROM webhooks WHERE server_id = @server_id AND active AND @event::text = ANY(events);
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+  SET url = $3, events = $4, active = $5, updated_at = now()
+WHERE id = $1 AND server_id = $2
+RETURNING id, server_id, created_by, url, events, active, created_at, updated_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND server_id = $2;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+  id, webhook_id, event, payload
+) VALUES (
+  $1, $2, $3, $4
+);
+
+-- name: ClaimWebhookDeliveries :many
+-- Leases due deliveries for a minute so concurrent workers never send the same one twice.
+WITH due AS (
+  SELECT id FROM webhook_deliveries
+  WHERE status = 'pending' AND next_attempt_at <= now()
+  ORDER BY next_attempt_at
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries d
+  SET next_attempt_at = now() + interval '1 minute'
+FROM due, webhooks w
+WHERE d.id = due.id AND w.id = d.webhook_id
+RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+  SET status = @status::text,
+    attempts = attempts + 1,
+    last_status_code = @last_status_code,
+    last_error = @last_error,
+    next_attempt_at = @next_attempt_at,
+    delivered_at = CASE WHEN @status::text = 'success' THEN now() ELSE delivered_at END
+WHERE id = @id;
+
+-- name: GetWebhookDeliveries :many
+SELECT d.id, d.event, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.delivered_at, d.created_at
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.webhook_id = $1 AND w.server_id = $2
+ORDER BY d.created_at DESC
+LIMIT 50;
+
+-- name: PruneWebhookDeliveries :exec
+DELETE FROM webhook_deliveries WHERE status != 'pending' AND created_at < now() - interval '30 days';
@@ -3,6 +3,7 @@ package actors
 import (
 	db "backend/db/gen_queries"
 	"backend/internal/database"
+	"backend/internal/sfu"
 	"backend/internal/types"
 	message "backend/proto"
 	"context"
@@ -82,7 +83,11 @@ type Service interface {
 
 	AcceptFriendRequest(friendshipID, senderID, receiverID, channelID string)
 
-	RemoveFriend(friendshipID, senderID, receiverID, channelID string)
+	RemoveFriend(changeType string, friendship db.Friend, channelID string)
+
+	BlockUser(userID, targetID string, ended *db.Friend)
+
+	UnblockUser(userID, targetID, channelID string)
 
 	BanUser(serverID string, body *types.BanUserParams)
 
@@ -98,11 +103,35 @@ type Service interface {
 
 	ProfileServerChange(serverID string, body *types.UpdateServerProfileParams)
 
+	ServerEmojiChange(serverID, changeType string, emoji *message.ServerEmoji)
+
 	EditChannel(channelID string, body *types.EditChannelParams)
 
 	EditCategory(categoryID string, body *types.EditCategoryParams)
 
 	MemberChange(serverIDs []string, userID string, avatarURL *string, displayName *string)
+
+	// SendInteraction delivers an interaction over the bot gateway socket.
+	// It returns false when the bot isn't connected.
+	SendInteraction(botID string, interaction *message.Interaction) bool
+
+	SendInteractionResponse(userID string, response *message.InteractionResponse)
+	NotifyFileBlocked(userIDs []string, blocked *message.FileBlocked)
+
+	// SendVoiceCommand hands a checked voice request to the channel owning the voice state.
+	SendVoiceCommand(command *message.VoiceCommand)
+
+	GetVoiceStates(serverID string) []*message.VoiceChannelStates
+
+	SendVoiceTrackEvent(event *message.VoiceTrackEvent)
+
+	KanbanChange(change *message.KanbanChange)
+	ChannelKeyChange(change *message.ChannelKeyChange)
+	GroupDMChange(change *message.GroupDMChange, userIDs []string)
+
+	Notify(userID string, notification *message.Notification)
+
+	NotificationsRead(userID string, ids []string)
 }
 
 type service struct {
@@ -115,7 +144,8 @@ func GetIDFromPID(PID *actor.PID) string {
 	return split[len(split)-1]
 }
 
-func New(dbService database.Service) Service {
+// New starts the actors, sfuService is nil when voice channels don't go through an SFU.
+func New(dbService database.Service, sfuService sfu.Service) Service {
 	config := cluster.NewConfig().WithID(os.Getenv("NODE_ID")).WithRegion(os.Getenv("REGION")).WithListenAddr(os.Getenv("NODE_IP"))
 	c, err := cluster.New(config)
 	if err != nil {
@@ -127,7 +157,7 @@ func New(dbService database.Service) Service {
 		db:      dbService,
 	}
 
-	c.RegisterKind("server", newServer(actorService), cluster.NewKindConfig())
+	c.RegisterKind("server", newServer(actorService, sfuService), cluster.NewKindConfig())
 	c.RegisterKind("user", newUser(actorService, dbService, nil), cluster.NewKindConfig())
 
 	eventPID := c.Engine().SpawnFunc(func(ctx *actor.Context) {
@@ -290,6 +320,49 @@ func (se *service) StartDMChannel(channelID string, userIDs []string) {
 	}
 }
 
+// GroupDMChange keeps the channel actor of a group direct message in line with its participants,
+// then tells userIDs about the change.
+func (se *service) GroupDMChange(change *message.GroupDMChange, userIDs []string) {
+	channelPIDs := se.GetAllChannelInstances("global", change.Channel.Id)
+	for _, channelPID := range channelPIDs {
+		switch change.Type {
+		case types.GroupDMDelete:
+			se.cluster.Engine().Poison(channelPID)
+		case types.GroupDMMemberAdd, types.GroupDMMemberRemove:
+			se.cluster.Engine().Send(channelPID, &message.EditChannel{Channel: change.Channel})
+		}
+	}
+
+	wsMessage := &message.WSMessage{
+		Content: &message.WSMessage_GroupDmChange{
+			GroupDmChange: change,
+		},
+	}
+
+	for _, userID := range userIDs {
+		se.BroadcastMessageToUser(se.GetUser(userID), wsMessage)
+	}
+}
+
+// Notify pushes a new notification to the sessions of userID.
+func (se *service) Notify(userID string, notification *message.Notification) {
+	se.BroadcastMessageToUser(se.GetUser(userID), &message.WSMessage{
+		Content: &message.WSMessage_Notification{
+			Notification: notification,
+		},
+	})
+}
+
+func (se *service) NotificationsRead(userID string, ids []string) {
+	se.BroadcastMessageToUser(se.GetUser(userID), &message.WSMessage{
+		Content: &message.WSMessage_NotificationsRead{
+			NotificationsRead: &message.NotificationsRead{
+				Ids: ids,
+			},
+		},
+	})
+}
+
 func (se *service) KillServer(serverID string) {
 	allUsers := se.GetActiveUsers(serverID)
 	serversPID := se.GetAllServerInstances(serverID)
@@ -371,6 +444,20 @@ func (se *service) SendChatMessage(chatMessage *message.NewChatMessage) {
 	}
 }
 
+func (se *service) KanbanChange(change *message.KanbanChange) {
+	channels := se.GetAllChannelInstances(change.ServerId, change.ChannelId)
+	for _, channelPID := range channels {
+		se.cluster.Engine().Send(channelPID, change)
+	}
+}
+
+func (se *service) ChannelKeyChange(change *message.ChannelKeyChange) {
+	channels := se.GetAllChannelInstances(change.ServerId, change.ChannelId)
+	for _, channelPID := range channels {
+		se.cluster.Engine().Send(channelPID, change)
+	}
+}
+
 func (se *service) EditMessage(chatMessage *message.EditChatMessage) {
 	channels := se.GetAllChannelInstances(chatMessage.Message.ServerId, chatMessage.Message.ChannelId)
 	for _, channelPID := range channels {
@@ -550,27 +637,79 @@ func (se *service) AcceptFriendRequest(friendshipID, senderID, receiverID, chann
 	})
 }
 
-func (se *service) RemoveFriend(friendshipID, senderID, receiverID, channelID string) {
-	senderPID := se.GetUser(senderID)
-	receiverPID := se.GetUser(receiverID)
+// RemoveFriend tells both users their friendship or pending request ended, and stops the direct
+// message of the friendship when it had one.
+func (se *service) RemoveFriend(changeType string, friendship db.Friend, channelID string) {
+	senderPID := se.GetUser(friendship.SenderID)
+	receiverPID := se.GetUser(friendship.ReceiverID)
 
 	message := &message.WSMessage{
 		Content: &message.WSMessage_RemoveFriend{
 			RemoveFriend: &message.RemoveFriend{
-				FriendshipId: friendshipID,
+				FriendshipId: friendship.ID,
+				Sender:       &message.User{Id: friendship.SenderID},
+				Receiver:     &message.User{Id: friendship.ReceiverID},
+				Type:         changeType,
 			},
 		},
 	}
 
-	channelPIDs := se.GetAllChannelInstances("global", channelID)
-	for _, channelPID := range channelPIDs {
-		se.cluster.Engine().Poison(channelPID)
+	if channelID != "" {
+		channelPIDs := se.GetAllChannelInstances("global", channelID)
+		for _, channelPID := range channelPIDs {
+			se.cluster.Engine().Poison(channelPID)
+		}
 	}
 
 	se.cluster.Engine().Send(senderPID, message)
 	se.cluster.Engine().Send(receiverPID, message)
 }
 
+// BlockUser has the actor of userID hide targetID from then on, and tells both of them about the
+// friendship or request the block ended.
+func (se *service) BlockUser(userID, targetID string, ended *db.Friend) {
+	se.cluster.Engine().Send(se.GetUser(userID), &message.UserBlock{
+		UserId:  targetID,
+		Blocked: true,
+	})
+
+	if ended == nil {
+		return
+	}
+
+	// The blocked user is only told the friendship ended, its direct message stays with the block.
+	removeMessage := &message.WSMessage{
+		Content: &message.WSMessage_RemoveFriend{
+			RemoveFriend: &message.RemoveFriend{
+				FriendshipId: ended.ID,
+				Sender:       &message.User{Id: ended.SenderID},
+				Receiver:     &message.User{Id: ended.ReceiverID},
+				Type:         types.FriendRemoved,
+			},
+		},
+	}
+	se.cluster.Engine().Send(se.GetUser(ended.SenderID), removeMessage)
+	se.cluster.Engine().Send(se.GetUser(ended.ReceiverID), removeMessage)
+}
+
+// UnblockUser lets the actor of userID show targetID again, and stops the direct message deleted with
+// the block.
+func (se *service) UnblockUser(userID, targetID, channelID string) {
+	se.cluster.Engine().Send(se.GetUser(userID), &message.UserBlock{
+		UserId:  targetID,
+		Blocked: false,
+	})
+
+	if channelID == "" {
+		return
+	}
+
+	channelPIDs := se.GetAllChannelInstances("global", channelID)
+	for _, channelPID := range channelPIDs {
+		se.cluster.Engine().Poison(channelPID)
+	}
+}
+
 func (se *service) SendUserStatusMessage(userPID *actor.PID, status *message.ChangeStatus) {
 	servers := se.GetAllServerInstances(status.ServerId)
 	for _, serverPID := range servers {
@@ -646,6 +785,24 @@ func (se *service) AvatarServerChange(serverID string, bannerURL, avatarURL *str
 	}
 }
 
+func (se *service) ServerEmojiChange(serverID, changeType string, emoji *message.ServerEmoji) {
+	serverPIDs := se.GetAllServerInstances(serverID)
+
+	message := &message.WSMessage{
+		Content: &message.WSMessage_ServerEmojiChange{
+			ServerEmojiChange: &message.ServerEmojiChange{
+				Type:     changeType,
+				ServerId: serverID,
+				Emoji:    emoji,
+			},
+		},
+	}
+
+	for _, serverPID := range serverPIDs {
+		se.cluster.Engine().Send(serverPID, message)
+	}
+}
+
 func (se *service) ProfileServerChange(serverID string, body *types.UpdateServerProfileParams) {
 	serverPIDs := se.GetAllServerInstances(serverID)
 
@@ -749,3 +906,89 @@ func (se *service) MemberChange(serverIDs []string, userID string, avatarURL *st
 		}
 	}
 }
+
+func (se *service) SendInteraction(botID string, interaction *message.Interaction) bool {
+	botPID := se.GetUser(botID)
+	if botPID == nil {
+		return false
+	}
+
+	se.cluster.Engine().Send(botPID, &message.WSMessage{
+		Content: &message.WSMessage_Interaction{
+			Interaction: interaction,
+		},
+	})
+
+	return true
+}
+
+func (se *service) SendInteractionResponse(userID string, response *message.InteractionResponse) {
+	userPID := se.GetUser(userID)
+	if userPID == nil {
+		return
+	}
+
+	se.cluster.Engine().Send(userPID, &message.WSMessage{
+		Content: &message.WSMessage_InteractionResponse{
+			InteractionResponse: response,
+		},
+	})
+}
+
+func (se *service) NotifyFileBlocked(userIDs []string, blocked *message.FileBlocked) {
+	for _, userID := range userIDs {
+		userPID := se.GetUser(userID)
+		if userPID == nil {
+			continue
+		}
+
+		se.cluster.Engine().Send(userPID, &message.WSMessage{
+			Content: &message.WSMessage_FileBlocked{
+				FileBlocked: blocked,
+			},
+		})
+	}
+}
+
+// voiceOwner returns the instance of a server keeping its voice state, the same one on every node.
+func (se *service) voiceOwner(serverID string) *actor.PID {
+	instances := se.GetAllServerInstances(serverID)
+	if len(instances) == 0 {
+		return nil
+	}
+
+	return instances[0]
+}
+
+func (se *service) SendVoiceCommand(command *message.VoiceCommand) {
+	serverPID := se.voiceOwner(command.Request.ServerId)
+	if serverPID == nil {
+		return
+	}
+
+	se.cluster.Engine().Send(serverPID.Child("channel/"+command.Request.ChannelId), command)
+}
+
+func (se *service) GetVoiceStates(serverID string) []*message.VoiceChannelStates {
+	serverPID := se.voiceOwner(serverID)
+	if serverPID == nil {
+		return nil
+	}
+
+	response := se.cluster.Engine().Request(serverPID, &message.GetVoiceStates{}, 10*time.Second)
+	result, err := response.Result()
+	if err != nil {
+		return nil
+	}
+
+	return result.(*message.GetVoiceStates).Channels
+}
+
+func (se *service) SendVoiceTrackEvent(event *message.VoiceTrackEvent) {
+	serverPID := se.voiceOwner(event.ServerId)
+	if serverPID == nil {
+		return
+	}
+
+	se.cluster.Engine().Send(serverPID.Child("channel/"+event.ChannelId), event)
+}
@@ -1,7 +1,10 @@
 package actors
 
 import (
+	"backend/internal/sfu"
+	"backend/internal/voice"
 	messages "backend/proto"
+	"context"
 	"log/slog"
 	"slices"
 	"time"
@@ -13,20 +16,28 @@ type channel struct {
 	logger *slog.Logger
 	users  []string
 	hub    Service
+	sfu    sfu.Service
+	// room is the voice state of the channel, only kept by the first instance of the server.
+	room *voice.Room
+	// sfuRequests holds the requests to the SFU waiting to be made.
+	sfuRequests chan func(ctx context.Context) error
 }
 
-func newChannel(actorService Service, users []string) actor.Producer {
+func newChannel(actorService Service, sfuService sfu.Service, users []string) actor.Producer {
 	return func() actor.Receiver {
 		return &channel{
 			logger: slog.Default(),
 			users:  users,
 			hub:    actorService,
+			sfu:    sfuService,
 		}
 	}
 }
 
 func (c *channel) Receive(ctx *actor.Context) {
 	switch msg := ctx.Message().(type) {
+	case actor.Stopped:
+		c.closeVoice(ctx)
 	case actor.InternalError:
 		slog.Error("channel erroring",
 			"id", ctx.PID().GetID(),
@@ -40,8 +51,18 @@ func (c *channel) Receive(ctx *actor.Context) {
 		c.EditMessage(ctx, c.GetChannelUsers(ctx), msg)
 	case *messages.DeleteChatMessage:
 		c.DeleteMessage(ctx, c.GetChannelUsers(ctx), msg)
+	case *messages.KanbanChange:
+		c.KanbanChange(ctx, c.GetChannelUsers(ctx), msg)
+	case *messages.ChannelKeyChange:
+		c.ChannelKeyChange(ctx, c.GetChannelUsers(ctx), msg)
 	case *messages.EditChannel:
 		c.EditChannel(ctx, msg)
+	case *messages.VoiceCommand:
+		c.voiceCommand(ctx, msg)
+	case *messages.VoiceTransfer:
+		c.voiceTransfer(ctx, msg)
+	case *messages.VoiceTrackEvent:
+		c.voiceTrackEvent(ctx, msg)
 	}
 }
 
@@ -96,6 +117,32 @@ func (c *channel) DeleteMessage(ctx *actor.Context, userIDs []string, msg *messa
 	}
 }
 
+func (c *channel) KanbanChange(ctx *actor.Context, userIDs []string, msg *messages.KanbanChange) {
+	messageToBroadcast := &messages.WSMessage{
+		Content: &messages.WSMessage_KanbanChange{
+			KanbanChange: msg,
+		},
+	}
+
+	for _, userID := range userIDs {
+		userPID := c.hub.GetUser(userID)
+		c.hub.BroadcastMessageToUser(userPID, messageToBroadcast)
+	}
+}
+
+func (c *channel) ChannelKeyChange(ctx *actor.Context, userIDs []string, msg *messages.ChannelKeyChange) {
+	messageToBroadcast := &messages.WSMessage{
+		Content: &messages.WSMessage_ChannelKeyChange{
+			ChannelKeyChange: msg,
+		},
+	}
+
+	for _, userID := range userIDs {
+		userPID := c.hub.GetUser(userID)
+		c.hub.BroadcastMessageToUser(userPID, messageToBroadcast)
+	}
+}
+
 func (c *channel) EditChannel(ctx *actor.Context, msg *messages.EditChannel) {
 	c.users = msg.Channel.Users
 }
@@ -1,6 +1,7 @@
 package actors
 
 import (
+	"backend/internal/sfu"
 	messages "backend/proto"
 	"log/slog"
 	"maps"
@@ -21,14 +22,19 @@ type server struct {
 	logger *slog.Logger
 	users  map[string]Status
 	hub    Service
+	sfu    sfu.Service
+	// voice holds the voice rosters of the server by channel.
+	voice map[string]*messages.VoiceChannelStates
 }
 
-func newServer(actorService Service) actor.Producer {
+func newServer(actorService Service, sfuService sfu.Service) actor.Producer {
 	return func() actor.Receiver {
 		return &server{
 			logger: slog.Default(),
 			users:  make(map[string]Status),
 			hub:    actorService,
+			sfu:    sfuService,
+			voice:  make(map[string]*messages.VoiceChannelStates),
 		}
 	}
 }
@@ -42,9 +48,17 @@ func (s *server) Receive(ctx *actor.Context) {
 			"err", msg.Err,
 		)
 	case *messages.WSMessage:
+		if roster := msg.GetVoiceChannelStates(); roster != nil {
+			s.storeVoiceStates(roster)
+		}
 		s.BroadcastToServer(msg)
+	case *messages.VoiceChannelStates:
+		s.voiceChannelStates(ctx, msg)
+	case *messages.GetVoiceStates:
+		ctx.Respond(s.getVoiceStates())
 	case *messages.AccountDeletion:
 		s.AccountDeletion(ctx, msg)
+		s.disconnectVoice(msg.UserId)
 	case *messages.KillCategory:
 		s.killCategory(ctx, msg)
 	case *messages.StartChannel:
@@ -55,10 +69,13 @@ func (s *server) Receive(ctx *actor.Context) {
 		s.editChannel(ctx, msg)
 	case *messages.LeaveServer:
 		s.LeaveServer(msg)
+		s.disconnectVoice(msg.UserId)
 	case *messages.KickUser:
 		s.KickUser(msg)
+		s.disconnectVoice(msg.UserId)
 	case *messages.BanUser:
 		s.BanUser(msg)
+		s.disconnectVoice(msg.UserId)
 	case *messages.GetServerUsers:
 		ctx.Respond(&messages.GetServerUsers{
 			UserIds: slices.Collect(maps.Keys(s.users)),
@@ -70,12 +87,13 @@ func (s *server) Receive(ctx *actor.Context) {
 			s.users[msg.User.Id] = Online
 		case "offline":
 			delete(s.users, msg.User.Id)
+			s.disconnectVoice(msg.User.Id)
 		}
 	}
 }
 
 func (s *server) startChannel(ctx *actor.Context, msg *messages.StartChannel) {
-	ctx.SpawnChild(newChannel(s.hub, msg.Channel.Users), "channel", actor.WithID(msg.Channel.Id))
+	ctx.SpawnChild(newChannel(s.hub, s.sfu, msg.Channel.Users), "channel", actor.WithID(msg.Channel.Id))
 
 	if msg.Channel.ServerId == "global" {
 		return
@@ -95,6 +113,7 @@ func (s *server) startChannel(ctx *actor.Context, msg *messages.StartChannel) {
 func (s *server) killChannel(ctx *actor.Context, msg *messages.KillChannel) {
 	channelPID := ctx.PID().Child("channel/" + msg.Channel.Id)
 	ctx.Engine().Poison(channelPID)
+	delete(s.voice, msg.Channel.Id)
 
 	message := &messages.WSMessage{
 		Content: &messages.WSMessage_KillChannel{
@@ -128,6 +147,7 @@ func (s *server) killCategory(ctx *actor.Context, msg *messages.KillCategory) {
 	for _, channelID := range msg.ChannelsIds {
 		channelPID := ctx.PID().Child("channel/" + channelID)
 		ctx.Engine().Poison(channelPID)
+		delete(s.voice, channelID)
 	}
 
 	message := &messages.WSMessage{
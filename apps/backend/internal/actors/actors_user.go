@@ -3,7 +3,9 @@ package actors
 import (
 	db "backend/db/gen_queries"
 	"backend/internal/database"
+	"backend/internal/types"
 	messages "backend/proto"
+	"context"
 	"fmt"
 	"log/slog"
 	"slices"
@@ -17,6 +19,8 @@ type user struct {
 	logger  *slog.Logger
 	wsConn  *gws.Conn
 	friends []string
+	// blocked are the users this user blocked, whose presence and direct messages are not sent.
+	blocked []string
 	hub     Service
 	db      database.Service
 }
@@ -27,6 +31,7 @@ func newUser(actorService Service, db database.Service, wsConn *gws.Conn) actor.
 			logger:  slog.Default(),
 			wsConn:  wsConn,
 			friends: []string{},
+			blocked: []string{},
 			hub:     actorService,
 			db:      db,
 		}
@@ -52,13 +57,100 @@ func (u *user) Receive(ctx *actor.Context) {
 	case *messages.AccountDeletion:
 		u.AccountDeletion(ctx, msg)
 	case *messages.ChangeStatus:
+		if slices.Contains(u.blocked, msg.User.Id) {
+			return
+		}
 		u.FriendChangeStatus(ctx, msg)
+	case *messages.UserBlock:
+		u.UserBlock(ctx, msg)
 	case *messages.WSMessage:
+		if msg = u.filter(ctx, msg); msg == nil {
+			return
+		}
 		message, _ := proto.Marshal(msg)
 		u.wsConn.WriteMessage(gws.OpcodeBinary, message)
 	}
 }
 
+func (u *user) UserBlock(ctx *actor.Context, msg *messages.UserBlock) {
+	u.blocked = slices.DeleteFunc(u.blocked, func(userID string) bool {
+		return userID == msg.UserId
+	})
+	if msg.Blocked {
+		u.blocked = append(u.blocked, msg.UserId)
+		u.friends = slices.DeleteFunc(u.friends, func(friendID string) bool {
+			return friendID == msg.UserId
+		})
+	}
+
+	m := &messages.WSMessage{
+		Content: &messages.WSMessage_UserBlock{
+			UserBlock: msg,
+		},
+	}
+	message, _ := proto.Marshal(m)
+	u.wsConn.WriteMessage(gws.OpcodeBinary, message)
+}
+
+// filter hides the blocked users from the user: their presence and their direct messages are dropped,
+// their messages in servers are flagged and don't mention the user. It returns nil for the messages
+// not to send.
+func (u *user) filter(ctx *actor.Context, msg *messages.WSMessage) *messages.WSMessage {
+	if len(u.blocked) == 0 {
+		return msg
+	}
+
+	switch content := msg.Content.(type) {
+	case *messages.WSMessage_UserChangeStatus:
+		if content.UserChangeStatus.User != nil && slices.Contains(u.blocked, content.UserChangeStatus.User.Id) {
+			return nil
+		}
+	case *messages.WSMessage_NewChatMessage:
+		message, ok := u.filterMessage(ctx, content.NewChatMessage.Message)
+		if !ok {
+			return nil
+		}
+		return &messages.WSMessage{
+			Content: &messages.WSMessage_NewChatMessage{
+				NewChatMessage: &messages.NewChatMessage{Message: message},
+			},
+		}
+	case *messages.WSMessage_EditChatMessage:
+		message, ok := u.filterMessage(ctx, content.EditChatMessage.Message)
+		if !ok {
+			return nil
+		}
+		return &messages.WSMessage{
+			Content: &messages.WSMessage_EditChatMessage{
+				EditChatMessage: &messages.EditChatMessage{Message: message},
+			},
+		}
+	}
+
+	return msg
+}
+
+// filterMessage returns the message as the user sees it, false when it must not reach them. The
+// message is shared with the other recipients, it is copied before being flagged.
+func (u *user) filterMessage(ctx *actor.Context, msg *messages.Message) (*messages.Message, bool) {
+	if msg == nil || msg.Author == nil || !slices.Contains(u.blocked, msg.Author.Id) {
+		return msg, true
+	}
+	if msg.ServerId == "global" {
+		return nil, false
+	}
+
+	userID := GetIDFromPID(ctx.PID())
+	flagged := proto.Clone(msg).(*messages.Message)
+	flagged.AuthorBlocked = true
+	flagged.Everyone = false
+	flagged.MentionsUsers = slices.DeleteFunc(flagged.MentionsUsers, func(id string) bool {
+		return id == userID
+	})
+
+	return flagged, true
+}
+
 func (u *user) FriendChangeStatus(ctx *actor.Context, msg *messages.ChangeStatus) {
 	u.friends = append(u.friends, msg.User.Id)
 
@@ -125,6 +217,12 @@ func (u *user) initializeUser(ctx *actor.Context) {
 		slog.Error("failed to get friendIDs", "err", err)
 	}
 
+	blockedIDs, err := u.db.GetBlockedUserIDs(ctx.Context(), userID)
+	if err != nil {
+		slog.Error("failed to get blocked users", "err", err)
+	}
+	u.blocked = append(u.blocked, blockedIDs...)
+
 	serverIDs, err := u.db.GetServersIDFromUser(ctx.Context(), userID)
 	if err != nil {
 		slog.Error("failed to get serverIDs", "err", err)
@@ -168,6 +266,33 @@ func (u *user) initializeUser(ctx *actor.Context) {
 	}
 }
 
+// leaveTemporaryServers removes the user from the servers joined with a temporary invite, they
+// leave with their last connection. The encrypted channels of those servers need a key without them.
+func (u *user) leaveTemporaryServers(ctx context.Context, userID string) {
+	serverIDs, err := u.db.DeleteTemporaryMemberships(ctx, userID)
+	if err != nil {
+		slog.Error("failed to remove temporary memberships on disconnect", "err", err)
+	}
+
+	for _, serverID := range serverIDs {
+		u.hub.LeaveServer(serverID, userID)
+
+		channels, err := u.db.RequireServerRekey(ctx, serverID)
+		if err != nil {
+			slog.Error("failed to require the rekey of the server channels", "server_id", serverID, "err", err)
+			continue
+		}
+		for _, channel := range channels {
+			u.hub.ChannelKeyChange(&messages.ChannelKeyChange{
+				Type:      types.RekeyRequired,
+				ServerId:  channel.ServerID,
+				ChannelId: channel.ID,
+				Reason:    types.RekeyMembersChanged,
+			})
+		}
+	}
+}
+
 func (u *user) killUser(ctx *actor.Context) {
 	userID := GetIDFromPID(ctx.PID())
 	servers, err := u.db.GetUserServers(ctx.Context(), userID)
@@ -193,6 +318,8 @@ func (u *user) killUser(ctx *actor.Context) {
 		u.hub.SendUserStatusMessage(ctx.PID(), disconnectMessage)
 	}
 
+	u.leaveTemporaryServers(ctx.Context(), userID)
+
 	for _, friendID := range friendIDs {
 		disconnectMessage := &messages.ChangeStatus{
 			Type: "disconnect",
@@ -0,0 +1,76 @@
+package actors
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/database"
+	"backend/internal/types"
+	messages "backend/proto"
+	"context"
+	"slices"
+	"testing"
+)
+
+type temporaryDB struct {
+	database.Service
+	temporary map[string][]string
+	channels  []db.Channel
+}
+
+func (f *temporaryDB) DeleteTemporaryMemberships(_ context.Context, userID string) ([]string, error) {
+	serverIDs := f.temporary[userID]
+	delete(f.temporary, userID)
+	return serverIDs, nil
+}
+
+func (f *temporaryDB) RequireServerRekey(_ context.Context, serverID string) ([]db.Channel, error) {
+	var channels []db.Channel
+	for _, channel := range f.channels {
+		if channel.ServerID == serverID && channel.E2ee {
+			channels = append(channels, channel)
+		}
+	}
+
+	return channels, nil
+}
+
+type recordingHub struct {
+	Service
+	left       []string
+	keyChanges []*messages.ChannelKeyChange
+}
+
+func (h *recordingHub) LeaveServer(serverID, userID string) {
+	h.left = append(h.left, serverID)
+}
+
+func (h *recordingHub) ChannelKeyChange(change *messages.ChannelKeyChange) {
+	h.keyChanges = append(h.keyChanges, change)
+}
+
+func TestLeaveTemporaryServersRequiresRekey(t *testing.T) {
+	fdb := &temporaryDB{
+		temporary: map[string][]string{"guest": {"server"}},
+		channels: []db.Channel{
+			{ID: "secret", ServerID: "server", E2ee: true},
+			{ID: "plain", ServerID: "server"},
+			{ID: "elsewhere", ServerID: "other", E2ee: true},
+		},
+	}
+	hub := &recordingHub{}
+	u := &user{hub: hub, db: fdb}
+
+	u.leaveTemporaryServers(context.Background(), "guest")
+
+	if !slices.Equal(hub.left, []string{"server"}) {
+		t.Fatalf("expected the guest to leave the server, got %v", hub.left)
+	}
+	if len(hub.keyChanges) != 1 || hub.keyChanges[0].ChannelId != "secret" || hub.keyChanges[0].Reason != types.RekeyMembersChanged {
+		t.Fatalf("expected the encrypted channel to need a new key, got %v", hub.keyChanges)
+	}
+
+	hub.keyChanges = nil
+	u.leaveTemporaryServers(context.Background(), "member")
+	if len(hub.keyChanges) != 0 {
+		t.Fatalf("expected members without temporary invites to change nothing, got %v", hub.keyChanges)
+	}
+}
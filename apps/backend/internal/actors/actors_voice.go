@@ -0,0 +1,391 @@
+package actors
+
+import (
+	"backend/internal/sfu"
+	"backend/internal/voice"
+	messages "backend/proto"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The voice state of a server lives in the channel actors of its first instance (see voiceOwner),
+// which report every change to their parent. The parent keeps one voice channel per member and
+// relays the rosters to the other instances. With an SFU configured, the channels open a room on it
+// and hand short-lived join tokens to their participants instead of relaying their signals.
+
+const (
+	sfuQueueSize = 64
+	sfuTimeout   = 5 * time.Second
+)
+
+var voiceErrorCodes = map[error]string{
+	voice.ErrNotInChannel:     "ERR_NOT_IN_VOICE_CHANNEL",
+	voice.ErrTargetNotInRoom:  "ERR_MEMBER_NOT_IN_VOICE_CHANNEL",
+	voice.ErrChannelFull:      "ERR_VOICE_CHANNEL_FULL",
+	voice.ErrVideoNotAllowed:  "ERR_FORBIDDEN_VIDEO",
+	voice.ErrSignalToYourself: "ERR_INVALID_SIGNAL",
+	voice.ErrTrackNotAllowed:  "ERR_FORBIDDEN_TRACK",
+}
+
+func (c *channel) voiceRoom() *voice.Room {
+	if c.room == nil {
+		limit := voice.MaxMeshParticipants
+		if c.sfu != nil {
+			limit = voice.MaxSFUParticipants
+		}
+		c.room = voice.NewRoom(limit)
+	}
+
+	return c.room
+}
+
+func (c *channel) voiceCommand(ctx *actor.Context, msg *messages.VoiceCommand) {
+	c.voiceRoom()
+	channelID := GetIDFromPID(ctx.PID())
+	request := msg.Request
+	abilities := voice.Abilities{Speak: msg.CanSpeak, Video: msg.CanVideo}
+
+	var err error
+	switch action := request.Action.(type) {
+	case *messages.VoiceRequest_Join:
+		self := voice.SelfState{Mute: action.Join.SelfMute, Deaf: action.Join.SelfDeaf, Video: action.Join.Video}
+		if _, err = c.room.Join(msg.UserId, self, abilities, time.Now()); err == nil {
+			c.sendVoiceSession(ctx, msg.UserId)
+		}
+	case *messages.VoiceRequest_Leave:
+		if _, ok := c.room.Leave(msg.UserId); !ok {
+			return
+		}
+		c.sfuRemove(channelID, msg.UserId)
+	case *messages.VoiceRequest_SelfState:
+		self := voice.SelfState{Mute: action.SelfState.Mute, Deaf: action.SelfState.Deaf, Video: action.SelfState.Video}
+		if _, err = c.room.SetSelf(msg.UserId, self, msg.CanVideo); err == nil {
+			c.sfuUpdatePermission(channelID, msg.UserId)
+		}
+	case *messages.VoiceRequest_Moderate:
+		var state *voice.State
+		if state, err = c.room.Moderate(action.Moderate.UserId, action.Moderate.Mute, action.Moderate.Deaf); err == nil {
+			c.sfuUpdatePermission(channelID, state.UserID)
+			if state.ServerMute {
+				c.sfuMuteTracks(channelID, state.UserID, state.Tracks, voice.TrackAudio)
+			}
+		}
+	case *messages.VoiceRequest_Move:
+		state, ok := c.room.Leave(action.Move.UserId)
+		if !ok {
+			err = voice.ErrTargetNotInRoom
+			break
+		}
+		c.sfuRemove(channelID, state.UserID)
+		ctx.Send(ctx.Parent().Child("channel/"+action.Move.ChannelId), &messages.VoiceTransfer{
+			ServerId: request.ServerId,
+			State:    voiceStateProto(state),
+			CanSpeak: msg.CanSpeak,
+			CanVideo: msg.CanVideo,
+		})
+	case *messages.VoiceRequest_Signal:
+		c.relaySignal(ctx, msg.UserId, action.Signal)
+		return
+	default:
+		return
+	}
+
+	if err != nil {
+		c.voiceError(ctx, msg.UserId, err)
+		return
+	}
+
+	c.publishVoiceStates(ctx, request.ServerId)
+}
+
+// voiceTransfer receives a participant moved from another channel.
+func (c *channel) voiceTransfer(ctx *actor.Context, msg *messages.VoiceTransfer) {
+	c.voiceRoom()
+
+	state := voice.State{
+		UserID:     msg.State.UserId,
+		SelfMute:   msg.State.SelfMute,
+		SelfDeaf:   msg.State.SelfDeaf,
+		ServerMute: msg.State.ServerMute,
+		ServerDeaf: msg.State.ServerDeaf,
+		Video:      msg.State.Video,
+	}
+	if _, err := c.room.Insert(state, voice.Abilities{Speak: msg.CanSpeak, Video: msg.CanVideo}, time.Now()); err != nil {
+		c.voiceError(ctx, state.UserID, err)
+		return
+	}
+	c.sendVoiceSession(ctx, state.UserID)
+
+	c.publishVoiceStates(ctx, msg.ServerId)
+}
+
+// voiceTrackEvent records the tracks published on the SFU, muting the ones a participant isn't
+// allowed to publish anymore.
+func (c *channel) voiceTrackEvent(ctx *actor.Context, msg *messages.VoiceTrackEvent) {
+	if c.room == nil || msg.Track == nil {
+		return
+	}
+
+	track := voice.Track{SID: msg.Track.Sid, Kind: voice.TrackKind(msg.Track.Kind), Source: msg.Track.Source}
+	if !msg.Published {
+		if _, ok := c.room.UnpublishTrack(msg.UserId, track.SID); ok {
+			c.publishVoiceStates(ctx, msg.ServerId)
+		}
+		return
+	}
+
+	_, err := c.room.PublishTrack(msg.UserId, track)
+	switch {
+	case errors.Is(err, voice.ErrTrackNotAllowed):
+		c.sfuMuteTracks(msg.ChannelId, msg.UserId, []voice.Track{track}, track.Kind)
+	case err != nil:
+		return
+	}
+
+	c.publishVoiceStates(ctx, msg.ServerId)
+}
+
+// sendVoiceSession hands a participant what they need to connect to the room of the channel.
+func (c *channel) sendVoiceSession(ctx *actor.Context, userID string) {
+	if c.sfu == nil {
+		return
+	}
+
+	channelID := GetIDFromPID(ctx.PID())
+	session, err := c.sfu.JoinSession(channelID, userID, c.sfuPermission(userID))
+	if err != nil {
+		c.logger.Error("failed to issue a voice session", "channel", channelID, "err", err)
+		c.voiceError(ctx, userID, err)
+		return
+	}
+
+	c.hub.BroadcastMessageToUser(c.hub.GetUser(userID), &messages.WSMessage{
+		Content: &messages.WSMessage_VoiceSession{
+			VoiceSession: &messages.VoiceSession{
+				ChannelId: channelID,
+				Url:       session.URL,
+				Token:     session.Token,
+				ExpiresAt: timestamppb.New(session.ExpiresAt),
+			},
+		},
+	})
+}
+
+func (c *channel) sfuPermission(userID string) sfu.Permission {
+	publishing := c.room.Publishing(userID)
+	return sfu.Permission{Audio: publishing.Audio, Video: publishing.Video, Subscribe: publishing.Subscribe}
+}
+
+func (c *channel) sfuUpdatePermission(channelID, userID string) {
+	permission := c.sfuPermission(userID)
+	c.sfuDo(func(ctx context.Context) error {
+		return c.sfu.UpdatePermission(ctx, channelID, userID, permission)
+	})
+}
+
+func (c *channel) sfuMuteTracks(channelID, userID string, tracks []voice.Track, kind voice.TrackKind) {
+	for _, track := range tracks {
+		if track.Kind == kind {
+			c.sfuDo(func(ctx context.Context) error {
+				return c.sfu.MuteTrack(ctx, channelID, userID, track.SID)
+			})
+		}
+	}
+}
+
+func (c *channel) sfuRemove(channelID, userID string) {
+	c.sfuDo(func(ctx context.Context) error {
+		return c.sfu.RemoveParticipant(ctx, channelID, userID)
+	})
+}
+
+// sfuDo queues a request to the SFU, they are made in order off the actor. The SFU failing only
+// leaves it behind the voice state of the channel.
+func (c *channel) sfuDo(request func(ctx context.Context) error) {
+	if c.sfu == nil {
+		return
+	}
+
+	if c.sfuRequests == nil {
+		c.sfuRequests = make(chan func(ctx context.Context) error, sfuQueueSize)
+		go func(requests <-chan func(ctx context.Context) error) {
+			for request := range requests {
+				ctx, cancel := context.WithTimeout(context.Background(), sfuTimeout)
+				if err := request(ctx); err != nil {
+					c.logger.Error("sfu request failed", "err", err)
+				}
+				cancel()
+			}
+		}(c.sfuRequests)
+	}
+
+	select {
+	case c.sfuRequests <- request:
+	default:
+		c.logger.Error("sfu queue full, dropping a request")
+	}
+}
+
+// closeVoice closes the room of a channel which went away.
+func (c *channel) closeVoice(ctx *actor.Context) {
+	if c.room != nil && c.room.Len() > 0 {
+		channelID := GetIDFromPID(ctx.PID())
+		c.sfuDo(func(ctx context.Context) error {
+			return c.sfu.DeleteRoom(ctx, channelID)
+		})
+	}
+
+	if c.sfuRequests != nil {
+		close(c.sfuRequests)
+		c.sfuRequests = nil
+	}
+}
+
+func (c *channel) relaySignal(ctx *actor.Context, senderID string, signal *messages.VoiceSignal) {
+	ready, err := c.room.OrderSignal(senderID, voice.Signal{
+		Seq:     signal.Seq,
+		ToID:    signal.UserId,
+		Type:    signal.Type,
+		Payload: signal.Payload,
+	})
+	if err != nil {
+		c.voiceError(ctx, senderID, err)
+		return
+	}
+
+	for _, s := range ready {
+		c.hub.BroadcastMessageToUser(c.hub.GetUser(s.ToID), &messages.WSMessage{
+			Content: &messages.WSMessage_VoiceSignal{
+				VoiceSignal: &messages.VoiceSignal{
+					ChannelId: GetIDFromPID(ctx.PID()),
+					UserId:    senderID,
+					Type:      s.Type,
+					Payload:   s.Payload,
+				},
+			},
+		})
+	}
+}
+
+func (c *channel) publishVoiceStates(ctx *actor.Context, serverID string) {
+	roster := &messages.VoiceChannelStates{
+		ServerId:  serverID,
+		ChannelId: GetIDFromPID(ctx.PID()),
+	}
+	for _, state := range c.room.States() {
+		roster.States = append(roster.States, voiceStateProto(state))
+	}
+
+	ctx.Send(ctx.Parent(), roster)
+}
+
+func (c *channel) voiceError(ctx *actor.Context, userID string, err error) {
+	code, ok := voiceErrorCodes[err]
+	if !ok {
+		code = "ERR_VOICE"
+	}
+
+	c.hub.BroadcastMessageToUser(c.hub.GetUser(userID), &messages.WSMessage{
+		Content: &messages.WSMessage_VoiceError{
+			VoiceError: &messages.VoiceError{
+				ChannelId: GetIDFromPID(ctx.PID()),
+				Code:      code,
+				Message:   err.Error(),
+			},
+		},
+	})
+}
+
+// voiceChannelStates stores the roster reported by a channel of this instance, it keeps members
+// in one voice channel of the server at a time before relaying it.
+func (s *server) voiceChannelStates(ctx *actor.Context, msg *messages.VoiceChannelStates) {
+	for _, state := range msg.States {
+		for channelID, other := range s.voice {
+			if channelID != msg.ChannelId && hasVoiceState(other, state.UserId) {
+				ctx.Send(ctx.PID().Child("channel/"+channelID), voiceLeave(msg.ServerId, channelID, state.UserId))
+			}
+		}
+	}
+
+	message := &messages.WSMessage{
+		Content: &messages.WSMessage_VoiceChannelStates{
+			VoiceChannelStates: msg,
+		},
+	}
+
+	s.storeVoiceStates(msg)
+	s.BroadcastToServer(message)
+	for _, serverPID := range s.hub.GetAllServerInstances(msg.ServerId) {
+		if !serverPID.Equals(ctx.PID()) {
+			ctx.Send(serverPID, message)
+		}
+	}
+}
+
+func (s *server) storeVoiceStates(msg *messages.VoiceChannelStates) {
+	if len(msg.States) == 0 {
+		delete(s.voice, msg.ChannelId)
+		return
+	}
+
+	s.voice[msg.ChannelId] = msg
+}
+
+// disconnectVoice makes a member who went offline or left the server leave its voice channel.
+func (s *server) disconnectVoice(userID string) {
+	for channelID, roster := range s.voice {
+		if hasVoiceState(roster, userID) {
+			s.hub.SendVoiceCommand(voiceLeave(roster.ServerId, channelID, userID))
+		}
+	}
+}
+
+func (s *server) getVoiceStates() *messages.GetVoiceStates {
+	response := &messages.GetVoiceStates{}
+	for _, roster := range s.voice {
+		response.Channels = append(response.Channels, roster)
+	}
+
+	return response
+}
+
+func voiceLeave(serverID, channelID, userID string) *messages.VoiceCommand {
+	return &messages.VoiceCommand{
+		UserId: userID,
+		Request: &messages.VoiceRequest{
+			ServerId:  serverID,
+			ChannelId: channelID,
+			Action:    &messages.VoiceRequest_Leave{Leave: &messages.VoiceLeave{}},
+		},
+	}
+}
+
+func hasVoiceState(roster *messages.VoiceChannelStates, userID string) bool {
+	return slices.ContainsFunc(roster.States, func(state *messages.VoiceState) bool {
+		return state.UserId == userID
+	})
+}
+
+func voiceStateProto(state voice.State) *messages.VoiceState {
+	tracks := make([]*messages.VoiceTrack, 0, len(state.Tracks))
+	for _, track := range state.Tracks {
+		tracks = append(tracks, &messages.VoiceTrack{Sid: track.SID, Kind: string(track.Kind), Source: track.Source})
+	}
+
+	return &messages.VoiceState{
+		UserId:     state.UserID,
+		SelfMute:   state.SelfMute,
+		SelfDeaf:   state.SelfDeaf,
+		ServerMute: state.ServerMute,
+		ServerDeaf: state.ServerDeaf,
+		Video:      state.Video,
+		Suppressed: state.Suppressed,
+		Tracks:     tracks,
+		JoinedAt:   timestamppb.New(state.JoinedAt),
+	}
+}
@@ -0,0 +1,122 @@
+package attachments
+
+import (
+	"backend/internal/database"
+	"backend/internal/files"
+	"backend/internal/types"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	pollInterval = 30 * time.Second
+	batchSize    = 50
+	maxBackoff   = time.Hour
+)
+
+// Service keeps track of the stored files and what they belong to. Attachment rows go away with
+// their owner (message, user, server or emoji) and the files are then deleted in the background,
+// unless another attachment still references the same key.
+type Service interface {
+	// Track records the files of a new owner, their sizes count against the quotas of the owner.
+	Track(ctx context.Context, owner types.AttachmentOwner, files ...types.StoredFile) error
+	// Replace swaps the files of an owner, the previous ones are queued for deletion.
+	Replace(ctx context.Context, owner types.AttachmentOwner, files ...types.StoredFile) error
+}
+
+type service struct {
+	db    database.Service
+	files files.Service
+}
+
+func New(db database.Service, files files.Service) Service {
+	s := &service{
+		db:    db,
+		files: files,
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *service) Track(ctx context.Context, owner types.AttachmentOwner, files ...types.StoredFile) error {
+	keys, sizes := s.keys(files)
+	return s.db.CreateAttachments(ctx, owner, keys, sizes)
+}
+
+func (s *service) Replace(ctx context.Context, owner types.AttachmentOwner, files ...types.StoredFile) error {
+	keys, sizes := s.keys(files)
+	return s.db.ReplaceAttachments(ctx, owner, keys, sizes)
+}
+
+// keys returns the storage keys of the files with their sizes, files which aren't served by the
+// storage are skipped. The size of a file is counted once, on its own key.
+func (s *service) keys(storedFiles []types.StoredFile) ([]string, []int64) {
+	var keys []string
+	var sizes []int64
+	for _, file := range storedFiles {
+		key := s.files.KeyFromURL(file.URL)
+		if key == "" {
+			continue
+		}
+
+		for i, k := range files.VariantKeys(key) {
+			keys = append(keys, k)
+			if i == 0 {
+				sizes = append(sizes, file.Size)
+			} else {
+				sizes = append(sizes, 0)
+			}
+		}
+	}
+
+	return keys, sizes
+}
+
+func (s *service) run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.processDeletions()
+	}
+}
+
+func (s *service) processDeletions() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deletions, err := s.db.ClaimFileDeletions(ctx, batchSize)
+	if err != nil {
+		slog.Error("failed to claim file deletions", "err", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		// The key was attached again since it got queued, it stays.
+		if !deletion.Referenced {
+			if err := s.files.DeleteFile(deletion.Key); err != nil {
+				slog.Warn("failed to delete file", "key", deletion.Key, "attempts", deletion.Attempts+1, "err", err)
+				if err := s.db.RecordFileDeletionFailure(ctx, deletion.Key, err, time.Now().Add(backoff(deletion.Attempts))); err != nil {
+					slog.Error("failed to record file deletion failure", "key", deletion.Key, "err", err)
+				}
+				continue
+			}
+		}
+
+		if err := s.db.DeleteFileDeletion(ctx, deletion.Key); err != nil {
+			slog.Error("failed to dequeue file deletion", "key", deletion.Key, "err", err)
+		}
+	}
+}
+
+// backoff doubles the delay after each failed attempt, from a minute up to an hour.
+func backoff(attempts int32) time.Duration {
+	if attempts >= 6 {
+		return maxBackoff
+	}
+
+	return min(time.Minute<<attempts, maxBackoff)
+}
@@ -0,0 +1,80 @@
+package attachments
+
+import (
+	"backend/internal/database"
+	"backend/internal/files"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const reconcileBatchSize = 500
+
+type Report struct {
+	Scanned  int
+	Orphaned int
+	Deleted  int
+}
+
+// Reconcile lists the whole storage and deletes the files no attachment or pending upload references,
+// such as the ones left over by a crash between the upload and the database write. Files modified
+// within grace are skipped since their rows may not be written yet.
+func Reconcile(ctx context.Context, db database.Service, storage files.Storage, dryRun bool, grace time.Duration) (*Report, error) {
+	report := &Report{}
+	cutoff := time.Now().Add(-grace)
+
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		referenced, err := db.GetReferencedKeys(ctx, batch)
+		if err != nil {
+			return err
+		}
+		kept := make(map[string]bool, len(referenced))
+		for _, key := range referenced {
+			kept[key] = true
+		}
+
+		for _, key := range batch {
+			if kept[key] {
+				continue
+			}
+
+			report.Orphaned++
+			if dryRun {
+				slog.Info("orphaned file", "key", key)
+				continue
+			}
+
+			if err := storage.Delete(ctx, key); err != nil {
+				slog.Error("failed to delete orphaned file", "key", key, "err", err)
+				continue
+			}
+			report.Deleted++
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	err := storage.List(ctx, func(key string, meta files.Metadata) error {
+		report.Scanned++
+		if meta.ModTime.After(cutoff) {
+			return nil
+		}
+
+		batch = append(batch, key)
+		if len(batch) < reconcileBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, flush()
+}
@@ -2,7 +2,10 @@ package broker
 
 import (
 	db "backend/db/gen_queries"
+	"backend/internal/types"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -43,6 +46,22 @@ type Service interface {
 
 	CacheServerAbilities(ctx context.Context, serverID, userID string, abilities []string) error
 	GetServerAbilities(ctx context.Context, serverID, userID string) (string, error)
+
+	CacheOAuthState(ctx context.Context, state string, data types.OAuthState) error
+	// GetOAuthState returns the pending sign in bound to this state and deletes it, so it can only be used once.
+	GetOAuthState(ctx context.Context, state string) (*types.OAuthState, error)
+
+	CacheAccessToken(ctx context.Context, tokenHash string, token types.CachedAccessToken) error
+	GetCachedAccessToken(ctx context.Context, tokenHash string) (*types.CachedAccessToken, error)
+	RemoveCachedAccessTokens(ctx context.Context, tokenHashes ...string) error
+
+	CacheInteraction(ctx context.Context, interaction types.PendingInteraction) error
+	GetInteraction(ctx context.Context, interactionID string) (*types.PendingInteraction, error)
+
+	// CacheEmbed keeps the preview of a URL, a nil embed remembers that the URL has none.
+	CacheEmbed(ctx context.Context, url string, embed *types.Embed) error
+	// GetCachedEmbed returns the cached preview of a URL, which is nil when the URL has none.
+	GetCachedEmbed(ctx context.Context, url string) (*types.Embed, error)
 }
 
 type service struct {
@@ -164,6 +183,126 @@ func (s *service) GetServerAbilities(ctx context.Context, serverID, userID strin
 	return res.Result()
 }
 
+func (s *service) CacheOAuthState(ctx context.Context, state string, data types.OAuthState) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Set(ctx, "oauth:"+state, dataJSON, 10*time.Minute).Err()
+}
+
+func (s *service) GetOAuthState(ctx context.Context, state string) (*types.OAuthState, error) {
+	dataJSON, err := s.db.GetDel(ctx, "oauth:"+state).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var data types.OAuthState
+	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (s *service) CacheAccessToken(ctx context.Context, tokenHash string, token types.CachedAccessToken) error {
+	token.User.Password = ""
+
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Set(ctx, "pat:"+tokenHash, tokenJSON, 10*time.Minute).Err()
+}
+
+func (s *service) GetCachedAccessToken(ctx context.Context, tokenHash string) (*types.CachedAccessToken, error) {
+	tokenJSON, err := s.db.Get(ctx, "pat:"+tokenHash).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var token types.CachedAccessToken
+	if err := json.Unmarshal([]byte(tokenJSON), &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *service) RemoveCachedAccessTokens(ctx context.Context, tokenHashes ...string) error {
+	if len(tokenHashes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(tokenHashes))
+	for i, hash := range tokenHashes {
+		keys[i] = "pat:" + hash
+	}
+
+	return s.db.Del(ctx, keys...).Err()
+}
+
+// CacheInteraction keeps an interaction around for 15 minutes, the time a bot has to reply to it.
+func (s *service) CacheInteraction(ctx context.Context, interaction types.PendingInteraction) error {
+	interactionJSON, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Set(ctx, "interaction:"+interaction.ID, interactionJSON, 15*time.Minute).Err()
+}
+
+func (s *service) GetInteraction(ctx context.Context, interactionID string) (*types.PendingInteraction, error) {
+	interactionJSON, err := s.db.Get(ctx, "interaction:"+interactionID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var interaction types.PendingInteraction
+	if err := json.Unmarshal([]byte(interactionJSON), &interaction); err != nil {
+		return nil, err
+	}
+
+	return &interaction, nil
+}
+
+func (s *service) CacheEmbed(ctx context.Context, url string, embed *types.Embed) error {
+	embedJSON, err := json.Marshal(embed)
+	if err != nil {
+		return err
+	}
+
+	// Pages without a preview may get one, they are fetched again sooner.
+	ttl := time.Hour
+	if embed == nil {
+		ttl = 10 * time.Minute
+	}
+
+	return s.db.Set(ctx, embedKey(url), embedJSON, ttl).Err()
+}
+
+func (s *service) GetCachedEmbed(ctx context.Context, url string) (*types.Embed, error) {
+	embedJSON, err := s.db.Get(ctx, embedKey(url)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var embed *types.Embed
+	if err := json.Unmarshal([]byte(embedJSON), &embed); err != nil {
+		return nil, err
+	}
+
+	return embed, nil
+}
+
+// embedKey hashes the URL, which can be long.
+func embedKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "embed:" + hex.EncodeToString(sum[:])
+}
+
 // Health checks the health of the broker connection by pinging the broker.
 // It returns a map with keys indicating various health statistics.
 func (s *service) Health() map[string]string {
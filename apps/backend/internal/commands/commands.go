@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+)
+
+type OptionType string
+
+const (
+	String  OptionType = "string"
+	Integer OptionType = "integer"
+	Number  OptionType = "number"
+	Boolean OptionType = "boolean"
+	User    OptionType = "user"
+	Channel OptionType = "channel"
+	Role    OptionType = "role"
+)
+
+const maxOptions = 25
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+type Choice struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type Option struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Type        OptionType `json:"type"`
+	Required    bool       `json:"required"`
+	Choices     []Choice   `json:"choices,omitempty"`
+}
+
+// Value is an option as sent by the client invoking a command.
+type Value struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ValidName reports whether name can be used for a command or an option.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// ValidateDefinition checks the options a bot registers for a command.
+func ValidateDefinition(options []Option) error {
+	if len(options) > maxOptions {
+		return fmt.Errorf("a command can't have more than %d options", maxOptions)
+	}
+
+	seen := make(map[string]bool, len(options))
+	optional := false
+
+	for _, option := range options {
+		if !ValidName(option.Name) {
+			return fmt.Errorf("option name %q is invalid", option.Name)
+		}
+		if seen[option.Name] {
+			return fmt.Errorf("option %q is defined twice", option.Name)
+		}
+		seen[option.Name] = true
+
+		if !slices.Contains([]OptionType{String, Integer, Number, Boolean, User, Channel, Role}, option.Type) {
+			return fmt.Errorf("option %q has an unknown type %q", option.Name, option.Type)
+		}
+
+		if option.Required && optional {
+			return fmt.Errorf("required option %q must come before optional ones", option.Name)
+		}
+		optional = optional || !option.Required
+
+		if len(option.Choices) > 0 {
+			if option.Type != String && option.Type != Integer && option.Type != Number {
+				return fmt.Errorf("option %q of type %s can't have choices", option.Name, option.Type)
+			}
+			for _, choice := range option.Choices {
+				if _, err := convert(option.Type, choice.Value); err != nil {
+					return fmt.Errorf("choice %q of option %q: %w", choice.Name, option.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolve type checks the values sent for a command against its options and returns them by name.
+// User, channel and role options resolve to their ID, existence is up to the caller.
+func Resolve(options []Option, values []Value) (map[string]any, error) {
+	resolved := make(map[string]any, len(values))
+
+	for _, value := range values {
+		idx := slices.IndexFunc(options, func(o Option) bool { return o.Name == value.Name })
+		if idx == -1 {
+			return nil, fmt.Errorf("unknown option %q", value.Name)
+		}
+		if _, ok := resolved[value.Name]; ok {
+			return nil, fmt.Errorf("option %q is given twice", value.Name)
+		}
+		option := options[idx]
+
+		var raw any
+		if err := json.Unmarshal(value.Value, &raw); err != nil {
+			return nil, fmt.Errorf("option %q: %w", option.Name, err)
+		}
+
+		v, err := convert(option.Type, raw)
+		if err != nil {
+			return nil, fmt.Errorf("option %q: %w", option.Name, err)
+		}
+
+		if len(option.Choices) > 0 && !slices.ContainsFunc(option.Choices, func(c Choice) bool {
+			choice, _ := convert(option.Type, c.Value)
+			return choice == v
+		}) {
+			return nil, fmt.Errorf("option %q: value is not one of the choices", option.Name)
+		}
+
+		resolved[option.Name] = v
+	}
+
+	for _, option := range options {
+		if _, ok := resolved[option.Name]; option.Required && !ok {
+			return nil, fmt.Errorf("option %q is required", option.Name)
+		}
+	}
+
+	return resolved, nil
+}
+
+var errWrongType = errors.New("value has the wrong type")
+
+// convert normalizes a decoded JSON value to the Go type of an option type.
+func convert(t OptionType, v any) (any, error) {
+	switch t {
+	case String, User, Channel, Role:
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, errWrongType
+		}
+		return s, nil
+	case Integer:
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, errWrongType
+		}
+		return int64(f), nil
+	case Number:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errWrongType
+		}
+		return f, nil
+	case Boolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errWrongType
+		}
+		return b, nil
+	}
+
+	return nil, errWrongType
+}
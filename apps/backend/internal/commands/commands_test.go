@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var deployOptions = []Option{
+	{Name: "service", Type: String, Required: true, Choices: []Choice{{Name: "API", Value: "api"}, {Name: "Web", Value: "web"}}},
+	{Name: "replicas", Type: Integer},
+	{Name: "dry-run", Type: Boolean},
+}
+
+func TestValidateDefinition(t *testing.T) {
+	if err := ValidateDefinition(deployOptions); err != nil {
+		t.Fatalf("expected a valid definition, got %v", err)
+	}
+
+	invalid := map[string][]Option{
+		"bad name":           {{Name: "Bad Name", Type: String}},
+		"duplicate":          {{Name: "a", Type: String}, {Name: "a", Type: String}},
+		"unknown type":       {{Name: "a", Type: "date"}},
+		"required after opt": {{Name: "a", Type: String}, {Name: "b", Type: String, Required: true}},
+		"boolean choices":    {{Name: "a", Type: Boolean, Choices: []Choice{{Name: "yes", Value: true}}}},
+		"mistyped choice":    {{Name: "a", Type: Integer, Choices: []Choice{{Name: "one", Value: "1"}}}},
+	}
+
+	for name, options := range invalid {
+		if err := ValidateDefinition(options); err == nil {
+			t.Errorf("%s: expected the definition to be rejected", name)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var values []Value
+	json.Unmarshal([]byte(`[{"name":"service","value":"api"},{"name":"replicas","value":3}]`), &values)
+
+	resolved, err := Resolve(deployOptions, values)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if resolved["service"] != "api" || resolved["replicas"] != int64(3) {
+		t.Fatalf("unexpected resolved options %v", resolved)
+	}
+
+	invalid := map[string]string{
+		"missing required": `[{"name":"replicas","value":3}]`,
+		"not a choice":     `[{"name":"service","value":"db"}]`,
+		"wrong type":       `[{"name":"service","value":"api"},{"name":"replicas","value":"3"}]`,
+		"not an integer":   `[{"name":"service","value":"api"},{"name":"replicas","value":1.5}]`,
+		"unknown option":   `[{"name":"service","value":"api"},{"name":"force","value":true}]`,
+		"given twice":      `[{"name":"service","value":"api"},{"name":"service","value":"web"}]`,
+	}
+
+	for name, raw := range invalid {
+		var values []Value
+		json.Unmarshal([]byte(raw), &values)
+
+		if _, err := Resolve(deployOptions, values); err == nil {
+			t.Errorf("%s: expected the values to be rejected", name)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package crypto
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	random "math/rand/v2"
@@ -48,6 +50,13 @@ func GenerateRandomID(n uint32) string {
 	return string(id)
 }
 
+// HashToken hashes high entropy secrets such as access tokens, which don't need a slow hash
+// and must stay searchable.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func HashPassword(password string) (string, error) {
 	salt, err := GenerateRandomBytes(16)
 	if err != nil {
@@ -5,9 +5,12 @@ import (
 	"backend/internal/types"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"slices"
 	"strconv"
 	"time"
 
@@ -34,6 +37,71 @@ type Service interface {
 	GetUserByID(ctx context.Context, userID string) (db.User, error)
 	GetUserProfile(ctx context.Context, userID string) (db.GetUserProfileRow, error)
 	CreateUser(ctx context.Context, user *types.SignUpParams) (db.User, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (db.User, error)
+	GetIdentity(ctx context.Context, provider, subject string) (db.UserIdentity, error)
+	GetUserIdentities(ctx context.Context, userID string) ([]db.GetUserIdentitiesRow, error)
+	CountUserIdentities(ctx context.Context, userID string) (int64, error)
+	CreateOAuthUser(ctx context.Context, body *types.CreateOAuthUserParams) (db.User, error)
+	LinkIdentity(ctx context.Context, userID, provider, subject, email string) error
+	UnlinkIdentity(ctx context.Context, userID, provider string) (int64, error)
+	CreateBot(ctx context.Context, ownerID string, body *types.CreateBotParams) (db.User, error)
+	GetBot(ctx context.Context, botID, ownerID string) (db.User, error)
+	GetBots(ctx context.Context, ownerID string) ([]db.GetBotsRow, error)
+	CountBots(ctx context.Context, ownerID string) (int64, error)
+	DeleteBot(ctx context.Context, botID, ownerID string) (int64, error)
+	CreateAccessToken(ctx context.Context, userID, createdBy, tokenHash string, body *types.CreateTokenParams) (db.CreateAccessTokenRow, error)
+	GetAccessTokenByHash(ctx context.Context, tokenHash string) (db.AccessToken, error)
+	GetAccessTokens(ctx context.Context, createdBy string) ([]db.GetAccessTokensRow, error)
+	GetAccessTokenHashes(ctx context.Context, userID string) ([]string, error)
+	TouchAccessToken(ctx context.Context, tokenID string) error
+	DeleteAccessToken(ctx context.Context, tokenID, createdBy string) (string, error)
+	CreateWebhook(ctx context.Context, serverID, userID, secret string, body *types.CreateWebhookParams) (db.Webhook, error)
+	GetServerWebhooks(ctx context.Context, serverID string) ([]db.GetServerWebhooksRow, error)
+	CountServerWebhooks(ctx context.Context, serverID string) (int64, error)
+	UpdateWebhook(ctx context.Context, serverID, webhookID string, body *types.EditWebhookParams) (db.UpdateWebhookRow, error)
+	DeleteWebhook(ctx context.Context, serverID, webhookID string) (int64, error)
+	GetWebhooksForEvent(ctx context.Context, serverID, event string) ([]string, error)
+	CreateWebhookDelivery(ctx context.Context, webhookID, event string, payload json.RawMessage) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]db.ClaimWebhookDeliveriesRow, error)
+	RecordWebhookAttempt(ctx context.Context, deliveryID, status string, statusCode int, errMessage string, nextAttemptAt time.Time) error
+	GetWebhookDeliveries(ctx context.Context, serverID, webhookID string) ([]db.GetWebhookDeliveriesRow, error)
+	PruneWebhookDeliveries(ctx context.Context) error
+	GetChannel(ctx context.Context, channelID string) (db.Channel, error)
+	GetReadableChannelIDs(ctx context.Context, serverID, userID string) ([]string, error)
+	GetCategory(ctx context.Context, categoryID string) (db.ChannelCategory, error)
+	CreateIncomingWebhook(ctx context.Context, serverID, userID, tokenHash string, body *types.CreateIncomingWebhookParams) (db.CreateIncomingWebhookRow, error)
+	GetIncomingWebhook(ctx context.Context, webhookID string) (db.IncomingWebhook, error)
+	GetServerIncomingWebhooks(ctx context.Context, serverID string) ([]db.GetServerIncomingWebhooksRow, error)
+	CountServerIncomingWebhooks(ctx context.Context, serverID string) (int64, error)
+	RotateIncomingWebhookToken(ctx context.Context, serverID, webhookID, tokenHash string) (int64, error)
+	DeleteIncomingWebhook(ctx context.Context, serverID, webhookID string) (int64, error)
+	SetCommands(ctx context.Context, serverID, botID string, commands []types.CommandDefinition) ([]db.Command, error)
+	GetCommand(ctx context.Context, commandID string) (db.Command, error)
+	GetServerCommands(ctx context.Context, serverID string) ([]db.GetServerCommandsRow, error)
+	UpsertInteractionEndpoint(ctx context.Context, botID, url, secret string) error
+	GetInteractionEndpoint(ctx context.Context, botID string) (db.InteractionEndpoint, error)
+	DeleteInteractionEndpoint(ctx context.Context, botID string) (int64, error)
+	CreateUpload(ctx context.Context, upload db.CreateUploadParams) (db.Upload, error)
+	GetUpload(ctx context.Context, uploadID, userID string) (db.Upload, error)
+	CountPendingUploads(ctx context.Context, userID string) (int64, error)
+	ConfirmUpload(ctx context.Context, uploadID, userID, key string, metadata json.RawMessage) (int64, error)
+	ClaimUploads(ctx context.Context, userID string, uploadIDs []string) ([]db.Upload, error)
+	SetUploadsMessage(ctx context.Context, messageID string, uploadIDs []string) error
+	GetOrphanedUploads(ctx context.Context, limit int32) ([]db.GetOrphanedUploadsRow, error)
+	PruneAttachedUploads(ctx context.Context) (int64, error)
+	DeleteUpload(ctx context.Context, uploadID string) error
+	CreateAttachments(ctx context.Context, owner types.AttachmentOwner, keys []string, sizes []int64) error
+	ReplaceAttachments(ctx context.Context, owner types.AttachmentOwner, keys []string, sizes []int64) error
+	GetUserStorageUsage(ctx context.Context, userID string) (int64, error)
+	GetServerStorageUsage(ctx context.Context, serverID string) (db.GetServerStorageUsageRow, error)
+	GetUploadsSize(ctx context.Context, userID string, uploadIDs []string) (int64, error)
+	GetReferencedKeys(ctx context.Context, keys []string) ([]string, error)
+	CreateQuarantinedFile(ctx context.Context, file db.CreateQuarantinedFileParams) (db.QuarantinedFile, error)
+	GetServerModerators(ctx context.Context, serverID string) ([]string, error)
+	ClaimFileDeletions(ctx context.Context, limit int32) ([]db.ClaimFileDeletionsRow, error)
+	DeleteFileDeletion(ctx context.Context, key string) error
+	RecordFileDeletionFailure(ctx context.Context, key string, deletionErr error, nextAttempt time.Time) error
 	UpdateUserAvatarNBanner(ctx context.Context, userID string, avatarURL, bannerURL *string) (db.User, error)
 	UpdateUserEmail(ctx context.Context, userID string, body *types.UpdateEmailParams) (db.User, error)
 	UpdateUserPassword(ctx context.Context, userID string, hashedPassword string) error
@@ -42,12 +110,23 @@ type Service interface {
 	GetUserServerIDs(ctx context.Context, userID string) ([]string, error)
 	GetServersIDFromUser(ctx context.Context, userID string) ([]string, error)
 	CreateServer(ctx context.Context, ownerID string, body *types.CreateServerParams, avatarURL *string) (*db.Server, error)
-	CheckInvite(ctx context.Context, inviteCode string) (string, error)
-	CreateInvite(ctx context.Context, userID, serverID string) (string, error)
-	JoinServer(ctx context.Context, serverID string, userID string, position int) (*db.JoinServerRow, []db.ChannelCategory, []db.Channel, []db.GetRolesFromServerRow, []db.GetLatestMessagesSentRow, error)
+	CheckInvite(ctx context.Context, inviteCode string) (db.CheckInviteRow, error)
+	CreateInvite(ctx context.Context, userID, serverID string, body *types.CreateInviteParams) (db.Invite, error)
+	GetInvite(ctx context.Context, inviteCode string) (db.Invite, error)
+	GetServerInvites(ctx context.Context, serverID string) ([]db.GetServerInvitesRow, error)
+	GetInvitePreview(ctx context.Context, inviteCode string) (db.GetInvitePreviewRow, error)
+	DeleteInvite(ctx context.Context, inviteID string) error
+	// SetVanityInvite replaces the vanity invite of a server, an empty code only removes it.
+	SetVanityInvite(ctx context.Context, userID, serverID, code string) (*db.Invite, error)
+	// JoinServer adds the user to the server, using one of the invite's uses when inviteCode isn't empty.
+	JoinServer(ctx context.Context, serverID string, userID string, position int, inviteCode string) (*db.JoinServerRow, []db.ChannelCategory, []db.Channel, []db.GetRolesFromServerRow, []db.GetLatestMessagesSentRow, error)
+	// DeleteTemporaryMemberships removes the user from the servers joined with a temporary invite
+	// without being given a role since, it returns their ids.
+	DeleteTemporaryMemberships(ctx context.Context, userID string) ([]string, error)
 	GetServer(ctx context.Context, serverID string) (db.Server, error)
 	UpdateServerAvatarNBanner(ctx context.Context, serverID string, avatar, bannerURL *string) error
 	UpdateServerProfile(ctx context.Context, serverID string, body *types.UpdateServerProfileParams) error
+	DiscoverServers(ctx context.Context, userID string, params *types.DiscoverServersParams) ([]db.DiscoverServersRow, error)
 	LeaveServer(ctx context.Context, serverID string, userID string) error
 	DeleteServer(ctx context.Context, userID, serverID string) error
 	GetChannelsFromServers(ctx context.Context, serverIDs []string) ([]db.Channel, error)
@@ -72,11 +151,16 @@ type Service interface {
 	UpdateChannelInformations(ctx context.Context, channelID string, body *types.EditChannelParams) error
 	UpdateCategoryInformations(ctx context.Context, categoryID string, body *types.EditCategoryParams) error
 	CreateMessage(ctx context.Context, userID string, body *types.CreateMessageParams) (db.Message, error)
+	UpdateMessageAttachments(ctx context.Context, messageID string, attachments []byte) (db.Message, error)
+	GetProcessingMessages(ctx context.Context) ([]db.Message, error)
+	UpdateMessageEmbeds(ctx context.Context, messageID string, content, embeds []byte) (db.Message, error)
 	GetServers(ctx context.Context) ([]string, error)
 	GetChannels(ctx context.Context) ([]db.GetChannelsIDsRow, error)
 	GetServerInformations(ctx context.Context, userID, serverID string, userIDs []string) (db.GetServerInformationsRow, error)
 	GetServerMembers(ctx context.Context, serverID string, offset int32, userIDs []string) ([]db.GetServerMembersRow, error)
-	GetMessages(ctx context.Context, serverID, channelID, beforeMessageID, afterMessageID string, userIDs []string) ([]db.GetMessagesFromChannelRow, error)
+	GetMessages(ctx context.Context, userID, serverID, channelID, cardID, postID, beforeMessageID, afterMessageID string, userIDs []string) ([]db.GetMessagesFromChannelRow, error)
+	GetMessage(ctx context.Context, messageID string) (db.Message, error)
+	GetGalleryPosts(ctx context.Context, channelID, beforeMessageID string, limit int) ([]db.GetGalleryPostsRow, error)
 	DeleteMessage(ctx context.Context, messageID string, userID string) error
 	GetMessageAuthor(ctx context.Context, messageID string) (string, error)
 	EditMessage(ctx context.Context, messageID string, body *types.EditMessageParams) error
@@ -87,11 +171,27 @@ type Service interface {
 	GetEmojis(ctx context.Context, userID string) ([]db.GetEmojisRow, error)
 	UpdateEmoji(ctx context.Context, emojiID string, userID string, body *types.UpdateEmojiParams) error
 	DeleteEmoji(ctx context.Context, emojiID string, userID string) error
+	GetServerEmojis(ctx context.Context, serverID string) ([]db.ServerEmoji, error)
+	GetMemberServerEmojis(ctx context.Context, userID string) ([]db.ServerEmoji, error)
+	CreateServerEmojis(ctx context.Context, emojis []db.CreateServerEmojiParams) error
+	UpdateServerEmoji(ctx context.Context, serverID, emojiID string, body *types.UpdateEmojiParams) (db.ServerEmoji, error)
+	DeleteServerEmoji(ctx context.Context, serverID, emojiID string) (db.ServerEmoji, error)
 	CreateFriendRequest(ctx context.Context, senderID, receiverID string) (db.Friend, error)
-	AcceptFriendRequest(ctx context.Context, friendshipID, senderID, receiverID string) (*string, error)
-	RemoveFriend(ctx context.Context, friendshipID, userID string) error
+	AcceptFriendRequest(ctx context.Context, friendshipID, receiverID string) (db.Friend, string, error)
+	RemoveFriend(ctx context.Context, friendshipID, userID string) (db.Friend, string, error)
+	DeclineFriendRequest(ctx context.Context, friendshipID, userID string) (db.Friend, error)
+	CancelFriendRequest(ctx context.Context, friendshipID, userID string) (db.Friend, error)
+	GetIncomingFriendRequests(ctx context.Context, userID string) ([]db.GetIncomingFriendRequestsRow, error)
+	GetOutgoingFriendRequests(ctx context.Context, userID string) ([]db.GetOutgoingFriendRequestsRow, error)
+	GetFriendshipsBetween(ctx context.Context, userID, otherID string) ([]db.Friend, error)
+	CanSendFriendRequest(ctx context.Context, senderID, receiverID string) (bool, error)
 	GetFriends(ctx context.Context, userID string) ([]db.GetFriendsRow, error)
 	GetFriendIDs(ctx context.Context, userID string) ([]string, error)
+	IsBlocked(ctx context.Context, userID, otherID string) (bool, error)
+	BlockUser(ctx context.Context, userID, targetID string) (db.Friend, *db.Friend, error)
+	UnblockUser(ctx context.Context, userID, targetID string) (db.Friend, string, error)
+	GetBlockedUsers(ctx context.Context, userID string) ([]db.GetBlockedUsersRow, error)
+	GetBlockedUserIDs(ctx context.Context, userID string) ([]string, error)
 	DeleteAccount(ctx context.Context, userID string) error
 	BanUser(ctx context.Context, serverID string, body *types.BanUserParams) error
 	KickUser(ctx context.Context, serverID string, body *types.KickUserParams) error
@@ -103,7 +203,64 @@ type Service interface {
 	GetLatestMessagesRead(ctx context.Context, userID string) ([]db.GetLatestMessagesReadRow, error)
 	GetLatestMessagesSent(ctx context.Context, channelIDs []string) ([]db.GetLatestMessagesSentRow, error)
 	GetRoleMembers(ctx context.Context, roleID string) ([]string, error)
-}
+	GetServerMemberIDs(ctx context.Context, serverID string, userIDs []string) ([]string, error)
+	GetKanbanBoard(ctx context.Context, channelID string) (*types.KanbanBoard, error)
+	GetKanbanCard(ctx context.Context, channelID, cardID string) (db.KanbanCard, error)
+	CreateKanbanColumn(ctx context.Context, channelID string, body *types.CreateKanbanColumnParams) (db.KanbanColumn, error)
+	UpdateKanbanColumn(ctx context.Context, channelID, columnID string, body *types.UpdateKanbanColumnParams) (db.KanbanColumn, error)
+	MoveKanbanColumn(ctx context.Context, channelID, columnID string, position int) (db.KanbanColumn, error)
+	DeleteKanbanColumn(ctx context.Context, channelID, columnID string) (db.KanbanColumn, error)
+	CreateKanbanCard(ctx context.Context, channelID, authorID string, body *types.CreateKanbanCardParams) (db.KanbanCard, error)
+	UpdateKanbanCard(ctx context.Context, channelID, cardID string, body *types.UpdateKanbanCardParams) (db.KanbanCard, error)
+	MoveKanbanCard(ctx context.Context, channelID, cardID string, body *types.MoveKanbanCardParams) (db.KanbanCard, error)
+	DeleteKanbanCard(ctx context.Context, channelID, cardID string) (db.KanbanCard, error)
+	GetUserDevices(ctx context.Context, userID string) ([]db.UserDevice, error)
+	GetUserDevice(ctx context.Context, userID, deviceID string) (db.UserDevice, error)
+	GetDevicesOfUsers(ctx context.Context, userIDs []string) ([]db.UserDevice, error)
+	CountUserDevices(ctx context.Context, userID string) (int64, error)
+	CreateUserDevice(ctx context.Context, userID string, body *types.RegisterDeviceParams) (db.UserDevice, error)
+	DeleteUserDevice(ctx context.Context, userID, deviceID string) (db.UserDevice, error)
+	GetChannelMemberIDs(ctx context.Context, channelID string) ([]string, error)
+	GetUserE2EEChannels(ctx context.Context, userID string) ([]db.Channel, error)
+	EnableChannelE2EE(ctx context.Context, channelID string) (db.Channel, error)
+	RequireChannelsRekey(ctx context.Context, channelIDs []string) ([]db.Channel, error)
+	RequireServerRekey(ctx context.Context, serverID string) ([]db.Channel, error)
+	RequireRoleRekey(ctx context.Context, serverID, roleID string) ([]db.Channel, error)
+	GetCurrentChannelKey(ctx context.Context, channelID string) (db.ChannelKey, error)
+	GetChannelKey(ctx context.Context, channelID, keyID string) (db.ChannelKey, error)
+	GetChannelKeys(ctx context.Context, channelID, deviceID string) ([]db.GetChannelKeysRow, error)
+	RotateChannelKey(ctx context.Context, channelID, deviceID string, envelopes []types.SealedKey) (db.ChannelKey, error)
+	AddChannelKeyEnvelopes(ctx context.Context, keyID, deviceID string, envelopes []types.SealedKey) error
+	GetGroupDMs(ctx context.Context, userID string) ([]db.Channel, error)
+	GetGroupDM(ctx context.Context, channelID string) (db.Channel, error)
+	CountGroupDMs(ctx context.Context, userID string) (int64, error)
+	CreateGroupDM(ctx context.Context, ownerID string, body *types.CreateGroupDMParams) (db.Channel, error)
+	RenameGroupDM(ctx context.Context, channelID, name string) (db.Channel, error)
+	AddGroupDMUsers(ctx context.Context, channelID string, userIDs []string) (db.Channel, error)
+	RemoveGroupDMUser(ctx context.Context, channelID, userID string) (db.Channel, error)
+	GetDMReachableUserIDs(ctx context.Context, userID string, userIDs []string) ([]string, error)
+	UpdatePrivacy(ctx context.Context, userID string, body *types.UpdatePrivacyParams) (db.User, error)
+	GetNotifications(ctx context.Context, userID string, params *types.GetNotificationsParams) ([]db.GetNotificationsRow, error)
+	CountUnreadNotifications(ctx context.Context, userID string) (int64, error)
+	GetMessageNotificationTargets(ctx context.Context, message db.Message, replyAuthorID string, everyone bool) ([]db.GetMessageNotificationTargetsRow, error)
+	CreateNotifications(ctx context.Context, userIDs, notificationTypes []string, params db.CreateNotificationsParams) ([]db.Notification, error)
+	ReadNotifications(ctx context.Context, userID string, ids []string) ([]string, error)
+	ReadAllNotifications(ctx context.Context, userID, serverID string) ([]string, error)
+	DeleteFriendshipNotifications(ctx context.Context, friendshipID string) error
+}
+
+// ErrKanbanFull is returned when a kanban board or column can't take more columns or cards.
+var ErrKanbanFull = errors.New("the board is full")
+
+var (
+	// ErrGroupDMFull is returned when a group direct message can't take more participants.
+	ErrGroupDMFull = errors.New("the group is full")
+	// ErrNotParticipant is returned when removing a user who isn't in a group direct message.
+	ErrNotParticipant = errors.New("not a participant of the group")
+)
+
+// ErrNotBlocked is returned when unblocking a user who isn't blocked.
+var ErrNotBlocked = errors.New("the user is not blocked")
 
 type service struct {
 	db      *pgxpool.Pool
@@ -182,689 +339,2204 @@ func (s *service) GetUserByID(ctx context.Context, userID string) (db.User, erro
 	return s.queries.GetUserById(ctx, userID)
 }
 
-func (s *service) UpdateUserAvatarNBanner(ctx context.Context, userID string, avatarURL, bannerURL *string) (db.User, error) {
-	var avatar pgtype.Text
-	var banner pgtype.Text
-
-	if avatarURL != nil {
-		avatar = pgtype.Text{String: *avatarURL, Valid: true}
-	} else {
-		avatar = pgtype.Text{Valid: false}
-	}
-
-	if bannerURL != nil {
-		banner = pgtype.Text{String: *bannerURL, Valid: true}
-	} else {
-		banner = pgtype.Text{Valid: false}
-	}
-
-	return s.queries.UpdateUserAvatarNBanner(ctx, db.UpdateUserAvatarNBannerParams{
-		ID:     userID,
-		Avatar: avatar,
-		Banner: banner,
-	})
-}
-
-func (s *service) GetUserServers(ctx context.Context, userID string) ([]db.GetServersFromUserRow, error) {
-	return s.queries.GetServersFromUser(ctx, userID)
+func (s *service) UsernameExists(ctx context.Context, username string) (bool, error) {
+	return s.queries.UsernameExists(ctx, username)
 }
 
-func (s *service) GetUserServerIDs(ctx context.Context, userID string) ([]string, error) {
-	return s.queries.GetServerIDsFromUser(ctx, userID)
+func (s *service) GetUserByIdentity(ctx context.Context, provider, subject string) (db.User, error) {
+	return s.queries.GetUserByIdentity(ctx, db.GetUserByIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+	})
 }
 
-func (s *service) UpdateUserEmail(ctx context.Context, userID string, body *types.UpdateEmailParams) (db.User, error) {
-	return s.queries.UpdateUserEmail(ctx, db.UpdateUserEmailParams{
-		ID:    userID,
-		Email: body.Email,
+func (s *service) GetIdentity(ctx context.Context, provider, subject string) (db.UserIdentity, error) {
+	return s.queries.GetIdentity(ctx, db.GetIdentityParams{
+		Provider: provider,
+		Subject:  subject,
 	})
 }
 
-func (s *service) UpdateUserPassword(ctx context.Context, userID string, hashedPassword string) error {
-	return s.queries.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
-		ID:       userID,
-		Password: hashedPassword,
-	})
+func (s *service) GetUserIdentities(ctx context.Context, userID string) ([]db.GetUserIdentitiesRow, error) {
+	return s.queries.GetUserIdentities(ctx, userID)
 }
 
-func (s *service) UpdateUserProfile(ctx context.Context, userID string, body *types.UpdateProfileParams) (db.User, error) {
-	return s.queries.UpdateUserProfile(ctx, db.UpdateUserProfileParams{
-		ID:          userID,
-		Username:    body.Username,
-		DisplayName: body.DisplayName,
-		AboutMe:     body.About,
-		Facts:       body.Facts,
-		Links:       body.Links,
-	})
+func (s *service) CountUserIdentities(ctx context.Context, userID string) (int64, error) {
+	return s.queries.CountUserIdentities(ctx, userID)
 }
 
-func (s *service) CreateServer(ctx context.Context, ownerID string, body *types.CreateServerParams, avatarURL *string) (*db.Server, error) {
+func (s *service) CreateOAuthUser(ctx context.Context, body *types.CreateOAuthUserParams) (db.User, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return db.User{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	qtx := s.queries.WithTx(tx)
 
-	server, err := qtx.CreateServer(ctx, db.CreateServerParams{
+	// Accounts created through a provider have no password until the user sets one.
+	user, err := qtx.CreateUser(ctx, db.CreateUserParams{
 		ID:          cuid2.Generate(),
-		Name:        body.Name,
-		Description: body.Description,
-		Avatar:      pgtype.Text{String: *avatarURL, Valid: true},
-		MainColor:   pgtype.Text{String: "#121214", Valid: true},
-		OwnerID:     ownerID,
-		Public:      body.Public,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	role, err := qtx.UpsertRole(ctx, db.UpsertRoleParams{
-		ID:        cuid2.Generate(),
-		Position:  999,
-		ServerID:  server.ID,
-		Name:      "Default Permissions",
-		Color:     "#ffffff",
-		Abilities: []string{"VIEW_CHANNELS", "CREATE_INVITE", "CHANGE_NICKNAME", "SEND_MESSAGES", "ATTACH_FILES", "ADD_REACTIONS", "USE_PERSONAL_EMOJIS", "CONNECT", "SPEAK", "VIDEO"},
+		Email:       body.Email,
+		Username:    body.Username,
+		DisplayName: body.DisplayName,
+		Avatar:      pgtype.Text{String: randomDefaultAvatar(), Valid: true},
 	})
 	if err != nil {
-		return nil, err
+		return db.User{}, err
 	}
 
-	_, err = qtx.JoinServer(ctx, db.JoinServerParams{
+	_, err = qtx.CreateIdentity(ctx, db.CreateIdentityParams{
 		ID:       cuid2.Generate(),
-		UserID:   ownerID,
-		ServerID: server.ID,
-		Position: int32(body.Position),
-		Roles:    []string{role.ID},
+		UserID:   user.ID,
+		Provider: body.Provider,
+		Subject:  body.Subject,
+		Email:    pgtype.Text{String: body.Email, Valid: body.Email != ""},
 	})
 	if err != nil {
-		return nil, err
+		return db.User{}, err
 	}
 
-	return &server, tx.Commit(ctx)
+	return user, tx.Commit(ctx)
 }
 
-func (s *service) GetServersIDFromUser(ctx context.Context, userID string) ([]string, error) {
-	return s.queries.GetServersIDFromUser(ctx, userID)
-}
+func (s *service) LinkIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	_, err := s.queries.CreateIdentity(ctx, db.CreateIdentityParams{
+		ID:       cuid2.Generate(),
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    pgtype.Text{String: email, Valid: email != ""},
+	})
 
-func (s *service) CheckInvite(ctx context.Context, inviteCode string) (string, error) {
-	return s.queries.CheckInvite(ctx, inviteCode)
+	return err
 }
 
-func (s *service) CreateInvite(ctx context.Context, userID, serverID string) (string, error) {
-	generateInviteCode, err := cuid2.Init(cuid2.WithLength(8))
-	if err != nil {
-		return "", err
-	}
-
-	invite, err := s.queries.GetOrCreateInvite(ctx, db.GetOrCreateInviteParams{
-		ID:        cuid2.Generate(),
-		CreatorID: userID,
-		ServerID:  serverID,
-		InviteID:  generateInviteCode(),
-		ExpireAt:  time.Now().Add(7 * 24 * time.Hour),
+func (s *service) UnlinkIdentity(ctx context.Context, userID, provider string) (int64, error) {
+	return s.queries.DeleteIdentity(ctx, db.DeleteIdentityParams{
+		UserID:   userID,
+		Provider: provider,
 	})
-	if err != nil {
-		return "", err
-	}
-
-	return invite.(string), nil
 }
 
-func (s *service) JoinServer(ctx context.Context, serverID string, userID string, position int) (*db.JoinServerRow, []db.ChannelCategory, []db.Channel, []db.GetRolesFromServerRow, []db.GetLatestMessagesSentRow, error) {
-	tx, err := s.db.Begin(ctx)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
-	defer tx.Rollback(ctx)
-
-	qtx := s.queries.WithTx(tx)
+func (s *service) CreateBot(ctx context.Context, ownerID string, body *types.CreateBotParams) (db.User, error) {
+	botID := cuid2.Generate()
 
-	roleID, err := qtx.GetDefaultRoleID(ctx, serverID)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+	// Bots never sign in with a password, the address only has to be unique.
+	return s.queries.CreateBot(ctx, db.CreateBotParams{
+		ID:          botID,
+		Email:       botID + "@bot.invalid",
+		Username:    body.Username,
+		DisplayName: body.DisplayName,
+		Avatar:      pgtype.Text{String: randomDefaultAvatar(), Valid: true},
+		BotOwnerID:  pgtype.Text{String: ownerID, Valid: true},
+	})
+}
 
-	join, err := qtx.JoinServer(ctx, db.JoinServerParams{
-		ID:       cuid2.Generate(),
-		UserID:   userID,
-		ServerID: serverID,
-		Position: int32(position),
-		Roles:    []string{roleID},
+func (s *service) GetBot(ctx context.Context, botID, ownerID string) (db.User, error) {
+	return s.queries.GetBot(ctx, db.GetBotParams{
+		ID:         botID,
+		BotOwnerID: pgtype.Text{String: ownerID, Valid: true},
 	})
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+}
 
-	categories, err := qtx.GetCategoriesFromServer(ctx, serverID)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+func (s *service) GetBots(ctx context.Context, ownerID string) ([]db.GetBotsRow, error) {
+	return s.queries.GetBots(ctx, pgtype.Text{String: ownerID, Valid: true})
+}
 
-	channels, err := qtx.GetChannelsFromServer(ctx, serverID)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+func (s *service) CountBots(ctx context.Context, ownerID string) (int64, error) {
+	return s.queries.CountBots(ctx, pgtype.Text{String: ownerID, Valid: true})
+}
 
-	roles, err := qtx.GetRolesFromServer(ctx, serverID)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+func (s *service) DeleteBot(ctx context.Context, botID, ownerID string) (int64, error) {
+	return s.queries.DeleteBot(ctx, db.DeleteBotParams{
+		ID:         botID,
+		BotOwnerID: pgtype.Text{String: ownerID, Valid: true},
+	})
+}
 
-	channelIDs := make([]string, len(channels))
-	for i, channel := range channels {
-		channelIDs[i] = channel.ID
+func (s *service) CreateAccessToken(ctx context.Context, userID, createdBy, tokenHash string, body *types.CreateTokenParams) (db.CreateAccessTokenRow, error) {
+	var expiresAt pgtype.Timestamptz
+	if body.ExpiresAt != nil {
+		expiresAt = pgtype.Timestamptz{Time: *body.ExpiresAt, Valid: true}
 	}
 
-	latestMessagesSent, err := qtx.GetLatestMessagesSent(ctx, channelIDs)
-	if err != nil {
-		return nil, nil, nil, nil, nil, err
-	}
+	return s.queries.CreateAccessToken(ctx, db.CreateAccessTokenParams{
+		ID:        cuid2.Generate(),
+		UserID:    userID,
+		CreatedBy: createdBy,
+		Name:      body.Name,
+		TokenHash: tokenHash,
+		Scopes:    body.Scopes,
+		RateLimit: body.RateLimit,
+		ExpiresAt: expiresAt,
+	})
+}
 
-	return &join, categories, channels, roles, latestMessagesSent, tx.Commit(ctx)
+func (s *service) GetAccessTokenByHash(ctx context.Context, tokenHash string) (db.AccessToken, error) {
+	return s.queries.GetAccessTokenByHash(ctx, tokenHash)
 }
 
-func (s *service) UpdateServerAvatarNBanner(ctx context.Context, serverID string, avatarURL, bannerURL *string) error {
-	var avatar pgtype.Text
-	var banner pgtype.Text
+func (s *service) GetAccessTokens(ctx context.Context, createdBy string) ([]db.GetAccessTokensRow, error) {
+	return s.queries.GetAccessTokens(ctx, createdBy)
+}
 
-	if avatarURL != nil {
-		avatar = pgtype.Text{String: *avatarURL, Valid: true}
-	} else {
-		avatar = pgtype.Text{Valid: false}
-	}
+func (s *service) GetAccessTokenHashes(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.GetAccessTokenHashes(ctx, userID)
+}
 
-	if bannerURL != nil {
-		banner = pgtype.Text{String: *bannerURL, Valid: true}
-	} else {
-		banner = pgtype.Text{Valid: false}
-	}
+func (s *service) TouchAccessToken(ctx context.Context, tokenID string) error {
+	return s.queries.TouchAccessToken(ctx, tokenID)
+}
 
-	return s.queries.UpdateServerAvatarNBanner(ctx, db.UpdateServerAvatarNBannerParams{
-		ID:     serverID,
-		Avatar: avatar,
-		Banner: banner,
+func (s *service) DeleteAccessToken(ctx context.Context, tokenID, createdBy string) (string, error) {
+	return s.queries.DeleteAccessToken(ctx, db.DeleteAccessTokenParams{
+		ID:        tokenID,
+		CreatedBy: createdBy,
 	})
 }
 
-func (s *service) UpdateServerProfile(ctx context.Context, serverID string, body *types.UpdateServerProfileParams) error {
-	return s.queries.UpdateServerProfile(ctx, db.UpdateServerProfileParams{
-		ID:          serverID,
-		Name:        body.Name,
-		Description: body.Description,
-		Public:      body.Public,
+func (s *service) CreateWebhook(ctx context.Context, serverID, userID, secret string, body *types.CreateWebhookParams) (db.Webhook, error) {
+	return s.queries.CreateWebhook(ctx, db.CreateWebhookParams{
+		ID:        cuid2.Generate(),
+		ServerID:  serverID,
+		CreatedBy: pgtype.Text{String: userID, Valid: true},
+		Url:       body.URL,
+		Secret:    secret,
+		Events:    body.Events,
 	})
 }
 
-func (s *service) GetServer(ctx context.Context, serverID string) (db.Server, error) {
-	return s.queries.GetServer(ctx, serverID)
+func (s *service) GetServerWebhooks(ctx context.Context, serverID string) ([]db.GetServerWebhooksRow, error) {
+	return s.queries.GetServerWebhooks(ctx, serverID)
 }
 
-func (s *service) LeaveServer(ctx context.Context, serverID string, userID string) error {
-	return s.queries.LeaveServer(ctx, db.LeaveServerParams{
+func (s *service) CountServerWebhooks(ctx context.Context, serverID string) (int64, error) {
+	return s.queries.CountServerWebhooks(ctx, serverID)
+}
+
+func (s *service) UpdateWebhook(ctx context.Context, serverID, webhookID string, body *types.EditWebhookParams) (db.UpdateWebhookRow, error) {
+	return s.queries.UpdateWebhook(ctx, db.UpdateWebhookParams{
+		ID:       webhookID,
 		ServerID: serverID,
-		UserID:   userID,
+		Url:      body.URL,
+		Events:   body.Events,
+		Active:   body.Active,
 	})
 }
 
-func (s *service) DeleteServer(ctx context.Context, userID, serverID string) error {
-	return s.queries.DeleteServer(ctx, db.DeleteServerParams{
-		ID:      serverID,
-		OwnerID: userID,
+func (s *service) DeleteWebhook(ctx context.Context, serverID, webhookID string) (int64, error) {
+	return s.queries.DeleteWebhook(ctx, db.DeleteWebhookParams{
+		ID:       webhookID,
+		ServerID: serverID,
 	})
 }
 
-func (s *service) GetChannelsFromServers(ctx context.Context, serverIDs []string) ([]db.Channel, error) {
-	return s.queries.GetChannelsFromServers(ctx, serverIDs)
+func (s *service) GetWebhooksForEvent(ctx context.Context, serverID, event string) ([]string, error) {
+	return s.queries.GetWebhooksForEvent(ctx, db.GetWebhooksForEventParams{
+		ServerID: serverID,
+		Event:    event,
+	})
 }
 
-func (s *service) GetCategoriesFromServers(ctx context.Context, serverIDs []string) ([]db.ChannelCategory, error) {
-	return s.queries.GetCategoriesFromServers(ctx, serverIDs)
+func (s *service) CreateWebhookDelivery(ctx context.Context, webhookID, event string, payload json.RawMessage) error {
+	return s.queries.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+		ID:        cuid2.Generate(),
+		WebhookID: webhookID,
+		Event:     event,
+		Payload:   payload,
+	})
 }
 
-func (s *service) GetRolesFromServers(ctx context.Context, serverIDs []string) ([]db.GetRolesFromServersRow, error) {
-	return s.queries.GetRolesFromServers(ctx, serverIDs)
+func (s *service) ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]db.ClaimWebhookDeliveriesRow, error) {
+	return s.queries.ClaimWebhookDeliveries(ctx, limit)
 }
 
-func (s *service) CreateCategory(ctx context.Context, body *types.CreateCategoryParams) (db.ChannelCategory, error) {
-	return s.queries.CreateCategory(ctx, db.CreateCategoryParams{
-		ID:       cuid2.Generate(),
-		Position: int32(body.Position),
-		ServerID: body.ServerID,
-		Name:     body.Name,
-		Users:    body.Users,
-		Roles:    body.Roles,
-		E2ee:     body.E2EE,
+func (s *service) RecordWebhookAttempt(ctx context.Context, deliveryID, status string, statusCode int, errMessage string, nextAttemptAt time.Time) error {
+	return s.queries.RecordWebhookAttempt(ctx, db.RecordWebhookAttemptParams{
+		ID:             deliveryID,
+		Status:         status,
+		LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      pgtype.Text{String: errMessage, Valid: errMessage != ""},
+		NextAttemptAt:  nextAttemptAt,
 	})
 }
 
-func (s *service) PinChannel(ctx context.Context, channelID, userID string, body *types.PinChannelParams) error {
-	return s.queries.PinChannel(ctx, db.PinChannelParams{
-		ID:        cuid2.Generate(),
-		Position:  int32(body.Position),
-		ServerID:  body.ServerID,
-		ChannelID: channelID,
-		UserID:    userID,
+func (s *service) GetWebhookDeliveries(ctx context.Context, serverID, webhookID string) ([]db.GetWebhookDeliveriesRow, error) {
+	return s.queries.GetWebhookDeliveries(ctx, db.GetWebhookDeliveriesParams{
+		WebhookID: webhookID,
+		ServerID:  serverID,
 	})
 }
 
-func (s *service) CreateChannel(ctx context.Context, body *types.CreateChannelParams) (db.Channel, error) {
-	return s.queries.CreateChannel(ctx, db.CreateChannelParams{
-		ID:          cuid2.Generate(),
-		Position:    int32(body.Position),
-		CategoryID:  pgtype.Text{String: body.CategoryID, Valid: true},
-		ServerID:    body.ServerID,
-		Name:        body.Name,
-		Description: pgtype.Text{String: body.Description, Valid: true},
-		Type:        body.Type,
-		E2ee:        body.E2EE,
-		Users:       body.Users,
-		Roles:       body.Roles,
-	})
+func (s *service) PruneWebhookDeliveries(ctx context.Context) error {
+	return s.queries.PruneWebhookDeliveries(ctx)
 }
 
-func (s *service) DeleteChannel(ctx context.Context, channelID string) error {
-	return s.queries.DeleteChannel(ctx, channelID)
+func (s *service) GetChannel(ctx context.Context, channelID string) (db.Channel, error) {
+	return s.queries.GetChannel(ctx, channelID)
 }
 
-func (s *service) DeleteCategory(ctx context.Context, categoryID string) error {
-	return s.queries.DeleteCategory(ctx, categoryID)
+func (s *service) GetReadableChannelIDs(ctx context.Context, serverID, userID string) ([]string, error) {
+	return s.queries.GetReadableChannelIDs(ctx, db.GetReadableChannelIDsParams{
+		ServerID: serverID,
+		UserID:   userID,
+	})
 }
 
-func (s *service) UpsertRole(ctx context.Context, body *types.CreateRoleParams) (db.Role, error) {
-	return s.queries.UpsertRole(ctx, db.UpsertRoleParams{
-		ID:        body.RoleID,
-		Position:  int32(body.Position),
-		ServerID:  body.ServerID,
-		Name:      body.Name,
-		Color:     body.Color,
-		Abilities: body.Abilities,
-	})
+func (s *service) GetCategory(ctx context.Context, categoryID string) (db.ChannelCategory, error) {
+	return s.queries.GetCategory(ctx, categoryID)
 }
 
-func (s *service) DeleteRole(ctx context.Context, body *types.DeleteRoleParams) error {
+func (s *service) CreateIncomingWebhook(ctx context.Context, serverID, userID, tokenHash string, body *types.CreateIncomingWebhookParams) (db.CreateIncomingWebhookRow, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return err
+		return db.CreateIncomingWebhookRow{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	qtx := s.queries.WithTx(tx)
 
-	if err = qtx.DeleteRole(ctx, body.RoleID); err != nil {
-		return err
+	// Messages need an author, every incoming webhook posts as its own bot user. It has no owner
+	// so it can't be managed as a regular bot, and it outlives the webhook to keep its messages.
+	botID := cuid2.Generate()
+	bot, err := qtx.CreateBot(ctx, db.CreateBotParams{
+		ID:          botID,
+		Email:       botID + "@bot.invalid",
+		Username:    "wh_" + cuid2.Generate()[:16],
+		DisplayName: body.Name,
+		Avatar:      pgtype.Text{String: randomDefaultAvatar(), Valid: true},
+	})
+	if err != nil {
+		return db.CreateIncomingWebhookRow{}, err
 	}
 
-	if err = qtx.RemoveRoleFromAllMembers(ctx, body.RoleID); err != nil {
+	webhook, err := qtx.CreateIncomingWebhook(ctx, db.CreateIncomingWebhookParams{
+		ID:        cuid2.Generate(),
+		ServerID:  serverID,
+		ChannelID: body.ChannelID,
+		UserID:    bot.ID,
+		CreatedBy: pgtype.Text{String: userID, Valid: true},
+		Name:      body.Name,
+		TokenHash: tokenHash,
+	})
+	if err != nil {
+		return db.CreateIncomingWebhookRow{}, err
+	}
+
+	return webhook, tx.Commit(ctx)
+}
+
+func (s *service) GetIncomingWebhook(ctx context.Context, webhookID string) (db.IncomingWebhook, error) {
+	return s.queries.GetIncomingWebhook(ctx, webhookID)
+}
+
+func (s *service) GetServerIncomingWebhooks(ctx context.Context, serverID string) ([]db.GetServerIncomingWebhooksRow, error) {
+	return s.queries.GetServerIncomingWebhooks(ctx, serverID)
+}
+
+func (s *service) CountServerIncomingWebhooks(ctx context.Context, serverID string) (int64, error) {
+	return s.queries.CountServerIncomingWebhooks(ctx, serverID)
+}
+
+func (s *service) RotateIncomingWebhookToken(ctx context.Context, serverID, webhookID, tokenHash string) (int64, error) {
+	return s.queries.RotateIncomingWebhookToken(ctx, db.RotateIncomingWebhookTokenParams{
+		ID:        webhookID,
+		ServerID:  serverID,
+		TokenHash: tokenHash,
+	})
+}
+
+func (s *service) DeleteIncomingWebhook(ctx context.Context, serverID, webhookID string) (int64, error) {
+	return s.queries.DeleteIncomingWebhook(ctx, db.DeleteIncomingWebhookParams{
+		ID:       webhookID,
+		ServerID: serverID,
+	})
+}
+
+// SetCommands replaces the commands of a bot in a server, so bots can register their whole set at once.
+func (s *service) SetCommands(ctx context.Context, serverID, botID string, commands []types.CommandDefinition) ([]db.Command, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.DeleteBotCommands(ctx, db.DeleteBotCommandsParams{
+		ServerID: serverID,
+		BotID:    botID,
+	}); err != nil {
+		return nil, err
+	}
+
+	created := make([]db.Command, 0, len(commands))
+	for _, command := range commands {
+		options, err := json.Marshal(command.Options)
+		if err != nil {
+			return nil, err
+		}
+		if command.Options == nil {
+			options = json.RawMessage("[]")
+		}
+
+		c, err := qtx.CreateCommand(ctx, db.CreateCommandParams{
+			ID:          cuid2.Generate(),
+			ServerID:    serverID,
+			BotID:       botID,
+			Name:        command.Name,
+			Description: command.Description,
+			Options:     options,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		created = append(created, c)
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+func (s *service) GetCommand(ctx context.Context, commandID string) (db.Command, error) {
+	return s.queries.GetCommand(ctx, commandID)
+}
+
+func (s *service) GetServerCommands(ctx context.Context, serverID string) ([]db.GetServerCommandsRow, error) {
+	return s.queries.GetServerCommands(ctx, serverID)
+}
+
+func (s *service) UpsertInteractionEndpoint(ctx context.Context, botID, url, secret string) error {
+	return s.queries.UpsertInteractionEndpoint(ctx, db.UpsertInteractionEndpointParams{
+		BotID:  botID,
+		Url:    url,
+		Secret: secret,
+	})
+}
+
+func (s *service) GetInteractionEndpoint(ctx context.Context, botID string) (db.InteractionEndpoint, error) {
+	return s.queries.GetInteractionEndpoint(ctx, botID)
+}
+
+func (s *service) DeleteInteractionEndpoint(ctx context.Context, botID string) (int64, error) {
+	return s.queries.DeleteInteractionEndpoint(ctx, botID)
+}
+
+func (s *service) CreateUpload(ctx context.Context, upload db.CreateUploadParams) (db.Upload, error) {
+	return s.queries.CreateUpload(ctx, upload)
+}
+
+func (s *service) GetUpload(ctx context.Context, uploadID, userID string) (db.Upload, error) {
+	return s.queries.GetUpload(ctx, db.GetUploadParams{
+		ID:     uploadID,
+		UserID: userID,
+	})
+}
+
+func (s *service) CountPendingUploads(ctx context.Context, userID string) (int64, error) {
+	return s.queries.CountPendingUploads(ctx, userID)
+}
+
+func (s *service) ConfirmUpload(ctx context.Context, uploadID, userID, key string, metadata json.RawMessage) (int64, error) {
+	return s.queries.ConfirmUpload(ctx, db.ConfirmUploadParams{
+		ID:       uploadID,
+		UserID:   userID,
+		Key:      key,
+		Metadata: metadata,
+	})
+}
+
+// ClaimUploads marks confirmed uploads as attached, either all of them or none so one upload
+// can't end up in two messages.
+func (s *service) ClaimUploads(ctx context.Context, userID string, uploadIDs []string) ([]db.Upload, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	uploads, err := s.queries.WithTx(tx).ClaimUploads(ctx, db.ClaimUploadsParams{
+		Ids:    uploadIDs,
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(uploads) != len(uploadIDs) {
+		return nil, fmt.Errorf("%d of %d uploads can't be attached", len(uploadIDs)-len(uploads), len(uploadIDs))
+	}
+
+	return uploads, tx.Commit(ctx)
+}
+
+func (s *service) SetUploadsMessage(ctx context.Context, messageID string, uploadIDs []string) error {
+	return s.queries.SetUploadsMessage(ctx, db.SetUploadsMessageParams{
+		MessageID: pgtype.Text{String: messageID, Valid: true},
+		Ids:       uploadIDs,
+	})
+}
+
+func (s *service) GetOrphanedUploads(ctx context.Context, limit int32) ([]db.GetOrphanedUploadsRow, error) {
+	return s.queries.GetOrphanedUploads(ctx, limit)
+}
+
+func (s *service) PruneAttachedUploads(ctx context.Context) (int64, error) {
+	return s.queries.PruneAttachedUploads(ctx)
+}
+
+func (s *service) DeleteUpload(ctx context.Context, uploadID string) error {
+	return s.queries.DeleteUpload(ctx, uploadID)
+}
+
+func (s *service) CreateAttachments(ctx context.Context, owner types.AttachmentOwner, keys []string, sizes []int64) error {
+	return createAttachments(ctx, s.queries, owner, keys, sizes)
+}
+
+// ReplaceAttachments swaps the files of an owner, the previous ones get queued for deletion.
+func (s *service) ReplaceAttachments(ctx context.Context, owner types.AttachmentOwner, keys []string, sizes []int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+	if err := qtx.DeleteOwnerAttachments(ctx, db.DeleteOwnerAttachmentsParams{
+		Kind:    string(owner.Kind),
+		OwnerID: owner.ID,
+	}); err != nil {
+		return err
+	}
+
+	if err := createAttachments(ctx, qtx, owner, keys, sizes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// createAttachments records one attachment per key, sizes are the bytes each key counts for.
+func createAttachments(ctx context.Context, queries *db.Queries, owner types.AttachmentOwner, keys []string, sizes []int64) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(keys))
+	for i := range keys {
+		ids[i] = cuid2.Generate()
+	}
+
+	params := db.CreateAttachmentsParams{
+		Ids:           ids,
+		Keys:          keys,
+		Kind:          string(owner.Kind),
+		Sizes:         sizes,
+		QuotaUserID:   pgtype.Text{String: owner.Quota.UserID, Valid: owner.Quota.UserID != ""},
+		QuotaServerID: pgtype.Text{String: owner.Quota.ServerID, Valid: owner.Quota.ServerID != ""},
+	}
+
+	ownerID := pgtype.Text{String: owner.ID, Valid: true}
+	switch owner.Kind {
+	case types.AttachmentMessage, types.AttachmentEmbed:
+		params.MessageID = ownerID
+	case types.AttachmentUserAvatar, types.AttachmentUserBanner:
+		params.UserID = ownerID
+	case types.AttachmentServerAvatar, types.AttachmentServerBanner:
+		params.ServerID = ownerID
+	case types.AttachmentEmoji:
+		params.EmojiID = ownerID
+	case types.AttachmentServerEmoji:
+		params.ServerEmojiID = ownerID
+	default:
+		return fmt.Errorf("unknown attachment kind %q", owner.Kind)
+	}
+
+	return queries.CreateAttachments(ctx, params)
+}
+
+func (s *service) GetUserStorageUsage(ctx context.Context, userID string) (int64, error) {
+	return s.queries.GetUserStorageUsage(ctx, userID)
+}
+
+func (s *service) GetServerStorageUsage(ctx context.Context, serverID string) (db.GetServerStorageUsageRow, error) {
+	return s.queries.GetServerStorageUsage(ctx, serverID)
+}
+
+func (s *service) GetUploadsSize(ctx context.Context, userID string, uploadIDs []string) (int64, error) {
+	return s.queries.GetUploadsSize(ctx, db.GetUploadsSizeParams{
+		Ids:    uploadIDs,
+		UserID: userID,
+	})
+}
+
+// GetReferencedKeys returns the keys, among the given ones, still used by an attachment, an upload
+// or a quarantined file.
+func (s *service) GetReferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	return s.queries.GetReferencedKeys(ctx, keys)
+}
+
+func (s *service) CreateQuarantinedFile(ctx context.Context, file db.CreateQuarantinedFileParams) (db.QuarantinedFile, error) {
+	return s.queries.CreateQuarantinedFile(ctx, file)
+}
+
+// GetServerModerators returns the owner and the members allowed to manage messages.
+func (s *service) GetServerModerators(ctx context.Context, serverID string) ([]string, error) {
+	return s.queries.GetServerModerators(ctx, serverID)
+}
+
+func (s *service) ClaimFileDeletions(ctx context.Context, limit int32) ([]db.ClaimFileDeletionsRow, error) {
+	return s.queries.ClaimFileDeletions(ctx, limit)
+}
+
+func (s *service) DeleteFileDeletion(ctx context.Context, key string) error {
+	return s.queries.DeleteFileDeletion(ctx, key)
+}
+
+func (s *service) RecordFileDeletionFailure(ctx context.Context, key string, deletionErr error, nextAttempt time.Time) error {
+	return s.queries.RecordFileDeletionFailure(ctx, db.RecordFileDeletionFailureParams{
+		Key:           key,
+		LastError:     deletionErr.Error(),
+		NextAttemptAt: nextAttempt,
+	})
+}
+
+func (s *service) UpdateUserAvatarNBanner(ctx context.Context, userID string, avatarURL, bannerURL *string) (db.User, error) {
+	var avatar pgtype.Text
+	var banner pgtype.Text
+
+	if avatarURL != nil {
+		avatar = pgtype.Text{String: *avatarURL, Valid: true}
+	} else {
+		avatar = pgtype.Text{Valid: false}
+	}
+
+	if bannerURL != nil {
+		banner = pgtype.Text{String: *bannerURL, Valid: true}
+	} else {
+		banner = pgtype.Text{Valid: false}
+	}
+
+	return s.queries.UpdateUserAvatarNBanner(ctx, db.UpdateUserAvatarNBannerParams{
+		ID:     userID,
+		Avatar: avatar,
+		Banner: banner,
+	})
+}
+
+func (s *service) GetUserServers(ctx context.Context, userID string) ([]db.GetServersFromUserRow, error) {
+	return s.queries.GetServersFromUser(ctx, userID)
+}
+
+func (s *service) GetUserServerIDs(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.GetServerIDsFromUser(ctx, userID)
+}
+
+func (s *service) UpdateUserEmail(ctx context.Context, userID string, body *types.UpdateEmailParams) (db.User, error) {
+	return s.queries.UpdateUserEmail(ctx, db.UpdateUserEmailParams{
+		ID:    userID,
+		Email: body.Email,
+	})
+}
+
+func (s *service) UpdateUserPassword(ctx context.Context, userID string, hashedPassword string) error {
+	return s.queries.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		ID:       userID,
+		Password: hashedPassword,
+	})
+}
+
+func (s *service) UpdateUserProfile(ctx context.Context, userID string, body *types.UpdateProfileParams) (db.User, error) {
+	return s.queries.UpdateUserProfile(ctx, db.UpdateUserProfileParams{
+		ID:          userID,
+		Username:    body.Username,
+		DisplayName: body.DisplayName,
+		AboutMe:     body.About,
+		Facts:       body.Facts,
+		Links:       body.Links,
+	})
+}
+
+func (s *service) CreateServer(ctx context.Context, ownerID string, body *types.CreateServerParams, avatarURL *string) (*db.Server, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	server, err := qtx.CreateServer(ctx, db.CreateServerParams{
+		ID:          cuid2.Generate(),
+		Name:        body.Name,
+		Description: body.Description,
+		Avatar:      pgtype.Text{String: *avatarURL, Valid: true},
+		MainColor:   pgtype.Text{String: "#121214", Valid: true},
+		OwnerID:     ownerID,
+		Public:      body.Public,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := qtx.UpsertRole(ctx, db.UpsertRoleParams{
+		ID:        cuid2.Generate(),
+		Position:  999,
+		ServerID:  server.ID,
+		Name:      "Default Permissions",
+		Color:     "#ffffff",
+		Abilities: []string{"VIEW_CHANNELS", "CREATE_INVITE", "CHANGE_NICKNAME", "SEND_MESSAGES", "ATTACH_FILES", "ADD_REACTIONS", "USE_PERSONAL_EMOJIS", "CONNECT", "SPEAK", "VIDEO"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = qtx.JoinServer(ctx, db.JoinServerParams{
+		ID:       cuid2.Generate(),
+		UserID:   ownerID,
+		ServerID: server.ID,
+		Position: int32(body.Position),
+		Roles:    []string{role.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &server, tx.Commit(ctx)
+}
+
+func (s *service) GetServersIDFromUser(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.GetServersIDFromUser(ctx, userID)
+}
+
+func (s *service) CheckInvite(ctx context.Context, inviteCode string) (db.CheckInviteRow, error) {
+	return s.queries.CheckInvite(ctx, inviteCode)
+}
+
+func (s *service) CreateInvite(ctx context.Context, userID, serverID string, body *types.CreateInviteParams) (db.Invite, error) {
+	generateInviteCode, err := cuid2.Init(cuid2.WithLength(8))
+	if err != nil {
+		return db.Invite{}, err
+	}
+
+	var expireAt pgtype.Timestamptz
+	if lifetime := types.InviteExpiries[body.ExpireIn]; lifetime > 0 {
+		expireAt = pgtype.Timestamptz{Time: time.Now().Add(lifetime), Valid: true}
+	}
+
+	return s.queries.CreateInvite(ctx, db.CreateInviteParams{
+		ID:        cuid2.Generate(),
+		CreatorID: userID,
+		ServerID:  serverID,
+		InviteID:  generateInviteCode(),
+		ExpireAt:  expireAt,
+		MaxUses:   int32(body.MaxUses),
+		Temporary: body.Temporary,
+	})
+}
+
+func (s *service) GetInvite(ctx context.Context, inviteCode string) (db.Invite, error) {
+	return s.queries.GetInvite(ctx, inviteCode)
+}
+
+func (s *service) GetServerInvites(ctx context.Context, serverID string) ([]db.GetServerInvitesRow, error) {
+	return s.queries.GetServerInvites(ctx, serverID)
+}
+
+func (s *service) GetInvitePreview(ctx context.Context, inviteCode string) (db.GetInvitePreviewRow, error) {
+	return s.queries.GetInvitePreview(ctx, inviteCode)
+}
+
+func (s *service) DeleteInvite(ctx context.Context, inviteID string) error {
+	return s.queries.DeleteInvite(ctx, inviteID)
+}
+
+func (s *service) SetVanityInvite(ctx context.Context, userID, serverID, code string) (*db.Invite, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.DeleteVanityInvite(ctx, serverID); err != nil {
+		return nil, err
+	}
+
+	if code == "" {
+		return nil, tx.Commit(ctx)
+	}
+
+	invite, err := qtx.CreateInvite(ctx, db.CreateInviteParams{
+		ID:        cuid2.Generate(),
+		CreatorID: userID,
+		ServerID:  serverID,
+		InviteID:  code,
+		Vanity:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invite, tx.Commit(ctx)
+}
+
+func (s *service) DeleteTemporaryMemberships(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.DeleteTemporaryMemberships(ctx, userID)
+}
+
+func (s *service) JoinServer(ctx context.Context, serverID string, userID string, position int, inviteCode string) (*db.JoinServerRow, []db.ChannelCategory, []db.Channel, []db.GetRolesFromServerRow, []db.GetLatestMessagesSentRow, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	// The use is only counted when the join goes through, the last one can't be taken twice.
+	var temporary bool
+	if inviteCode != "" {
+		invite, err := qtx.UseInvite(ctx, inviteCode)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		if invite.ServerID != serverID {
+			return nil, nil, nil, nil, nil, fmt.Errorf("invite %s is for another server", inviteCode)
+		}
+		temporary = invite.Temporary
+	}
+
+	roleID, err := qtx.GetDefaultRoleID(ctx, serverID)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	join, err := qtx.JoinServer(ctx, db.JoinServerParams{
+		ID:        cuid2.Generate(),
+		UserID:    userID,
+		ServerID:  serverID,
+		Position:  int32(position),
+		Roles:     []string{roleID},
+		Temporary: temporary,
+	})
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	categories, err := qtx.GetCategoriesFromServer(ctx, serverID)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	channels, err := qtx.GetChannelsFromServer(ctx, serverID)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	roles, err := qtx.GetRolesFromServer(ctx, serverID)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	channelIDs := make([]string, len(channels))
+	for i, channel := range channels {
+		channelIDs[i] = channel.ID
+	}
+
+	latestMessagesSent, err := qtx.GetLatestMessagesSent(ctx, channelIDs)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	return &join, categories, channels, roles, latestMessagesSent, tx.Commit(ctx)
+}
+
+func (s *service) UpdateServerAvatarNBanner(ctx context.Context, serverID string, avatarURL, bannerURL *string) error {
+	var avatar pgtype.Text
+	var banner pgtype.Text
+
+	if avatarURL != nil {
+		avatar = pgtype.Text{String: *avatarURL, Valid: true}
+	} else {
+		avatar = pgtype.Text{Valid: false}
+	}
+
+	if bannerURL != nil {
+		banner = pgtype.Text{String: *bannerURL, Valid: true}
+	} else {
+		banner = pgtype.Text{Valid: false}
+	}
+
+	return s.queries.UpdateServerAvatarNBanner(ctx, db.UpdateServerAvatarNBannerParams{
+		ID:     serverID,
+		Avatar: avatar,
+		Banner: banner,
+	})
+}
+
+func (s *service) UpdateServerProfile(ctx context.Context, serverID string, body *types.UpdateServerProfileParams) error {
+	return s.queries.UpdateServerProfile(ctx, db.UpdateServerProfileParams{
+		ID:          serverID,
+		Name:        body.Name,
+		Description: body.Description,
+		Public:      body.Public,
+		Category:    pgtype.Text{String: body.Category, Valid: body.Category != ""},
+		// A nil slice is sent as NULL and keeps the current tags.
+		Tags: body.Tags,
+	})
+}
+
+func (s *service) DiscoverServers(ctx context.Context, userID string, params *types.DiscoverServersParams) ([]db.DiscoverServersRow, error) {
+	return s.queries.DiscoverServers(ctx, db.DiscoverServersParams{
+		UserID:     userID,
+		Query:      pgtype.Text{String: params.Query, Valid: params.Query != ""},
+		Category:   pgtype.Text{String: params.Category, Valid: params.Category != ""},
+		Tag:        pgtype.Text{String: params.Tag, Valid: params.Tag != ""},
+		Sort:       params.Sort,
+		PageSize:   types.DiscoveryPageSize,
+		PageOffset: int32(params.Offset),
+	})
+}
+
+func (s *service) GetServer(ctx context.Context, serverID string) (db.Server, error) {
+	return s.queries.GetServer(ctx, serverID)
+}
+
+func (s *service) LeaveServer(ctx context.Context, serverID string, userID string) error {
+	return s.queries.LeaveServer(ctx, db.LeaveServerParams{
+		ServerID: serverID,
+		UserID:   userID,
+	})
+}
+
+func (s *service) DeleteServer(ctx context.Context, userID, serverID string) error {
+	return s.queries.DeleteServer(ctx, db.DeleteServerParams{
+		ID:      serverID,
+		OwnerID: userID,
+	})
+}
+
+func (s *service) GetChannelsFromServers(ctx context.Context, serverIDs []string) ([]db.Channel, error) {
+	return s.queries.GetChannelsFromServers(ctx, serverIDs)
+}
+
+func (s *service) GetCategoriesFromServers(ctx context.Context, serverIDs []string) ([]db.ChannelCategory, error) {
+	return s.queries.GetCategoriesFromServers(ctx, serverIDs)
+}
+
+func (s *service) GetRolesFromServers(ctx context.Context, serverIDs []string) ([]db.GetRolesFromServersRow, error) {
+	return s.queries.GetRolesFromServers(ctx, serverIDs)
+}
+
+func (s *service) CreateCategory(ctx context.Context, body *types.CreateCategoryParams) (db.ChannelCategory, error) {
+	return s.queries.CreateCategory(ctx, db.CreateCategoryParams{
+		ID:       cuid2.Generate(),
+		Position: int32(body.Position),
+		ServerID: body.ServerID,
+		Name:     body.Name,
+		Users:    body.Users,
+		Roles:    body.Roles,
+		E2ee:     body.E2EE,
+	})
+}
+
+func (s *service) PinChannel(ctx context.Context, channelID, userID string, body *types.PinChannelParams) error {
+	return s.queries.PinChannel(ctx, db.PinChannelParams{
+		ID:        cuid2.Generate(),
+		Position:  int32(body.Position),
+		ServerID:  body.ServerID,
+		ChannelID: channelID,
+		UserID:    userID,
+	})
+}
+
+func (s *service) CreateChannel(ctx context.Context, body *types.CreateChannelParams) (db.Channel, error) {
+	return s.queries.CreateChannel(ctx, db.CreateChannelParams{
+		ID:          cuid2.Generate(),
+		Position:    int32(body.Position),
+		CategoryID:  pgtype.Text{String: body.CategoryID, Valid: true},
+		ServerID:    body.ServerID,
+		Name:        body.Name,
+		Description: pgtype.Text{String: body.Description, Valid: true},
+		Type:        body.Type,
+		E2ee:        body.E2EE,
+		Users:       body.Users,
+		Roles:       body.Roles,
+	})
+}
+
+func (s *service) DeleteChannel(ctx context.Context, channelID string) error {
+	return s.queries.DeleteChannel(ctx, channelID)
+}
+
+func (s *service) DeleteCategory(ctx context.Context, categoryID string) error {
+	return s.queries.DeleteCategory(ctx, categoryID)
+}
+
+func (s *service) UpsertRole(ctx context.Context, body *types.CreateRoleParams) (db.Role, error) {
+	return s.queries.UpsertRole(ctx, db.UpsertRoleParams{
+		ID:        body.RoleID,
+		Position:  int32(body.Position),
+		ServerID:  body.ServerID,
+		Name:      body.Name,
+		Color:     body.Color,
+		Abilities: body.Abilities,
+	})
+}
+
+func (s *service) DeleteRole(ctx context.Context, body *types.DeleteRoleParams) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err = qtx.DeleteRole(ctx, body.RoleID); err != nil {
+		return err
+	}
+
+	if err = qtx.RemoveRoleFromAllMembers(ctx, body.RoleID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *service) AddRoleMember(ctx context.Context, body *types.ChangeRoleMemberParams) error {
+	return s.queries.GiveRole(ctx, db.GiveRoleParams{
+		ServerID:    body.ServerID,
+		UserID:      body.UserID,
+		ArrayAppend: body.RoleID,
+	})
+}
+
+func (s *service) RemoveRoleMember(ctx context.Context, body *types.ChangeRoleMemberParams) error {
+	return s.queries.RemoveRoleMember(ctx, db.RemoveRoleMemberParams{
+		ServerID:    body.ServerID,
+		UserID:      body.UserID,
+		ArrayRemove: body.RoleID,
+	})
+}
+
+func (s *service) MoveRole(ctx context.Context, body *types.MoveRoleMemberParams) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.MoveRole(ctx, db.MoveRoleParams{
+		ID:       body.MovedRoleID,
+		Position: int32(body.To),
+	}); err != nil {
+		return err
+	}
+
+	if err := qtx.MoveRole(ctx, db.MoveRoleParams{
+		ID:       body.TargetRoleID,
+		Position: int32(body.From),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *service) CheckPermission(ctx context.Context, serverID, userID string, ability types.Ability) (bool, error) {
+	ok, err := s.queries.CheckPermission(ctx, db.CheckPermissionParams{
+		ID:      serverID,
+		OwnerID: userID,
+		Column3: string(ability),
+	})
+
+	return ok == 1, err
+}
+
+func (s *service) GetServerAbilities(ctx context.Context, serverID, userID string) ([]string, error) {
+	return s.queries.GetUserAbilities(ctx, db.GetUserAbilitiesParams{
+		ServerID: serverID,
+		UserID:   userID,
+	})
+}
+
+func (s *service) UpdateChannelInformations(ctx context.Context, channelID string, body *types.EditChannelParams) error {
+	return s.queries.UpdateChannelInformations(ctx, db.UpdateChannelInformationsParams{
+		ID:          channelID,
+		Name:        body.Name,
+		Description: pgtype.Text{String: body.Description, Valid: true},
+		Users:       body.Users,
+		Roles:       body.Roles,
+	})
+}
+
+func (s *service) UpdateCategoryInformations(ctx context.Context, categoryID string, body *types.EditCategoryParams) error {
+	return s.queries.UpdateCategoryInformations(ctx, db.UpdateCategoryInformationsParams{
+		ID:    categoryID,
+		Name:  body.Name,
+		Users: body.Users,
+		Roles: body.Roles,
+	})
+}
+
+func (s *service) CreateMessage(ctx context.Context, userID string, body *types.CreateMessageParams) (db.Message, error) {
+	return s.queries.CreateMessage(ctx, db.CreateMessageParams{
+		ID:               cuid2.Generate(),
+		AuthorID:         userID,
+		ServerID:         body.ServerID,
+		ChannelID:        body.ChannelID,
+		Content:          body.Content,
+		Everyone:         body.Everyone,
+		MentionsUsers:    body.MentionsUsers,
+		MentionsRoles:    body.MentionsRoles,
+		MentionsChannels: body.MentionsChannels,
+		Attachments:      body.Attachments,
+		AuthorOverride:   body.AuthorOverride,
+		CardID:           pgtype.Text{String: body.CardID, Valid: body.CardID != ""},
+		PostID:           pgtype.Text{String: body.PostID, Valid: body.PostID != ""},
+		ReplyToID:        pgtype.Text{String: body.ReplyTo, Valid: body.ReplyTo != ""},
+	})
+}
+
+func (s *service) GetServers(ctx context.Context) ([]string, error) {
+	return s.queries.GetServersIDs(ctx)
+}
+
+func (s *service) GetChannels(ctx context.Context) ([]db.GetChannelsIDsRow, error) {
+	return s.queries.GetChannelsIDs(ctx)
+}
+
+func (s *service) GetServerInformations(ctx context.Context, userID, serverID string, userIDs []string) (db.GetServerInformationsRow, error) {
+	return s.queries.GetServerInformations(ctx, db.GetServerInformationsParams{
+		ID:      serverID,
+		Column2: userIDs,
+		UserID:  userID,
+	})
+}
+
+func (s *service) GetServerMembers(ctx context.Context, serverID string, offset int32, userIDs []string) ([]db.GetServerMembersRow, error) {
+	return s.queries.GetServerMembers(ctx, db.GetServerMembersParams{
+		ServerID: serverID,
+		Offset:   offset,
+		Column3:  userIDs,
+	})
+}
+
+func (s *service) GetMessages(ctx context.Context, userID, serverID, channelID, cardID, postID, beforeMessageID, afterMessageID string, userIDs []string) ([]db.GetMessagesFromChannelRow, error) {
+	return s.queries.GetMessagesFromChannel(ctx, db.GetMessagesFromChannelParams{
+		ServerID:  serverID,
+		ChannelID: channelID,
+		Column3:   beforeMessageID,
+		Column4:   afterMessageID,
+		Column5:   userIDs,
+		Column6:   cardID,
+		Column7:   postID,
+		Column8:   userID,
+	})
+}
+
+func (s *service) GetMessage(ctx context.Context, messageID string) (db.Message, error) {
+	return s.queries.GetMessage(ctx, messageID)
+}
+
+func (s *service) GetGalleryPosts(ctx context.Context, channelID, beforeMessageID string, limit int) ([]db.GetGalleryPostsRow, error) {
+	return s.queries.GetGalleryPosts(ctx, db.GetGalleryPostsParams{
+		ChannelID: channelID,
+		Before:    beforeMessageID,
+		MaxPosts:  int32(limit),
+	})
+}
+
+func (s *service) DeleteMessage(ctx context.Context, messageID string, userID string) error {
+	return s.queries.DeleteMessage(ctx, db.DeleteMessageParams{
+		ID:       messageID,
+		AuthorID: userID,
+	})
+}
+
+func (s *service) GetMessageAuthor(ctx context.Context, messageID string) (string, error) {
+	return s.queries.GetMessageAuthor(ctx, messageID)
+}
+
+func (s *service) UpdateMessageAttachments(ctx context.Context, messageID string, attachments []byte) (db.Message, error) {
+	return s.queries.UpdateMessageAttachments(ctx, db.UpdateMessageAttachmentsParams{
+		ID:          messageID,
+		Attachments: attachments,
+	})
+}
+
+func (s *service) GetProcessingMessages(ctx context.Context) ([]db.Message, error) {
+	return s.queries.GetProcessingMessages(ctx)
+}
+
+// UpdateMessageEmbeds stores the link previews of a message, unless its content changed since.
+func (s *service) UpdateMessageEmbeds(ctx context.Context, messageID string, content, embeds []byte) (db.Message, error) {
+	return s.queries.UpdateMessageEmbeds(ctx, db.UpdateMessageEmbedsParams{
+		Embeds:  embeds,
+		ID:      messageID,
+		Content: content,
+	})
+}
+
+func (s *service) EditMessage(ctx context.Context, messageID string, body *types.EditMessageParams) error {
+	return s.queries.UpdateMessage(ctx, db.UpdateMessageParams{
+		ID:               messageID,
+		Content:          body.Content,
+		Everyone:         body.Everyone,
+		MentionsUsers:    body.MentionsUsers,
+		MentionsChannels: body.MentionsChannels,
+	})
+}
+
+func (s *service) GetUserLinks(ctx context.Context, userID string) ([]json.RawMessage, error) {
+	return s.queries.GetUserLinks(ctx, userID)
+}
+
+func (s *service) GetUserFacts(ctx context.Context, userID string) ([]json.RawMessage, error) {
+	return s.queries.GetUserFacts(ctx, userID)
+}
+
+func (s *service) GetUserPassword(ctx context.Context, userID string) (string, error) {
+	return s.queries.GetUserPassword(ctx, userID)
+}
+
+func (s *service) GetUserProfile(ctx context.Context, userID string) (db.GetUserProfileRow, error) {
+	return s.queries.GetUserProfile(ctx, userID)
+}
+
+func (s *service) GetChannelsFromServer(ctx context.Context, serverID string) ([]db.Channel, error) {
+	return s.queries.GetChannelsFromServer(ctx, serverID)
+}
+
+func (s *service) GetCategoriesFromServer(ctx context.Context, serverID string) ([]db.ChannelCategory, error) {
+	return s.queries.GetCategoriesFromServer(ctx, serverID)
+}
+
+func (s *service) GetRolesFromServer(ctx context.Context, serverID string) ([]db.GetRolesFromServerRow, error) {
+	return s.queries.GetRolesFromServer(ctx, serverID)
+}
+
+func (s *service) GetUserRolesFromServers(ctx context.Context, userID string, serverIDs []string) ([]db.GetUserRolesFromServersRow, error) {
+	return s.queries.GetUserRolesFromServers(ctx, db.GetUserRolesFromServersParams{
+		UserID:  userID,
+		Column2: serverIDs,
+	})
+}
+
+func (s *service) UploadEmojis(ctx context.Context, userID string, emojis []db.CreateEmojiParams) error {
+	if _, err := s.queries.CreateEmoji(ctx, emojis); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) GetEmojis(ctx context.Context, userID string) ([]db.GetEmojisRow, error) {
+	return s.queries.GetEmojis(ctx, userID)
+}
+
+func (s *service) DeleteEmoji(ctx context.Context, emojiID string, userID string) error {
+	return s.queries.DeleteEmoji(ctx, db.DeleteEmojiParams{
+		ID:     emojiID,
+		UserID: userID,
+	})
+}
+
+func (s *service) UpdateEmoji(ctx context.Context, emojiID string, userID string, body *types.UpdateEmojiParams) error {
+	return s.queries.UpdateEmoji(ctx, db.UpdateEmojiParams{
+		ID:        emojiID,
+		UserID:    userID,
+		Shortcode: body.Shortcode,
+	})
+}
+
+func (s *service) GetServerEmojis(ctx context.Context, serverID string) ([]db.ServerEmoji, error) {
+	return s.queries.GetServerEmojis(ctx, serverID)
+}
+
+func (s *service) GetMemberServerEmojis(ctx context.Context, userID string) ([]db.ServerEmoji, error) {
+	return s.queries.GetMemberServerEmojis(ctx, userID)
+}
+
+func (s *service) CreateServerEmojis(ctx context.Context, emojis []db.CreateServerEmojiParams) error {
+	if _, err := s.queries.CreateServerEmoji(ctx, emojis); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) UpdateServerEmoji(ctx context.Context, serverID, emojiID string, body *types.UpdateEmojiParams) (db.ServerEmoji, error) {
+	return s.queries.UpdateServerEmoji(ctx, db.UpdateServerEmojiParams{
+		ID:        emojiID,
+		ServerID:  serverID,
+		Shortcode: body.Shortcode,
+	})
+}
+
+func (s *service) DeleteServerEmoji(ctx context.Context, serverID, emojiID string) (db.ServerEmoji, error) {
+	return s.queries.DeleteServerEmoji(ctx, db.DeleteServerEmojiParams{
+		ServerID: serverID,
+		ID:       emojiID,
+	})
+}
+
+func (s *service) CreateFriendRequest(ctx context.Context, senderID, receiverID string) (db.Friend, error) {
+	return s.queries.AddFriend(ctx, db.AddFriendParams{
+		ID:         cuid2.Generate(),
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+	})
+}
+
+// AcceptFriendRequest accepts the pending request received by receiverID and opens the direct message
+// of the friendship.
+func (s *service) AcceptFriendRequest(ctx context.Context, friendshipID, receiverID string) (db.Friend, string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	friendship, err := qtx.AcceptFriend(ctx, db.AcceptFriendParams{
+		ID:         friendshipID,
+		ReceiverID: receiverID,
+	})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	channel, err := qtx.CreateChannel(ctx, db.CreateChannelParams{
+		ID:           cuid2.Generate(),
+		ServerID:     "global",
+		FriendshipID: pgtype.Text{String: friendshipID, Valid: true},
+		Name:         "",
+		Type:         "dm",
+		E2ee:         false,
+		Users:        []string{friendship.SenderID, friendship.ReceiverID},
+		Description:  pgtype.Text{String: "", Valid: true},
+	})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	return friendship, channel.ID, tx.Commit(ctx)
+}
+
+// RemoveFriend ends a friendship of userID. Its direct message goes with it, the ID of which is
+// returned.
+func (s *service) RemoveFriend(ctx context.Context, friendshipID, userID string) (db.Friend, string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	channelIDs, err := qtx.GetFriendshipChannelIDs(ctx, pgtype.Text{String: friendshipID, Valid: true})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	friendship, err := qtx.DeleteFriend(ctx, db.DeleteFriendParams{
+		ID:         friendshipID,
+		ReceiverID: userID,
+	})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	var channelID string
+	if len(channelIDs) > 0 {
+		channelID = channelIDs[0]
+	}
+
+	return friendship, channelID, tx.Commit(ctx)
+}
+
+func (s *service) DeclineFriendRequest(ctx context.Context, friendshipID, userID string) (db.Friend, error) {
+	return s.queries.DeclineFriendRequest(ctx, db.DeclineFriendRequestParams{
+		ID:         friendshipID,
+		ReceiverID: userID,
+	})
+}
+
+func (s *service) CancelFriendRequest(ctx context.Context, friendshipID, userID string) (db.Friend, error) {
+	return s.queries.CancelFriendRequest(ctx, db.CancelFriendRequestParams{
+		ID:       friendshipID,
+		SenderID: userID,
+	})
+}
+
+func (s *service) GetIncomingFriendRequests(ctx context.Context, userID string) ([]db.GetIncomingFriendRequestsRow, error) {
+	return s.queries.GetIncomingFriendRequests(ctx, userID)
+}
+
+func (s *service) GetOutgoingFriendRequests(ctx context.Context, userID string) ([]db.GetOutgoingFriendRequestsRow, error) {
+	return s.queries.GetOutgoingFriendRequests(ctx, userID)
+}
+
+func (s *service) GetFriendshipsBetween(ctx context.Context, userID, otherID string) ([]db.Friend, error) {
+	return s.queries.GetFriendshipsBetween(ctx, db.GetFriendshipsBetweenParams{
+		UserID:  userID,
+		OtherID: otherID,
+	})
+}
+
+func (s *service) CanSendFriendRequest(ctx context.Context, senderID, receiverID string) (bool, error) {
+	return s.queries.CanSendFriendRequest(ctx, db.CanSendFriendRequestParams{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+	})
+}
+
+func (s *service) GetFriends(ctx context.Context, userID string) ([]db.GetFriendsRow, error) {
+	return s.queries.GetFriends(ctx, userID)
+}
+
+func (s *service) GetFriendIDs(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.GetFriendIDs(ctx, userID)
+}
+
+// IsBlocked reports whether userID or otherID blocked the other.
+func (s *service) IsBlocked(ctx context.Context, userID, otherID string) (bool, error) {
+	return s.queries.IsBlockedBetween(ctx, db.IsBlockedBetweenParams{
+		UserID:  userID,
+		OtherID: otherID,
+	})
+}
+
+// BlockUser makes userID block targetID. Their friendship or pending request becomes the block, and
+// is returned as it was so the other side can be told it ended.
+func (s *service) BlockUser(ctx context.Context, userID, targetID string) (db.Friend, *db.Friend, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.Friend{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	friendships, err := qtx.GetFriendshipsBetween(ctx, db.GetFriendshipsBetweenParams{
+		UserID:  userID,
+		OtherID: targetID,
+	})
+	if err != nil {
+		return db.Friend{}, nil, err
+	}
+
+	for _, friendship := range friendships {
+		if friendship.Blocked && friendship.SenderID == userID {
+			return friendship, nil, nil
+		}
+	}
+
+	var block db.Friend
+	var ended *db.Friend
+	idx := slices.IndexFunc(friendships, func(f db.Friend) bool { return !f.Blocked })
+	if idx >= 0 {
+		ended = &friendships[idx]
+		block, err = qtx.BlockFriendship(ctx, db.BlockFriendshipParams{
+			ID:         ended.ID,
+			SenderID:   userID,
+			ReceiverID: targetID,
+		})
+	} else {
+		block, err = qtx.CreateBlock(ctx, db.CreateBlockParams{
+			ID:         cuid2.Generate(),
+			SenderID:   userID,
+			ReceiverID: targetID,
+		})
+	}
+	if err != nil {
+		return db.Friend{}, nil, err
+	}
+
+	return block, ended, tx.Commit(ctx)
+}
+
+// UnblockUser lifts the block of userID on targetID. The direct message of the friendship the block
+// came from goes with it, its ID is returned when there was one.
+func (s *service) UnblockUser(ctx context.Context, userID, targetID string) (db.Friend, string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	friendships, err := qtx.GetFriendshipsBetween(ctx, db.GetFriendshipsBetweenParams{
+		UserID:  userID,
+		OtherID: targetID,
+	})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	idx := slices.IndexFunc(friendships, func(f db.Friend) bool { return f.Blocked && f.SenderID == userID })
+	if idx < 0 {
+		return db.Friend{}, "", ErrNotBlocked
+	}
+
+	channelIDs, err := qtx.GetFriendshipChannelIDs(ctx, pgtype.Text{String: friendships[idx].ID, Valid: true})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	block, err := qtx.DeleteBlock(ctx, db.DeleteBlockParams{
+		SenderID:   userID,
+		ReceiverID: targetID,
+	})
+	if err != nil {
+		return db.Friend{}, "", err
+	}
+
+	var channelID string
+	if len(channelIDs) > 0 {
+		channelID = channelIDs[0]
+	}
+
+	return block, channelID, tx.Commit(ctx)
+}
+
+func (s *service) GetBlockedUsers(ctx context.Context, userID string) ([]db.GetBlockedUsersRow, error) {
+	return s.queries.GetBlockedUsers(ctx, userID)
+}
+
+func (s *service) GetBlockedUserIDs(ctx context.Context, userID string) ([]string, error) {
+	return s.queries.GetBlockedUserIDs(ctx, userID)
+}
+
+func (s *service) DeleteAccount(ctx context.Context, userID string) error {
+	return s.queries.DeleteUser(ctx, userID)
+}
+
+func (s *service) BanUser(ctx context.Context, serverID string, body *types.BanUserParams) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	qtx.BanUser(ctx, db.BanUserParams{
+		UserID:    body.UserID,
+		ServerID:  serverID,
+		BanReason: pgtype.Text{String: body.Reason, Valid: true},
+	})
+
+	qtx.DeleteServerMessages(ctx, db.DeleteServerMessagesParams{
+		ServerID: serverID,
+		AuthorID: body.UserID,
+	})
 
 	return tx.Commit(ctx)
 }
 
-func (s *service) AddRoleMember(ctx context.Context, body *types.ChangeRoleMemberParams) error {
-	return s.queries.GiveRole(ctx, db.GiveRoleParams{
-		ServerID:    body.ServerID,
-		UserID:      body.UserID,
-		ArrayAppend: body.RoleID,
+func (s *service) KickUser(ctx context.Context, serverID string, body *types.KickUserParams) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	qtx.KickUser(ctx, db.KickUserParams{
+		UserID:   body.UserID,
+		ServerID: serverID,
+	})
+
+	qtx.DeleteServerMessages(ctx, db.DeleteServerMessagesParams{
+		ServerID: serverID,
+		AuthorID: body.UserID,
 	})
+
+	return tx.Commit(ctx)
 }
 
-func (s *service) RemoveRoleMember(ctx context.Context, body *types.ChangeRoleMemberParams) error {
-	return s.queries.RemoveRoleMember(ctx, db.RemoveRoleMemberParams{
-		ServerID:    body.ServerID,
-		UserID:      body.UserID,
-		ArrayRemove: body.RoleID,
+func (s *service) UnbanUser(ctx context.Context, serverID, userID string) error {
+	return s.queries.KickUser(ctx, db.KickUserParams{
+		UserID:   userID,
+		ServerID: serverID,
 	})
 }
 
-func (s *service) MoveRole(ctx context.Context, body *types.MoveRoleMemberParams) error {
+func (s *service) CheckBan(ctx context.Context, serverID, userID string) (pgtype.Text, error) {
+	return s.queries.CheckBan(ctx, db.CheckBanParams{
+		UserID:   userID,
+		ServerID: serverID,
+	})
+}
+
+func (s *service) GetBannedMembers(ctx context.Context, serverID string) ([]db.GetBannedMembersRow, error) {
+	return s.queries.GetBannedMembers(ctx, serverID)
+}
+
+func (s *service) SearchServerMembers(ctx context.Context, serverID, query string) ([]db.SearchServerMembersRow, error) {
+	return s.queries.SearchServerMembers(ctx, db.SearchServerMembersParams{
+		ServerID:    serverID,
+		DisplayName: "%" + query + "%",
+	})
+}
+
+func (s *service) Sync(ctx context.Context, userID string, body *types.SyncParams) error {
+	return s.queries.SaveUnreadMessagesState(ctx, db.SaveUnreadMessagesStateParams{
+		UserID:             userID,
+		ChannelIds:         body.ChannelIDs,
+		LastReadMessageIds: body.LastMessageIDs,
+		UnreadMentionIds:   body.MentionIDs,
+	})
+}
+
+func (s *service) GetLatestMessagesRead(ctx context.Context, userID string) ([]db.GetLatestMessagesReadRow, error) {
+	return s.queries.GetLatestMessagesRead(ctx, userID)
+}
+
+func (s *service) GetLatestMessagesSent(ctx context.Context, channelIDs []string) ([]db.GetLatestMessagesSentRow, error) {
+	return s.queries.GetLatestMessagesSent(ctx, channelIDs)
+}
+
+func (s *service) GetRoleMembers(ctx context.Context, roleID string) ([]string, error) {
+	return s.queries.GetRoleMembers(ctx, roleID)
+}
+
+func (s *service) GetServerMemberIDs(ctx context.Context, serverID string, userIDs []string) ([]string, error) {
+	return s.queries.GetServerMemberIDs(ctx, db.GetServerMemberIDsParams{
+		ServerID: serverID,
+		UserIds:  userIDs,
+	})
+}
+
+func (s *service) GetKanbanBoard(ctx context.Context, channelID string) (*types.KanbanBoard, error) {
+	columns, err := s.queries.GetKanbanColumns(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := s.queries.GetKanbanCards(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.KanbanBoard{Columns: columns, Cards: cards}, nil
+}
+
+func (s *service) GetKanbanCard(ctx context.Context, channelID, cardID string) (db.KanbanCard, error) {
+	return s.queries.GetKanbanCard(ctx, db.GetKanbanCardParams{
+		ID:        cardID,
+		ChannelID: channelID,
+	})
+}
+
+func (s *service) CreateKanbanColumn(ctx context.Context, channelID string, body *types.CreateKanbanColumnParams) (db.KanbanColumn, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return err
+		return db.KanbanColumn{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	qtx := s.queries.WithTx(tx)
 
-	if err := qtx.MoveRole(ctx, db.MoveRoleParams{
-		ID:       body.MovedRoleID,
-		Position: int32(body.To),
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	count, err := qtx.CountKanbanColumns(ctx, channelID)
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+	if count >= types.MaxKanbanColumns {
+		return db.KanbanColumn{}, ErrKanbanFull
+	}
+
+	column, err := qtx.CreateKanbanColumn(ctx, db.CreateKanbanColumnParams{
+		ID:        cuid2.Generate(),
+		ChannelID: channelID,
+		Name:      body.Name,
+		Position:  int32(count),
+	})
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	return column, tx.Commit(ctx)
+}
+
+func (s *service) UpdateKanbanColumn(ctx context.Context, channelID, columnID string, body *types.UpdateKanbanColumnParams) (db.KanbanColumn, error) {
+	return s.queries.UpdateKanbanColumn(ctx, db.UpdateKanbanColumnParams{
+		Name:      body.Name,
+		ID:        columnID,
+		ChannelID: channelID,
+	})
+}
+
+// MoveKanbanColumn moves a column to a position of its board, the columns in between are shifted.
+func (s *service) MoveKanbanColumn(ctx context.Context, channelID, columnID string, position int) (db.KanbanColumn, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	column, err := qtx.GetKanbanColumn(ctx, db.GetKanbanColumnParams{ID: columnID, ChannelID: channelID})
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	count, err := qtx.CountKanbanColumns(ctx, channelID)
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+	to := int32(min(int64(position), count-1))
+
+	shift := db.ShiftKanbanColumnsParams{ChannelID: channelID}
+	switch {
+	case to > column.Position:
+		shift.Delta, shift.FromPosition, shift.ToPosition = -1, column.Position+1, to
+	case to < column.Position:
+		shift.Delta, shift.FromPosition, shift.ToPosition = 1, to, column.Position-1
+	default:
+		return column, nil
+	}
+	if err := qtx.ShiftKanbanColumns(ctx, shift); err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	column, err = qtx.SetKanbanColumnPosition(ctx, db.SetKanbanColumnPositionParams{ID: columnID, Position: to})
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	return column, tx.Commit(ctx)
+}
+
+// DeleteKanbanColumn deletes a column along with its cards.
+func (s *service) DeleteKanbanColumn(ctx context.Context, channelID, columnID string) (db.KanbanColumn, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	column, err := qtx.DeleteKanbanColumn(ctx, db.DeleteKanbanColumnParams{ID: columnID, ChannelID: channelID})
+	if err != nil {
+		return db.KanbanColumn{}, err
+	}
+
+	if err := qtx.ShiftKanbanColumns(ctx, db.ShiftKanbanColumnsParams{
+		Delta:        -1,
+		ChannelID:    channelID,
+		FromPosition: column.Position + 1,
+		ToPosition:   math.MaxInt32,
 	}); err != nil {
-		return err
+		return db.KanbanColumn{}, err
+	}
+
+	return column, tx.Commit(ctx)
+}
+
+// CreateKanbanCard adds a card at the bottom of its column.
+func (s *service) CreateKanbanCard(ctx context.Context, channelID, authorID string, body *types.CreateKanbanCardParams) (db.KanbanCard, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	if _, err := qtx.GetKanbanColumn(ctx, db.GetKanbanColumnParams{ID: body.ColumnID, ChannelID: channelID}); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	count, err := qtx.CountKanbanCards(ctx, db.CountKanbanCardsParams{ColumnID: body.ColumnID})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+	if count >= types.MaxKanbanCards {
+		return db.KanbanCard{}, ErrKanbanFull
+	}
+
+	var dueAt pgtype.Timestamptz
+	if body.DueAt != nil {
+		dueAt = pgtype.Timestamptz{Time: *body.DueAt, Valid: true}
+	}
+
+	card, err := qtx.CreateKanbanCard(ctx, db.CreateKanbanCardParams{
+		ID:          cuid2.Generate(),
+		ChannelID:   channelID,
+		ColumnID:    body.ColumnID,
+		AuthorID:    pgtype.Text{String: authorID, Valid: true},
+		Title:       body.Title,
+		Description: body.Description,
+		Assignees:   append([]string{}, body.Assignees...),
+		Labels:      append([]string{}, body.Labels...),
+		DueAt:       dueAt,
+		Position:    int32(count),
+	})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	return card, tx.Commit(ctx)
+}
+
+func (s *service) UpdateKanbanCard(ctx context.Context, channelID, cardID string, body *types.UpdateKanbanCardParams) (db.KanbanCard, error) {
+	params := db.UpdateKanbanCardParams{
+		ID:          cardID,
+		ChannelID:   channelID,
+		Description: body.Description,
+		Assignees:   body.Assignees,
+		Labels:      body.Labels,
+		ClearDueAt:  body.ClearDueAt,
+	}
+	if body.Title != nil {
+		params.Title = pgtype.Text{String: *body.Title, Valid: true}
+	}
+	if body.DueAt != nil {
+		params.DueAt = pgtype.Timestamptz{Time: *body.DueAt, Valid: true}
+	}
+
+	return s.queries.UpdateKanbanCard(ctx, params)
+}
+
+// MoveKanbanCard moves a card to a position of a column of the same board, the cards after it in
+// both columns are shifted.
+func (s *service) MoveKanbanCard(ctx context.Context, channelID, cardID string, body *types.MoveKanbanCardParams) (db.KanbanCard, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	card, err := qtx.GetKanbanCard(ctx, db.GetKanbanCardParams{ID: cardID, ChannelID: channelID})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	if _, err := qtx.GetKanbanColumn(ctx, db.GetKanbanColumnParams{ID: body.ColumnID, ChannelID: channelID}); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	count, err := qtx.CountKanbanCards(ctx, db.CountKanbanCardsParams{ColumnID: body.ColumnID, ExcludedID: cardID})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+	if body.ColumnID != card.ColumnID && count >= types.MaxKanbanCards {
+		return db.KanbanCard{}, ErrKanbanFull
+	}
+	to := int32(min(int64(body.Position), count))
+
+	if err := qtx.ShiftKanbanCards(ctx, db.ShiftKanbanCardsParams{
+		Delta:        -1,
+		ColumnID:     card.ColumnID,
+		ExcludedID:   cardID,
+		FromPosition: card.Position + 1,
+	}); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	if err := qtx.ShiftKanbanCards(ctx, db.ShiftKanbanCardsParams{
+		Delta:        1,
+		ColumnID:     body.ColumnID,
+		ExcludedID:   cardID,
+		FromPosition: to,
+	}); err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	card, err = qtx.SetKanbanCardPosition(ctx, db.SetKanbanCardPositionParams{
+		ID:       cardID,
+		ColumnID: body.ColumnID,
+		Position: to,
+	})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	return card, tx.Commit(ctx)
+}
+
+func (s *service) DeleteKanbanCard(ctx context.Context, channelID, cardID string) (db.KanbanCard, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.KanbanCard{}, err
 	}
 
-	if err := qtx.MoveRole(ctx, db.MoveRoleParams{
-		ID:       body.TargetRoleID,
-		Position: int32(body.From),
+	card, err := qtx.DeleteKanbanCard(ctx, db.DeleteKanbanCardParams{ID: cardID, ChannelID: channelID})
+	if err != nil {
+		return db.KanbanCard{}, err
+	}
+
+	if err := qtx.ShiftKanbanCards(ctx, db.ShiftKanbanCardsParams{
+		Delta:        -1,
+		ColumnID:     card.ColumnID,
+		ExcludedID:   cardID,
+		FromPosition: card.Position + 1,
 	}); err != nil {
-		return err
+		return db.KanbanCard{}, err
 	}
 
-	return tx.Commit(ctx)
+	return card, tx.Commit(ctx)
 }
 
-func (s *service) CheckPermission(ctx context.Context, serverID, userID string, ability types.Ability) (bool, error) {
-	ok, err := s.queries.CheckPermission(ctx, db.CheckPermissionParams{
-		ID:      serverID,
-		OwnerID: userID,
-		Column3: string(ability),
+func (s *service) GetUserDevices(ctx context.Context, userID string) ([]db.UserDevice, error) {
+	return s.queries.GetUserDevices(ctx, userID)
+}
+
+func (s *service) GetUserDevice(ctx context.Context, userID, deviceID string) (db.UserDevice, error) {
+	return s.queries.GetUserDevice(ctx, db.GetUserDeviceParams{
+		ID:     deviceID,
+		UserID: userID,
 	})
+}
 
-	return ok == 1, err
+func (s *service) GetDevicesOfUsers(ctx context.Context, userIDs []string) ([]db.UserDevice, error) {
+	return s.queries.GetDevicesOfUsers(ctx, userIDs)
 }
 
-func (s *service) GetServerAbilities(ctx context.Context, serverID, userID string) ([]string, error) {
-	return s.queries.GetUserAbilities(ctx, db.GetUserAbilitiesParams{
-		ServerID: serverID,
-		UserID:   userID,
-	})
+func (s *service) CountUserDevices(ctx context.Context, userID string) (int64, error) {
+	return s.queries.CountUserDevices(ctx, userID)
 }
 
-func (s *service) UpdateChannelInformations(ctx context.Context, channelID string, body *types.EditChannelParams) error {
-	return s.queries.UpdateChannelInformations(ctx, db.UpdateChannelInformationsParams{
-		ID:          channelID,
+func (s *service) CreateUserDevice(ctx context.Context, userID string, body *types.RegisterDeviceParams) (db.UserDevice, error) {
+	return s.queries.CreateUserDevice(ctx, db.CreateUserDeviceParams{
+		ID:          cuid2.Generate(),
+		UserID:      userID,
 		Name:        body.Name,
-		Description: pgtype.Text{String: body.Description, Valid: true},
-		Users:       body.Users,
-		Roles:       body.Roles,
+		IdentityKey: body.IdentityKey,
+		PublicKey:   body.PublicKey,
+		Signature:   body.Signature,
 	})
 }
 
-func (s *service) UpdateCategoryInformations(ctx context.Context, categoryID string, body *types.EditCategoryParams) error {
-	return s.queries.UpdateCategoryInformations(ctx, db.UpdateCategoryInformationsParams{
-		ID:    categoryID,
-		Name:  body.Name,
-		Users: body.Users,
-		Roles: body.Roles,
+func (s *service) DeleteUserDevice(ctx context.Context, userID, deviceID string) (db.UserDevice, error) {
+	return s.queries.DeleteUserDevice(ctx, db.DeleteUserDeviceParams{
+		ID:     deviceID,
+		UserID: userID,
 	})
 }
 
-func (s *service) CreateMessage(ctx context.Context, userID string, body *types.CreateMessageParams) (db.Message, error) {
-	return s.queries.CreateMessage(ctx, db.CreateMessageParams{
-		ID:               cuid2.Generate(),
-		AuthorID:         userID,
-		ServerID:         body.ServerID,
-		ChannelID:        body.ChannelID,
-		Content:          body.Content,
-		Everyone:         body.Everyone,
-		MentionsUsers:    body.MentionsUsers,
-		MentionsRoles:    body.MentionsRoles,
-		MentionsChannels: body.MentionsChannels,
-		Attachments:      body.Attachments,
-	})
+func (s *service) GetChannelMemberIDs(ctx context.Context, channelID string) ([]string, error) {
+	return s.queries.GetChannelMemberIDs(ctx, channelID)
 }
 
-func (s *service) GetServers(ctx context.Context) ([]string, error) {
-	return s.queries.GetServersIDs(ctx)
+func (s *service) GetUserE2EEChannels(ctx context.Context, userID string) ([]db.Channel, error) {
+	return s.queries.GetUserE2EEChannels(ctx, userID)
 }
 
-func (s *service) GetChannels(ctx context.Context) ([]db.GetChannelsIDsRow, error) {
-	return s.queries.GetChannelsIDs(ctx)
+func (s *service) EnableChannelE2EE(ctx context.Context, channelID string) (db.Channel, error) {
+	return s.queries.EnableChannelE2EE(ctx, channelID)
 }
 
-func (s *service) GetServerInformations(ctx context.Context, userID, serverID string, userIDs []string) (db.GetServerInformationsRow, error) {
-	return s.queries.GetServerInformations(ctx, db.GetServerInformationsParams{
-		ID:      serverID,
-		Column2: userIDs,
-		UserID:  userID,
-	})
+func (s *service) RequireChannelsRekey(ctx context.Context, channelIDs []string) ([]db.Channel, error) {
+	return s.queries.RequireChannelsRekey(ctx, channelIDs)
 }
 
-func (s *service) GetServerMembers(ctx context.Context, serverID string, offset int32, userIDs []string) ([]db.GetServerMembersRow, error) {
-	return s.queries.GetServerMembers(ctx, db.GetServerMembersParams{
-		ServerID: serverID,
-		Offset:   offset,
-		Column3:  userIDs,
-	})
+func (s *service) RequireServerRekey(ctx context.Context, serverID string) ([]db.Channel, error) {
+	return s.queries.RequireServerRekey(ctx, serverID)
 }
 
-func (s *service) GetMessages(ctx context.Context, serverID, channelID, beforeMessageID, afterMessageID string, userIDs []string) ([]db.GetMessagesFromChannelRow, error) {
-	return s.queries.GetMessagesFromChannel(ctx, db.GetMessagesFromChannelParams{
-		ServerID:  serverID,
-		ChannelID: channelID,
-		Column3:   beforeMessageID,
-		Column4:   afterMessageID,
-		Column5:   userIDs,
+func (s *service) RequireRoleRekey(ctx context.Context, serverID, roleID string) ([]db.Channel, error) {
+	return s.queries.RequireRoleRekey(ctx, db.RequireRoleRekeyParams{
+		ServerID: serverID,
+		RoleID:   roleID,
 	})
 }
 
-func (s *service) DeleteMessage(ctx context.Context, messageID string, userID string) error {
-	return s.queries.DeleteMessage(ctx, db.DeleteMessageParams{
-		ID:       messageID,
-		AuthorID: userID,
-	})
+func (s *service) GetCurrentChannelKey(ctx context.Context, channelID string) (db.ChannelKey, error) {
+	return s.queries.GetCurrentChannelKey(ctx, channelID)
 }
 
-func (s *service) GetMessageAuthor(ctx context.Context, messageID string) (string, error) {
-	return s.queries.GetMessageAuthor(ctx, messageID)
+func (s *service) GetChannelKey(ctx context.Context, channelID, keyID string) (db.ChannelKey, error) {
+	return s.queries.GetChannelKey(ctx, db.GetChannelKeyParams{
+		ID:        keyID,
+		ChannelID: channelID,
+	})
 }
 
-func (s *service) EditMessage(ctx context.Context, messageID string, body *types.EditMessageParams) error {
-	return s.queries.UpdateMessage(ctx, db.UpdateMessageParams{
-		ID:               messageID,
-		Content:          body.Content,
-		Everyone:         body.Everyone,
-		MentionsUsers:    body.MentionsUsers,
-		MentionsChannels: body.MentionsChannels,
+func (s *service) GetChannelKeys(ctx context.Context, channelID, deviceID string) ([]db.GetChannelKeysRow, error) {
+	return s.queries.GetChannelKeys(ctx, db.GetChannelKeysParams{
+		DeviceID:  deviceID,
+		ChannelID: channelID,
 	})
 }
 
-func (s *service) GetUserLinks(ctx context.Context, userID string) ([]json.RawMessage, error) {
-	return s.queries.GetUserLinks(ctx, userID)
-}
+// RotateChannelKey creates the next group key of a channel with its envelopes, the channel can take
+// messages again once it's done.
+func (s *service) RotateChannelKey(ctx context.Context, channelID, deviceID string, envelopes []types.SealedKey) (db.ChannelKey, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return db.ChannelKey{}, err
+	}
+	defer tx.Rollback(ctx)
 
-func (s *service) GetUserFacts(ctx context.Context, userID string) ([]json.RawMessage, error) {
-	return s.queries.GetUserFacts(ctx, userID)
-}
+	qtx := s.queries.WithTx(tx)
 
-func (s *service) GetUserPassword(ctx context.Context, userID string) (string, error) {
-	return s.queries.GetUserPassword(ctx, userID)
-}
+	if err := qtx.LockChannel(ctx, channelID); err != nil {
+		return db.ChannelKey{}, err
+	}
 
-func (s *service) GetUserProfile(ctx context.Context, userID string) (db.GetUserProfileRow, error) {
-	return s.queries.GetUserProfile(ctx, userID)
-}
+	key, err := qtx.CreateChannelKey(ctx, db.CreateChannelKeyParams{
+		ID:              cuid2.Generate(),
+		ChannelID:       channelID,
+		CreatorDeviceID: pgtype.Text{String: deviceID, Valid: true},
+	})
+	if err != nil {
+		return db.ChannelKey{}, err
+	}
 
-func (s *service) GetChannelsFromServer(ctx context.Context, serverID string) ([]db.Channel, error) {
-	return s.queries.GetChannelsFromServer(ctx, serverID)
-}
+	if err := qtx.CreateChannelKeyEnvelopes(ctx, sealedKeysParams(key.ID, deviceID, envelopes)); err != nil {
+		return db.ChannelKey{}, err
+	}
 
-func (s *service) GetCategoriesFromServer(ctx context.Context, serverID string) ([]db.ChannelCategory, error) {
-	return s.queries.GetCategoriesFromServer(ctx, serverID)
-}
+	if err := qtx.ClearChannelRekey(ctx, channelID); err != nil {
+		return db.ChannelKey{}, err
+	}
 
-func (s *service) GetRolesFromServer(ctx context.Context, serverID string) ([]db.GetRolesFromServerRow, error) {
-	return s.queries.GetRolesFromServer(ctx, serverID)
+	return key, tx.Commit(ctx)
 }
 
-func (s *service) GetUserRolesFromServers(ctx context.Context, userID string, serverIDs []string) ([]db.GetUserRolesFromServersRow, error) {
-	return s.queries.GetUserRolesFromServers(ctx, db.GetUserRolesFromServersParams{
-		UserID:  userID,
-		Column2: serverIDs,
-	})
+func (s *service) AddChannelKeyEnvelopes(ctx context.Context, keyID, deviceID string, envelopes []types.SealedKey) error {
+	return s.queries.CreateChannelKeyEnvelopes(ctx, sealedKeysParams(keyID, deviceID, envelopes))
 }
 
-func (s *service) UploadEmojis(ctx context.Context, userID string, emojis []db.CreateEmojiParams) error {
-	if _, err := s.queries.CreateEmoji(ctx, emojis); err != nil {
-		return err
+func sealedKeysParams(keyID, deviceID string, envelopes []types.SealedKey) db.CreateChannelKeyEnvelopesParams {
+	params := db.CreateChannelKeyEnvelopesParams{
+		KeyID:          keyID,
+		SenderDeviceID: deviceID,
+		DeviceIds:      make([]string, 0, len(envelopes)),
+		Ciphertexts:    make([]string, 0, len(envelopes)),
+	}
+	for _, envelope := range envelopes {
+		params.DeviceIds = append(params.DeviceIds, envelope.DeviceID)
+		params.Ciphertexts = append(params.Ciphertexts, envelope.Ciphertext)
 	}
 
-	return nil
+	return params
 }
 
-func (s *service) GetEmojis(ctx context.Context, userID string) ([]db.GetEmojisRow, error) {
-	return s.queries.GetEmojis(ctx, userID)
+func (s *service) GetGroupDMs(ctx context.Context, userID string) ([]db.Channel, error) {
+	return s.queries.GetGroupDMs(ctx, userID)
 }
 
-func (s *service) DeleteEmoji(ctx context.Context, emojiID string, userID string) error {
-	return s.queries.DeleteEmoji(ctx, db.DeleteEmojiParams{
-		ID:     emojiID,
-		UserID: userID,
-	})
+func (s *service) GetGroupDM(ctx context.Context, channelID string) (db.Channel, error) {
+	return s.queries.GetGroupDM(ctx, channelID)
 }
 
-func (s *service) UpdateEmoji(ctx context.Context, emojiID string, userID string, body *types.UpdateEmojiParams) error {
-	return s.queries.UpdateEmoji(ctx, db.UpdateEmojiParams{
-		ID:        emojiID,
-		UserID:    userID,
-		Shortcode: body.Shortcode,
+func (s *service) CountGroupDMs(ctx context.Context, userID string) (int64, error) {
+	return s.queries.CountGroupDMs(ctx, userID)
+}
+
+func (s *service) CreateGroupDM(ctx context.Context, ownerID string, body *types.CreateGroupDMParams) (db.Channel, error) {
+	return s.queries.CreateGroupDM(ctx, db.CreateGroupDMParams{
+		ID:      cuid2.Generate(),
+		Name:    body.Name,
+		Users:   append([]string{ownerID}, body.Users...),
+		OwnerID: pgtype.Text{String: ownerID, Valid: true},
 	})
 }
 
-func (s *service) CreateFriendRequest(ctx context.Context, senderID, receiverID string) (db.Friend, error) {
-	return s.queries.AddFriend(ctx, db.AddFriendParams{
-		ID:         cuid2.Generate(),
-		SenderID:   senderID,
-		ReceiverID: receiverID,
+func (s *service) RenameGroupDM(ctx context.Context, channelID, name string) (db.Channel, error) {
+	return s.queries.RenameGroupDM(ctx, db.RenameGroupDMParams{
+		ID:   channelID,
+		Name: name,
 	})
 }
 
-func (s *service) AcceptFriendRequest(ctx context.Context, friendshipID, senderID, receiverID string) (*string, error) {
+// AddGroupDMUsers adds the users who aren't in a group direct message yet.
+func (s *service) AddGroupDMUsers(ctx context.Context, channelID string, userIDs []string) (db.Channel, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return db.Channel{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	qtx := s.queries.WithTx(tx)
 
-	err = qtx.AcceptFriend(ctx, db.AcceptFriendParams{
-		ID:         friendshipID,
-		ReceiverID: receiverID,
-	})
+	channel, err := qtx.LockGroupDM(ctx, channelID)
 	if err != nil {
-		return nil, err
+		return db.Channel{}, err
 	}
 
-	channel, err := qtx.CreateChannel(ctx, db.CreateChannelParams{
-		ID:           cuid2.Generate(),
-		ServerID:     "global",
-		FriendshipID: pgtype.Text{String: friendshipID, Valid: true},
-		Name:         "",
-		Type:         "dm",
-		E2ee:         false,
-		Users:        []string{senderID, receiverID},
-		Description:  pgtype.Text{String: "", Valid: true},
-	})
-	if err != nil {
-		return nil, err
+	users := append([]string{}, channel.Users...)
+	for _, userID := range userIDs {
+		if !slices.Contains(users, userID) {
+			users = append(users, userID)
+		}
+	}
+	if len(users) > types.MaxGroupDMUsers {
+		return db.Channel{}, ErrGroupDMFull
 	}
 
-	return &channel.ID, tx.Commit(ctx)
-}
-
-func (s *service) RemoveFriend(ctx context.Context, friendshipID, userID string) error {
-	return s.queries.DeleteFriend(ctx, db.DeleteFriendParams{
-		ID:         friendshipID,
-		ReceiverID: userID,
+	channel, err = qtx.SetGroupDMUsers(ctx, db.SetGroupDMUsersParams{
+		ID:      channelID,
+		Users:   users,
+		OwnerID: channel.OwnerID,
 	})
-}
-
-func (s *service) GetFriends(ctx context.Context, userID string) ([]db.GetFriendsRow, error) {
-	return s.queries.GetFriends(ctx, userID)
-}
-
-func (s *service) GetFriendIDs(ctx context.Context, userID string) ([]string, error) {
-	return s.queries.GetFriendIDs(ctx, userID)
-}
+	if err != nil {
+		return db.Channel{}, err
+	}
 
-func (s *service) DeleteAccount(ctx context.Context, userID string) error {
-	return s.queries.DeleteUser(ctx, userID)
+	return channel, tx.Commit(ctx)
 }
 
-func (s *service) BanUser(ctx context.Context, serverID string, body *types.BanUserParams) error {
+// RemoveGroupDMUser takes a user out of a group direct message. The oldest participant left becomes
+// the owner when the owner leaves, and the group is deleted with its last participant.
+func (s *service) RemoveGroupDMUser(ctx context.Context, channelID, userID string) (db.Channel, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return err
+		return db.Channel{}, err
 	}
 	defer tx.Rollback(ctx)
 
 	qtx := s.queries.WithTx(tx)
 
-	qtx.BanUser(ctx, db.BanUserParams{
-		UserID:    body.UserID,
-		ServerID:  serverID,
-		BanReason: pgtype.Text{String: body.Reason, Valid: true},
-	})
+	channel, err := qtx.LockGroupDM(ctx, channelID)
+	if err != nil {
+		return db.Channel{}, err
+	}
+	if !slices.Contains(channel.Users, userID) {
+		return db.Channel{}, ErrNotParticipant
+	}
 
-	qtx.DeleteServerMessages(ctx, db.DeleteServerMessagesParams{
-		ServerID: serverID,
-		AuthorID: body.UserID,
-	})
+	users := slices.DeleteFunc(append([]string{}, channel.Users...), func(id string) bool { return id == userID })
+	if len(users) == 0 {
+		if err := qtx.DeleteChannel(ctx, channelID); err != nil {
+			return db.Channel{}, err
+		}
+		channel.Users = users
+		return channel, tx.Commit(ctx)
+	}
 
-	return tx.Commit(ctx)
-}
+	ownerID := channel.OwnerID
+	if ownerID.String == userID || !ownerID.Valid {
+		ownerID = pgtype.Text{String: users[0], Valid: true}
+	}
 
-func (s *service) KickUser(ctx context.Context, serverID string, body *types.KickUserParams) error {
-	tx, err := s.db.Begin(ctx)
+	channel, err = qtx.SetGroupDMUsers(ctx, db.SetGroupDMUsersParams{
+		ID:      channelID,
+		Users:   users,
+		OwnerID: ownerID,
+	})
 	if err != nil {
-		return err
+		return db.Channel{}, err
 	}
-	defer tx.Rollback(ctx)
-
-	qtx := s.queries.WithTx(tx)
 
-	qtx.KickUser(ctx, db.KickUserParams{
-		UserID:   body.UserID,
-		ServerID: serverID,
-	})
+	return channel, tx.Commit(ctx)
+}
 
-	qtx.DeleteServerMessages(ctx, db.DeleteServerMessagesParams{
-		ServerID: serverID,
-		AuthorID: body.UserID,
+func (s *service) GetDMReachableUserIDs(ctx context.Context, userID string, userIDs []string) ([]string, error) {
+	return s.queries.GetDMReachableUserIDs(ctx, db.GetDMReachableUserIDsParams{
+		UserIds: userIDs,
+		UserID:  userID,
 	})
-
-	return tx.Commit(ctx)
 }
 
-func (s *service) UnbanUser(ctx context.Context, serverID, userID string) error {
-	return s.queries.KickUser(ctx, db.KickUserParams{
-		UserID:   userID,
-		ServerID: serverID,
+func (s *service) UpdatePrivacy(ctx context.Context, userID string, body *types.UpdatePrivacyParams) (db.User, error) {
+	return s.queries.UpdatePrivacy(ctx, db.UpdatePrivacyParams{
+		ID:                   userID,
+		DmPrivacy:            body.DMPrivacy,
+		FriendRequestPrivacy: body.FriendRequestPrivacy,
 	})
 }
 
-func (s *service) CheckBan(ctx context.Context, serverID, userID string) (pgtype.Text, error) {
-	return s.queries.CheckBan(ctx, db.CheckBanParams{
-		UserID:   userID,
-		ServerID: serverID,
+func (s *service) GetNotifications(ctx context.Context, userID string, params *types.GetNotificationsParams) ([]db.GetNotificationsRow, error) {
+	return s.queries.GetNotifications(ctx, db.GetNotificationsParams{
+		UserID:           userID,
+		Before:           params.Before,
+		UnreadOnly:       params.Unread,
+		ServerID:         params.ServerID,
+		MaxNotifications: int32(params.Limit),
 	})
 }
 
-func (s *service) GetBannedMembers(ctx context.Context, serverID string) ([]db.GetBannedMembersRow, error) {
-	return s.queries.GetBannedMembers(ctx, serverID)
+func (s *service) CountUnreadNotifications(ctx context.Context, userID string) (int64, error) {
+	return s.queries.CountUnreadNotifications(ctx, userID)
 }
 
-func (s *service) SearchServerMembers(ctx context.Context, serverID, query string) ([]db.SearchServerMembersRow, error) {
-	return s.queries.SearchServerMembers(ctx, db.SearchServerMembersParams{
-		ServerID:    serverID,
-		DisplayName: "%" + query + "%",
+// GetMessageNotificationTargets returns who a new message notifies and why, replyAuthorID is the
+// author of the message it replies to and everyone whether its mention of everyone notifies.
+func (s *service) GetMessageNotificationTargets(ctx context.Context, message db.Message, replyAuthorID string, everyone bool) ([]db.GetMessageNotificationTargetsRow, error) {
+	return s.queries.GetMessageNotificationTargets(ctx, db.GetMessageNotificationTargetsParams{
+		ChannelID:     message.ChannelID,
+		ReplyAuthorID: replyAuthorID,
+		MentionsUsers: message.MentionsUsers,
+		AuthorID:      message.AuthorID,
+		MentionsRoles: message.MentionsRoles,
+		Everyone:      everyone,
 	})
 }
 
-func (s *service) Sync(ctx context.Context, userID string, body *types.SyncParams) error {
-	return s.queries.SaveUnreadMessagesState(ctx, db.SaveUnreadMessagesStateParams{
-		UserID:             userID,
-		ChannelIds:         body.ChannelIDs,
-		LastReadMessageIds: body.LastMessageIDs,
-		UnreadMentionIds:   body.MentionIDs,
-	})
+// CreateNotifications notifies each of userIDs with the notification type at the same index, params
+// hold what the notifications point to.
+func (s *service) CreateNotifications(ctx context.Context, userIDs, notificationTypes []string, params db.CreateNotificationsParams) ([]db.Notification, error) {
+	params.Ids = make([]string, len(userIDs))
+	for i := range userIDs {
+		params.Ids[i] = cuid2.Generate()
+	}
+	params.UserIds = userIDs
+	params.Types = notificationTypes
+
+	return s.queries.CreateNotifications(ctx, params)
 }
 
-func (s *service) GetLatestMessagesRead(ctx context.Context, userID string) ([]db.GetLatestMessagesReadRow, error) {
-	return s.queries.GetLatestMessagesRead(ctx, userID)
+func (s *service) ReadNotifications(ctx context.Context, userID string, ids []string) ([]string, error) {
+	return s.queries.ReadNotifications(ctx, db.ReadNotificationsParams{
+		UserID: userID,
+		Ids:    ids,
+	})
 }
 
-func (s *service) GetLatestMessagesSent(ctx context.Context, channelIDs []string) ([]db.GetLatestMessagesSentRow, error) {
-	return s.queries.GetLatestMessagesSent(ctx, channelIDs)
+func (s *service) ReadAllNotifications(ctx context.Context, userID, serverID string) ([]string, error) {
+	return s.queries.ReadAllNotifications(ctx, db.ReadAllNotificationsParams{
+		UserID:   userID,
+		ServerID: serverID,
+	})
 }
 
-func (s *service) GetRoleMembers(ctx context.Context, roleID string) ([]string, error) {
-	return s.queries.GetRoleMembers(ctx, roleID)
+func (s *service) DeleteFriendshipNotifications(ctx context.Context, friendshipID string) error {
+	return s.queries.DeleteFriendshipNotifications(ctx, pgtype.Text{String: friendshipID, Valid: true})
 }
 
 // Health checks the health of the database connection by pinging the database.
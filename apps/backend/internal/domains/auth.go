@@ -1,31 +1,45 @@
 package domains
 
 import (
+	db "backend/db/gen_queries"
 	"backend/internal/broker"
 	"backend/internal/crypto"
 	"backend/internal/database"
+	"backend/internal/oauth"
 	"backend/internal/types"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 type AuthService interface {
 	SignIn(ctx *gin.Context, user *types.SignInParams) (*string, *types.APIError)
 	SignUp(ctx *gin.Context, user *types.SignUpParams) (*string, *types.APIError)
 	Logout(ctx *gin.Context) *types.APIError
+	OAuthAuthorize(ctx *gin.Context, provider string) (string, *types.APIError)
+	LinkProvider(ctx *gin.Context, provider string) (string, *types.APIError)
+	OAuthCallback(ctx *gin.Context, provider string, body *types.OAuthCallbackParams) (*string, *types.APIError)
+	GetIdentities(ctx *gin.Context) ([]db.GetUserIdentitiesRow, *types.APIError)
+	UnlinkIdentity(ctx *gin.Context, provider string) *types.APIError
 }
 
 type authService struct {
 	db     database.Service
 	broker broker.Service
+	oauth  oauth.Service
 }
 
-func NewAuthService(db database.Service, broker broker.Service) *authService {
+func NewAuthService(db database.Service, broker broker.Service, oauth oauth.Service) *authService {
 	return &authService{
 		db:     db,
 		broker: broker,
+		oauth:  oauth,
 	}
 }
 
@@ -98,3 +112,266 @@ func (s *authService) Logout(ctx *gin.Context) *types.APIError {
 
 	return nil
 }
+
+// OAuthAuthorize starts a sign in with the given provider and returns the URL to redirect to.
+func (s *authService) OAuthAuthorize(ctx *gin.Context, provider string) (string, *types.APIError) {
+	return s.authorize(ctx, provider, "")
+}
+
+// LinkProvider starts the same flow, but the callback attaches the provider identity to the signed in user.
+func (s *authService) LinkProvider(ctx *gin.Context, provider string) (string, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return "", types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	return s.authorize(ctx, provider, user.ID)
+}
+
+func (s *authService) authorize(ctx *gin.Context, provider, linkUserID string) (string, *types.APIError) {
+	if !s.oauth.HasProvider(provider) {
+		return "", types.NewAPIError(http.StatusNotFound, "ERR_UNKNOWN_PROVIDER", "This sign in provider doesn't exist.", nil)
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oauth.GenerateVerifier()
+		if err != nil {
+			return "", types.NewAPIError(http.StatusInternalServerError, "ERR_TOKEN_GENERATION", "Failed to generate oauth state.", err)
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	err := s.broker.CacheOAuthState(ctx, state, types.OAuthState{
+		Provider:   provider,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		LinkUserID: linkUserID,
+	})
+	if err != nil {
+		return "", types.NewAPIError(http.StatusInternalServerError, "ERR_CACHING_OAUTH_STATE", "Failed to cache the oauth state in memdb.", err)
+	}
+
+	url, err := s.oauth.AuthCodeURL(ctx, provider, state, nonce, verifier)
+	if err != nil {
+		return "", types.NewAPIError(http.StatusBadGateway, "ERR_OAUTH_PROVIDER", "Failed to reach the sign in provider.", err)
+	}
+
+	return url, nil
+}
+
+// OAuthCallback finishes the flow started by OAuthAuthorize. It returns a session token when the user
+// signed in, or nil when the identity was linked to an already signed in account.
+func (s *authService) OAuthCallback(ctx *gin.Context, provider string, body *types.OAuthCallbackParams) (*string, *types.APIError) {
+	state, err := s.broker.GetOAuthState(ctx, body.State)
+	if err != nil || state.Provider != provider {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_OAUTH_STATE", "The sign in request expired or is invalid.", err)
+	}
+
+	// The callback is public, a link is only made for the session which started it, otherwise a
+	// forged callback would attach an identity to whoever opens it.
+	if state.LinkUserID != "" {
+		if apiErr := s.checkLinkSession(ctx, state.LinkUserID); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	claims, err := s.oauth.Exchange(ctx, provider, body.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_OAUTH_EXCHANGE", "Failed to verify the sign in with the provider.", err)
+	}
+
+	if state.LinkUserID != "" {
+		return nil, s.linkIdentity(ctx, state.LinkUserID, provider, claims)
+	}
+
+	dbUser, err := s.db.GetUserByIdentity(ctx, provider, claims.Subject)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_USER", "Failed to get the user of this sign in.", err)
+	}
+	if err != nil {
+		var derr *types.APIError
+		dbUser, derr = s.createOAuthUser(ctx, provider, claims)
+		if derr != nil {
+			return nil, derr
+		}
+	}
+
+	token, err := crypto.GenerateRandomBytes(64)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_TOKEN_GENERATION", "Failed to generate auth token.", err)
+	}
+
+	b64Token := base64.RawStdEncoding.EncodeToString(token)
+	err = s.broker.CacheUser(ctx, b64Token, dbUser)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CACHING_USER", "Failed to cache the user in memdb.", err)
+	}
+
+	return &b64Token, nil
+}
+
+func (s *authService) GetIdentities(ctx *gin.Context) ([]db.GetUserIdentitiesRow, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	identities, err := s.db.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_IDENTITIES", "Failed to get linked providers.", err)
+	}
+
+	return identities, nil
+}
+
+func (s *authService) UnlinkIdentity(ctx *gin.Context, provider string) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	// Never remove the last way a user can sign in.
+	password, err := s.db.GetUserPassword(ctx, user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_PASSWORD", "Failed to get user password.", err)
+	}
+
+	if password == "" {
+		count, err := s.db.CountUserIdentities(ctx, user.ID)
+		if err != nil {
+			return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_IDENTITIES", "Failed to get linked providers.", err)
+		}
+		if count <= 1 {
+			return types.NewAPIError(http.StatusConflict, "ERR_LAST_LOGIN_METHOD", "Set a password before unlinking your last sign in provider.", nil)
+		}
+	}
+
+	rows, err := s.db.UnlinkIdentity(ctx, user.ID, provider)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_UNLINK_IDENTITY", "Failed to unlink the provider.", err)
+	}
+	if rows == 0 {
+		return types.NewAPIError(http.StatusNotFound, "ERR_IDENTITY_NOT_FOUND", "This provider isn't linked to your account.", nil)
+	}
+
+	return nil
+}
+
+// checkLinkSession makes sure the request comes from the signed in user who asked for the link.
+func (s *authService) checkLinkSession(ctx *gin.Context, userID string) *types.APIError {
+	token, err := ctx.Cookie("token")
+	if err != nil {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_TOKEN", "The auth token is invalid.", err)
+	}
+
+	user, err := s.broker.GetCachedUser(ctx, token)
+	if err != nil {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_MISSING_CACHED_USER", "The auth token is invalid.", err)
+	}
+
+	if user.ID != userID {
+		return types.NewAPIError(http.StatusForbidden, "ERR_OAUTH_LINK_MISMATCH", "This link was started by another account.", nil)
+	}
+
+	return nil
+}
+
+func (s *authService) linkIdentity(ctx *gin.Context, userID, provider string, claims *oauth.Claims) *types.APIError {
+	identity, err := s.db.GetIdentity(ctx, provider, claims.Subject)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_IDENTITIES", "Failed to get linked providers.", err)
+	}
+	if err == nil {
+		if identity.UserID == userID {
+			return nil
+		}
+		return types.NewAPIError(http.StatusConflict, "ERR_IDENTITY_TAKEN", "This account is already linked to another user.", nil)
+	}
+
+	if err := s.db.LinkIdentity(ctx, userID, provider, claims.Subject, claims.Email); err != nil {
+		return types.NewAPIError(http.StatusConflict, "ERR_PROVIDER_ALREADY_LINKED", "A different account of this provider is already linked.", err)
+	}
+
+	return nil
+}
+
+func (s *authService) createOAuthUser(ctx *gin.Context, provider string, claims *oauth.Claims) (db.User, *types.APIError) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return db.User{}, types.NewAPIError(http.StatusBadRequest, "ERR_OAUTH_EMAIL_REQUIRED", "The provider didn't share a verified email address.", nil)
+	}
+
+	// Linking by email would let anyone controlling a provider account take over an existing user,
+	// so the user has to sign in and link the provider from their settings instead.
+	if _, err := s.db.GetUser(ctx, claims.Email); err == nil {
+		return db.User{}, types.NewAPIError(http.StatusConflict, "ERR_EMAIL_TAKEN", "An account already uses this email, sign in and link the provider from your settings.", nil)
+	}
+
+	username, err := s.generateUsername(ctx, claims)
+	if err != nil {
+		return db.User{}, types.NewAPIError(http.StatusInternalServerError, "ERR_USERNAME_GENERATION", "Failed to generate a username.", err)
+	}
+
+	displayName := claims.Name
+	if displayName == "" {
+		displayName = username
+	}
+	if len([]rune(displayName)) > 20 {
+		displayName = string([]rune(displayName)[:20])
+	}
+
+	dbUser, err := s.db.CreateOAuthUser(ctx, &types.CreateOAuthUserParams{
+		Email:       claims.Email,
+		Username:    username,
+		DisplayName: displayName,
+		Provider:    provider,
+		Subject:     claims.Subject,
+	})
+	if err != nil {
+		return db.User{}, types.NewAPIError(http.StatusInternalServerError, "ERR_FAILED_USER_CREATION", "Failed to create the user account.", err)
+	}
+
+	return dbUser, nil
+}
+
+// generateUsername derives a free username from the provider profile, adding a numeric suffix on collisions.
+func (s *authService) generateUsername(ctx *gin.Context, claims *oauth.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '.' {
+			b.WriteRune(r)
+		}
+	}
+
+	base = b.String()
+	if len(base) < 2 {
+		base = "user"
+	}
+	if len(base) > 15 {
+		base = base[:15]
+	}
+
+	candidate := base
+	for range 10 {
+		exists, err := s.db.UsernameExists(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		candidate = fmt.Sprintf("%s%04d", base, rand.IntN(10000))
+	}
+
+	return "", fmt.Errorf("failed to find a free username for %s", base)
+}
@@ -7,6 +7,7 @@ import (
 	"backend/internal/permissions"
 	"backend/internal/types"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,13 +26,15 @@ type channelService struct {
 	db          database.Service
 	actors      actors.Service
 	permissions permissions.Service
+	e2ee        *e2eeService
 }
 
-func NewChannelService(db database.Service, actors actors.Service, permissions permissions.Service) *channelService {
+func NewChannelService(db database.Service, actors actors.Service, permissions permissions.Service, e2ee *e2eeService) *channelService {
 	return &channelService{
 		db:          db,
 		actors:      actors,
 		permissions: permissions,
+		e2ee:        e2ee,
 	}
 }
 
@@ -55,6 +58,18 @@ func (s *channelService) CreateChannel(c *gin.Context, body *types.CreateChannel
 		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_CREATE_CHANNEL", "Forbidden to create channel.", nil)
 	}
 
+	// Text channels of an encrypted category are encrypted too, the other kinds can't be.
+	if body.Type == "textual" && !body.E2EE {
+		category, err := s.db.GetCategory(c, body.CategoryID)
+		if err != nil {
+			return nil, types.NewAPIError(http.StatusNotFound, "ERR_CATEGORY_NOT_FOUND", "Category not found.", err)
+		}
+		body.E2EE = category.E2ee
+	}
+	if body.E2EE && body.Type != "textual" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_CHANNEL_TYPE", "Only text channels can be encrypted.", nil)
+	}
+
 	channel, err := s.db.CreateChannel(c, body)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_CHANNEL", "Failed to create channel.", err)
@@ -119,12 +134,22 @@ func (s *channelService) EditChannel(c *gin.Context, body *types.EditChannelPara
 		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_EDIT_CHANNEL", "Forbidden to edit channel.", nil)
 	}
 
+	channel, err := s.db.GetChannel(c, channelID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+
 	if err := s.db.UpdateChannelInformations(c, channelID, body); err != nil {
 		return types.NewAPIError(http.StatusInternalServerError, "ERR_EDIT_CHANNEL", "Failed to edit channel.", err)
 	}
 
 	s.actors.EditChannel(channelID, body)
 
+	// Who can read an encrypted channel changed, the members left out must not get the next messages.
+	if channel.E2ee && (!slices.Equal(channel.Users, body.Users) || !slices.Equal(channel.Roles, body.Roles)) {
+		s.e2ee.requireRekey(c, []string{channelID}, types.RekeyMembersChanged)
+	}
+
 	return nil
 }
 
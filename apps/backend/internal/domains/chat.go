@@ -3,40 +3,95 @@ package domains
 import (
 	db "backend/db/gen_queries"
 	"backend/internal/actors"
+	"backend/internal/attachments"
+	"backend/internal/crypto"
 	"backend/internal/database"
+	"backend/internal/e2ee"
 	"backend/internal/files"
 	"backend/internal/permissions"
+	"backend/internal/quotas"
 	"backend/internal/types"
+	"backend/internal/unfurl"
+	"backend/internal/validation"
+	"backend/internal/webhooks"
 	"backend/proto"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nrednav/cuid2"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type ChatService interface {
 	CreateMessage(ctx *gin.Context, files []*multipart.FileHeader, message *types.CreateMessageParams) *types.APIError
+	AuthenticateWebhook(ctx *gin.Context) (*db.IncomingWebhook, *types.APIError)
+	ExecuteWebhook(ctx *gin.Context, webhook *db.IncomingWebhook, files []*multipart.FileHeader, body *types.ExecuteWebhookParams) *types.APIError
 	EditMessage(ctx *gin.Context, message *types.EditMessageParams) *types.APIError
 	DeleteMessage(ctx *gin.Context, params *types.DeleteMessageParams) *types.APIError
 	GetMessages(ctx *gin.Context) ([]db.GetMessagesFromChannelRow, *types.APIError)
 }
 
+const (
+	mediaWorkers   = 2
+	mediaQueueSize = 256
+	mediaTimeout   = 5 * time.Minute
+	// maxEmbeds is how many links of a message get a preview.
+	maxEmbeds       = 5
+	unfurlWorkers   = 4
+	unfurlQueueSize = 256
+	unfurlTimeout   = 30 * time.Second
+)
+
 type chatService struct {
 	db          database.Service
 	actors      actors.Service
 	permissions permissions.Service
 	files       files.Service
+	webhooks    webhooks.Service
+	attachments attachments.Service
+	quotas      quotas.Service
+	unfurl      unfurl.Service
+	// notifications notifies the users new messages mention or reply to.
+	notifications *notificationService
+	// mediaJobs holds the messages whose video and audio attachments wait to be processed.
+	mediaJobs chan db.Message
+	// unfurlJobs holds the messages whose links wait for their previews.
+	unfurlJobs chan unfurlJob
+}
+
+type unfurlJob struct {
+	messageID string
+	content   json.RawMessage
+	// edited messages are updated even without links, their previous previews go away.
+	edited bool
 }
 
-func NewChatService(actors actors.Service, db database.Service, files files.Service, permissions permissions.Service) *chatService {
-	return &chatService{
-		db:          db,
-		actors:      actors,
-		permissions: permissions,
-		files:       files,
+func NewChatService(actors actors.Service, db database.Service, files files.Service, permissions permissions.Service, webhooks webhooks.Service, attachments attachments.Service, quotas quotas.Service, unfurl unfurl.Service, notifications *notificationService) *chatService {
+	s := &chatService{
+		db:            db,
+		actors:        actors,
+		permissions:   permissions,
+		files:         files,
+		webhooks:      webhooks,
+		attachments:   attachments,
+		quotas:        quotas,
+		unfurl:        unfurl,
+		notifications: notifications,
 	}
+
+	s.startMediaWorkers()
+	s.startUnfurlWorkers()
+
+	return s
 }
 
 func (s *chatService) GetMessages(ctx *gin.Context) ([]db.GetMessagesFromChannelRow, *types.APIError) {
@@ -44,9 +99,26 @@ func (s *chatService) GetMessages(ctx *gin.Context) ([]db.GetMessagesFromChannel
 	channelID := ctx.Param("channel_id")
 	beforeMessageID, _ := ctx.GetQuery("before")
 	afterMessageID, _ := ctx.GetQuery("after")
+	// The comments of a kanban card or of a gallery post are only listed with it.
+	cardID, _ := ctx.GetQuery("card")
+	postID, _ := ctx.GetQuery("post")
+
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	// Participants who left a group direct message don't read it anymore.
+	if serverID == "global" {
+		channel, err := s.db.GetChannel(ctx, channelID)
+		if err != nil || !slices.Contains(channel.Users, userID) {
+			return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to read this channel.", err)
+		}
+	}
 
 	userIDs := s.actors.GetActiveUsers(serverID)
-	messages, err := s.db.GetMessages(ctx, serverID, channelID, beforeMessageID, afterMessageID, userIDs)
+	messages, err := s.db.GetMessages(ctx, userID, serverID, channelID, cardID, postID, beforeMessageID, afterMessageID, userIDs)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_MESSAGES", "Failed to get messages", err)
 	}
@@ -61,26 +133,137 @@ func (s *chatService) CreateMessage(ctx *gin.Context, files []*multipart.FileHea
 	}
 	author := user.(*db.User)
 
-	jsonAttachments, ferr := s.files.ProcessAndUploadFiles(files)
+	return s.createMessage(ctx, author, files, message, nil)
+}
+
+// AuthenticateWebhook returns the incoming webhook of the request once its token is checked.
+func (s *chatService) AuthenticateWebhook(ctx *gin.Context) (*db.IncomingWebhook, *types.APIError) {
+	webhook, err := s.db.GetIncomingWebhook(ctx, ctx.Param("webhook_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_WEBHOOK_NOT_FOUND", "Webhook not found.", err)
+	}
+
+	hash := crypto.HashToken(ctx.Param("token"))
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(webhook.TokenHash)) != 1 {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_WEBHOOK_TOKEN", "The webhook token is invalid.", nil)
+	}
+
+	return &webhook, nil
+}
+
+// ExecuteWebhook posts the payload of an incoming webhook as a message from the webhook bot user.
+func (s *chatService) ExecuteWebhook(ctx *gin.Context, webhook *db.IncomingWebhook, files []*multipart.FileHeader, body *types.ExecuteWebhookParams) *types.APIError {
+	if strings.TrimSpace(body.Content) == "" && len(files) == 0 {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_EMPTY_MESSAGE", "A message needs content or attachments.", nil)
+	}
+
+	author, err := s.db.GetUserByID(ctx, webhook.UserID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_USER_BY_ID", "Failed to get the webhook user.", err)
+	}
+
+	message := &types.CreateMessageParams{
+		ServerID:  webhook.ServerID,
+		ChannelID: webhook.ChannelID,
+		Content:   textDocument(body.Content),
+	}
+
+	var override *types.AuthorOverride
+	if body.Username != "" || body.AvatarURL != "" {
+		override = &types.AuthorOverride{
+			Username: body.Username,
+			Avatar:   body.AvatarURL,
+		}
+	}
+
+	return s.createMessage(ctx, &author, files, message, override)
+}
+
+func (s *chatService) createMessage(ctx *gin.Context, author *db.User, files []*multipart.FileHeader, message *types.CreateMessageParams, override *types.AuthorOverride) *types.APIError {
+	if len(files)+len(message.Uploads) > validation.DefaultFileConfig.MaxFiles {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_FILES", "Too many attachments.", nil)
+	}
+
+	channel, aerr := s.checkChannelType(ctx, author, message, files)
+	if aerr != nil {
+		return aerr
+	}
+
+	var replyAuthorID string
+	if message.ReplyTo != "" {
+		replied, err := s.db.GetMessage(ctx, message.ReplyTo)
+		if err != nil || replied.ChannelID != channel.ID {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_REPLY", "Replies are made to messages of the same channel.", err)
+		}
+		replyAuthorID = replied.AuthorID
+	}
+
+	if aerr := s.checkQuotas(ctx, author, message, files); aerr != nil {
+		return aerr
+	}
+
+	if aerr := s.scanAttachments(ctx, author, message, files); aerr != nil {
+		return aerr
+	}
+
+	jsonAttachments, ferr := s.files.ProcessAndUploadFiles(ctx, files)
 	if ferr != nil {
 		return ferr
 	}
 
+	if len(message.Uploads) > 0 {
+		attachments, aerr := s.attachUploads(ctx, author.ID, message.Uploads, jsonAttachments)
+		if aerr != nil {
+			return aerr
+		}
+		jsonAttachments = attachments
+	}
+
 	message.Attachments = jsonAttachments
 
+	pbAuthor := &proto.User{
+		Id:          author.ID,
+		Avatar:      author.Avatar.String,
+		DisplayName: author.DisplayName,
+		Bot:         author.Bot,
+	}
+
+	if override != nil {
+		message.AuthorOverride, _ = json.Marshal(override)
+
+		if override.Username != "" {
+			pbAuthor.DisplayName = override.Username
+		}
+		if override.Avatar != "" {
+			pbAuthor.Avatar = override.Avatar
+		}
+	}
+
 	m, err := s.db.CreateMessage(ctx, author.ID, message)
 	if err != nil {
 		return types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_MESSAGE", "Failed to create message", err)
 	}
 
+	if len(message.Uploads) > 0 {
+		if err := s.db.SetUploadsMessage(ctx, m.ID, message.Uploads); err != nil {
+			slog.Error("failed to link uploads to their message", "message_id", m.ID, "err", err)
+		}
+	}
+
+	attachedFiles := messageFiles(m.Attachments)
+	if err := s.trackAttachments(ctx, m, attachedFiles); err != nil {
+		slog.Error("failed to track message attachments", "message_id", m.ID, "err", err)
+	}
+
+	// The server can't read the links of encrypted messages, the clients preview them.
+	if !channel.E2ee {
+		s.enqueueUnfurl(unfurlJob{messageID: m.ID, content: m.Content})
+	}
+
 	pbMessage := &proto.NewChatMessage{
 		Message: &proto.Message{
-			Id: m.ID,
-			Author: &proto.User{
-				Id:          author.ID,
-				Avatar:      author.Avatar.String,
-				DisplayName: author.DisplayName,
-			},
+			Id:               m.ID,
+			Author:           pbAuthor,
 			ServerId:         m.ServerID,
 			ChannelId:        m.ChannelID,
 			Content:          m.Content,
@@ -90,11 +273,33 @@ func (s *chatService) CreateMessage(ctx *gin.Context, files []*multipart.FileHea
 			Attachments:      m.Attachments,
 			CreatedAt:        timestamppb.New(m.CreatedAt),
 			UpdatedAt:        timestamppb.New(m.UpdatedAt),
+			CardId:           m.CardID.String,
+			PostId:           m.PostID.String,
+			ReplyToId:        m.ReplyToID.String,
 		},
 	}
 
 	s.actors.SendChatMessage(pbMessage)
 
+	// Queued once the message is sent so its edit can't arrive first. When the queue is full the
+	// request processes the files itself, which slows the senders down instead of piling up jobs.
+	if hasProcessingFiles(attachedFiles) {
+		select {
+		case s.mediaJobs <- m:
+		default:
+			s.processMessageMedia(m)
+		}
+	}
+
+	s.notifications.notifyMessage(ctx, m, replyAuthorID, pbAuthor)
+	s.webhooks.Fire(m.ServerID, types.EventMessageCreate, types.WebhookMessage{
+		ID:          m.ID,
+		ChannelID:   m.ChannelID,
+		AuthorID:    author.ID,
+		Content:     m.Content,
+		Attachments: m.Attachments,
+	})
+
 	return nil
 }
 
@@ -114,6 +319,22 @@ func (s *chatService) EditMessage(ctx *gin.Context, message *types.EditMessagePa
 		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to edit this message", nil)
 	}
 
+	// The channel of the body is only used to route the edit, the envelope is checked against the
+	// channel the message really is in.
+	m, err := s.db.GetMessage(ctx, messageID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_MESSAGE_NOT_FOUND", "Message not found.", err)
+	}
+	channel, err := s.db.GetChannel(ctx, m.ChannelID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if channel.E2ee {
+		if aerr := s.checkEnvelope(ctx, userID, &channel, message.Content); aerr != nil {
+			return aerr
+		}
+	}
+
 	err = s.db.EditMessage(ctx, messageID, message)
 	if err != nil {
 		return types.NewAPIError(http.StatusInternalServerError, "ERR_EDIT_MESSAGE", "Failed to edit message", err)
@@ -122,8 +343,8 @@ func (s *chatService) EditMessage(ctx *gin.Context, message *types.EditMessagePa
 	s.actors.EditMessage(&proto.EditChatMessage{
 		Message: &proto.Message{
 			Id:               messageID,
-			ServerId:         message.ServerID,
-			ChannelId:        message.ChannelID,
+			ServerId:         m.ServerID,
+			ChannelId:        m.ChannelID,
 			Content:          message.Content,
 			Everyone:         message.Everyone,
 			MentionsUsers:    message.MentionsUsers,
@@ -131,6 +352,16 @@ func (s *chatService) EditMessage(ctx *gin.Context, message *types.EditMessagePa
 			UpdatedAt:        timestamppb.New(time.Now()),
 		},
 	})
+	s.webhooks.Fire(m.ServerID, types.EventMessageUpdate, types.WebhookMessage{
+		ID:        messageID,
+		ChannelID: m.ChannelID,
+		AuthorID:  userID,
+		Content:   message.Content,
+	})
+
+	if !channel.E2ee {
+		s.enqueueUnfurl(unfurlJob{messageID: messageID, content: message.Content, edited: true})
+	}
 
 	return nil
 }
@@ -138,11 +369,21 @@ func (s *chatService) EditMessage(ctx *gin.Context, message *types.EditMessagePa
 func (s *chatService) DeleteMessage(ctx *gin.Context, params *types.DeleteMessageParams) *types.APIError {
 	messageID := ctx.Param("message_id")
 
+	// The permission is checked against the server of the body, which has to be the one the message
+	// really is in.
+	m, err := s.db.GetMessage(ctx, messageID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_MESSAGE_NOT_FOUND", "Message not found.", err)
+	}
+	if m.ServerID != params.ServerID || m.ChannelID != params.ChannelID || m.AuthorID != params.AuthorID {
+		return types.NewAPIError(http.StatusNotFound, "ERR_MESSAGE_NOT_FOUND", "Message not found.", nil)
+	}
+
 	if allowed := s.permissions.CheckPermission(ctx, params.ServerID, types.ManageMessages, messageID, params.AuthorID); !allowed {
 		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to delete this message.", nil)
 	}
 
-	err := s.db.DeleteMessage(ctx, messageID, params.AuthorID)
+	err = s.db.DeleteMessage(ctx, messageID, m.AuthorID)
 	if err != nil {
 		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_MESSAGE", "Failed to delete message.", err)
 	}
@@ -150,10 +391,551 @@ func (s *chatService) DeleteMessage(ctx *gin.Context, params *types.DeleteMessag
 	s.actors.DeleteMessage(&proto.DeleteChatMessage{
 		Message: &proto.Message{
 			Id:        messageID,
-			ServerId:  params.ServerID,
-			ChannelId: params.ChannelID,
+			ServerId:  m.ServerID,
+			ChannelId: m.ChannelID,
 		},
 	})
+	s.webhooks.Fire(m.ServerID, types.EventMessageDelete, types.WebhookMessage{
+		ID:        messageID,
+		ChannelID: m.ChannelID,
+		AuthorID:  m.AuthorID,
+	})
 
 	return nil
 }
+
+// checkChannelType makes sure a message fits the kind of its channel. The messages of kanban
+// channels are the comments of their cards, the ones of gallery channels are either posts with
+// images or videos, or the comments of a post, and the ones of encrypted channels are envelopes.
+func (s *chatService) checkChannelType(ctx *gin.Context, author *db.User, message *types.CreateMessageParams, headers []*multipart.FileHeader) (*db.Channel, *types.APIError) {
+	channel, err := s.db.GetChannel(ctx, message.ChannelID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+
+	// Direct messages are only written by their participants, who can leave group ones.
+	if channel.ServerID == "global" && !slices.Contains(channel.Users, author.ID) {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to send messages in this channel.", nil)
+	}
+
+	// A direct message between two users stays readable once one blocked the other, but closed. The
+	// messages of blocked users in group ones are dropped before reaching the blocker.
+	if channel.Type == "dm" {
+		idx := slices.IndexFunc(channel.Users, func(id string) bool { return id != author.ID })
+		if idx >= 0 {
+			blocked, err := s.db.IsBlocked(ctx, author.ID, channel.Users[idx])
+			if err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BLOCKS", "Failed to check the blocked users.", err)
+			}
+			if blocked {
+				return nil, types.NewAPIError(http.StatusForbidden, "ERR_USER_BLOCKED", "You can't send messages to this user.", nil)
+			}
+		}
+	}
+
+	if channel.E2ee {
+		// Files sent with the message would be processed in clear, they have to be encrypted by
+		// the client and uploaded directly.
+		if len(headers) > 0 {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_FILES", "Files of encrypted channels have to be encrypted uploads.", nil)
+		}
+		for _, uploadID := range message.Uploads {
+			upload, err := s.db.GetUpload(ctx, uploadID, author.ID)
+			if err == nil && upload.ContentType != "application/octet-stream" {
+				return nil, types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_FILES", "Files of encrypted channels have to be encrypted uploads.", nil)
+			}
+		}
+
+		if aerr := s.checkEnvelope(ctx, author.ID, &channel, message.Content); aerr != nil {
+			return nil, aerr
+		}
+	}
+
+	if channel.Type != "kanban" && message.CardID != "" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_NOT_KANBAN_CHANNEL", "This channel is not a kanban channel.", nil)
+	}
+	if channel.Type != "gallery" && message.PostID != "" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_NOT_GALLERY_CHANNEL", "This channel is not a gallery channel.", nil)
+	}
+
+	switch channel.Type {
+	case "kanban":
+		if message.CardID == "" {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_CARD_REQUIRED", "Messages of kanban channels are comments of a card.", nil)
+		}
+		if _, err := s.db.GetKanbanCard(ctx, channel.ID, message.CardID); err != nil {
+			return nil, types.NewAPIError(http.StatusNotFound, "ERR_CARD_NOT_FOUND", "Card not found.", err)
+		}
+	case "gallery":
+		if message.PostID != "" {
+			// Comments don't have threads of their own.
+			post, err := s.db.GetMessage(ctx, message.PostID)
+			if err != nil || post.ChannelID != channel.ID || post.PostID.Valid {
+				return nil, types.NewAPIError(http.StatusNotFound, "ERR_POST_NOT_FOUND", "Post not found.", err)
+			}
+			return &channel, nil
+		}
+
+		hasMedia, err := s.hasVisualMedia(ctx, author, message, headers)
+		if err != nil {
+			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_READ_FILE", "Failed to read file.", err)
+		}
+		if !hasMedia {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_MEDIA_REQUIRED", "Posts of gallery channels need at least one image or video.", nil)
+		}
+	}
+
+	return &channel, nil
+}
+
+// checkEnvelope makes sure the content of a message of an encrypted channel is an envelope, sealed
+// by a device of its author with the current group key of the channel.
+func (s *chatService) checkEnvelope(ctx *gin.Context, authorID string, channel *db.Channel, content json.RawMessage) *types.APIError {
+	envelope, err := e2ee.ParseEnvelope(content)
+	if err != nil {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_INVALID_ENVELOPE", "Messages of encrypted channels have to be encrypted.", err)
+	}
+
+	if _, err := s.db.GetUserDevice(ctx, authorID, envelope.DeviceID); err != nil {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	if channel.RekeyRequired {
+		return types.NewAPIError(http.StatusConflict, "ERR_E2EE_REKEY_REQUIRED", "The key of this channel has to be rotated.", nil)
+	}
+
+	key, err := s.db.GetCurrentChannelKey(ctx, channel.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusConflict, "ERR_E2EE_NO_KEY", "This channel has no key yet.", err)
+	}
+	if envelope.KeyID != key.ID {
+		return types.NewAPIError(http.StatusConflict, "ERR_E2EE_STALE_KEY", "The message is encrypted with an old key of the channel.", nil)
+	}
+
+	return nil
+}
+
+// hasVisualMedia tells if a message comes with an image or a video, before its files are processed.
+func (s *chatService) hasVisualMedia(ctx *gin.Context, author *db.User, message *types.CreateMessageParams, headers []*multipart.FileHeader) (bool, error) {
+	for _, header := range headers {
+		contentType, err := files.DetectContentType(header)
+		if err != nil {
+			return false, err
+		}
+		if files.IsVisualMedia(contentType) {
+			return true, nil
+		}
+	}
+
+	// Uploads which can't be attached are reported when they are claimed.
+	for _, uploadID := range message.Uploads {
+		upload, err := s.db.GetUpload(ctx, uploadID, author.ID)
+		if err == nil && files.IsVisualMedia(upload.ContentType) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// attachUploads claims confirmed direct uploads and appends them to the attachments processed by the API.
+func (s *chatService) attachUploads(ctx *gin.Context, authorID string, uploadIDs []string, processed []byte) ([]byte, *types.APIError) {
+	uploads, err := s.db.ClaimUploads(ctx, authorID, uploadIDs)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_UPLOADS", "Some uploads don't exist, aren't confirmed or are already attached.", err)
+	}
+
+	slices.SortFunc(uploads, func(a, b db.Upload) int {
+		return slices.Index(uploadIDs, a.ID) - slices.Index(uploadIDs, b.ID)
+	})
+
+	var attachments []files.File
+	if err := json.Unmarshal(processed, &attachments); err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_MARSHAL_FILES", "Failed to marshal files.", err)
+	}
+
+	for _, upload := range uploads {
+		file := s.files.UploadedFile(upload.ID, upload.Key, upload.FileName, upload.ContentType, upload.Size)
+		if len(upload.Metadata) > 0 {
+			if err := json.Unmarshal(upload.Metadata, &file); err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_MARSHAL_FILES", "Failed to marshal files.", err)
+			}
+		}
+		attachments = append(attachments, file)
+	}
+
+	res, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_MARSHAL_FILES", "Failed to marshal files.", err)
+	}
+
+	return res, nil
+}
+
+// scanAttachments runs the scanner on the files and uploads of a message before anything is stored
+// for it. A flagged file is quarantined and reported to the moderators of the server.
+func (s *chatService) scanAttachments(ctx *gin.Context, author *db.User, message *types.CreateMessageParams, headers []*multipart.FileHeader) *types.APIError {
+	// Direct uploads were scanned when they were confirmed.
+	quarantined, err := s.files.ScanFiles(ctx, headers)
+	if err != nil {
+		return files.ScanError(err)
+	}
+
+	if quarantined == nil {
+		return nil
+	}
+
+	file, err := s.db.CreateQuarantinedFile(ctx, db.CreateQuarantinedFileParams{
+		ID:          cuid2.Generate(),
+		Key:         quarantined.Key,
+		UserID:      pgtype.Text{String: author.ID, Valid: true},
+		ServerID:    pgtype.Text{String: message.ServerID, Valid: message.ServerID != ""},
+		ChannelID:   pgtype.Text{String: message.ChannelID, Valid: message.ChannelID != ""},
+		FileName:    quarantined.FileName,
+		ContentType: quarantined.ContentType,
+		Size:        quarantined.Size,
+		Signature:   quarantined.Signature,
+	})
+	if err != nil {
+		slog.Error("failed to record quarantined file", "key", quarantined.Key, "err", err)
+	} else if message.ServerID != "global" {
+		moderators, err := s.db.GetServerModerators(ctx, message.ServerID)
+		if err != nil {
+			slog.Error("failed to get server moderators", "server_id", message.ServerID, "err", err)
+		}
+
+		s.actors.NotifyFileBlocked(moderators, &proto.FileBlocked{
+			Id:        file.ID,
+			ServerId:  message.ServerID,
+			ChannelId: message.ChannelID,
+			User: &proto.User{
+				Id:          author.ID,
+				Avatar:      author.Avatar.String,
+				DisplayName: author.DisplayName,
+			},
+			FileName:  file.FileName,
+			Signature: file.Signature,
+			CreatedAt: timestamppb.New(file.CreatedAt),
+		})
+	}
+
+	return types.NewAPIError(http.StatusUnprocessableEntity, "ERR_FILE_BLOCKED", "An attachment was flagged by the malware scanner.", nil)
+}
+
+// checkQuotas makes sure the attachments of a message fit in the quotas of its author and of its
+// server. Direct uploads already count for their author since they were created.
+func (s *chatService) checkQuotas(ctx *gin.Context, author *db.User, message *types.CreateMessageParams, headers []*multipart.FileHeader) *types.APIError {
+	if len(headers) == 0 && len(message.Uploads) == 0 {
+		return nil
+	}
+
+	sizes := fileSizes(headers)
+
+	if aerr := s.quotas.CheckFileSize(author, sizes...); aerr != nil {
+		return aerr
+	}
+	if len(headers) > 0 {
+		if aerr := s.quotas.CheckUser(ctx, author, sizes...); aerr != nil {
+			return aerr
+		}
+	}
+
+	if len(message.Uploads) > 0 {
+		uploaded, err := s.db.GetUploadsSize(ctx, author.ID, message.Uploads)
+		if err != nil {
+			return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_UPLOADS", "Failed to get uploads.", err)
+		}
+		sizes = append(sizes, uploaded)
+	}
+
+	return s.quotas.CheckServer(ctx, message.ServerID, sizes...)
+}
+
+// trackAttachments records the files of a message so they are deleted along with it.
+func (s *chatService) trackAttachments(ctx context.Context, m db.Message, attachedFiles []files.File) error {
+	if len(attachedFiles) == 0 {
+		return nil
+	}
+
+	// Variants and posters are derived from the files, only the files themselves are counted.
+	var storedFiles []types.StoredFile
+	for _, file := range attachedFiles {
+		for i, url := range file.URLs() {
+			storedFile := types.StoredFile{URL: url}
+			if i == 0 {
+				storedFile.Size = file.Size
+			}
+			storedFiles = append(storedFiles, storedFile)
+		}
+	}
+
+	return s.attachments.Track(ctx, messageOwner(m), storedFiles...)
+}
+
+// fileSizes returns the sizes of the uploaded files.
+func fileSizes(headers ...[]*multipart.FileHeader) []int64 {
+	var sizes []int64
+	for _, group := range headers {
+		for _, header := range group {
+			sizes = append(sizes, header.Size)
+		}
+	}
+
+	return sizes
+}
+
+// messageOwner is the owner of the attachments of a message, they count against its author and server.
+func messageOwner(m db.Message) types.AttachmentOwner {
+	owner := types.AttachmentOwner{
+		Kind:  types.AttachmentMessage,
+		ID:    m.ID,
+		Quota: types.QuotaAccount{UserID: m.AuthorID},
+	}
+	if m.ServerID != "global" {
+		owner.Quota.ServerID = m.ServerID
+	}
+
+	return owner
+}
+
+func (s *chatService) startMediaWorkers() {
+	s.mediaJobs = make(chan db.Message, mediaQueueSize)
+
+	for range mediaWorkers {
+		go func() {
+			for m := range s.mediaJobs {
+				s.processMessageMedia(m)
+			}
+		}()
+	}
+
+	go s.resumeMediaProcessing()
+}
+
+// resumeMediaProcessing queues the messages whose media were still processing when the previous
+// instance stopped, they would stay marked as processing otherwise. Processing a message twice
+// only overwrites its metadata and posters with the same ones.
+func (s *chatService) resumeMediaProcessing() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	messages, err := s.db.GetProcessingMessages(ctx)
+	cancel()
+	if err != nil {
+		slog.Error("failed to get the messages with processing media", "err", err)
+		return
+	}
+
+	for _, m := range messages {
+		s.mediaJobs <- m
+	}
+}
+
+// processMessageMedia probes the video and audio attachments of a message, then edits the message
+// with their metadata and posters.
+func (s *chatService) processMessageMedia(m db.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), mediaTimeout)
+	defer cancel()
+
+	attachedFiles := messageFiles(m.Attachments)
+	var posters []types.StoredFile
+
+	for i, file := range attachedFiles {
+		if !file.Processing {
+			continue
+		}
+
+		// A failed file is still described as done, it is shown without metadata.
+		processed, err := s.files.ProcessMedia(ctx, file)
+		if err != nil {
+			slog.Warn("failed to process media", "message_id", m.ID, "file_id", file.ID, "err", err)
+		}
+		if processed.Poster != "" {
+			posters = append(posters, types.StoredFile{URL: processed.Poster})
+		}
+		attachedFiles[i] = processed
+	}
+
+	raw, err := json.Marshal(attachedFiles)
+	if err != nil {
+		slog.Error("failed to marshal processed media", "message_id", m.ID, "err", err)
+		return
+	}
+
+	updated, err := s.db.UpdateMessageAttachments(ctx, m.ID, raw)
+	if err != nil {
+		// The message is most likely gone, nothing tracks its posters.
+		slog.Warn("failed to update message attachments", "message_id", m.ID, "err", err)
+		for _, poster := range posters {
+			if key := s.files.KeyFromURL(poster.URL); key != "" {
+				s.files.DeleteFile(key)
+			}
+		}
+		return
+	}
+
+	if len(posters) > 0 {
+		if err := s.attachments.Track(ctx, messageOwner(m), posters...); err != nil {
+			slog.Error("failed to track posters", "message_id", m.ID, "err", err)
+		}
+	}
+
+	s.actors.EditMessage(&proto.EditChatMessage{
+		Message: &proto.Message{
+			Id:               updated.ID,
+			ServerId:         updated.ServerID,
+			ChannelId:        updated.ChannelID,
+			Content:          updated.Content,
+			Everyone:         updated.Everyone,
+			MentionsUsers:    updated.MentionsUsers,
+			MentionsChannels: updated.MentionsChannels,
+			Attachments:      updated.Attachments,
+			UpdatedAt:        timestamppb.New(updated.UpdatedAt),
+		},
+	})
+}
+
+func (s *chatService) startUnfurlWorkers() {
+	s.unfurlJobs = make(chan unfurlJob, unfurlQueueSize)
+
+	for range unfurlWorkers {
+		go func() {
+			for job := range s.unfurlJobs {
+				s.unfurlMessage(job)
+			}
+		}()
+	}
+}
+
+func (s *chatService) enqueueUnfurl(job unfurlJob) {
+	if !job.edited && len(unfurl.ExtractURLs(job.content, 1)) == 0 {
+		return
+	}
+
+	// Previews are best effort, they are skipped rather than piling up when the queue is full.
+	select {
+	case s.unfurlJobs <- job:
+	default:
+		slog.Warn("unfurl queue is full, skipping link previews", "message_id", job.messageID)
+	}
+}
+
+// unfurlMessage previews the links of a message, then edits the message with them. The preview
+// images are hosted with the other files and go away with the message.
+func (s *chatService) unfurlMessage(job unfurlJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), unfurlTimeout)
+	defer cancel()
+
+	embeds := []types.Embed{}
+	var images []types.StoredFile
+
+	for _, url := range unfurl.ExtractURLs(job.content, maxEmbeds) {
+		embed, err := s.unfurl.Unfurl(ctx, url)
+		if err != nil {
+			continue
+		}
+
+		// The cached embed is shared, the image is hosted for this message only.
+		if embed.Image != nil {
+			embed.Image = s.hostEmbedImage(ctx, embed.Image.URL)
+			if embed.Image != nil {
+				images = append(images, types.StoredFile{URL: embed.Image.URL})
+			}
+		}
+		if embed.Type == types.EmbedImage && embed.Image == nil {
+			continue
+		}
+
+		embeds = append(embeds, *embed)
+	}
+
+	if len(embeds) == 0 && !job.edited {
+		return
+	}
+
+	raw, err := json.Marshal(embeds)
+	if err != nil {
+		slog.Error("failed to marshal embeds", "message_id", job.messageID, "err", err)
+		return
+	}
+
+	updated, err := s.db.UpdateMessageEmbeds(ctx, job.messageID, job.content, raw)
+	if err != nil {
+		// The message is gone or was edited again, its own job takes over.
+		for _, image := range images {
+			if key := s.files.KeyFromURL(image.URL); key != "" {
+				s.files.DeleteFile(key)
+			}
+		}
+		return
+	}
+
+	// Images aren't counted in the quotas, nobody chose to upload them.
+	owner := types.AttachmentOwner{Kind: types.AttachmentEmbed, ID: updated.ID}
+	if err := s.attachments.Replace(ctx, owner, images...); err != nil {
+		slog.Error("failed to track embed images", "message_id", updated.ID, "err", err)
+	}
+
+	s.actors.EditMessage(&proto.EditChatMessage{
+		Message: &proto.Message{
+			Id:               updated.ID,
+			ServerId:         updated.ServerID,
+			ChannelId:        updated.ChannelID,
+			Content:          updated.Content,
+			Everyone:         updated.Everyone,
+			MentionsUsers:    updated.MentionsUsers,
+			MentionsChannels: updated.MentionsChannels,
+			Attachments:      updated.Attachments,
+			Embeds:           updated.Embeds,
+			UpdatedAt:        timestamppb.New(updated.UpdatedAt),
+		},
+	})
+}
+
+// hostEmbedImage copies the image of a preview to the storage, it returns nil when it can't.
+func (s *chatService) hostEmbedImage(ctx context.Context, url string) *types.EmbedMedia {
+	data, err := s.unfurl.FetchImage(ctx, url)
+	if err != nil {
+		return nil
+	}
+
+	image, err := s.files.ProcessAndUploadEmbedImage(ctx, data)
+	if err != nil {
+		slog.Warn("failed to host embed image", "url", url, "err", err)
+		return nil
+	}
+
+	return &types.EmbedMedia{URL: image.URL, Width: image.Width, Height: image.Height}
+}
+
+func hasProcessingFiles(attachedFiles []files.File) bool {
+	return slices.ContainsFunc(attachedFiles, func(f files.File) bool { return f.Processing })
+}
+
+func messageFiles(raw []byte) []files.File {
+	var attachedFiles []files.File
+	if err := json.Unmarshal(raw, &attachedFiles); err != nil {
+		return nil
+	}
+
+	return attachedFiles
+}
+
+// textDocument wraps plain text into the editor document format, one paragraph per line.
+func textDocument(text string) json.RawMessage {
+	type node struct {
+		Type    string `json:"type"`
+		Text    string `json:"text,omitempty"`
+		Content []node `json:"content,omitempty"`
+	}
+
+	doc := node{Type: "doc"}
+	for _, line := range strings.Split(text, "\n") {
+		paragraph := node{Type: "paragraph"}
+		if line != "" {
+			paragraph.Content = []node{{Type: "text", Text: line}}
+		}
+		doc.Content = append(doc.Content, paragraph)
+	}
+
+	b, _ := json.Marshal(doc)
+	return b
+}
@@ -0,0 +1,328 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/broker"
+	"backend/internal/commands"
+	"backend/internal/crypto"
+	"backend/internal/database"
+	"backend/internal/permissions"
+	"backend/internal/safehttp"
+	"backend/internal/types"
+	"backend/internal/webhooks"
+	"backend/proto"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nrednav/cuid2"
+)
+
+const interactionTimeout = 5 * time.Second
+
+type CommandService interface {
+	GetCommands(ctx *gin.Context) ([]db.GetServerCommandsRow, *types.APIError)
+	RegisterCommands(ctx *gin.Context, body *types.RegisterCommandsParams) ([]db.Command, *types.APIError)
+	SetInteractionEndpoint(ctx *gin.Context, body *types.InteractionEndpointParams) (*types.InteractionEndpoint, *types.APIError)
+	DeleteInteractionEndpoint(ctx *gin.Context) *types.APIError
+	InvokeCommand(ctx *gin.Context, body *types.InvokeCommandParams) (string, *types.APIError)
+	RespondToInteraction(ctx *gin.Context, body *types.InteractionResponseParams) *types.APIError
+}
+
+type commandService struct {
+	db          database.Service
+	broker      broker.Service
+	actors      actors.Service
+	permissions permissions.Service
+	chat        *chatService
+	client      *http.Client
+}
+
+func NewCommandService(db database.Service, broker broker.Service, actors actors.Service, permissions permissions.Service, chat *chatService) *commandService {
+	client := safehttp.NewClient(interactionTimeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &commandService{
+		db:          db,
+		broker:      broker,
+		actors:      actors,
+		permissions: permissions,
+		chat:        chat,
+		client:      client,
+	}
+}
+
+func (s *commandService) GetCommands(ctx *gin.Context) ([]db.GetServerCommandsRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.SendMessages); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to use commands in this server.", nil)
+	}
+
+	cmds, err := s.db.GetServerCommands(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_COMMANDS", "Failed to get commands.", err)
+	}
+
+	return cmds, nil
+}
+
+// RegisterCommands replaces the commands the authenticated bot has in a server it is a member of.
+func (s *commandService) RegisterCommands(ctx *gin.Context, body *types.RegisterCommandsParams) ([]db.Command, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	bot := u.(*db.User)
+
+	if !bot.Bot {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_NOT_A_BOT", "Only bots can register commands.", nil)
+	}
+
+	serverID := ctx.Param("server_id")
+	if err := s.checkMembership(ctx, serverID, bot.ID); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(body.Commands))
+	for _, command := range body.Commands {
+		if !commands.ValidName(command.Name) {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_COMMAND", fmt.Sprintf("Command name %q is invalid.", command.Name), nil)
+		}
+		if names[command.Name] {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_COMMAND", fmt.Sprintf("Command %q is defined twice.", command.Name), nil)
+		}
+		names[command.Name] = true
+
+		if err := commands.ValidateDefinition(command.Options); err != nil {
+			return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_COMMAND", fmt.Sprintf("Command %q is invalid.", command.Name), err)
+		}
+	}
+
+	created, err := s.db.SetCommands(ctx, serverID, bot.ID, body.Commands)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_REGISTER_COMMANDS", "Failed to register commands, another bot may already use one of these names.", err)
+	}
+
+	return created, nil
+}
+
+// SetInteractionEndpoint makes interactions reach the bot by signed HTTP callbacks when it isn't
+// connected to the gateway. The secret is returned so the bot can verify the signatures.
+func (s *commandService) SetInteractionEndpoint(ctx *gin.Context, body *types.InteractionEndpointParams) (*types.InteractionEndpoint, *types.APIError) {
+	bot, err := s.ownedBot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, rerr := crypto.GenerateRandomBytes(32)
+	if rerr != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_SECRET_GENERATION", "Failed to generate the endpoint secret.", rerr)
+	}
+	endpoint := &types.InteractionEndpoint{
+		URL:    body.URL,
+		Secret: "whsec_" + hex.EncodeToString(secret),
+	}
+
+	if err := s.db.UpsertInteractionEndpoint(ctx, bot.ID, endpoint.URL, endpoint.Secret); err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_SET_INTERACTION_ENDPOINT", "Failed to set the interaction endpoint.", err)
+	}
+
+	return endpoint, nil
+}
+
+func (s *commandService) DeleteInteractionEndpoint(ctx *gin.Context) *types.APIError {
+	bot, err := s.ownedBot(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, derr := s.db.DeleteInteractionEndpoint(ctx, bot.ID)
+	if derr != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_INTERACTION_ENDPOINT", "Failed to delete the interaction endpoint.", derr)
+	}
+	if rows == 0 {
+		return types.NewAPIError(http.StatusNotFound, "ERR_INTERACTION_ENDPOINT_NOT_FOUND", "This bot has no interaction endpoint.", nil)
+	}
+
+	return nil
+}
+
+// InvokeCommand validates the options against the command and hands the interaction to its bot,
+// over the gateway when it is connected or through its interaction endpoint otherwise.
+func (s *commandService) InvokeCommand(ctx *gin.Context, body *types.InvokeCommandParams) (string, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return "", types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	if allowed := s.permissions.CheckPermission(ctx, body.ServerID, types.SendMessages); !allowed {
+		return "", types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to use commands in this server.", nil)
+	}
+
+	command, err := s.db.GetCommand(ctx, body.CommandID)
+	if err != nil || command.ServerID != body.ServerID {
+		return "", types.NewAPIError(http.StatusNotFound, "ERR_COMMAND_NOT_FOUND", "Command not found.", err)
+	}
+
+	channel, err := s.db.GetChannel(ctx, body.ChannelID)
+	if err != nil || channel.ServerID != body.ServerID {
+		return "", types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+
+	var definition []commands.Option
+	if err := json.Unmarshal(command.Options, &definition); err != nil {
+		return "", types.NewAPIError(http.StatusInternalServerError, "ERR_INVALID_COMMAND", "The command definition is corrupted.", err)
+	}
+
+	options, err := commands.Resolve(definition, body.Options)
+	if err != nil {
+		return "", types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_OPTIONS", "The command options are invalid.", err)
+	}
+
+	interaction := types.PendingInteraction{
+		ID:        cuid2.Generate(),
+		CommandID: command.ID,
+		BotID:     command.BotID,
+		ServerID:  body.ServerID,
+		ChannelID: body.ChannelID,
+		UserID:    user.ID,
+	}
+	if err := s.broker.CacheInteraction(ctx, interaction); err != nil {
+		return "", types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_INTERACTION", "Failed to create the interaction.", err)
+	}
+
+	payload := types.InteractionPayload{
+		ID:          interaction.ID,
+		CommandID:   command.ID,
+		CommandName: command.Name,
+		ServerID:    body.ServerID,
+		ChannelID:   body.ChannelID,
+		User: types.InteractionUser{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+		},
+		Options: options,
+	}
+	encodedOptions, _ := json.Marshal(options)
+
+	delivered := s.actors.SendInteraction(command.BotID, &proto.Interaction{
+		Id:          payload.ID,
+		CommandId:   payload.CommandID,
+		CommandName: payload.CommandName,
+		ServerId:    payload.ServerID,
+		ChannelId:   payload.ChannelID,
+		User: &proto.User{
+			Id:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+		},
+		Options: encodedOptions,
+	})
+	if delivered {
+		return interaction.ID, nil
+	}
+
+	if err := s.callEndpoint(ctx, command.BotID, &payload); err != nil {
+		return "", err
+	}
+
+	return interaction.ID, nil
+}
+
+// RespondToInteraction lets the bot answer an interaction. Regular replies go through the same path
+// as any message, ephemeral ones are only sent to the invoker and never stored.
+func (s *commandService) RespondToInteraction(ctx *gin.Context, body *types.InteractionResponseParams) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	bot := u.(*db.User)
+
+	interaction, err := s.broker.GetInteraction(ctx, ctx.Param("interaction_id"))
+	if err != nil || interaction.BotID != bot.ID {
+		return types.NewAPIError(http.StatusNotFound, "ERR_INTERACTION_NOT_FOUND", "Interaction not found or expired.", err)
+	}
+
+	if !body.Ephemeral {
+		return s.chat.createMessage(ctx, bot, nil, &types.CreateMessageParams{
+			ServerID:  interaction.ServerID,
+			ChannelID: interaction.ChannelID,
+			Content:   textDocument(body.Content),
+		}, nil)
+	}
+
+	s.actors.SendInteractionResponse(interaction.UserID, &proto.InteractionResponse{
+		InteractionId: interaction.ID,
+		ServerId:      interaction.ServerID,
+		ChannelId:     interaction.ChannelID,
+		Author: &proto.User{
+			Id:          bot.ID,
+			Avatar:      bot.Avatar.String,
+			DisplayName: bot.DisplayName,
+			Bot:         true,
+		},
+		Content: textDocument(body.Content),
+	})
+
+	return nil
+}
+
+func (s *commandService) callEndpoint(ctx context.Context, botID string, payload *types.InteractionPayload) *types.APIError {
+	endpoint, err := s.db.GetInteractionEndpoint(ctx, botID)
+	if err != nil {
+		return types.NewAPIError(http.StatusServiceUnavailable, "ERR_BOT_UNAVAILABLE", "The bot is offline.", err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_INTERACTION", "Failed to create the interaction.", err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, interactionTimeout)
+	defer cancel()
+
+	if _, err := webhooks.Send(callCtx, s.client, endpoint.Url, endpoint.Secret, payload.ID, "interaction.create", body); err != nil {
+		slog.Warn("failed to deliver interaction", "bot_id", botID, "err", err)
+		return types.NewAPIError(http.StatusBadGateway, "ERR_BOT_UNAVAILABLE", "The bot didn't acknowledge the interaction.", err)
+	}
+
+	return nil
+}
+
+func (s *commandService) checkMembership(ctx *gin.Context, serverID, userID string) *types.APIError {
+	serverIDs, err := s.db.GetUserServerIDs(ctx, userID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_SERVERS", "Failed to get servers.", err)
+	}
+	if !slices.Contains(serverIDs, serverID) {
+		return types.NewAPIError(http.StatusForbidden, "ERR_NOT_A_MEMBER", "The bot isn't a member of this server.", nil)
+	}
+
+	return nil
+}
+
+func (s *commandService) ownedBot(ctx *gin.Context) (*db.User, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	bot, err := s.db.GetBot(ctx, ctx.Param("bot_id"), user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_BOT_NOT_FOUND", "Bot not found.", err)
+	}
+
+	return &bot, nil
+}
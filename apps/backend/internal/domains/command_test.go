@@ -0,0 +1,44 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/types"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBotRegistersCommandsInServer(t *testing.T) {
+	owner := db.User{ID: "owner"}
+	bot := db.User{ID: "bot", Bot: true}
+	fdb := newFakeDB(owner, bot)
+
+	servers := NewServerService(fdb, fakeActors{}, nil, allowAll{}, fakeWebhooks{}, nil, nil, nil)
+	commands := NewCommandService(fdb, nil, fakeActors{}, allowAll{}, nil)
+
+	body := &types.RegisterCommandsParams{
+		Commands: []types.CommandDefinition{{Name: "deploy", Description: "Deploys a service."}},
+	}
+	serverParam := gin.Param{Key: "server_id", Value: "server"}
+
+	if _, err := commands.RegisterCommands(newTestContext(&bot, serverParam), body); err == nil || err.Status != http.StatusForbidden {
+		t.Fatalf("expected the bot to be refused before joining, got %v", err)
+	}
+
+	if err := servers.AddBot(newTestContext(&owner, serverParam, gin.Param{Key: "bot_id", Value: bot.ID})); err != nil {
+		t.Fatalf("failed to add the bot: %v", err)
+	}
+
+	created, err := commands.RegisterCommands(newTestContext(&bot, serverParam), body)
+	if err != nil {
+		t.Fatalf("failed to register commands: %v", err)
+	}
+	if len(created) != 1 || len(fdb.commands["server/bot"]) != 1 {
+		t.Fatalf("expected one registered command, got %d", len(created))
+	}
+
+	if err := servers.AddBot(newTestContext(&owner, serverParam, gin.Param{Key: "bot_id", Value: owner.ID})); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("expected users who aren't bots to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/types"
+	"backend/proto"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type DMService interface {
+	CreateGroupDM(ctx *gin.Context, body *types.CreateGroupDMParams) (*db.Channel, *types.APIError)
+	RenameGroupDM(ctx *gin.Context, body *types.RenameGroupDMParams) (*db.Channel, *types.APIError)
+	AddUsers(ctx *gin.Context, body *types.AddGroupDMUsersParams) (*db.Channel, *types.APIError)
+	RemoveUser(ctx *gin.Context) *types.APIError
+	LeaveGroupDM(ctx *gin.Context) *types.APIError
+}
+
+type dmService struct {
+	db     database.Service
+	actors actors.Service
+	e2ee   *e2eeService
+}
+
+func NewDMService(db database.Service, actors actors.Service, e2ee *e2eeService) *dmService {
+	return &dmService{
+		db:     db,
+		actors: actors,
+		e2ee:   e2ee,
+	}
+}
+
+// CreateGroupDM starts a group direct message owned by the user, with friends or members of the
+// servers they share who accept it.
+func (s *dmService) CreateGroupDM(ctx *gin.Context, body *types.CreateGroupDMParams) (*db.Channel, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	if slices.Contains(body.Users, userID) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_PARTICIPANT", "You are already in the group.", nil)
+	}
+
+	count, err := s.db.CountGroupDMs(ctx, userID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_COUNT_GROUP_DMS", "Failed to count the group messages.", err)
+	}
+	if count >= types.MaxGroupDMs {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_GROUP_DMS", "Leave a group before starting a new one.", nil)
+	}
+
+	if aerr := s.checkReachable(ctx, userID, body.Users); aerr != nil {
+		return nil, aerr
+	}
+
+	channel, err := s.db.CreateGroupDM(ctx, userID, body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_GROUP_DM", "Failed to create the group.", err)
+	}
+
+	s.actors.StartDMChannel(channel.ID, channel.Users)
+	s.sendChange(types.GroupDMCreate, &channel, userID, channel.Users, channel.Users)
+
+	return &channel, nil
+}
+
+func (s *dmService) RenameGroupDM(ctx *gin.Context, body *types.RenameGroupDMParams) (*db.Channel, *types.APIError) {
+	channel, userID, aerr := s.groupDM(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	renamed, err := s.db.RenameGroupDM(ctx, channel.ID, body.Name)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_GROUP_DM_NOT_FOUND", "Group not found.", err)
+	}
+
+	s.sendChange(types.GroupDMUpdate, &renamed, userID, nil, renamed.Users)
+
+	return &renamed, nil
+}
+
+// AddUsers lets any participant bring in people they could have started the group with.
+func (s *dmService) AddUsers(ctx *gin.Context, body *types.AddGroupDMUsersParams) (*db.Channel, *types.APIError) {
+	channel, userID, aerr := s.groupDM(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	added := slices.DeleteFunc(slices.Clone(body.Users), func(id string) bool { return slices.Contains(channel.Users, id) })
+	if len(added) == 0 {
+		return channel, nil
+	}
+
+	if aerr := s.checkReachable(ctx, userID, added); aerr != nil {
+		return nil, aerr
+	}
+
+	updated, err := s.db.AddGroupDMUsers(ctx, channel.ID, added)
+	if errors.Is(err, database.ErrGroupDMFull) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_GROUP_DM_FULL", "This group can't have more participants.", err)
+	}
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_GROUP_DM_NOT_FOUND", "Group not found.", err)
+	}
+
+	s.sendChange(types.GroupDMMemberAdd, &updated, userID, added, updated.Users)
+	s.e2ee.requireRekey(ctx, []string{updated.ID}, types.RekeyMembersChanged)
+
+	return &updated, nil
+}
+
+// RemoveUser is the owner kicking a participant out of the group.
+func (s *dmService) RemoveUser(ctx *gin.Context) *types.APIError {
+	channel, userID, aerr := s.groupDM(ctx)
+	if aerr != nil {
+		return aerr
+	}
+
+	if channel.OwnerID.String != userID {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "Only the owner of the group can remove its participants.", nil)
+	}
+
+	targetID := ctx.Param("user_id")
+	if targetID == userID {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_PARTICIPANT", "Leave the group instead.", nil)
+	}
+
+	return s.removeUser(ctx, channel, userID, targetID)
+}
+
+func (s *dmService) LeaveGroupDM(ctx *gin.Context) *types.APIError {
+	channel, userID, aerr := s.groupDM(ctx)
+	if aerr != nil {
+		return aerr
+	}
+
+	return s.removeUser(ctx, channel, userID, userID)
+}
+
+func (s *dmService) removeUser(ctx *gin.Context, channel *db.Channel, authorID, userID string) *types.APIError {
+	updated, err := s.db.RemoveGroupDMUser(ctx, channel.ID, userID)
+	if errors.Is(err, database.ErrNotParticipant) {
+		return types.NewAPIError(http.StatusNotFound, "ERR_PARTICIPANT_NOT_FOUND", "This user is not in the group.", err)
+	}
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_REMOVE_PARTICIPANT", "Failed to remove the participant.", err)
+	}
+
+	// The removed user hears about it one last time.
+	recipients := append(slices.Clone(updated.Users), userID)
+	if len(updated.Users) == 0 {
+		s.sendChange(types.GroupDMDelete, &updated, authorID, []string{userID}, recipients)
+		return nil
+	}
+
+	s.sendChange(types.GroupDMMemberRemove, &updated, authorID, []string{userID}, recipients)
+	s.e2ee.requireRekey(ctx, []string{updated.ID}, types.RekeyMembersChanged)
+
+	return nil
+}
+
+// groupDM returns the group direct message of the request once the user is checked to be in it.
+func (s *dmService) groupDM(ctx *gin.Context) (*db.Channel, string, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, "", types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	channel, err := s.db.GetGroupDM(ctx, ctx.Param("channel_id"))
+	if err != nil || !slices.Contains(channel.Users, userID) {
+		return nil, "", types.NewAPIError(http.StatusNotFound, "ERR_GROUP_DM_NOT_FOUND", "Group not found.", err)
+	}
+
+	return &channel, userID, nil
+}
+
+// checkReachable makes sure the user can add everyone of userIDs to a group: friends always, members
+// of a shared server unless their privacy settings say otherwise.
+func (s *dmService) checkReachable(ctx *gin.Context, userID string, userIDs []string) *types.APIError {
+	reachable, err := s.db.GetDMReachableUserIDs(ctx, userID, userIDs)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_USERS", "Failed to get the users.", err)
+	}
+
+	for _, id := range userIDs {
+		if !slices.Contains(reachable, id) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_USER_NOT_REACHABLE", "You can't add some of these users to a group.", nil)
+		}
+	}
+
+	return nil
+}
+
+func (s *dmService) sendChange(changeType string, channel *db.Channel, authorID string, userIDs, recipients []string) {
+	s.actors.GroupDMChange(&proto.GroupDMChange{
+		Type: changeType,
+		Channel: &proto.Channel{
+			Id:        channel.ID,
+			ServerId:  channel.ServerID,
+			Name:      channel.Name,
+			Type:      channel.Type,
+			E2Ee:      channel.E2ee,
+			Users:     channel.Users,
+			CreatedAt: timestamppb.New(channel.CreatedAt),
+			UpdatedAt: timestamppb.New(channel.UpdatedAt),
+			OwnerId:   channel.OwnerID.String,
+		},
+		AuthorId: authorID,
+		UserIds:  userIDs,
+	}, recipients)
+}
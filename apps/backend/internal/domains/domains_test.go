@@ -0,0 +1,184 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/types"
+	"backend/proto"
+	"context"
+	"net/http/httptest"
+	"slices"
+
+	"github.com/anthdm/hollywood/actor"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakeDB keeps the few tables the domain tests need in memory. The methods it doesn't override
+// panic through the nil embedded Service, so a test touching something unexpected fails loudly.
+type fakeDB struct {
+	database.Service
+	users    map[string]db.User
+	members  map[string][]string
+	commands map[string][]types.CommandDefinition
+	channels []db.Channel
+	roles    map[string][]string
+	posts    map[string][]db.GetGalleryPostsRow
+}
+
+func newFakeDB(users ...db.User) *fakeDB {
+	f := &fakeDB{
+		users:    make(map[string]db.User),
+		members:  make(map[string][]string),
+		commands: make(map[string][]types.CommandDefinition),
+		roles:    make(map[string][]string),
+		posts:    make(map[string][]db.GetGalleryPostsRow),
+	}
+	for _, user := range users {
+		f.users[user.ID] = user
+	}
+
+	return f
+}
+
+func (f *fakeDB) GetUserByID(_ context.Context, userID string) (db.User, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (f *fakeDB) CheckBan(context.Context, string, string) (pgtype.Text, error) {
+	return pgtype.Text{}, pgx.ErrNoRows
+}
+
+func (f *fakeDB) GetServerMemberIDs(_ context.Context, serverID string, userIDs []string) ([]string, error) {
+	var memberIDs []string
+	for _, userID := range userIDs {
+		if slices.Contains(f.members[serverID], userID) {
+			memberIDs = append(memberIDs, userID)
+		}
+	}
+
+	return memberIDs, nil
+}
+
+func (f *fakeDB) GetUserServerIDs(_ context.Context, userID string) ([]string, error) {
+	var serverIDs []string
+	for serverID, memberIDs := range f.members {
+		if slices.Contains(memberIDs, userID) {
+			serverIDs = append(serverIDs, serverID)
+		}
+	}
+
+	return serverIDs, nil
+}
+
+func (f *fakeDB) JoinServer(_ context.Context, serverID, userID string, _ int, _ string) (*db.JoinServerRow, []db.ChannelCategory, []db.Channel, []db.GetRolesFromServerRow, []db.GetLatestMessagesSentRow, error) {
+	f.members[serverID] = append(f.members[serverID], userID)
+	return &db.JoinServerRow{}, nil, nil, nil, nil, nil
+}
+
+func (f *fakeDB) SetCommands(_ context.Context, serverID, botID string, commands []types.CommandDefinition) ([]db.Command, error) {
+	f.commands[serverID+"/"+botID] = commands
+	return make([]db.Command, len(commands)), nil
+}
+
+func (f *fakeDB) GetChannel(_ context.Context, channelID string) (db.Channel, error) {
+	idx := slices.IndexFunc(f.channels, func(c db.Channel) bool { return c.ID == channelID })
+	if idx < 0 {
+		return db.Channel{}, pgx.ErrNoRows
+	}
+
+	return f.channels[idx], nil
+}
+
+// GetReadableChannelIDs follows the rules of the query, roles are the ones of f.roles.
+func (f *fakeDB) GetReadableChannelIDs(_ context.Context, serverID, userID string) ([]string, error) {
+	var channelIDs []string
+	for _, c := range f.channels {
+		open := len(c.Users) == 0 && len(c.Roles) == 0
+		shared := slices.ContainsFunc(f.roles[userID], func(role string) bool { return slices.Contains(c.Roles, role) })
+		if c.ServerID == serverID && (open || slices.Contains(c.Users, userID) || shared) {
+			channelIDs = append(channelIDs, c.ID)
+		}
+	}
+
+	return channelIDs, nil
+}
+
+func (f *fakeDB) GetKanbanBoard(context.Context, string) (*types.KanbanBoard, error) {
+	return &types.KanbanBoard{}, nil
+}
+
+func (f *fakeDB) GetGalleryPosts(_ context.Context, channelID, _ string, _ int) ([]db.GetGalleryPostsRow, error) {
+	return f.posts[channelID], nil
+}
+
+func (f *fakeDB) RemoveRoleMember(context.Context, *types.ChangeRoleMemberParams) error {
+	return nil
+}
+
+func (f *fakeDB) DeleteRole(context.Context, *types.DeleteRoleParams) error {
+	return nil
+}
+
+func (f *fakeDB) RequireRoleRekey(_ context.Context, serverID, roleID string) ([]db.Channel, error) {
+	var channels []db.Channel
+	for i, channel := range f.channels {
+		if channel.ServerID == serverID && channel.E2ee && slices.Contains(channel.Roles, roleID) {
+			f.channels[i].RekeyRequired = true
+			channels = append(channels, f.channels[i])
+		}
+	}
+
+	return channels, nil
+}
+
+// allowAll grants every ability, the permission checks themselves are tested with the roles.
+type allowAll struct{}
+
+func (allowAll) CheckPermission(*gin.Context, string, types.Ability, ...string) bool { return true }
+
+func (allowAll) HasAbility(context.Context, string, string, types.Ability) bool { return true }
+
+// fakeActors drops everything sent to the actors, nobody is connected.
+type fakeActors struct {
+	actors.Service
+}
+
+func (fakeActors) GetUser(string) *actor.PID { return nil }
+
+func (fakeActors) SendUserStatusMessage(*actor.PID, *proto.ChangeStatus) {}
+
+// recordingActors keeps the channel key changes, the rest is dropped like with fakeActors.
+type recordingActors struct {
+	fakeActors
+	keyChanges []*proto.ChannelKeyChange
+}
+
+func (a *recordingActors) ChannelKeyChange(change *proto.ChannelKeyChange) {
+	a.keyChanges = append(a.keyChanges, change)
+}
+
+func (*recordingActors) RemoveRole(*types.DeleteRoleParams) {}
+
+func (*recordingActors) RemoveRoleMember(*types.ChangeRoleMemberParams) {}
+
+type fakeWebhooks struct{}
+
+func (fakeWebhooks) Fire(string, types.WebhookEvent, any) {}
+
+// newTestContext returns a request context authenticated as user, with the given route parameters.
+func newTestContext(user *db.User, params ...gin.Param) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("POST", "/", nil)
+	ctx.Params = params
+	ctx.Set("user", user)
+
+	return ctx
+}
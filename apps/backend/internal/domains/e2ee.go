@@ -0,0 +1,469 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/e2ee"
+	"backend/internal/permissions"
+	"backend/internal/types"
+	"backend/proto"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type E2EEService interface {
+	GetDevices(ctx *gin.Context) ([]db.UserDevice, *types.APIError)
+	RegisterDevice(ctx *gin.Context, body *types.RegisterDeviceParams) (*db.UserDevice, *types.APIError)
+	RevokeDevice(ctx *gin.Context) *types.APIError
+	GetUserDevices(ctx *gin.Context) ([]types.DeviceKey, *types.APIError)
+	GetChannelDevices(ctx *gin.Context) ([]types.DeviceKey, *types.APIError)
+	EnableE2EE(ctx *gin.Context) (*db.Channel, *types.APIError)
+	GetChannelKeys(ctx *gin.Context, params *types.GetChannelKeysParams) ([]db.GetChannelKeysRow, *types.APIError)
+	RotateChannelKey(ctx *gin.Context, body *types.RotateChannelKeyParams) (*db.ChannelKey, *types.APIError)
+	AddKeyEnvelopes(ctx *gin.Context, body *types.AddKeyEnvelopesParams) *types.APIError
+	RequestKeyEnvelopes(ctx *gin.Context, body *types.RequestKeyEnvelopesParams) *types.APIError
+}
+
+type e2eeService struct {
+	db          database.Service
+	actors      actors.Service
+	permissions permissions.Service
+}
+
+func NewE2EEService(db database.Service, actors actors.Service, permissions permissions.Service) *e2eeService {
+	return &e2eeService{
+		db:          db,
+		actors:      actors,
+		permissions: permissions,
+	}
+}
+
+func (s *e2eeService) GetDevices(ctx *gin.Context) ([]db.UserDevice, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+
+	devices, err := s.db.GetUserDevices(ctx, user.(*db.User).ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_DEVICES", "Failed to get the devices.", err)
+	}
+
+	return devices, nil
+}
+
+// RegisterDevice publishes the keys of a new device. It only gets the group keys of the channels
+// once another device of a member seals them to it.
+func (s *e2eeService) RegisterDevice(ctx *gin.Context, body *types.RegisterDeviceParams) (*db.UserDevice, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	if err := e2ee.VerifyDevice(body.IdentityKey, body.PublicKey, body.Signature); err != nil {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_DEVICE_KEY", "The device key isn't signed by its identity key.", err)
+	}
+
+	count, err := s.db.CountUserDevices(ctx, userID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_COUNT_DEVICES", "Failed to count the devices.", err)
+	}
+	if count >= types.MaxUserDevices {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_DEVICES", "Revoke a device before registering a new one.", nil)
+	}
+
+	device, err := s.db.CreateUserDevice(ctx, userID, body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_REGISTER_DEVICE", "Failed to register the device.", err)
+	}
+
+	return &device, nil
+}
+
+// RevokeDevice removes the keys of a device. The group keys it holds can't be taken back, so every
+// encrypted channel of the user needs a new one.
+func (s *e2eeService) RevokeDevice(ctx *gin.Context) *types.APIError {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	if _, err := s.db.DeleteUserDevice(ctx, userID, ctx.Param("device_id")); err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	channels, err := s.db.GetUserE2EEChannels(ctx, userID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNELS", "Failed to get the encrypted channels.", err)
+	}
+
+	channelIDs := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	s.requireRekey(ctx, channelIDs, types.RekeyDeviceRevoked)
+
+	return nil
+}
+
+func (s *e2eeService) GetUserDevices(ctx *gin.Context) ([]types.DeviceKey, *types.APIError) {
+	devices, err := s.db.GetDevicesOfUsers(ctx, []string{ctx.Param("user_id")})
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_DEVICES", "Failed to get the devices.", err)
+	}
+
+	return deviceKeys(devices), nil
+}
+
+// GetChannelDevices lists every device a group key of the channel has to be sealed to.
+func (s *e2eeService) GetChannelDevices(ctx *gin.Context) ([]types.DeviceKey, *types.APIError) {
+	channel, _, aerr := s.e2eeChannel(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	devices, aerr := s.channelDevices(ctx, channel)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return deviceKeys(devices), nil
+}
+
+// EnableE2EE turns on the encryption of a channel, it can't be turned off. The channel takes no
+// message until a member shares the first group key.
+func (s *e2eeService) EnableE2EE(ctx *gin.Context) (*db.Channel, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+
+	channel, err := s.db.GetChannel(ctx, ctx.Param("channel_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if channel.Type != "textual" && channel.Type != "dm" && channel.Type != "group_dm" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_CHANNEL_TYPE", "Only text channels and direct messages can be encrypted.", nil)
+	}
+
+	// Every participant of a direct message can encrypt it, server channels are managed by the
+	// moderators.
+	if channel.ServerID == "global" {
+		if !slices.Contains(channel.Users, user.(*db.User).ID) {
+			return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to do this in this channel.", nil)
+		}
+	} else if allowed := s.permissions.CheckPermission(ctx, channel.ServerID, types.ManageChannels); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to do this in this channel.", nil)
+	}
+
+	if channel.E2ee {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_E2EE_ENABLED", "This channel is already encrypted.", nil)
+	}
+
+	channel, err = s.db.EnableChannelE2EE(ctx, channel.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_E2EE_ENABLED", "This channel is already encrypted.", err)
+	}
+
+	s.actors.ChannelKeyChange(&proto.ChannelKeyChange{
+		Type:      types.E2EEEnabled,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+	})
+
+	return &channel, nil
+}
+
+// GetChannelKeys returns the group keys of the channel sealed to a device of the user, from the
+// current one.
+func (s *e2eeService) GetChannelKeys(ctx *gin.Context, params *types.GetChannelKeysParams) ([]db.GetChannelKeysRow, *types.APIError) {
+	channel, userID, aerr := s.e2eeChannel(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if _, err := s.db.GetUserDevice(ctx, userID, params.DeviceID); err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	keys, err := s.db.GetChannelKeys(ctx, channel.ID, params.DeviceID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNEL_KEYS", "Failed to get the keys of the channel.", err)
+	}
+
+	return keys, nil
+}
+
+// RotateChannelKey replaces the group key of a channel. The new key has to be sealed to exactly the
+// devices of the current members, so that nobody who left can read what comes next.
+func (s *e2eeService) RotateChannelKey(ctx *gin.Context, body *types.RotateChannelKeyParams) (*db.ChannelKey, *types.APIError) {
+	channel, userID, aerr := s.e2eeChannel(ctx)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if _, err := s.db.GetUserDevice(ctx, userID, body.DeviceID); err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	if aerr := checkSealedKeys(body.Envelopes); aerr != nil {
+		return nil, aerr
+	}
+
+	devices, aerr := s.channelDevices(ctx, channel)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	missing, unexpected := e2ee.CompareRecipients(deviceIDs(devices), sealedKeyDevices(body.Envelopes))
+	if len(missing) > 0 || len(unexpected) > 0 {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_E2EE_RECIPIENTS", "The key has to be sealed to every device of the members of the channel, and to them only.", nil)
+	}
+
+	key, err := s.db.RotateChannelKey(ctx, channel.ID, body.DeviceID, body.Envelopes)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_ROTATE_CHANNEL_KEY", "Failed to rotate the key of the channel.", err)
+	}
+
+	s.actors.ChannelKeyChange(&proto.ChannelKeyChange{
+		Type:      types.KeyRotated,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		KeyId:     key.ID,
+		Epoch:     key.Epoch,
+		DeviceId:  body.DeviceID,
+	})
+
+	return &key, nil
+}
+
+// AddKeyEnvelopes shares a group key of the channel with devices of the members which don't have it,
+// like a device registered after the last rotation.
+func (s *e2eeService) AddKeyEnvelopes(ctx *gin.Context, body *types.AddKeyEnvelopesParams) *types.APIError {
+	channel, userID, aerr := s.e2eeChannel(ctx)
+	if aerr != nil {
+		return aerr
+	}
+
+	if _, err := s.db.GetUserDevice(ctx, userID, body.DeviceID); err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	key, err := s.db.GetChannelKey(ctx, channel.ID, ctx.Param("key_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_KEY_NOT_FOUND", "Key not found.", err)
+	}
+
+	if aerr := checkSealedKeys(body.Envelopes); aerr != nil {
+		return aerr
+	}
+
+	devices, aerr := s.channelDevices(ctx, channel)
+	if aerr != nil {
+		return aerr
+	}
+
+	if _, unexpected := e2ee.CompareRecipients(deviceIDs(devices), sealedKeyDevices(body.Envelopes)); len(unexpected) > 0 {
+		return types.NewAPIError(http.StatusConflict, "ERR_E2EE_RECIPIENTS", "Keys can only be sealed to the devices of the members of the channel.", nil)
+	}
+
+	// Devices which already have the key keep their envelope.
+	if err := s.db.AddChannelKeyEnvelopes(ctx, key.ID, body.DeviceID, body.Envelopes); err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_ADD_KEY_ENVELOPES", "Failed to share the key.", err)
+	}
+
+	s.actors.ChannelKeyChange(&proto.ChannelKeyChange{
+		Type:      types.EnvelopesAdded,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		KeyId:     key.ID,
+		Epoch:     key.Epoch,
+		DeviceId:  body.DeviceID,
+	})
+
+	return nil
+}
+
+// RequestKeyEnvelopes asks the other members to share the group keys of the channel with a device.
+func (s *e2eeService) RequestKeyEnvelopes(ctx *gin.Context, body *types.RequestKeyEnvelopesParams) *types.APIError {
+	channel, userID, aerr := s.e2eeChannel(ctx)
+	if aerr != nil {
+		return aerr
+	}
+
+	if _, err := s.db.GetUserDevice(ctx, userID, body.DeviceID); err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_DEVICE_NOT_FOUND", "Device not found.", err)
+	}
+
+	s.actors.ChannelKeyChange(&proto.ChannelKeyChange{
+		Type:      types.EnvelopesRequested,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		DeviceId:  body.DeviceID,
+	})
+
+	return nil
+}
+
+// requireRekey stops the encrypted channels among channelIDs from taking messages until one of
+// their members rotates the group key.
+func (s *e2eeService) requireRekey(ctx context.Context, channelIDs []string, reason string) {
+	if len(channelIDs) == 0 {
+		return
+	}
+
+	channels, err := s.db.RequireChannelsRekey(ctx, channelIDs)
+	if err != nil {
+		slog.Error("failed to require the rekey of channels", "channel_ids", channelIDs, "err", err)
+		return
+	}
+
+	s.sendRekeyRequired(channels, reason)
+}
+
+// requireServerRekey is requireRekey for every encrypted channel of a server, once a member is gone.
+func (s *e2eeService) requireServerRekey(ctx context.Context, serverID, reason string) {
+	channels, err := s.db.RequireServerRekey(ctx, serverID)
+	if err != nil {
+		slog.Error("failed to require the rekey of the server channels", "server_id", serverID, "err", err)
+		return
+	}
+
+	s.sendRekeyRequired(channels, reason)
+}
+
+// requireRoleRekey is requireRekey for the encrypted channels a role gives access to, once some of
+// its members lost it.
+func (s *e2eeService) requireRoleRekey(ctx context.Context, serverID, roleID, reason string) {
+	channels, err := s.db.RequireRoleRekey(ctx, serverID, roleID)
+	if err != nil {
+		slog.Error("failed to require the rekey of the role channels", "role_id", roleID, "err", err)
+		return
+	}
+
+	s.sendRekeyRequired(channels, reason)
+}
+
+func (s *e2eeService) sendRekeyRequired(channels []db.Channel, reason string) {
+	for _, channel := range channels {
+		s.actors.ChannelKeyChange(&proto.ChannelKeyChange{
+			Type:      types.RekeyRequired,
+			ServerId:  channel.ServerID,
+			ChannelId: channel.ID,
+			Reason:    reason,
+		})
+	}
+}
+
+// e2eeChannel returns the encrypted channel of the request once the user is checked to be one of
+// its members.
+func (s *e2eeService) e2eeChannel(ctx *gin.Context) (*db.Channel, string, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, "", types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	channel, err := s.db.GetChannel(ctx, ctx.Param("channel_id"))
+	if err != nil {
+		return nil, "", types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if !channel.E2ee {
+		return nil, "", types.NewAPIError(http.StatusBadRequest, "ERR_NOT_E2EE_CHANNEL", "This channel is not encrypted.", nil)
+	}
+
+	members, aerr := s.channelMembers(ctx, &channel)
+	if aerr != nil {
+		return nil, "", aerr
+	}
+	if !slices.Contains(members, userID) {
+		return nil, "", types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to do this in this channel.", nil)
+	}
+
+	return &channel, userID, nil
+}
+
+func (s *e2eeService) channelMembers(ctx context.Context, channel *db.Channel) ([]string, *types.APIError) {
+	if channel.ServerID == "global" {
+		return channel.Users, nil
+	}
+
+	members, err := s.db.GetChannelMemberIDs(ctx, channel.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_MEMBERS", "Failed to get the members of the channel.", err)
+	}
+
+	return members, nil
+}
+
+func (s *e2eeService) channelDevices(ctx context.Context, channel *db.Channel) ([]db.UserDevice, *types.APIError) {
+	members, aerr := s.channelMembers(ctx, channel)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	devices, err := s.db.GetDevicesOfUsers(ctx, members)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_DEVICES", "Failed to get the devices.", err)
+	}
+	if len(devices) > e2ee.MaxRecipients {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_TOO_MANY_DEVICES", "This channel has too many devices to be encrypted.", nil)
+	}
+
+	return devices, nil
+}
+
+func checkSealedKeys(envelopes []types.SealedKey) *types.APIError {
+	if len(envelopes) > e2ee.MaxRecipients {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_E2EE_TOO_MANY_DEVICES", "This channel has too many devices to be encrypted.", nil)
+	}
+
+	for _, envelope := range envelopes {
+		if err := e2ee.CheckSealedKey(envelope.Ciphertext); err != nil {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_SEALED_KEY", "Invalid sealed key.", err)
+		}
+	}
+
+	return nil
+}
+
+func deviceKeys(devices []db.UserDevice) []types.DeviceKey {
+	keys := make([]types.DeviceKey, 0, len(devices))
+	for _, device := range devices {
+		keys = append(keys, types.DeviceKey{
+			ID:          device.ID,
+			UserID:      device.UserID,
+			IdentityKey: device.IdentityKey,
+			PublicKey:   device.PublicKey,
+			Signature:   device.Signature,
+			CreatedAt:   device.CreatedAt,
+		})
+	}
+
+	return keys
+}
+
+func deviceIDs(devices []db.UserDevice) []string {
+	ids := make([]string, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+
+	return ids
+}
+
+func sealedKeyDevices(envelopes []types.SealedKey) []string {
+	ids := make([]string, 0, len(envelopes))
+	for _, envelope := range envelopes {
+		ids = append(ids, envelope.DeviceID)
+	}
+
+	return ids
+}
@@ -5,26 +5,37 @@ import (
 	"backend/internal/actors"
 	"backend/internal/database"
 	"backend/internal/types"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type FriendService interface {
 	SendRequest(ctx *gin.Context, body *types.SendRequestParams) (*db.GetFriendsRow, *types.APIError)
 	AcceptRequest(ctx *gin.Context, body *types.AcceptRequestParams) *types.APIError
 	RemoveFriend(ctx *gin.Context, body *types.RemoveFriendParams) *types.APIError
+	GetRequests(ctx *gin.Context) (*types.FriendRequests, *types.APIError)
+	DeclineRequest(ctx *gin.Context) *types.APIError
+	CancelRequest(ctx *gin.Context) *types.APIError
+	GetBlockedUsers(ctx *gin.Context) ([]db.GetBlockedUsersRow, *types.APIError)
+	BlockUser(ctx *gin.Context) *types.APIError
+	UnblockUser(ctx *gin.Context) *types.APIError
 }
 
 type friendService struct {
-	db     database.Service
-	actors actors.Service
+	db            database.Service
+	actors        actors.Service
+	notifications *notificationService
 }
 
-func NewFriendService(db database.Service, actors actors.Service) *friendService {
+func NewFriendService(db database.Service, actors actors.Service, notifications *notificationService) *friendService {
 	return &friendService{
-		db:     db,
-		actors: actors,
+		db:            db,
+		actors:        actors,
+		notifications: notifications,
 	}
 }
 
@@ -40,12 +51,49 @@ func (s *friendService) SendRequest(ctx *gin.Context, body *types.SendRequestPar
 		return nil, types.NewAPIError(http.StatusNotFound, "ERR_USER_NOT_FOUND", "User not found", err)
 	}
 
+	if receiver.ID == user.ID {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_FRIEND_SELF", "You can't send a friend request to yourself", nil)
+	}
+
+	if aerr := s.checkBlocked(ctx, user.ID, receiver.ID); aerr != nil {
+		return nil, aerr
+	}
+
+	friendships, err := s.db.GetFriendshipsBetween(ctx, user.ID, receiver.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_FRIENDS", "Failed to get the friendship", err)
+	}
+	for _, friendship := range friendships {
+		switch {
+		case friendship.Accepted:
+			return nil, types.NewAPIError(http.StatusConflict, "ERR_ALREADY_FRIENDS", "You are already friends with this user", nil)
+		case friendship.SenderID == user.ID:
+			return nil, types.NewAPIError(http.StatusConflict, "ERR_REQUEST_ALREADY_SENT", "You already sent a friend request to this user", nil)
+		default:
+			return nil, types.NewAPIError(http.StatusConflict, "ERR_REQUEST_ALREADY_RECEIVED", "This user already sent you a friend request", nil)
+		}
+	}
+
+	allowed, err := s.db.CanSendFriendRequest(ctx, user.ID, receiver.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_PRIVACY", "Failed to check the privacy settings of the user", err)
+	}
+	if !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FRIEND_REQUESTS_CLOSED", "This user doesn't accept friend requests from you", nil)
+	}
+
 	friendship, err := s.db.CreateFriendRequest(ctx, user.ID, receiver.ID)
+	// Another request made at the same time got in first.
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_REQUEST_ALREADY_SENT", "You already sent a friend request to this user", err)
+	}
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_FRIENDSHIP_CREATION", "Failed to create friend request", err)
 	}
 
 	s.actors.SendFriendRequest(friendship.ID, receiver.ID, user)
+	s.notifications.notifyFriendRequest(ctx, friendship, user)
 
 	return &db.GetFriendsRow{
 		ID:                 receiver.ID,
@@ -66,12 +114,21 @@ func (s *friendService) AcceptRequest(ctx *gin.Context, body *types.AcceptReques
 	}
 	user := u.(*db.User)
 
-	channelID, err := s.db.AcceptFriendRequest(ctx, body.FriendshipID, body.SenderID, user.ID)
+	if aerr := s.checkBlocked(ctx, user.ID, body.SenderID); aerr != nil {
+		return aerr
+	}
+
+	friendship, channelID, err := s.db.AcceptFriendRequest(ctx, body.FriendshipID, user.ID)
 	if err != nil {
-		return types.NewAPIError(http.StatusInternalServerError, "ERR_FRIENDSHIP_ACCEPT", "Failed to accept friend request", err)
+		return types.NewAPIError(http.StatusNotFound, "ERR_FRIEND_REQUEST_NOT_FOUND", "Friend request not found", err)
 	}
 
-	s.actors.AcceptFriendRequest(body.FriendshipID, body.SenderID, user.ID, *channelID)
+	s.actors.AcceptFriendRequest(friendship.ID, friendship.SenderID, friendship.ReceiverID, channelID)
+
+	// The request is answered, it leaves the notifications of the receiver.
+	if err := s.db.DeleteFriendshipNotifications(ctx, friendship.ID); err != nil {
+		slog.Error("failed to delete the notifications of a friend request", "friendship_id", friendship.ID, "err", err)
+	}
 
 	return nil
 }
@@ -83,12 +140,147 @@ func (s *friendService) RemoveFriend(ctx *gin.Context, body *types.RemoveFriendP
 	}
 	user := u.(*db.User)
 
-	if err := s.db.RemoveFriend(ctx, body.FriendshipID, user.ID); err != nil {
-		return types.NewAPIError(http.StatusInternalServerError, "ERR_FRIENDSHIP_REMOVE", "Failed to remove friend", err)
+	friendship, channelID, err := s.db.RemoveFriend(ctx, body.FriendshipID, user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_FRIEND_NOT_FOUND", "Friend not found", err)
+	}
+
+	s.actors.RemoveFriend(types.FriendRemoved, friendship, channelID)
+
+	return nil
+}
+
+func (s *friendService) GetRequests(ctx *gin.Context) (*types.FriendRequests, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	incoming, err := s.db.GetIncomingFriendRequests(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_FRIEND_REQUESTS", "Failed to get the friend requests", err)
+	}
+
+	outgoing, err := s.db.GetOutgoingFriendRequests(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_FRIEND_REQUESTS", "Failed to get the friend requests", err)
+	}
+
+	return &types.FriendRequests{
+		Incoming: incoming,
+		Outgoing: outgoing,
+	}, nil
+}
+
+// DeclineRequest is the receiver of a pending request turning it down.
+func (s *friendService) DeclineRequest(ctx *gin.Context) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	friendship, err := s.db.DeclineFriendRequest(ctx, ctx.Param("friendship_id"), user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_FRIEND_REQUEST_NOT_FOUND", "Friend request not found", err)
 	}
 
-	if body.ChannelID != "" {
-		s.actors.RemoveFriend(body.FriendshipID, body.SenderID, body.ReceiverID, body.ChannelID)
+	s.actors.RemoveFriend(types.FriendRequestDeclined, friendship, "")
+
+	return nil
+}
+
+// CancelRequest is the sender of a pending request taking it back.
+func (s *friendService) CancelRequest(ctx *gin.Context) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	friendship, err := s.db.CancelFriendRequest(ctx, ctx.Param("friendship_id"), user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_FRIEND_REQUEST_NOT_FOUND", "Friend request not found", err)
+	}
+
+	s.actors.RemoveFriend(types.FriendRequestCanceled, friendship, "")
+
+	return nil
+}
+
+func (s *friendService) GetBlockedUsers(ctx *gin.Context) ([]db.GetBlockedUsersRow, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	blocked, err := s.db.GetBlockedUsers(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BLOCKS", "Failed to get the blocked users", err)
+	}
+
+	return blocked, nil
+}
+
+// BlockUser ends any friendship or request with the user, who can't send a new one nor add the
+// blocker to a group anymore.
+func (s *friendService) BlockUser(ctx *gin.Context) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	target, err := s.db.GetUserByID(ctx, ctx.Param("user_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_USER_NOT_FOUND", "User not found", err)
+	}
+	if target.ID == user.ID {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_BLOCK_SELF", "You can't block yourself", nil)
+	}
+
+	_, ended, err := s.db.BlockUser(ctx, user.ID, target.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_BLOCK_USER", "Failed to block the user", err)
+	}
+
+	s.actors.BlockUser(user.ID, target.ID, ended)
+
+	return nil
+}
+
+func (s *friendService) UnblockUser(ctx *gin.Context) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+	targetID := ctx.Param("user_id")
+
+	_, channelID, err := s.db.UnblockUser(ctx, user.ID, targetID)
+	if errors.Is(err, database.ErrNotBlocked) {
+		return types.NewAPIError(http.StatusNotFound, "ERR_BLOCK_NOT_FOUND", "This user is not blocked", err)
+	}
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_UNBLOCK_USER", "Failed to unblock the user", err)
+	}
+
+	s.actors.UnblockUser(user.ID, targetID, channelID)
+
+	return nil
+}
+
+// checkBlocked stops friend requests between two users when either blocked the other. The error
+// doesn't say who did.
+func (s *friendService) checkBlocked(ctx *gin.Context, userID, otherID string) *types.APIError {
+	blocked, err := s.db.IsBlocked(ctx, userID, otherID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BLOCKS", "Failed to check the blocked users", err)
+	}
+	if blocked {
+		return types.NewAPIError(http.StatusForbidden, "ERR_USER_BLOCKED", "You can't send a friend request to this user", nil)
 	}
 
 	return nil
@@ -0,0 +1,114 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/database"
+	"backend/internal/files"
+	"backend/internal/permissions"
+	"backend/internal/types"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GalleryService interface {
+	GetMedia(ctx *gin.Context, params *types.GetGalleryMediaParams) (*types.GalleryPage, *types.APIError)
+}
+
+type galleryService struct {
+	db          database.Service
+	permissions permissions.Service
+}
+
+func NewGalleryService(db database.Service, permissions permissions.Service) *galleryService {
+	return &galleryService{
+		db:          db,
+		permissions: permissions,
+	}
+}
+
+// GetMedia lists the posts of a gallery channel from the newest, with the media to lay them out
+// in a grid. The comments are read with the messages of the post.
+func (s *galleryService) GetMedia(ctx *gin.Context, params *types.GetGalleryMediaParams) (*types.GalleryPage, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	channel, err := s.db.GetChannel(ctx, ctx.Param("channel_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if channel.Type != "gallery" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_NOT_GALLERY_CHANNEL", "This channel is not a gallery channel.", nil)
+	}
+
+	if allowed := s.permissions.CheckPermission(ctx, channel.ServerID, types.ViewChannels); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to view this channel.", nil)
+	}
+
+	// Galleries restricted to some users or roles are hidden from the other members.
+	readable, err := s.db.GetReadableChannelIDs(ctx, channel.ServerID, u.(*db.User).ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNELS", "Failed to get the channels.", err)
+	}
+	if !slices.Contains(readable, channel.ID) {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", nil)
+	}
+
+	posts, err := s.db.GetGalleryPosts(ctx, channel.ID, params.Before, params.Limit)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_MEDIA", "Failed to get the media of the channel.", err)
+	}
+
+	page := &types.GalleryPage{Posts: make([]types.GalleryPost, 0, len(posts))}
+	for _, post := range posts {
+		page.Posts = append(page.Posts, types.GalleryPost{
+			ID:        post.ID,
+			AuthorID:  post.AuthorID,
+			Content:   post.Content,
+			Media:     galleryMedia(messageFiles(post.Attachments)),
+			Comments:  post.Comments,
+			CreatedAt: post.CreatedAt,
+		})
+	}
+	if len(posts) == params.Limit {
+		page.Next = posts[len(posts)-1].ID
+	}
+
+	return page, nil
+}
+
+// galleryMedia keeps the images and videos of an album, the other files aren't shown in the grid.
+func galleryMedia(attachedFiles []files.File) []types.GalleryMedia {
+	media := []types.GalleryMedia{}
+	for _, file := range attachedFiles {
+		if !files.IsVisualMedia(file.Type) {
+			continue
+		}
+
+		item := types.GalleryMedia{
+			ID:         file.ID,
+			Type:       file.Type,
+			URL:        file.URL,
+			Width:      file.Width,
+			Height:     file.Height,
+			Duration:   file.Duration,
+			Blurhash:   file.Blurhash,
+			Processing: file.Processing,
+		}
+		// Videos only get a poster once they are processed.
+		if thumbnail, ok := file.Variants["thumbnail"]; ok {
+			item.Thumbnail = thumbnail.URL
+		} else if file.Poster != "" {
+			item.Thumbnail = file.Poster
+		} else if strings.HasPrefix(file.Type, "image/") {
+			item.Thumbnail = file.URL
+		}
+		media = append(media, item)
+	}
+
+	return media
+}
@@ -0,0 +1,33 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/types"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestrictedGallery(t *testing.T) {
+	member := db.User{ID: "member"}
+	fdb := newFakeDB(member)
+	fdb.channels = []db.Channel{
+		{ID: "public", ServerID: "server", Type: "gallery"},
+		{ID: "private", ServerID: "server", Type: "gallery", Users: []string{"owner"}},
+	}
+	fdb.posts["public"] = []db.GetGalleryPostsRow{{ID: "holiday", Attachments: []byte(`[]`)}}
+	fdb.posts["private"] = []db.GetGalleryPostsRow{{ID: "secret", Attachments: []byte(`[]`)}}
+
+	gallery := NewGalleryService(fdb, allowAll{})
+	params := &types.GetGalleryMediaParams{Limit: 50}
+
+	page, err := gallery.GetMedia(newTestContext(&member, gin.Param{Key: "channel_id", Value: "public"}), params)
+	if err != nil || len(page.Posts) != 1 || page.Posts[0].ID != "holiday" {
+		t.Fatalf("expected the media of the open gallery, got %v, %v", page, err)
+	}
+
+	if _, err := gallery.GetMedia(newTestContext(&member, gin.Param{Key: "channel_id", Value: "private"}), params); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("expected the restricted gallery to be hidden, got %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/permissions"
+	"backend/internal/types"
+	"backend/proto"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type KanbanService interface {
+	GetBoard(ctx *gin.Context) (*types.KanbanBoard, *types.APIError)
+	CreateColumn(ctx *gin.Context, body *types.CreateKanbanColumnParams) (*db.KanbanColumn, *types.APIError)
+	UpdateColumn(ctx *gin.Context, body *types.UpdateKanbanColumnParams) (*db.KanbanColumn, *types.APIError)
+	MoveColumn(ctx *gin.Context, body *types.MoveKanbanColumnParams) (*db.KanbanColumn, *types.APIError)
+	DeleteColumn(ctx *gin.Context) *types.APIError
+	CreateCard(ctx *gin.Context, body *types.CreateKanbanCardParams) (*db.KanbanCard, *types.APIError)
+	UpdateCard(ctx *gin.Context, body *types.UpdateKanbanCardParams) (*db.KanbanCard, *types.APIError)
+	MoveCard(ctx *gin.Context, body *types.MoveKanbanCardParams) (*db.KanbanCard, *types.APIError)
+	DeleteCard(ctx *gin.Context) *types.APIError
+}
+
+type kanbanService struct {
+	db          database.Service
+	actors      actors.Service
+	permissions permissions.Service
+}
+
+func NewKanbanService(db database.Service, actors actors.Service, permissions permissions.Service) *kanbanService {
+	return &kanbanService{
+		db:          db,
+		actors:      actors,
+		permissions: permissions,
+	}
+}
+
+func (s *kanbanService) GetBoard(ctx *gin.Context) (*types.KanbanBoard, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.ViewChannels)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	board, err := s.db.GetKanbanBoard(ctx, channel.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BOARD", "Failed to get the board.", err)
+	}
+
+	return board, nil
+}
+
+func (s *kanbanService) CreateColumn(ctx *gin.Context, body *types.CreateKanbanColumnParams) (*db.KanbanColumn, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.ManageChannels)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	column, err := s.db.CreateKanbanColumn(ctx, channel.ID, body)
+	if errors.Is(err, database.ErrKanbanFull) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_COLUMNS", "This board can't have more columns.", err)
+	}
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_COLUMN", "Failed to create the column.", err)
+	}
+
+	s.sendColumnChange(channel, types.KanbanColumnCreate, column)
+
+	return &column, nil
+}
+
+func (s *kanbanService) UpdateColumn(ctx *gin.Context, body *types.UpdateKanbanColumnParams) (*db.KanbanColumn, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.ManageChannels)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	column, err := s.db.UpdateKanbanColumn(ctx, channel.ID, ctx.Param("column_id"), body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_COLUMN_NOT_FOUND", "Column not found.", err)
+	}
+
+	s.sendColumnChange(channel, types.KanbanColumnUpdate, column)
+
+	return &column, nil
+}
+
+func (s *kanbanService) MoveColumn(ctx *gin.Context, body *types.MoveKanbanColumnParams) (*db.KanbanColumn, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.ManageChannels)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	column, err := s.db.MoveKanbanColumn(ctx, channel.ID, ctx.Param("column_id"), body.Position)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_COLUMN_NOT_FOUND", "Column not found.", err)
+	}
+
+	s.sendColumnChange(channel, types.KanbanColumnMove, column)
+
+	return &column, nil
+}
+
+func (s *kanbanService) DeleteColumn(ctx *gin.Context) *types.APIError {
+	channel, aerr := s.kanbanChannel(ctx, types.ManageChannels)
+	if aerr != nil {
+		return aerr
+	}
+
+	// Its cards and their comments go away with it.
+	column, err := s.db.DeleteKanbanColumn(ctx, channel.ID, ctx.Param("column_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_COLUMN_NOT_FOUND", "Column not found.", err)
+	}
+
+	s.sendColumnChange(channel, types.KanbanColumnDelete, column)
+
+	return nil
+}
+
+func (s *kanbanService) CreateCard(ctx *gin.Context, body *types.CreateKanbanCardParams) (*db.KanbanCard, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+
+	channel, aerr := s.kanbanChannel(ctx, types.SendMessages)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if aerr := s.checkAssignees(ctx, channel.ServerID, body.Assignees); aerr != nil {
+		return nil, aerr
+	}
+
+	card, err := s.db.CreateKanbanCard(ctx, channel.ID, user.(*db.User).ID, body)
+	if errors.Is(err, database.ErrKanbanFull) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_CARDS", "This column can't have more cards.", err)
+	}
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_COLUMN_NOT_FOUND", "Column not found.", err)
+	}
+
+	s.sendCardChange(channel, types.KanbanCardCreate, card)
+
+	return &card, nil
+}
+
+func (s *kanbanService) UpdateCard(ctx *gin.Context, body *types.UpdateKanbanCardParams) (*db.KanbanCard, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.SendMessages)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	if aerr := s.checkAssignees(ctx, channel.ServerID, body.Assignees); aerr != nil {
+		return nil, aerr
+	}
+
+	card, err := s.db.UpdateKanbanCard(ctx, channel.ID, ctx.Param("card_id"), body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CARD_NOT_FOUND", "Card not found.", err)
+	}
+
+	s.sendCardChange(channel, types.KanbanCardUpdate, card)
+
+	return &card, nil
+}
+
+func (s *kanbanService) MoveCard(ctx *gin.Context, body *types.MoveKanbanCardParams) (*db.KanbanCard, *types.APIError) {
+	channel, aerr := s.kanbanChannel(ctx, types.SendMessages)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	card, err := s.db.MoveKanbanCard(ctx, channel.ID, ctx.Param("card_id"), body)
+	if errors.Is(err, database.ErrKanbanFull) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_TOO_MANY_CARDS", "This column can't have more cards.", err)
+	}
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CARD_NOT_FOUND", "Card or column not found.", err)
+	}
+
+	s.sendCardChange(channel, types.KanbanCardMove, card)
+
+	return &card, nil
+}
+
+func (s *kanbanService) DeleteCard(ctx *gin.Context) *types.APIError {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+
+	channel, aerr := s.kanbanChannel(ctx, types.ViewChannels)
+	if aerr != nil {
+		return aerr
+	}
+
+	cardID := ctx.Param("card_id")
+	card, err := s.db.GetKanbanCard(ctx, channel.ID, cardID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CARD_NOT_FOUND", "Card not found.", err)
+	}
+
+	// Like messages, cards are deleted by their author or by the moderators.
+	userID := user.(*db.User).ID
+	if card.AuthorID.String != userID && !s.permissions.HasAbility(ctx, channel.ServerID, userID, types.ManageMessages) {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to delete this card.", nil)
+	}
+
+	card, err = s.db.DeleteKanbanCard(ctx, channel.ID, cardID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CARD_NOT_FOUND", "Card not found.", err)
+	}
+
+	s.sendCardChange(channel, types.KanbanCardDelete, card)
+
+	return nil
+}
+
+// kanbanChannel returns the kanban channel of the request once the user is checked for reading it
+// and for the ability.
+func (s *kanbanService) kanbanChannel(ctx *gin.Context, ability types.Ability) (*db.Channel, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	channel, err := s.db.GetChannel(ctx, ctx.Param("channel_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if channel.Type != "kanban" {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_NOT_KANBAN_CHANNEL", "This channel is not a kanban channel.", nil)
+	}
+
+	// Boards restricted to some users or roles are hidden from the other members.
+	readable, err := s.db.GetReadableChannelIDs(ctx, channel.ServerID, u.(*db.User).ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNELS", "Failed to get the channels.", err)
+	}
+	if !slices.Contains(readable, channel.ID) {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", nil)
+	}
+
+	if allowed := s.permissions.CheckPermission(ctx, channel.ServerID, ability); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to do this in this channel.", nil)
+	}
+
+	return &channel, nil
+}
+
+// checkAssignees makes sure cards are only assigned to members of the server.
+func (s *kanbanService) checkAssignees(ctx *gin.Context, serverID string, assignees []string) *types.APIError {
+	if len(assignees) == 0 {
+		return nil
+	}
+
+	members, err := s.db.GetServerMemberIDs(ctx, serverID, assignees)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_MEMBERS", "Failed to get the members of the server.", err)
+	}
+
+	for _, userID := range assignees {
+		if !slices.Contains(members, userID) {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_ASSIGNEE", "Cards can only be assigned to members of the server.", nil)
+		}
+	}
+
+	return nil
+}
+
+func (s *kanbanService) sendColumnChange(channel *db.Channel, changeType string, column db.KanbanColumn) {
+	s.actors.KanbanChange(&proto.KanbanChange{
+		Type:      changeType,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		Column: &proto.KanbanColumn{
+			Id:       column.ID,
+			Name:     column.Name,
+			Position: column.Position,
+		},
+	})
+}
+
+func (s *kanbanService) sendCardChange(channel *db.Channel, changeType string, card db.KanbanCard) {
+	pbCard := &proto.KanbanCard{
+		Id:          card.ID,
+		ColumnId:    card.ColumnID,
+		AuthorId:    card.AuthorID.String,
+		Title:       card.Title,
+		Description: card.Description,
+		Assignees:   card.Assignees,
+		Labels:      card.Labels,
+		Position:    card.Position,
+		CreatedAt:   timestamppb.New(card.CreatedAt),
+		UpdatedAt:   timestamppb.New(card.UpdatedAt),
+	}
+	if card.DueAt.Valid {
+		pbCard.DueAt = timestamppb.New(card.DueAt.Time)
+	}
+
+	s.actors.KanbanChange(&proto.KanbanChange{
+		Type:      changeType,
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		Card:      pbCard,
+	})
+}
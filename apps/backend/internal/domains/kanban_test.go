@@ -0,0 +1,32 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPrivateBoard(t *testing.T) {
+	member := db.User{ID: "member"}
+	staff := db.User{ID: "staff"}
+	fdb := newFakeDB(member, staff)
+	fdb.channels = []db.Channel{
+		{ID: "board", ServerID: "server", Type: "kanban", Roles: []string{"staff"}},
+	}
+	fdb.roles["staff"] = []string{"staff"}
+
+	kanban := NewKanbanService(fdb, fakeActors{}, allowAll{})
+	param := gin.Param{Key: "channel_id", Value: "board"}
+
+	if _, err := kanban.GetBoard(newTestContext(&member, param)); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("expected the board to be hidden from the other members, got %v", err)
+	}
+	if err := kanban.DeleteCard(newTestContext(&member, param)); err == nil || err.Status != http.StatusNotFound {
+		t.Fatalf("expected the cards to be hidden from the other members, got %v", err)
+	}
+	if _, err := kanban.GetBoard(newTestContext(&staff, param)); err != nil {
+		t.Fatalf("failed to get the board with the role: %v", err)
+	}
+}
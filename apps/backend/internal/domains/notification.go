@@ -0,0 +1,247 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/permissions"
+	"backend/internal/types"
+	"backend/proto"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type NotificationService interface {
+	GetNotifications(ctx *gin.Context, params *types.GetNotificationsParams) (*types.NotificationPage, *types.APIError)
+	ReadNotifications(ctx *gin.Context, body *types.ReadNotificationsParams) *types.APIError
+	ReadAllNotifications(ctx *gin.Context, body *types.ReadAllNotificationsParams) *types.APIError
+	SendInvite(ctx *gin.Context, body *types.SendInviteParams) *types.APIError
+}
+
+type notificationService struct {
+	db          database.Service
+	actors      actors.Service
+	permissions permissions.Service
+}
+
+func NewNotificationService(db database.Service, actors actors.Service, permissions permissions.Service) *notificationService {
+	return &notificationService{
+		db:          db,
+		actors:      actors,
+		permissions: permissions,
+	}
+}
+
+func (s *notificationService) GetNotifications(ctx *gin.Context, params *types.GetNotificationsParams) (*types.NotificationPage, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+
+	rows, err := s.db.GetNotifications(ctx, user.(*db.User).ID, params)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_NOTIFICATIONS", "Failed to get the notifications.", err)
+	}
+
+	page := &types.NotificationPage{Notifications: make([]types.Notification, 0, len(rows))}
+	for _, row := range rows {
+		notification := types.Notification{
+			ID:           row.ID,
+			Type:         row.Type,
+			ServerID:     row.ServerID.String,
+			ChannelID:    row.ChannelID.String,
+			MessageID:    row.MessageID.String,
+			Content:      row.MessageContent,
+			FriendshipID: row.FriendshipID.String,
+			InviteID:     row.InviteID.String,
+			Read:         row.ReadAt.Valid,
+			CreatedAt:    row.CreatedAt,
+		}
+		if row.ActorID.Valid {
+			notification.Actor = &types.NotificationActor{
+				ID:          row.ActorID.String,
+				DisplayName: row.ActorDisplayName.String,
+				Avatar:      row.ActorAvatar.String,
+			}
+		}
+		page.Notifications = append(page.Notifications, notification)
+	}
+	if len(rows) == params.Limit {
+		page.Next = rows[len(rows)-1].ID
+	}
+
+	return page, nil
+}
+
+func (s *notificationService) ReadNotifications(ctx *gin.Context, body *types.ReadNotificationsParams) *types.APIError {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	ids, err := s.db.ReadNotifications(ctx, userID, body.IDs)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_READ_NOTIFICATIONS", "Failed to mark the notifications as read.", err)
+	}
+
+	if len(ids) > 0 {
+		s.actors.NotificationsRead(userID, ids)
+	}
+
+	return nil
+}
+
+// ReadAllNotifications clears the feed of the user, or only the notifications of a server.
+func (s *notificationService) ReadAllNotifications(ctx *gin.Context, body *types.ReadAllNotificationsParams) *types.APIError {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	userID := user.(*db.User).ID
+
+	ids, err := s.db.ReadAllNotifications(ctx, userID, body.ServerID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_READ_NOTIFICATIONS", "Failed to mark the notifications as read.", err)
+	}
+
+	if len(ids) > 0 {
+		s.actors.NotificationsRead(userID, ids)
+	}
+
+	return nil
+}
+
+// SendInvite sends an invite of a server to friends of the user, who find it in their notifications.
+func (s *notificationService) SendInvite(ctx *gin.Context, body *types.SendInviteParams) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	invite, err := s.db.GetInvite(ctx, ctx.Param("invite_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_INVITE_NOT_FOUND", "Invite not found.", err)
+	}
+	// Friends would be sent an invite they can't join with, it goes through the check of joining.
+	if _, err := s.db.CheckInvite(ctx, invite.InviteID); err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_INVITE_NOT_FOUND", "This invite is invalid or has expired.", err)
+	}
+
+	if allowed := s.permissions.CheckPermission(ctx, invite.ServerID, types.CreateInvite); !allowed {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to send invites.", nil)
+	}
+
+	friends, err := s.db.GetFriends(ctx, user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_FRIENDS", "Failed to get the friends.", err)
+	}
+	for _, userID := range body.Users {
+		if !slices.ContainsFunc(friends, func(f db.GetFriendsRow) bool { return f.Accepted && f.ID == userID }) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_NOT_FRIEND", "Invites can only be sent to friends.", nil)
+		}
+	}
+
+	notificationTypes := make([]string, len(body.Users))
+	for i := range notificationTypes {
+		notificationTypes[i] = types.NotificationInvite
+	}
+
+	notifications, err := s.db.CreateNotifications(ctx, body.Users, notificationTypes, db.CreateNotificationsParams{
+		ActorID:  pgtype.Text{String: user.ID, Valid: true},
+		ServerID: pgtype.Text{String: invite.ServerID, Valid: true},
+		InviteID: pgtype.Text{String: invite.ID, Valid: true},
+	})
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_SEND_INVITE", "Failed to send the invite.", err)
+	}
+
+	s.push(notifications, userActor(user), nil)
+
+	return nil
+}
+
+// notifyMessage notifies the users a new message mentions or replies to. replyAuthorID is the author
+// of the message it replies to, author is the message author as shown in the channel.
+func (s *notificationService) notifyMessage(ctx context.Context, message db.Message, replyAuthorID string, author *proto.User) {
+	// Mentioning everyone in a server takes its ability, direct messages only reach their participants.
+	everyone := message.Everyone && (message.ServerID == "global" || s.permissions.HasAbility(ctx, message.ServerID, message.AuthorID, types.MentionEveryone))
+
+	if replyAuthorID == "" && len(message.MentionsUsers) == 0 && len(message.MentionsRoles) == 0 && !everyone {
+		return
+	}
+
+	targets, err := s.db.GetMessageNotificationTargets(ctx, message, replyAuthorID, everyone)
+	if err != nil {
+		slog.Error("failed to get the users to notify of a message", "message_id", message.ID, "err", err)
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	userIDs := make([]string, 0, len(targets))
+	notificationTypes := make([]string, 0, len(targets))
+	for _, target := range targets {
+		userIDs = append(userIDs, target.UserID)
+		notificationTypes = append(notificationTypes, target.Type)
+	}
+
+	notifications, err := s.db.CreateNotifications(ctx, userIDs, notificationTypes, db.CreateNotificationsParams{
+		ActorID:   pgtype.Text{String: message.AuthorID, Valid: true},
+		ServerID:  pgtype.Text{String: message.ServerID, Valid: message.ServerID != "global"},
+		ChannelID: pgtype.Text{String: message.ChannelID, Valid: true},
+		MessageID: pgtype.Text{String: message.ID, Valid: true},
+	})
+	if err != nil {
+		slog.Error("failed to create the notifications of a message", "message_id", message.ID, "err", err)
+		return
+	}
+
+	s.push(notifications, author, message.Content)
+}
+
+func (s *notificationService) notifyFriendRequest(ctx context.Context, friendship db.Friend, sender *db.User) {
+	notifications, err := s.db.CreateNotifications(ctx, []string{friendship.ReceiverID}, []string{types.NotificationFriendRequest}, db.CreateNotificationsParams{
+		ActorID:      pgtype.Text{String: sender.ID, Valid: true},
+		FriendshipID: pgtype.Text{String: friendship.ID, Valid: true},
+	})
+	if err != nil {
+		slog.Error("failed to create the notification of a friend request", "friendship_id", friendship.ID, "err", err)
+		return
+	}
+
+	s.push(notifications, userActor(sender), nil)
+}
+
+func (s *notificationService) push(notifications []db.Notification, actor *proto.User, content []byte) {
+	for _, notification := range notifications {
+		s.actors.Notify(notification.UserID, &proto.Notification{
+			Id:           notification.ID,
+			Type:         notification.Type,
+			Actor:        actor,
+			ServerId:     notification.ServerID.String,
+			ChannelId:    notification.ChannelID.String,
+			MessageId:    notification.MessageID.String,
+			Content:      content,
+			FriendshipId: notification.FriendshipID.String,
+			InviteId:     notification.InviteID.String,
+			CreatedAt:    timestamppb.New(notification.CreatedAt),
+		})
+	}
+}
+
+func userActor(user *db.User) *proto.User {
+	return &proto.User{
+		Id:          user.ID,
+		DisplayName: user.DisplayName,
+		Avatar:      user.Avatar.String,
+	}
+}
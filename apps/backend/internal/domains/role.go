@@ -24,13 +24,15 @@ type roleService struct {
 	db          database.Service
 	actors      actors.Service
 	permissions permissions.Service
+	e2ee        *e2eeService
 }
 
-func NewRoleService(db database.Service, actors actors.Service, permissions permissions.Service) *roleService {
+func NewRoleService(db database.Service, actors actors.Service, permissions permissions.Service, e2ee *e2eeService) *roleService {
 	return &roleService{
 		db:          db,
 		actors:      actors,
 		permissions: permissions,
+		e2ee:        e2ee,
 	}
 }
 
@@ -59,6 +61,8 @@ func (s *roleService) DeleteRole(ctx *gin.Context, body *types.DeleteRoleParams)
 	}
 
 	s.actors.RemoveRole(body)
+	// The members of the role lost the channels it opened.
+	s.e2ee.requireRoleRekey(ctx, body.ServerID, body.RoleID, types.RekeyMembersChanged)
 
 	return nil
 }
@@ -87,6 +91,7 @@ func (s *roleService) RemoveRoleMember(ctx *gin.Context, body *types.ChangeRoleM
 	}
 
 	s.actors.RemoveRoleMember(body)
+	s.e2ee.requireRoleRekey(ctx, body.ServerID, body.RoleID, types.RekeyMembersChanged)
 
 	return nil
 }
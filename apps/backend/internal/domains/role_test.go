@@ -0,0 +1,39 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/types"
+	"testing"
+)
+
+func TestRoleChangesRequireRekey(t *testing.T) {
+	owner := db.User{ID: "owner"}
+	fdb := newFakeDB(owner)
+	fdb.channels = []db.Channel{
+		{ID: "secret", ServerID: "server", E2ee: true, Roles: []string{"staff"}},
+		{ID: "plain", ServerID: "server", Roles: []string{"staff"}},
+		{ID: "other", ServerID: "server", E2ee: true, Roles: []string{"guests"}},
+	}
+
+	actors := &recordingActors{}
+	roles := NewRoleService(fdb, actors, allowAll{}, NewE2EEService(fdb, actors, allowAll{}))
+
+	body := &types.ChangeRoleMemberParams{ServerID: "server", RoleID: "staff", UserID: "member"}
+	if err := roles.RemoveRoleMember(newTestContext(&owner), body); err != nil {
+		t.Fatalf("failed to remove the role: %v", err)
+	}
+	if len(actors.keyChanges) != 1 || actors.keyChanges[0].ChannelId != "secret" || actors.keyChanges[0].Type != types.RekeyRequired {
+		t.Fatalf("expected the encrypted channel of the role to need a new key, got %v", actors.keyChanges)
+	}
+	if !fdb.channels[0].RekeyRequired || fdb.channels[2].RekeyRequired {
+		t.Fatal("expected only the channel of the role to be marked")
+	}
+
+	actors.keyChanges = nil
+	if err := roles.DeleteRole(newTestContext(&owner), &types.DeleteRoleParams{ServerID: "server", RoleID: "guests"}); err != nil {
+		t.Fatalf("failed to delete the role: %v", err)
+	}
+	if len(actors.keyChanges) != 1 || actors.keyChanges[0].ChannelId != "other" {
+		t.Fatalf("expected the members of the deleted role to be rekeyed out, got %v", actors.keyChanges)
+	}
+}
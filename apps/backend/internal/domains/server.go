@@ -3,19 +3,26 @@ package domains
 import (
 	db "backend/db/gen_queries"
 	"backend/internal/actors"
+	"backend/internal/attachments"
 	"backend/internal/database"
 	"backend/internal/files"
 	"backend/internal/permissions"
+	"backend/internal/quotas"
 	"backend/internal/types"
 	"backend/internal/validation"
+	"backend/internal/webhooks"
 	"backend/proto"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/nrednav/cuid2"
 )
 
@@ -23,8 +30,13 @@ type ServerService interface {
 	CreateServer(ctx *gin.Context, serverAvatar []*multipart.FileHeader, body *types.CreateServerParams) (*db.Server, *types.APIError)
 	JoinServer(ctx *gin.Context, body *types.JoinServerParams) (*types.JoinServerWithCategories, *types.APIError)
 	LeaveServer(ctx *gin.Context) *types.APIError
-	CreateInvite(ctx *gin.Context) (*string, *types.APIError)
+	AddBot(ctx *gin.Context) *types.APIError
+	CreateInvite(ctx *gin.Context, body *types.CreateInviteParams) (*string, *types.APIError)
+	GetInvites(ctx *gin.Context) ([]db.GetServerInvitesRow, *types.APIError)
 	DeleteInvite(ctx *gin.Context) *types.APIError
+	SetVanityInvite(ctx *gin.Context, body *types.SetVanityInviteParams) (*db.Invite, *types.APIError)
+	GetInvitePreview(ctx *gin.Context) (*types.InvitePreview, *types.APIError)
+	DiscoverServers(ctx *gin.Context, params *types.DiscoverServersParams) ([]types.DiscoverableServer, *types.APIError)
 	UpdateProfile(ctx *gin.Context, body *types.UpdateServerProfileParams) *types.APIError
 	UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileHeader, banner []*multipart.FileHeader, body *types.UpdateAvatarParams) (*string, *string, *types.APIError)
 	DeleteServer(ctx *gin.Context) *types.APIError
@@ -35,6 +47,17 @@ type ServerService interface {
 	UnbanUser(ctx *gin.Context) *types.APIError
 	KickUser(ctx *gin.Context, body *types.KickUserParams) *types.APIError
 	SearchMembers(ctx *gin.Context) ([]db.SearchServerMembersRow, *types.APIError)
+	GetStorageUsage(ctx *gin.Context) (*types.StorageUsage, *types.APIError)
+	GetEmojis(ctx *gin.Context) ([]db.ServerEmoji, *types.APIError)
+	UploadEmojis(ctx *gin.Context, emojis []*multipart.FileHeader, body *types.UploadServerEmojiParams) ([]db.ServerEmoji, *types.APIError)
+	UpdateEmoji(ctx *gin.Context, body *types.UpdateEmojiParams) (*db.ServerEmoji, *types.APIError)
+	DeleteEmoji(ctx *gin.Context) *types.APIError
+}
+
+// maxServerEmojis is how many emojis and stickers a server can have, of each kind.
+var maxServerEmojis = map[types.ServerEmojiKind]int{
+	types.ServerEmoji:   100,
+	types.ServerSticker: 30,
 }
 
 type serverService struct {
@@ -42,14 +65,22 @@ type serverService struct {
 	files       files.Service
 	actors      actors.Service
 	permissions permissions.Service
+	webhooks    webhooks.Service
+	attachments attachments.Service
+	quotas      quotas.Service
+	e2ee        *e2eeService
 }
 
-func NewServerService(db database.Service, actors actors.Service, files files.Service, permissions permissions.Service) *serverService {
+func NewServerService(db database.Service, actors actors.Service, files files.Service, permissions permissions.Service, webhooks webhooks.Service, attachments attachments.Service, quotas quotas.Service, e2ee *e2eeService) *serverService {
 	return &serverService{
 		db:          db,
 		files:       files,
 		actors:      actors,
 		permissions: permissions,
+		webhooks:    webhooks,
+		attachments: attachments,
+		quotas:      quotas,
+		e2ee:        e2ee,
 	}
 }
 
@@ -58,19 +89,31 @@ func (s *serverService) CreateServer(ctx *gin.Context, serverAvatar []*multipart
 	if !exists {
 		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
 	}
+	user := u.(*db.User)
 
-	avatarURL, perr := s.files.ProcessAndUploadAvatar(cuid2.Generate(), "avatar", serverAvatar[0], body.Crop)
+	if aerr := s.quotas.CheckFileSize(user, serverAvatar[0].Size); aerr != nil {
+		return nil, aerr
+	}
+	// The server has no storage of its own yet, its avatar has to fit in its creator's.
+	if aerr := s.quotas.CheckUser(ctx, user, serverAvatar[0].Size); aerr != nil {
+		return nil, aerr
+	}
+
+	avatarURL, perr := s.files.ProcessAndUploadAvatar(ctx, cuid2.Generate(), "avatar", serverAvatar[0], body.Crop)
 	if perr != nil {
 		return nil, perr
 	}
 
-	user := u.(*db.User)
-
 	server, err := s.db.CreateServer(ctx, user.ID, body, avatarURL)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_SERVER", "Failed to create server.", err)
 	}
 
+	owner := types.AttachmentOwner{Kind: types.AttachmentServerAvatar, ID: server.ID, Quota: types.QuotaAccount{ServerID: server.ID}}
+	if err := s.attachments.Track(ctx, owner, types.StoredFile{URL: *avatarURL, Size: serverAvatar[0].Size}); err != nil {
+		fmt.Println("Failed to track server avatar:", err)
+	}
+
 	s.actors.StartServerInRegion(server.ID, os.Getenv("REGION"))
 	userPID := s.actors.GetUser(user.ID)
 
@@ -98,11 +141,23 @@ func (s *serverService) JoinServer(ctx *gin.Context, body *types.JoinServerParam
 
 	var serverID string
 	if body.InviteID != "" {
-		id, err := s.db.CheckInvite(ctx, body.InviteID)
+		invite, err := s.db.CheckInvite(ctx, body.InviteID)
 		if err != nil {
-			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CHECK_INVITE", "Failed to check invite.", err)
+			return nil, types.NewAPIError(http.StatusNotFound, "ERR_INVITE_NOT_FOUND", "This invite is invalid or has expired.", err)
+		}
+		serverID = invite.ServerID
+	} else if body.ServerID != "" {
+		// Public servers can be joined from the discovery without an invite.
+		server, err := s.db.GetServer(ctx, body.ServerID)
+		if err != nil {
+			return nil, types.NewAPIError(http.StatusNotFound, "ERR_SERVER_NOT_FOUND", "Server not found.", err)
+		}
+		if !server.Public {
+			return nil, types.NewAPIError(http.StatusForbidden, "ERR_SERVER_NOT_PUBLIC", "This server can only be joined with an invite.", nil)
 		}
-		serverID = id
+		serverID = server.ID
+	} else {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_MISSING_INVITE", "An invite or a public server is required.", nil)
 	}
 
 	reason, err := s.db.CheckBan(ctx, serverID, user.ID)
@@ -110,7 +165,7 @@ func (s *serverService) JoinServer(ctx *gin.Context, body *types.JoinServerParam
 		return nil, types.NewAPIError(http.StatusForbidden, "USER_BANNED", reason.String, nil)
 	}
 
-	server, categories, channels, roles, latestMessagesSent, err := s.db.JoinServer(ctx, serverID, user.ID, body.Position)
+	server, categories, channels, roles, latestMessagesSent, err := s.db.JoinServer(ctx, serverID, user.ID, body.Position, body.InviteID)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_JOIN_SERVER", "Failed to join server.", err)
 	}
@@ -158,6 +213,7 @@ func (s *serverService) JoinServer(ctx *gin.Context, body *types.JoinServerParam
 		Status:   "online",
 	}
 	s.actors.SendUserStatusMessage(userPID, changeStatus)
+	s.webhooks.Fire(server.ID, types.EventMemberJoin, types.WebhookMember{UserID: user.ID})
 
 	return serverWithCategories, nil
 }
@@ -177,11 +233,63 @@ func (s *serverService) LeaveServer(ctx *gin.Context) *types.APIError {
 	}
 
 	s.actors.LeaveServer(serverID, userID)
+	s.e2ee.requireServerRekey(ctx, serverID, types.RekeyMembersChanged)
+	s.webhooks.Fire(serverID, types.EventMemberLeave, types.WebhookMember{UserID: userID})
+
+	return nil
+}
+
+// AddBot makes a bot a member of the server. Bots have no session to join with an invite, they are
+// added by the members managing the server.
+func (s *serverService) AddBot(ctx *gin.Context) *types.APIError {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to add bots to this server.", nil)
+	}
+
+	bot, err := s.db.GetUserByID(ctx, ctx.Param("bot_id"))
+	if err != nil || !bot.Bot {
+		return types.NewAPIError(http.StatusNotFound, "ERR_BOT_NOT_FOUND", "Bot not found.", err)
+	}
+
+	if reason, err := s.db.CheckBan(ctx, serverID, bot.ID); err == nil {
+		return types.NewAPIError(http.StatusForbidden, "USER_BANNED", reason.String, nil)
+	}
+
+	memberIDs, err := s.db.GetServerMemberIDs(ctx, serverID, []string{bot.ID})
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_MEMBERS", "Failed to get server members.", err)
+	}
+	if len(memberIDs) > 0 {
+		return types.NewAPIError(http.StatusConflict, "ERR_ALREADY_MEMBER", "This bot is already a member of the server.", nil)
+	}
+
+	if _, _, _, _, _, err := s.db.JoinServer(ctx, serverID, bot.ID, 0, ""); err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_JOIN_SERVER", "Failed to add the bot to the server.", err)
+	}
+
+	// The bot is only online when its gateway connection is open.
+	status := "offline"
+	botPID := s.actors.GetUser(bot.ID)
+	if botPID != nil {
+		status = "online"
+	}
+	s.actors.SendUserStatusMessage(botPID, &proto.ChangeStatus{
+		Type: "join",
+		User: &proto.User{
+			Id:          bot.ID,
+			DisplayName: bot.DisplayName,
+			Avatar:      bot.Avatar.String,
+		},
+		ServerId: serverID,
+		Status:   status,
+	})
+	s.webhooks.Fire(serverID, types.EventMemberJoin, types.WebhookMember{UserID: bot.ID})
 
 	return nil
 }
 
-func (s *serverService) CreateInvite(ctx *gin.Context) (*string, *types.APIError) {
+func (s *serverService) CreateInvite(ctx *gin.Context, body *types.CreateInviteParams) (*string, *types.APIError) {
 	user, exists := ctx.Get("user")
 	if !exists {
 		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
@@ -190,14 +298,121 @@ func (s *serverService) CreateInvite(ctx *gin.Context) (*string, *types.APIError
 	userID := user.(*db.User).ID
 	serverID := ctx.Param("server_id")
 
-	inviteID, err := s.db.CreateInvite(ctx, userID, serverID)
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.CreateInvite); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to create invites.", nil)
+	}
+
+	invite, err := s.db.CreateInvite(ctx, userID, serverID, body)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_INVITE", "Failed to create invite.", err)
 	}
 
-	inviteURL := fmt.Sprintf("https://%s/invite/%s", os.Getenv("DOMAIN"), inviteID)
+	url := inviteURL(invite.InviteID)
 
-	return &inviteURL, nil
+	return &url, nil
+}
+
+func (s *serverService) GetInvites(ctx *gin.Context) ([]db.GetServerInvitesRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to see the invites of this server.", nil)
+	}
+
+	invites, err := s.db.GetServerInvites(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_INVITES", "Failed to get invites.", err)
+	}
+
+	return invites, nil
+}
+
+func (s *serverService) DeleteInvite(ctx *gin.Context) *types.APIError {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	invite, err := s.db.GetInvite(ctx, ctx.Param("invite_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_INVITE_NOT_FOUND", "Invite not found.", err)
+	}
+
+	// Creators can revoke their own invites, the vanity one belongs to the server.
+	ownInvite := invite.CreatorID == user.(*db.User).ID && !invite.Vanity
+	if !ownInvite && !s.permissions.CheckPermission(ctx, invite.ServerID, types.ManageServer) {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to revoke this invite.", nil)
+	}
+
+	if err := s.db.DeleteInvite(ctx, invite.ID); err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_INVITE", "Failed to revoke invite.", err)
+	}
+
+	return nil
+}
+
+func (s *serverService) SetVanityInvite(ctx *gin.Context, body *types.SetVanityInviteParams) (*db.Invite, *types.APIError) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to edit this server.", nil)
+	}
+
+	if body.Code != "" {
+		if existing, err := s.db.GetInvite(ctx, body.Code); err == nil && !(existing.Vanity && existing.ServerID == serverID) {
+			return nil, types.NewAPIError(http.StatusConflict, "ERR_INVITE_CODE_TAKEN", "This invite code is already taken.", nil)
+		}
+	}
+
+	invite, err := s.db.SetVanityInvite(ctx, user.(*db.User).ID, serverID, body.Code)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_SET_VANITY_INVITE", "Failed to set the vanity invite.", err)
+	}
+
+	return invite, nil
+}
+
+// GetInvitePreview describes the server of an invite to people who haven't joined it, signed in or not.
+func (s *serverService) GetInvitePreview(ctx *gin.Context) (*types.InvitePreview, *types.APIError) {
+	preview, err := s.db.GetInvitePreview(ctx, ctx.Param("invite_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_INVITE_NOT_FOUND", "This invite is invalid or has expired.", err)
+	}
+
+	return &types.InvitePreview{
+		GetInvitePreviewRow: preview,
+		OnlineCount:         len(s.actors.GetActiveUsers(preview.ServerID)),
+	}, nil
+}
+
+func (s *serverService) DiscoverServers(ctx *gin.Context, params *types.DiscoverServersParams) ([]types.DiscoverableServer, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	servers, err := s.db.DiscoverServers(ctx, u.(*db.User).ID, params)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_DISCOVER_SERVERS", "Failed to get public servers.", err)
+	}
+
+	discoverable := make([]types.DiscoverableServer, len(servers))
+	for i, server := range servers {
+		discoverable[i] = types.DiscoverableServer{
+			DiscoverServersRow: server,
+			OnlineCount:        len(s.actors.GetActiveUsers(server.ID)),
+		}
+	}
+
+	return discoverable, nil
+}
+
+func inviteURL(code string) string {
+	return fmt.Sprintf("https://%s/invite/%s", os.Getenv("DOMAIN"), code)
 }
 
 func (s *serverService) UpdateProfile(ctx *gin.Context, body *types.UpdateServerProfileParams) *types.APIError {
@@ -282,10 +497,6 @@ func (s *serverService) SearchMembers(ctx *gin.Context) ([]db.SearchServerMember
 	return members, nil
 }
 
-func (s *serverService) DeleteInvite(ctx *gin.Context) *types.APIError {
-	return nil
-}
-
 func (s *serverService) UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileHeader, banner []*multipart.FileHeader, body *types.UpdateAvatarParams) (*string, *string, *types.APIError) {
 	serverID := ctx.Param("server_id")
 
@@ -298,45 +509,36 @@ func (s *serverService) UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileH
 		return nil, nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_SERVER", "Failed to get server.", err)
 	}
 
-	var oldAvatar, oldBanner string
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
 
-	if server.Avatar.String != "" {
-		oldAvatar = server.Avatar.String[len(os.Getenv("CDN_URL"))+1:]
+	// The replaced files still count until they are deleted.
+	sizes := fileSizes(avatar, banner)
+	if aerr := s.quotas.CheckFileSize(u.(*db.User), sizes...); aerr != nil {
+		return nil, nil, aerr
 	}
-	if server.Banner.String != "" {
-		oldBanner = server.Banner.String[len(os.Getenv("CDN_URL"))+1:]
+	if aerr := s.quotas.CheckServer(ctx, server.ID, sizes...); aerr != nil {
+		return nil, nil, aerr
 	}
 
 	var avatarURL, bannerURL *string
 
 	if len(avatar) > 0 {
-		a, perr := s.files.ProcessAndUploadAvatar(server.ID, "avatar", avatar[0], body.CropAvatar)
+		a, perr := s.files.ProcessAndUploadAvatar(ctx, server.ID, "avatar", avatar[0], body.CropAvatar)
 		if perr != nil {
 			return nil, nil, perr
 		}
 		avatarURL = a
-
-		if oldAvatar != "" {
-			err := s.files.DeleteFile(oldAvatar)
-			if err != nil {
-				fmt.Println("Failed to delete old avatar:", err)
-			}
-		}
 	}
 
 	if len(banner) > 0 {
-		b, perr := s.files.ProcessAndUploadAvatar(server.ID, "banner", banner[0], body.CropBanner)
+		b, perr := s.files.ProcessAndUploadAvatar(ctx, server.ID, "banner", banner[0], body.CropBanner)
 		if perr != nil {
 			return nil, nil, perr
 		}
 		bannerURL = b
-
-		if oldBanner != "" {
-			err := s.files.DeleteFile(oldBanner)
-			if err != nil {
-				fmt.Println("Failed to delete old banner:", err)
-			}
-		}
 	}
 
 	err = s.db.UpdateServerAvatarNBanner(ctx, serverID, avatarURL, bannerURL)
@@ -344,6 +546,20 @@ func (s *serverService) UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileH
 		return nil, nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPDATE_SERVER_AVATAR", "Failed to update server avatar/banner.", err)
 	}
 
+	// The previous files are deleted once they are replaced.
+	if avatarURL != nil {
+		owner := types.AttachmentOwner{Kind: types.AttachmentServerAvatar, ID: serverID, Quota: types.QuotaAccount{ServerID: serverID}}
+		if err := s.attachments.Replace(ctx, owner, types.StoredFile{URL: *avatarURL, Size: avatar[0].Size}); err != nil {
+			fmt.Println("Failed to track server avatar:", err)
+		}
+	}
+	if bannerURL != nil {
+		owner := types.AttachmentOwner{Kind: types.AttachmentServerBanner, ID: serverID, Quota: types.QuotaAccount{ServerID: serverID}}
+		if err := s.attachments.Replace(ctx, owner, types.StoredFile{URL: *bannerURL, Size: banner[0].Size}); err != nil {
+			fmt.Println("Failed to track server banner:", err)
+		}
+	}
+
 	s.actors.AvatarServerChange(serverID, bannerURL, avatarURL)
 
 	return avatarURL, bannerURL, nil
@@ -360,6 +576,8 @@ func (s *serverService) BanUser(ctx *gin.Context, body *types.BanUserParams) *ty
 	}
 
 	s.actors.BanUser(serverID, body)
+	s.e2ee.requireServerRekey(ctx, serverID, types.RekeyMembersChanged)
+	s.webhooks.Fire(serverID, types.EventMemberBan, types.WebhookMember{UserID: body.UserID, Reason: body.Reason})
 
 	return nil
 }
@@ -390,6 +608,8 @@ func (s *serverService) KickUser(ctx *gin.Context, body *types.KickUserParams) *
 	}
 
 	s.actors.KickUser(serverID, body)
+	s.e2ee.requireServerRekey(ctx, serverID, types.RekeyMembersChanged)
+	s.webhooks.Fire(serverID, types.EventMemberKick, types.WebhookMember{UserID: body.UserID, Reason: body.Reason})
 
 	return nil
 }
@@ -408,3 +628,175 @@ func (s *serverService) GetBannedMembers(ctx *gin.Context) ([]db.GetBannedMember
 
 	return bans, nil
 }
+
+func (s *serverService) GetStorageUsage(ctx *gin.Context) (*types.StorageUsage, *types.APIError) {
+	serverID := ctx.Param("server_id")
+
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to see the storage of this server.", nil)
+	}
+
+	return s.quotas.ServerUsage(ctx, serverID)
+}
+
+func (s *serverService) GetEmojis(ctx *gin.Context) ([]db.ServerEmoji, *types.APIError) {
+	serverID := ctx.Param("server_id")
+
+	emojis, err := s.db.GetServerEmojis(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_EMOJIS", "Failed to get the emojis of the server.", err)
+	}
+
+	return emojis, nil
+}
+
+func (s *serverService) UploadEmojis(ctx *gin.Context, emojis []*multipart.FileHeader, body *types.UploadServerEmojiParams) ([]db.ServerEmoji, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageEmojis); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage the emojis of this server.", nil)
+	}
+
+	if len(emojis) != len(body.Shortcodes) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_EMOJIS", "Every emoji needs a shortcode.", nil)
+	}
+
+	existing, err := s.db.GetServerEmojis(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_EMOJIS", "Failed to get the emojis of the server.", err)
+	}
+
+	taken := make(map[string]bool)
+	for _, emoji := range existing {
+		if emoji.Kind == string(body.Kind) {
+			taken[emoji.Shortcode] = true
+		}
+	}
+	if len(taken)+len(emojis) > maxServerEmojis[body.Kind] {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_TOO_MANY_EMOJIS", fmt.Sprintf("A server can't have more than %d %ss.", maxServerEmojis[body.Kind], body.Kind), nil)
+	}
+	for _, shortcode := range body.Shortcodes {
+		if taken[shortcode] {
+			return nil, types.NewAPIError(http.StatusConflict, "ERR_SHORTCODE_TAKEN", fmt.Sprintf("The shortcode %q is already used.", shortcode), nil)
+		}
+		taken[shortcode] = true
+	}
+
+	sizes := fileSizes(emojis)
+	if aerr := s.quotas.CheckFileSize(user, sizes...); aerr != nil {
+		return nil, aerr
+	}
+	if aerr := s.quotas.CheckServer(ctx, serverID, sizes...); aerr != nil {
+		return nil, aerr
+	}
+
+	var emojisToCreate []db.CreateServerEmojiParams
+	for i, emoji := range emojis {
+		url, perr := s.files.ProcessAndUploadServerEmoji(ctx, serverID, body.Kind, emoji)
+		if perr != nil {
+			return nil, perr
+		}
+
+		emojisToCreate = append(emojisToCreate, db.CreateServerEmojiParams{
+			ID:        cuid2.Generate(),
+			ServerID:  serverID,
+			CreatorID: pgtype.Text{String: user.ID, Valid: true},
+			Kind:      string(body.Kind),
+			Shortcode: body.Shortcodes[i],
+			Url:       *url,
+			Animated:  strings.HasSuffix(*url, "-animated.webp"),
+		})
+	}
+
+	if err := s.db.CreateServerEmojis(ctx, emojisToCreate); err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_EMOJIS", "Failed to upload emojis.", err)
+	}
+
+	created := make([]db.ServerEmoji, 0, len(emojisToCreate))
+	for i, emoji := range emojisToCreate {
+		owner := types.AttachmentOwner{Kind: types.AttachmentServerEmoji, ID: emoji.ID, Quota: types.QuotaAccount{ServerID: serverID}}
+		if err := s.attachments.Track(ctx, owner, types.StoredFile{URL: emoji.Url, Size: sizes[i]}); err != nil {
+			fmt.Println("Failed to track server emoji:", err)
+		}
+
+		serverEmoji := db.ServerEmoji{
+			ID:        emoji.ID,
+			ServerID:  emoji.ServerID,
+			CreatorID: emoji.CreatorID,
+			Kind:      emoji.Kind,
+			Shortcode: emoji.Shortcode,
+			Url:       emoji.Url,
+			Animated:  emoji.Animated,
+			CreatedAt: time.Now(),
+		}
+		created = append(created, serverEmoji)
+		s.actors.ServerEmojiChange(serverID, "create", serverEmojiMessage(serverEmoji))
+	}
+
+	return created, nil
+}
+
+func (s *serverService) UpdateEmoji(ctx *gin.Context, body *types.UpdateEmojiParams) (*db.ServerEmoji, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageEmojis); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage the emojis of this server.", nil)
+	}
+
+	emojiID := ctx.Param("emoji_id")
+	emojis, err := s.db.GetServerEmojis(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_EMOJIS", "Failed to get the emojis of the server.", err)
+	}
+
+	index := slices.IndexFunc(emojis, func(e db.ServerEmoji) bool { return e.ID == emojiID })
+	if index == -1 {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_EMOJI_NOT_FOUND", "Emoji not found.", nil)
+	}
+	if slices.ContainsFunc(emojis, func(e db.ServerEmoji) bool {
+		return e.ID != emojiID && e.Kind == emojis[index].Kind && e.Shortcode == body.Shortcode
+	}) {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_SHORTCODE_TAKEN", fmt.Sprintf("The shortcode %q is already used.", body.Shortcode), nil)
+	}
+
+	emoji, err := s.db.UpdateServerEmoji(ctx, serverID, emojiID, body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPDATE_EMOJI", "Failed to update emoji.", err)
+	}
+
+	s.actors.ServerEmojiChange(serverID, "update", serverEmojiMessage(emoji))
+
+	return &emoji, nil
+}
+
+func (s *serverService) DeleteEmoji(ctx *gin.Context) *types.APIError {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageEmojis); !allowed {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage the emojis of this server.", nil)
+	}
+
+	// Its files are deleted along with its attachments.
+	emoji, err := s.db.DeleteServerEmoji(ctx, serverID, ctx.Param("emoji_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_EMOJI_NOT_FOUND", "Emoji not found.", err)
+	}
+
+	s.actors.ServerEmojiChange(serverID, "delete", &proto.ServerEmoji{Id: emoji.ID, Kind: emoji.Kind})
+
+	return nil
+}
+
+func serverEmojiMessage(emoji db.ServerEmoji) *proto.ServerEmoji {
+	return &proto.ServerEmoji{
+		Id:        emoji.ID,
+		Kind:      emoji.Kind,
+		Shortcode: emoji.Shortcode,
+		Url:       emoji.Url,
+		Animated:  emoji.Animated,
+		CreatorId: emoji.CreatorID.String,
+	}
+}
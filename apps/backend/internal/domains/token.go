@@ -0,0 +1,192 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/broker"
+	"backend/internal/crypto"
+	"backend/internal/database"
+	"backend/internal/types"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxBotsPerUser   = 10
+	defaultRateLimit = 60
+	tokenPrefix      = "kyob_"
+)
+
+type TokenService interface {
+	CreateToken(ctx *gin.Context, body *types.CreateTokenParams) (*types.CreatedToken, *types.APIError)
+	GetTokens(ctx *gin.Context) ([]db.GetAccessTokensRow, *types.APIError)
+	RevokeToken(ctx *gin.Context, tokenID string) *types.APIError
+	CreateBot(ctx *gin.Context, body *types.CreateBotParams) (*db.User, *types.APIError)
+	GetBots(ctx *gin.Context) ([]db.GetBotsRow, *types.APIError)
+	DeleteBot(ctx *gin.Context, botID string) *types.APIError
+}
+
+type tokenService struct {
+	db     database.Service
+	broker broker.Service
+}
+
+func NewTokenService(db database.Service, broker broker.Service) *tokenService {
+	return &tokenService{
+		db:     db,
+		broker: broker,
+	}
+}
+
+// CreateToken issues a personal access token for the user, or for one of their bots when bot_id is set.
+// The plain token is only ever returned here, only its hash is stored.
+func (s *tokenService) CreateToken(ctx *gin.Context, body *types.CreateTokenParams) (*types.CreatedToken, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_EXPIRATION", "The expiration date is in the past.", nil)
+	}
+
+	if body.RateLimit == 0 {
+		body.RateLimit = defaultRateLimit
+	}
+
+	ownerID := user.ID
+	if body.BotID != "" {
+		bot, err := s.db.GetBot(ctx, body.BotID, user.ID)
+		if err != nil {
+			return nil, types.NewAPIError(http.StatusNotFound, "ERR_BOT_NOT_FOUND", "Bot not found.", err)
+		}
+		ownerID = bot.ID
+	}
+
+	secret, err := crypto.GenerateRandomBytes(32)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_TOKEN_GENERATION", "Failed to generate auth token.", err)
+	}
+
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	created, err := s.db.CreateAccessToken(ctx, ownerID, user.ID, crypto.HashToken(token), body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_TOKEN", "Failed to create the token.", err)
+	}
+
+	return &types.CreatedToken{
+		CreateAccessTokenRow: created,
+		Token:                token,
+	}, nil
+}
+
+func (s *tokenService) GetTokens(ctx *gin.Context) ([]db.GetAccessTokensRow, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	tokens, err := s.db.GetAccessTokens(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_TOKENS", "Failed to get tokens.", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *tokenService) RevokeToken(ctx *gin.Context, tokenID string) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	hash, err := s.db.DeleteAccessToken(ctx, tokenID, user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_TOKEN_NOT_FOUND", "Token not found.", err)
+	}
+
+	if err := s.broker.RemoveCachedAccessTokens(ctx, hash); err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_REMOVE_CACHED_TOKEN", "Failed to revoke the token.", err)
+	}
+
+	return nil
+}
+
+func (s *tokenService) CreateBot(ctx *gin.Context, body *types.CreateBotParams) (*db.User, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	count, err := s.db.CountBots(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BOTS", "Failed to get bots.", err)
+	}
+	if count >= maxBotsPerUser {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_TOO_MANY_BOTS", "You reached the maximum amount of bots.", nil)
+	}
+
+	taken, err := s.db.UsernameExists(ctx, body.Username)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_BOT", "Failed to create the bot.", err)
+	}
+	if taken {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_USERNAME_TAKEN", "This username is already taken.", nil)
+	}
+
+	bot, err := s.db.CreateBot(ctx, user.ID, body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_BOT", "Failed to create the bot.", err)
+	}
+
+	return &bot, nil
+}
+
+func (s *tokenService) GetBots(ctx *gin.Context) ([]db.GetBotsRow, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	bots, err := s.db.GetBots(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BOTS", "Failed to get bots.", err)
+	}
+
+	return bots, nil
+}
+
+func (s *tokenService) DeleteBot(ctx *gin.Context, botID string) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized", nil)
+	}
+	user := u.(*db.User)
+
+	hashes, err := s.db.GetAccessTokenHashes(ctx, botID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_BOT", "Failed to delete the bot.", err)
+	}
+
+	rows, err := s.db.DeleteBot(ctx, botID, user.ID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_BOT", "Failed to delete the bot.", err)
+	}
+	if rows == 0 {
+		return types.NewAPIError(http.StatusNotFound, "ERR_BOT_NOT_FOUND", "Bot not found.", nil)
+	}
+
+	// The rows are gone with the bot, drop the cached tokens so they stop working right away.
+	if err := s.broker.RemoveCachedAccessTokens(ctx, hashes...); err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_REMOVE_CACHED_TOKEN", "Failed to revoke the bot tokens.", err)
+	}
+
+	return nil
+}
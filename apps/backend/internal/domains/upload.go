@@ -0,0 +1,247 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/database"
+	"backend/internal/files"
+	"backend/internal/quotas"
+	"backend/internal/types"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/nrednav/cuid2"
+)
+
+const (
+	maxDirectUploadSize = 1 << 30 // 1gb
+	maxPendingUploads   = 20
+	uploadURLExpiry     = 15 * time.Minute
+	// uploadExpiry is how long an upload can wait for its message before being collected.
+	uploadExpiry      = 24 * time.Hour
+	uploadsGCInterval = 15 * time.Minute
+)
+
+type UploadService interface {
+	CreateUpload(ctx *gin.Context, body *types.CreateUploadParams) (*types.PresignedUpload, *types.APIError)
+	ConfirmUpload(ctx *gin.Context) (*files.File, *types.APIError)
+}
+
+type uploadService struct {
+	db     database.Service
+	files  files.Service
+	quotas quotas.Service
+}
+
+func NewUploadService(db database.Service, files files.Service, quotas quotas.Service) *uploadService {
+	s := &uploadService{
+		db:     db,
+		files:  files,
+		quotas: quotas,
+	}
+
+	go s.collectOrphans()
+
+	return s
+}
+
+// CreateUpload reserves an upload and returns where the client PUTs the file, straight to the storage
+// so large files don't go through the API.
+func (s *uploadService) CreateUpload(ctx *gin.Context, body *types.CreateUploadParams) (*types.PresignedUpload, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	if body.Size > maxDirectUploadSize {
+		return nil, types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_FILE_TOO_LARGE", "The file is too large.", nil)
+	}
+	if aerr := s.quotas.CheckFileSize(user, body.Size); aerr != nil {
+		return nil, aerr
+	}
+
+	contentType, _, err := mime.ParseMediaType(body.ContentType)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_MIME_TYPE", "Invalid mime type.", err)
+	}
+	if strings.HasPrefix(contentType, "image/") && body.Size > files.MaxImageSize {
+		return nil, types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_IMAGE_TOO_LARGE", "The image is too large.", nil)
+	}
+	if limit := s.files.MaxScanSize(); limit > 0 && body.Size > limit {
+		return nil, files.ScanError(files.ErrTooLargeToScan)
+	}
+
+	pending, err := s.db.CountPendingUploads(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_UPLOADS", "Failed to get uploads.", err)
+	}
+	if pending >= maxPendingUploads {
+		return nil, types.NewAPIError(http.StatusTooManyRequests, "ERR_TOO_MANY_UPLOADS", "Too many uploads in progress.", nil)
+	}
+
+	// The declared size is reserved until the upload is attached or collected.
+	if aerr := s.quotas.CheckUser(ctx, user, body.Size); aerr != nil {
+		return nil, aerr
+	}
+
+	uploadID := cuid2.Generate()
+	presigned, err := s.files.PresignUpload(ctx, uploadID, body.FileName, contentType, body.Size, uploadURLExpiry)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PRESIGN_UPLOAD", "Failed to prepare the upload.", err)
+	}
+
+	if _, err := s.db.CreateUpload(ctx, db.CreateUploadParams{
+		ID:          uploadID,
+		UserID:      user.ID,
+		Key:         presigned.Key,
+		FileName:    body.FileName,
+		ContentType: contentType,
+		Size:        body.Size,
+		ExpiresAt:   time.Now().Add(uploadExpiry),
+	}); err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_UPLOAD", "Failed to create the upload.", err)
+	}
+
+	return &types.PresignedUpload{
+		ID:        uploadID,
+		URL:       presigned.URL,
+		Method:    http.MethodPut,
+		Headers:   presigned.Headers,
+		ExpiresAt: time.Now().Add(uploadURLExpiry),
+	}, nil
+}
+
+// ConfirmUpload checks the uploaded object against what was declared, the upload can then be
+// attached to a message. Mismatching objects are deleted right away.
+func (s *uploadService) ConfirmUpload(ctx *gin.Context) (*files.File, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	upload, err := s.db.GetUpload(ctx, ctx.Param("upload_id"), user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_UPLOAD_NOT_FOUND", "Upload not found.", err)
+	}
+	if upload.Status != "pending" {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_UPLOAD_CONFIRMED", "This upload is already confirmed.", nil)
+	}
+
+	// The checks are made on a copy the presigned URL can't write to.
+	key, err := s.files.PromoteUpload(ctx, upload.Key)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusBadRequest, "ERR_UPLOAD_MISSING", "The file wasn't uploaded.", err)
+	}
+
+	size, head, err := s.files.InspectUpload(ctx, key)
+	if err != nil {
+		s.discard(ctx, upload.ID, key)
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_INSPECT_UPLOAD", "Failed to read the uploaded file.", err)
+	}
+
+	if size != upload.Size || !files.MatchesContent(upload.ContentType, head) {
+		s.discard(ctx, upload.ID, key)
+		return nil, types.NewAPIError(http.StatusUnprocessableEntity, "ERR_INVALID_UPLOAD", "The uploaded file doesn't match its declared size or type.", nil)
+	}
+
+	// The file can't change anymore, it is scanned once before anyone can download it.
+	quarantined, err := s.files.ScanStored(ctx, key, upload.FileName)
+	if err != nil {
+		s.discard(ctx, upload.ID, key)
+		return nil, files.ScanError(err)
+	}
+	if quarantined != nil {
+		s.quarantine(ctx, user, upload.ID, quarantined)
+		return nil, types.NewAPIError(http.StatusUnprocessableEntity, "ERR_FILE_BLOCKED", "The file was flagged by the malware scanner.", nil)
+	}
+
+	file := s.files.UploadedFile(upload.ID, key, upload.FileName, upload.ContentType, upload.Size)
+
+	// Images are stripped of their metadata before anyone else can see them.
+	var metadata json.RawMessage
+	if strings.HasPrefix(upload.ContentType, "image/") {
+		processed, err := s.files.ProcessImage(ctx, file)
+		if err != nil {
+			s.discard(ctx, upload.ID, key)
+			return nil, types.NewAPIError(http.StatusUnprocessableEntity, "ERR_INVALID_UPLOAD", "The uploaded image can't be processed.", err)
+		}
+		file = processed
+
+		if metadata, err = json.Marshal(file); err != nil {
+			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_MARSHAL_FILES", "Failed to marshal files.", err)
+		}
+	}
+
+	rows, err := s.db.ConfirmUpload(ctx, upload.ID, user.ID, key, metadata)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CONFIRM_UPLOAD", "Failed to confirm the upload.", err)
+	}
+	if rows == 0 {
+		return nil, types.NewAPIError(http.StatusConflict, "ERR_UPLOAD_CONFIRMED", "This upload is already confirmed.", nil)
+	}
+
+	return &file, nil
+}
+
+func (s *uploadService) discard(ctx context.Context, uploadID, key string) {
+	for _, k := range append([]string{key}, files.DerivedKeys(key)...) {
+		if err := s.files.DeleteFile(k); err != nil {
+			slog.Error("failed to delete upload", "upload_id", uploadID, "err", err)
+			return
+		}
+	}
+	if err := s.db.DeleteUpload(ctx, uploadID); err != nil {
+		slog.Error("failed to delete upload", "upload_id", uploadID, "err", err)
+	}
+}
+
+// quarantine records a flagged upload, its file was already moved to the quarantine.
+func (s *uploadService) quarantine(ctx context.Context, user *db.User, uploadID string, quarantined *files.Quarantined) {
+	if _, err := s.db.CreateQuarantinedFile(ctx, db.CreateQuarantinedFileParams{
+		ID:          cuid2.Generate(),
+		Key:         quarantined.Key,
+		UserID:      pgtype.Text{String: user.ID, Valid: true},
+		FileName:    quarantined.FileName,
+		ContentType: quarantined.ContentType,
+		Size:        quarantined.Size,
+		Signature:   quarantined.Signature,
+	}); err != nil {
+		slog.Error("failed to record quarantined file", "key", quarantined.Key, "err", err)
+	}
+	if err := s.db.DeleteUpload(ctx, uploadID); err != nil {
+		slog.Error("failed to delete quarantined upload", "upload_id", uploadID, "err", err)
+	}
+}
+
+// collectOrphans deletes the uploads which never made it into a message. Attached uploads only
+// lose their row once their message is gone, the file itself follows the message attachments.
+func (s *uploadService) collectOrphans() {
+	ticker := time.NewTicker(uploadsGCInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+
+		orphans, err := s.db.GetOrphanedUploads(ctx, 100)
+		if err != nil {
+			slog.Error("failed to get orphaned uploads", "err", err)
+		}
+		for _, orphan := range orphans {
+			s.discard(ctx, orphan.ID, orphan.Key)
+		}
+
+		if _, err := s.db.PruneAttachedUploads(ctx); err != nil {
+			slog.Error("failed to prune attached uploads", "err", err)
+		}
+
+		cancel()
+	}
+}
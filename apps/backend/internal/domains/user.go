@@ -3,17 +3,18 @@ package domains
 import (
 	db "backend/db/gen_queries"
 	"backend/internal/actors"
+	"backend/internal/attachments"
 	"backend/internal/broker"
 	"backend/internal/crypto"
 	"backend/internal/database"
 	"backend/internal/files"
+	"backend/internal/quotas"
 	"backend/internal/types"
 	"backend/internal/utils"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nrednav/cuid2"
@@ -22,6 +23,7 @@ import (
 type UserService interface {
 	GetUserByID(ctx *gin.Context, userID string) (*db.User, *types.APIError)
 	UpdateEmail(ctx *gin.Context, body *types.UpdateEmailParams) *types.APIError
+	UpdatePrivacy(ctx *gin.Context, body *types.UpdatePrivacyParams) *types.APIError
 	UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileHeader, banner []*multipart.FileHeader, body *types.UpdateAvatarParams) (*string, *string, *types.APIError)
 	UpdateProfile(ctx *gin.Context, body *types.UpdateProfileParams) *types.APIError
 	Setup(ctx *gin.Context) (*types.Setup, *types.APIError)
@@ -32,21 +34,26 @@ type UserService interface {
 	DeleteEmoji(ctx *gin.Context) *types.APIError
 	DeleteAccount(ctx *gin.Context) *types.APIError
 	Sync(ctx *gin.Context, body *types.SyncParams) *types.APIError
+	GetStorageUsage(ctx *gin.Context) (*types.StorageUsage, *types.APIError)
 }
 
 type userService struct {
-	db     database.Service
-	broker broker.Service
-	files  files.Service
-	actors actors.Service
+	db          database.Service
+	broker      broker.Service
+	files       files.Service
+	actors      actors.Service
+	attachments attachments.Service
+	quotas      quotas.Service
 }
 
-func NewUserService(db database.Service, broker broker.Service, files files.Service, actors actors.Service) *userService {
+func NewUserService(db database.Service, broker broker.Service, files files.Service, actors actors.Service, attachments attachments.Service, quotas quotas.Service) *userService {
 	return &userService{
-		db:     db,
-		broker: broker,
-		files:  files,
-		actors: actors,
+		db:          db,
+		broker:      broker,
+		files:       files,
+		actors:      actors,
+		attachments: attachments,
+		quotas:      quotas,
 	}
 }
 
@@ -80,51 +87,59 @@ func (s *userService) UpdateEmail(ctx *gin.Context, body *types.UpdateEmailParam
 	return nil
 }
 
+func (s *userService) UpdatePrivacy(ctx *gin.Context, body *types.UpdatePrivacyParams) *types.APIError {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	userID := u.(*db.User).ID
+
+	updatedUser, err := s.db.UpdatePrivacy(ctx, userID, body)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_UPDATE_ACCOUNT", "Failed to update account.", err)
+	}
+
+	token, err := ctx.Cookie("token")
+	if err != nil {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_MISSING_TOKEN", "Session token not found.", err)
+	}
+	s.broker.RefreshCachedUser(ctx, token, updatedUser)
+
+	return nil
+}
+
 func (s *userService) UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileHeader, banner []*multipart.FileHeader, body *types.UpdateAvatarParams) (*string, *string, *types.APIError) {
 	u, exists := ctx.Get("user")
 	if !exists {
 		return nil, nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
 	}
 	user := u.(*db.User)
-	var oldAvatar, oldBanner string
 
-	if user.Avatar.String != "" {
-		oldAvatar = user.Avatar.String[len(os.Getenv("CDN_URL"))+1:]
+	// The replaced files still count until they are deleted.
+	sizes := fileSizes(avatar, banner)
+	if aerr := s.quotas.CheckFileSize(user, sizes...); aerr != nil {
+		return nil, nil, aerr
 	}
-	if user.Banner.String != "" {
-		oldBanner = user.Banner.String[len(os.Getenv("CDN_URL"))+1:]
+	if aerr := s.quotas.CheckUser(ctx, user, sizes...); aerr != nil {
+		return nil, nil, aerr
 	}
 
 	var avatarURL, bannerURL *string
 
 	if len(avatar) > 0 {
-		a, perr := s.files.ProcessAndUploadAvatar(user.ID, "avatar", avatar[0], body.CropAvatar)
+		a, perr := s.files.ProcessAndUploadAvatar(ctx, user.ID, "avatar", avatar[0], body.CropAvatar)
 		if perr != nil {
 			return nil, nil, perr
 		}
 		avatarURL = a
-
-		if oldAvatar != "" {
-			err := s.files.DeleteFile(oldAvatar)
-			if err != nil {
-				fmt.Println("Failed to delete old avatar:", err)
-			}
-		}
 	}
 
 	if len(banner) > 0 {
-		b, perr := s.files.ProcessAndUploadAvatar(user.ID, "banner", banner[0], body.CropBanner)
+		b, perr := s.files.ProcessAndUploadAvatar(ctx, user.ID, "banner", banner[0], body.CropBanner)
 		if perr != nil {
 			return nil, nil, perr
 		}
 		bannerURL = b
-
-		if oldBanner != "" {
-			err := s.files.DeleteFile(oldBanner)
-			if err != nil {
-				fmt.Println("Failed to delete old banner:", err)
-			}
-		}
 	}
 
 	updatedUser, err := s.db.UpdateUserAvatarNBanner(ctx, user.ID, avatarURL, bannerURL)
@@ -132,6 +147,20 @@ func (s *userService) UpdateAvatar(ctx *gin.Context, avatar []*multipart.FileHea
 		return nil, nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPDATE_AVATAR_BANNER", "Failed to update avatar/banner.", err)
 	}
 
+	// The previous files are deleted once they are replaced.
+	if avatarURL != nil {
+		owner := types.AttachmentOwner{Kind: types.AttachmentUserAvatar, ID: user.ID, Quota: types.QuotaAccount{UserID: user.ID}}
+		if err := s.attachments.Replace(ctx, owner, types.StoredFile{URL: *avatarURL, Size: avatar[0].Size}); err != nil {
+			fmt.Println("Failed to track avatar:", err)
+		}
+	}
+	if bannerURL != nil {
+		owner := types.AttachmentOwner{Kind: types.AttachmentUserBanner, ID: user.ID, Quota: types.QuotaAccount{UserID: user.ID}}
+		if err := s.attachments.Replace(ctx, owner, types.StoredFile{URL: *bannerURL, Size: banner[0].Size}); err != nil {
+			fmt.Println("Failed to track banner:", err)
+		}
+	}
+
 	token, err := ctx.Cookie("token")
 	if err != nil {
 		return nil, nil, types.NewAPIError(http.StatusUnauthorized, "ERR_MISSING_TOKEN", "Session token not found.", err)
@@ -207,8 +236,11 @@ func (s *userService) UpdatePassword(ctx *gin.Context, body *types.UpdatePasswor
 		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_PASSWORD", "Failed to get user password.", err)
 	}
 
-	if valid, err := crypto.VerifyPassword(body.Current, password); err != nil || !valid {
-		return types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_PASSWORD", "Invalid password.", err)
+	// Users who signed up with a provider have no password, they set their first one without it.
+	if password != "" {
+		if valid, err := crypto.VerifyPassword(body.Current, password); err != nil || !valid {
+			return types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_PASSWORD", "Invalid password.", err)
+		}
 	}
 
 	hashedPassword, err := crypto.HashPassword(body.New)
@@ -284,11 +316,34 @@ func (s *userService) Setup(ctx *gin.Context) (*types.Setup, *types.APIError) {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_EMOJIS", "Failed to get user's emojis.", err)
 	}
 
+	serverEmojis, err := s.db.GetMemberServerEmojis(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_EMOJIS", "Failed to get the emojis of the servers.", err)
+	}
+
 	friendsData, friendChannelIDs, err := s.fetchFriendsData(ctx, user.ID)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_FETCH_FRIENDS", "Failed to fetch friends data.", err)
 	}
 
+	groupDMs, err := s.db.GetGroupDMs(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_GROUP_DMS", "Failed to get the group messages.", err)
+	}
+	for _, channel := range groupDMs {
+		friendChannelIDs = append(friendChannelIDs, channel.ID)
+	}
+
+	blockedIDs, err := s.db.GetBlockedUserIDs(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_BLOCKS", "Failed to get the blocked users.", err)
+	}
+
+	unreadNotifications, err := s.db.CountUnreadNotifications(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_COUNT_NOTIFICATIONS", "Failed to count the unread notifications.", err)
+	}
+
 	serversData, serverChannelIDs, err := s.fetchServersData(ctx, user.ID)
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_FETCH_SERVERS", "Failed to fetch servers data.", err)
@@ -301,13 +356,26 @@ func (s *userService) Setup(ctx *gin.Context) (*types.Setup, *types.APIError) {
 	}
 
 	friends := s.processFriendsWithData(friendsData, messageStates)
+
+	groups := make([]types.GroupDM, 0, len(groupDMs))
+	for _, channel := range groupDMs {
+		groups = append(groups, types.GroupDM{
+			Channel:         channel,
+			LastMessageRead: messageStates.readMap[channel.ID],
+			LastMessageSent: messageStates.sentMap[channel.ID],
+		})
+	}
 	serversMap := s.processServersWithData(serversData, messageStates)
 
 	return &types.Setup{
-		User:    user,
-		Emojis:  emojis,
-		Friends: friends,
-		Servers: serversMap,
+		User:                user,
+		Emojis:              emojis,
+		ServerEmojis:        serverEmojis,
+		Friends:             friends,
+		GroupDMs:            groups,
+		BlockedIDs:          blockedIDs,
+		UnreadNotifications: unreadNotifications,
+		Servers:             serversMap,
 	}, nil
 }
 
@@ -475,9 +543,18 @@ func (s *userService) UploadEmojis(ctx *gin.Context, emojis []*multipart.FileHea
 	if !exists {
 		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
 	}
-	userID := u.(*db.User).ID
+	user := u.(*db.User)
+	userID := user.ID
+
+	sizes := fileSizes(emojis)
+	if aerr := s.quotas.CheckFileSize(user, sizes...); aerr != nil {
+		return nil, aerr
+	}
+	if aerr := s.quotas.CheckUser(ctx, user, sizes...); aerr != nil {
+		return nil, aerr
+	}
 
-	emojisURLs, err := s.files.ProcessAndUploadEmojis(emojis)
+	emojisURLs, err := s.files.ProcessAndUploadEmojis(ctx, emojis)
 	if err != nil {
 		return nil, err
 	}
@@ -505,6 +582,13 @@ func (s *userService) UploadEmojis(ctx *gin.Context, emojis []*multipart.FileHea
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_EMOJIS", "Failed to upload emojis.", err)
 	}
 
+	for i, emoji := range emojisToUpload {
+		owner := types.AttachmentOwner{Kind: types.AttachmentEmoji, ID: emoji.ID, Quota: types.QuotaAccount{UserID: userID}}
+		if err := s.attachments.Track(ctx, owner, types.StoredFile{URL: emoji.Url, Size: sizes[i]}); err != nil {
+			fmt.Println("Failed to track emoji:", err)
+		}
+	}
+
 	return &emojisResponse, nil
 }
 
@@ -585,3 +669,12 @@ func (s *userService) Sync(ctx *gin.Context, body *types.SyncParams) *types.APIE
 
 	return nil
 }
+
+func (s *userService) GetStorageUsage(ctx *gin.Context) (*types.StorageUsage, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+
+	return s.quotas.UserUsage(ctx, u.(*db.User))
+}
@@ -0,0 +1,229 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/actors"
+	"backend/internal/database"
+	"backend/internal/permissions"
+	"backend/internal/sfu"
+	"backend/internal/types"
+	"backend/internal/voice"
+	"backend/proto"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignalSize bounds the relayed SDP and ICE payloads.
+const maxSignalSize = 64 << 10 // 64kb
+
+var signalTypes = []string{"offer", "answer", "candidate"}
+
+type VoiceService interface {
+	// HandleRequest checks a voice request received over the socket of a user and hands it to its channel.
+	HandleRequest(ctx context.Context, userID string, request *proto.VoiceRequest) *types.APIError
+	GetVoiceStates(ctx *gin.Context) (map[string][]voice.State, *types.APIError)
+	// HandleSFUWebhook relays the tracks published on the SFU to their channel.
+	HandleSFUWebhook(ctx *gin.Context) *types.APIError
+}
+
+type voiceService struct {
+	db          database.Service
+	actors      actors.Service
+	permissions permissions.Service
+	sfu         sfu.Service
+}
+
+func NewVoiceService(db database.Service, actors actors.Service, permissions permissions.Service, sfu sfu.Service) *voiceService {
+	return &voiceService{
+		db:          db,
+		actors:      actors,
+		permissions: permissions,
+		sfu:         sfu,
+	}
+}
+
+func (s *voiceService) HandleRequest(ctx context.Context, userID string, request *proto.VoiceRequest) *types.APIError {
+	if request.ServerId == "" || request.ChannelId == "" {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_VOICE_REQUEST", "The server and the channel are required.", nil)
+	}
+
+	command := &proto.VoiceCommand{
+		UserId:  userID,
+		Request: request,
+	}
+
+	// Leaving, self states and signals only concern participants, which the channel checks itself.
+	switch action := request.Action.(type) {
+	case *proto.VoiceRequest_Join:
+		if err := s.checkVoiceChannel(ctx, request.ServerId, request.ChannelId); err != nil {
+			return err
+		}
+		if err := s.checkReadable(ctx, request.ServerId, request.ChannelId, userID); err != nil {
+			return err
+		}
+		if !s.permissions.HasAbility(ctx, request.ServerId, userID, types.Connect) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_CONNECT", "You are not allowed to join this voice channel.", nil)
+		}
+		command.CanSpeak = s.permissions.HasAbility(ctx, request.ServerId, userID, types.Speak)
+		command.CanVideo = s.permissions.HasAbility(ctx, request.ServerId, userID, types.Video)
+	case *proto.VoiceRequest_Leave:
+	case *proto.VoiceRequest_SelfState:
+		if action.SelfState.Video {
+			command.CanVideo = s.permissions.HasAbility(ctx, request.ServerId, userID, types.Video)
+		}
+	case *proto.VoiceRequest_Moderate:
+		if action.Moderate.UserId == "" {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_VOICE_REQUEST", "The member to moderate is required.", nil)
+		}
+		if action.Moderate.Mute != nil && !s.permissions.HasAbility(ctx, request.ServerId, userID, types.MuteMembers) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_MUTE", "You are not allowed to mute members.", nil)
+		}
+		if action.Moderate.Deaf != nil && !s.permissions.HasAbility(ctx, request.ServerId, userID, types.DeafenMembers) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_DEAFEN", "You are not allowed to deafen members.", nil)
+		}
+	case *proto.VoiceRequest_Move:
+		move := action.Move
+		if move.UserId == "" || move.ChannelId == "" || move.ChannelId == request.ChannelId {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_VOICE_REQUEST", "The member and another channel are required.", nil)
+		}
+		if !s.permissions.HasAbility(ctx, request.ServerId, userID, types.MoveMembers) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_MOVE", "You are not allowed to move members.", nil)
+		}
+		if err := s.checkVoiceChannel(ctx, request.ServerId, move.ChannelId); err != nil {
+			return err
+		}
+		// Members are only moved between channels both the moderator and themselves can read.
+		for _, id := range []string{userID, move.UserId} {
+			if err := s.checkReadable(ctx, request.ServerId, move.ChannelId, id); err != nil {
+				return err
+			}
+		}
+		if !s.permissions.HasAbility(ctx, request.ServerId, move.UserId, types.Connect) {
+			return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN_CONNECT", "This member is not allowed to join voice channels.", nil)
+		}
+		// The abilities are the ones of the moved member.
+		command.CanSpeak = s.permissions.HasAbility(ctx, request.ServerId, move.UserId, types.Speak)
+		command.CanVideo = s.permissions.HasAbility(ctx, request.ServerId, move.UserId, types.Video)
+	case *proto.VoiceRequest_Signal:
+		signal := action.Signal
+		if signal.UserId == "" || !slices.Contains(signalTypes, signal.Type) || len(signal.Payload) > maxSignalSize {
+			return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_SIGNAL", "The signal is invalid.", nil)
+		}
+	default:
+		return types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_VOICE_REQUEST", "Unknown voice request.", nil)
+	}
+
+	s.actors.SendVoiceCommand(command)
+
+	return nil
+}
+
+// GetVoiceStates returns who is in the voice channels of a server, limited to the channels the
+// user can read.
+func (s *voiceService) GetVoiceStates(ctx *gin.Context) (map[string][]voice.State, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	serverID := ctx.Param("server_id")
+
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ViewChannels); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to view the channels of this server.", nil)
+	}
+
+	readable, err := s.db.GetReadableChannelIDs(ctx, serverID, u.(*db.User).ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNELS", "Failed to get the channels.", err)
+	}
+
+	states := make(map[string][]voice.State)
+	for _, roster := range s.actors.GetVoiceStates(serverID) {
+		if !slices.Contains(readable, roster.ChannelId) {
+			continue
+		}
+
+		for _, state := range roster.States {
+			tracks := make([]voice.Track, 0, len(state.Tracks))
+			for _, track := range state.Tracks {
+				tracks = append(tracks, voice.Track{SID: track.Sid, Kind: voice.TrackKind(track.Kind), Source: track.Source})
+			}
+
+			states[roster.ChannelId] = append(states[roster.ChannelId], voice.State{
+				UserID:     state.UserId,
+				SelfMute:   state.SelfMute,
+				SelfDeaf:   state.SelfDeaf,
+				ServerMute: state.ServerMute,
+				ServerDeaf: state.ServerDeaf,
+				Video:      state.Video,
+				Suppressed: state.Suppressed,
+				Tracks:     tracks,
+				JoinedAt:   state.JoinedAt.AsTime(),
+			})
+		}
+	}
+
+	return states, nil
+}
+
+func (s *voiceService) HandleSFUWebhook(ctx *gin.Context) *types.APIError {
+	if s.sfu == nil {
+		return types.NewAPIError(http.StatusNotFound, "ERR_SFU_DISABLED", "No SFU is configured.", nil)
+	}
+
+	event, err := s.sfu.ParseWebhook(ctx.Request)
+	if err != nil {
+		return types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_WEBHOOK", "Invalid webhook.", err)
+	}
+	if event.Type != sfu.TrackPublished && event.Type != sfu.TrackUnpublished {
+		return nil
+	}
+
+	// Rooms are named after their channel, the events of deleted channels are dropped.
+	channel, err := s.db.GetChannel(ctx, event.Room)
+	if err != nil {
+		return nil
+	}
+
+	s.actors.SendVoiceTrackEvent(&proto.VoiceTrackEvent{
+		ServerId:  channel.ServerID,
+		ChannelId: channel.ID,
+		UserId:    event.Identity,
+		Track: &proto.VoiceTrack{
+			Sid:    event.Track.SID,
+			Kind:   event.Track.Kind,
+			Source: event.Track.Source,
+		},
+		Published: event.Type == sfu.TrackPublished,
+	})
+
+	return nil
+}
+
+func (s *voiceService) checkVoiceChannel(ctx context.Context, serverID, channelID string) *types.APIError {
+	channel, err := s.db.GetChannel(ctx, channelID)
+	if err != nil || channel.ServerID != serverID {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+	if channel.Type != "voice" {
+		return types.NewAPIError(http.StatusBadRequest, "ERR_NOT_VOICE_CHANNEL", "This channel is not a voice channel.", nil)
+	}
+
+	return nil
+}
+
+// checkReadable makes sure a member can read a voice channel, the channels they can't read are
+// hidden from them.
+func (s *voiceService) checkReadable(ctx context.Context, serverID, channelID, userID string) *types.APIError {
+	readable, err := s.db.GetReadableChannelIDs(ctx, serverID, userID)
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_GET_CHANNELS", "Failed to get the channels.", err)
+	}
+	if !slices.Contains(readable, channelID) {
+		return types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package domains
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/crypto"
+	"backend/internal/database"
+	"backend/internal/permissions"
+	"backend/internal/types"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxWebhooksPerServer = 10
+
+type WebhookService interface {
+	GetWebhooks(ctx *gin.Context) ([]db.GetServerWebhooksRow, *types.APIError)
+	CreateWebhook(ctx *gin.Context, body *types.CreateWebhookParams) (*db.Webhook, *types.APIError)
+	EditWebhook(ctx *gin.Context, body *types.EditWebhookParams) (*db.UpdateWebhookRow, *types.APIError)
+	DeleteWebhook(ctx *gin.Context) *types.APIError
+	GetDeliveries(ctx *gin.Context) ([]db.GetWebhookDeliveriesRow, *types.APIError)
+	GetIncomingWebhooks(ctx *gin.Context) ([]db.GetServerIncomingWebhooksRow, *types.APIError)
+	CreateIncomingWebhook(ctx *gin.Context, body *types.CreateIncomingWebhookParams) (*types.CreatedIncomingWebhook, *types.APIError)
+	RotateIncomingWebhookToken(ctx *gin.Context) (*types.IncomingWebhookURL, *types.APIError)
+	DeleteIncomingWebhook(ctx *gin.Context) *types.APIError
+}
+
+type webhookService struct {
+	db          database.Service
+	permissions permissions.Service
+}
+
+func NewWebhookService(db database.Service, permissions permissions.Service) *webhookService {
+	return &webhookService{
+		db:          db,
+		permissions: permissions,
+	}
+}
+
+func (s *webhookService) GetWebhooks(ctx *gin.Context) ([]db.GetServerWebhooksRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	webhooks, err := s.db.GetServerWebhooks(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_WEBHOOKS", "Failed to get webhooks.", err)
+	}
+
+	return webhooks, nil
+}
+
+// CreateWebhook returns the webhook with its signing secret, which isn't listed afterwards.
+func (s *webhookService) CreateWebhook(ctx *gin.Context, body *types.CreateWebhookParams) (*db.Webhook, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	count, err := s.db.CountServerWebhooks(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_WEBHOOKS", "Failed to get webhooks.", err)
+	}
+	if count >= maxWebhooksPerServer {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_TOO_MANY_WEBHOOKS", "This server reached the maximum amount of webhooks.", nil)
+	}
+
+	secret, err := crypto.GenerateRandomBytes(32)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_SECRET_GENERATION", "Failed to generate the webhook secret.", err)
+	}
+
+	webhook, err := s.db.CreateWebhook(ctx, serverID, user.ID, "whsec_"+hex.EncodeToString(secret), body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_WEBHOOK", "Failed to create the webhook.", err)
+	}
+
+	return &webhook, nil
+}
+
+func (s *webhookService) EditWebhook(ctx *gin.Context, body *types.EditWebhookParams) (*db.UpdateWebhookRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	webhook, err := s.db.UpdateWebhook(ctx, serverID, ctx.Param("webhook_id"), body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_WEBHOOK_NOT_FOUND", "Webhook not found.", err)
+	}
+
+	return &webhook, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx *gin.Context) *types.APIError {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	rows, err := s.db.DeleteWebhook(ctx, serverID, ctx.Param("webhook_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_WEBHOOK", "Failed to delete the webhook.", err)
+	}
+	if rows == 0 {
+		return types.NewAPIError(http.StatusNotFound, "ERR_WEBHOOK_NOT_FOUND", "Webhook not found.", nil)
+	}
+
+	return nil
+}
+
+func (s *webhookService) GetDeliveries(ctx *gin.Context) ([]db.GetWebhookDeliveriesRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	deliveries, err := s.db.GetWebhookDeliveries(ctx, serverID, ctx.Param("webhook_id"))
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_DELIVERIES", "Failed to get webhook deliveries.", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *webhookService) GetIncomingWebhooks(ctx *gin.Context) ([]db.GetServerIncomingWebhooksRow, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	webhooks, err := s.db.GetServerIncomingWebhooks(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_WEBHOOKS", "Failed to get webhooks.", err)
+	}
+
+	return webhooks, nil
+}
+
+// CreateIncomingWebhook returns the webhook with its URL. The token in the URL is only stored hashed,
+// a lost URL has to be replaced with RotateIncomingWebhookToken.
+func (s *webhookService) CreateIncomingWebhook(ctx *gin.Context, body *types.CreateIncomingWebhookParams) (*types.CreatedIncomingWebhook, *types.APIError) {
+	u, exists := ctx.Get("user")
+	if !exists {
+		return nil, types.NewAPIError(http.StatusUnauthorized, "ERR_UNAUTHORIZED", "Unauthorized.", nil)
+	}
+	user := u.(*db.User)
+
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	channel, err := s.db.GetChannel(ctx, body.ChannelID)
+	if err != nil || channel.ServerID != serverID {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_CHANNEL_NOT_FOUND", "Channel not found.", err)
+	}
+
+	count, err := s.db.CountServerIncomingWebhooks(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_WEBHOOKS", "Failed to get webhooks.", err)
+	}
+	if count >= maxWebhooksPerServer {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_TOO_MANY_WEBHOOKS", "This server reached the maximum amount of webhooks.", nil)
+	}
+
+	token, hash, err := generateWebhookToken()
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_TOKEN_GENERATION", "Failed to generate the webhook token.", err)
+	}
+
+	webhook, err := s.db.CreateIncomingWebhook(ctx, serverID, user.ID, hash, body)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_CREATE_WEBHOOK", "Failed to create the webhook.", err)
+	}
+
+	return &types.CreatedIncomingWebhook{
+		CreateIncomingWebhookRow: webhook,
+		URL:                      incomingWebhookURL(webhook.ID, token),
+	}, nil
+}
+
+// RotateIncomingWebhookToken replaces the webhook token, the previous URL stops working immediately.
+func (s *webhookService) RotateIncomingWebhookToken(ctx *gin.Context) (*types.IncomingWebhookURL, *types.APIError) {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return nil, types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	token, hash, err := generateWebhookToken()
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_TOKEN_GENERATION", "Failed to generate the webhook token.", err)
+	}
+
+	webhookID := ctx.Param("webhook_id")
+	rows, err := s.db.RotateIncomingWebhookToken(ctx, serverID, webhookID, hash)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_ROTATE_WEBHOOK_TOKEN", "Failed to rotate the webhook token.", err)
+	}
+	if rows == 0 {
+		return nil, types.NewAPIError(http.StatusNotFound, "ERR_WEBHOOK_NOT_FOUND", "Webhook not found.", nil)
+	}
+
+	return &types.IncomingWebhookURL{URL: incomingWebhookURL(webhookID, token)}, nil
+}
+
+func (s *webhookService) DeleteIncomingWebhook(ctx *gin.Context) *types.APIError {
+	serverID := ctx.Param("server_id")
+	if allowed := s.permissions.CheckPermission(ctx, serverID, types.ManageServer); !allowed {
+		return types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You are not allowed to manage webhooks.", nil)
+	}
+
+	rows, err := s.db.DeleteIncomingWebhook(ctx, serverID, ctx.Param("webhook_id"))
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_DELETE_WEBHOOK", "Failed to delete the webhook.", err)
+	}
+	if rows == 0 {
+		return types.NewAPIError(http.StatusNotFound, "ERR_WEBHOOK_NOT_FOUND", "Webhook not found.", nil)
+	}
+
+	return nil
+}
+
+func generateWebhookToken() (string, string, error) {
+	b, err := crypto.GenerateRandomBytes(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, crypto.HashToken(token), nil
+}
+
+func incomingWebhookURL(webhookID, token string) string {
+	return fmt.Sprintf("https://%s/api/webhooks/%s/%s", os.Getenv("DOMAIN"), webhookID, token)
+}
@@ -0,0 +1,137 @@
+// Package e2ee checks what clients of end-to-end encrypted channels send, without being able to
+// read any of it. Devices publish an X25519 key, signed by their Ed25519 identity key. Channels
+// share a group key, sealed by a member device to every device of the members, and messages are
+// envelopes encrypted with the current group key.
+package e2ee
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// EnvelopeVersion is the version of the message envelopes.
+	EnvelopeVersion = 1
+	// MaxCiphertextSize bounds the encrypted content of a message.
+	MaxCiphertextSize = 64 << 10 // 64kb
+	// MaxSealedKeySize bounds a group key sealed to a device.
+	MaxSealedKeySize = 1 << 10 // 1kb
+	// MaxRecipients is how many devices a group key can be distributed to.
+	MaxRecipients = 2000
+)
+
+var (
+	ErrInvalidKey       = errors.New("e2ee: invalid public key")
+	ErrInvalidSignature = errors.New("e2ee: the device key isn't signed by its identity key")
+	ErrInvalidEnvelope  = errors.New("e2ee: invalid envelope")
+	ErrInvalidSealedKey = errors.New("e2ee: invalid sealed key")
+)
+
+// Envelope is the content of a message of an encrypted channel.
+type Envelope struct {
+	Version int `json:"v"`
+	// KeyID is the group key the message is encrypted with.
+	KeyID string `json:"key_id"`
+	// DeviceID is the device of the author which encrypted the message.
+	DeviceID   string `json:"device_id"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// DecodeKey decodes a base64 public key, X25519 and Ed25519 keys are both 32 bytes.
+func DecodeKey(key string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(b) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	return b, nil
+}
+
+// VerifyDevice checks the public key of a device is signed by its identity key.
+func VerifyDevice(identityKey, publicKey, signature string) error {
+	identity, err := DecodeKey(identityKey)
+	if err != nil {
+		return err
+	}
+	public, err := DecodeKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(ed25519.PublicKey(identity), public, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// ParseEnvelope decodes the content of a message of an encrypted channel. Anything else than an
+// envelope is refused, plaintext documents included.
+func ParseEnvelope(content json.RawMessage) (*Envelope, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	var envelope Envelope
+	if err := decoder.Decode(&envelope); err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	if envelope.Version != EnvelopeVersion || envelope.KeyID == "" || envelope.DeviceID == "" {
+		return nil, ErrInvalidEnvelope
+	}
+
+	// AES-GCM nonces are 12 bytes, XChaCha20-Poly1305 ones 24.
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil || (len(nonce) != 12 && len(nonce) != 24) {
+		return nil, ErrInvalidEnvelope
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext) > MaxCiphertextSize {
+		return nil, ErrInvalidEnvelope
+	}
+
+	return &envelope, nil
+}
+
+// CheckSealedKey checks a group key sealed to a device looks like one.
+func CheckSealedKey(sealed string) error {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) == 0 || len(b) > MaxSealedKeySize {
+		return ErrInvalidSealedKey
+	}
+
+	return nil
+}
+
+// CompareRecipients returns the devices which should get a group key but don't, and the ones which
+// get it but shouldn't, or get it more than once.
+func CompareRecipients(expected, got []string) (missing, unexpected []string) {
+	wanted := make(map[string]bool, len(expected))
+	for _, deviceID := range expected {
+		wanted[deviceID] = true
+	}
+
+	seen := make(map[string]bool, len(got))
+	for _, deviceID := range got {
+		if !wanted[deviceID] || seen[deviceID] {
+			unexpected = append(unexpected, deviceID)
+		}
+		seen[deviceID] = true
+	}
+
+	for _, deviceID := range expected {
+		if !seen[deviceID] {
+			missing = append(missing, deviceID)
+		}
+	}
+
+	return missing, unexpected
+}
@@ -0,0 +1,100 @@
+package e2ee
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func encode(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestVerifyDevice(t *testing.T) {
+	identity, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := make([]byte, 32)
+	rand.Read(public)
+	signature := ed25519.Sign(private, public)
+
+	if err := VerifyDevice(encode(identity), encode(public), encode(signature)); err != nil {
+		t.Fatalf("VerifyDevice() failed: %v", err)
+	}
+
+	other := make([]byte, 32)
+	rand.Read(other)
+	if err := VerifyDevice(encode(identity), encode(other), encode(signature)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for another key, got %v", err)
+	}
+	if err := VerifyDevice(encode(identity), encode(public[:16]), encode(signature)); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey for a short key, got %v", err)
+	}
+	if err := VerifyDevice(encode(identity), encode(public), "not base64"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a malformed signature, got %v", err)
+	}
+}
+
+func TestParseEnvelope(t *testing.T) {
+	valid := map[string]any{
+		"v":          EnvelopeVersion,
+		"key_id":     "key",
+		"device_id":  "device",
+		"nonce":      encode(make([]byte, 12)),
+		"ciphertext": encode([]byte("sealed")),
+	}
+
+	tests := []struct {
+		name    string
+		change  func(map[string]any)
+		wantErr bool
+	}{
+		{name: "valid", change: func(map[string]any) {}},
+		{name: "xchacha nonce", change: func(e map[string]any) { e["nonce"] = encode(make([]byte, 24)) }},
+		{name: "unknown version", change: func(e map[string]any) { e["v"] = 2 }, wantErr: true},
+		{name: "no key", change: func(e map[string]any) { delete(e, "key_id") }, wantErr: true},
+		{name: "bad nonce", change: func(e map[string]any) { e["nonce"] = encode(make([]byte, 8)) }, wantErr: true},
+		{name: "empty ciphertext", change: func(e map[string]any) { e["ciphertext"] = "" }, wantErr: true},
+		{name: "plaintext", change: func(e map[string]any) { e["type"] = "doc" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := map[string]any{}
+			for k, v := range valid {
+				envelope[k] = v
+			}
+			tt.change(envelope)
+			content, _ := json.Marshal(envelope)
+
+			_, err := ParseEnvelope(content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEnvelope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ParseEnvelope(json.RawMessage(`{"type":"doc","content":[]}`)); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("expected a document to be refused, got %v", err)
+	}
+}
+
+func TestCompareRecipients(t *testing.T) {
+	missing, unexpected := CompareRecipients([]string{"a", "b", "c"}, []string{"b", "c", "c", "d"})
+	if !slices.Equal(missing, []string{"a"}) {
+		t.Fatalf("expected a to be missing, got %v", missing)
+	}
+	if !slices.Equal(unexpected, []string{"c", "d"}) {
+		t.Fatalf("expected the second c and d to be unexpected, got %v", unexpected)
+	}
+
+	missing, unexpected = CompareRecipients([]string{"a"}, []string{"a"})
+	if len(missing) != 0 || len(unexpected) != 0 {
+		t.Fatalf("expected the recipients to match, got %v and %v", missing, unexpected)
+	}
+}
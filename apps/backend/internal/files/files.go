@@ -10,30 +10,60 @@ import (
 	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/nrednav/cuid2"
 )
 
 type Service interface {
 	UploadFile(key string, mimeType string, fileData io.Reader, fileName string) error
-	ProcessAndUploadFiles(files []*multipart.FileHeader) ([]byte, *types.APIError)
-	ProcessAndUploadEmojis(files []*multipart.FileHeader) ([]string, *types.APIError)
-	ProcessAndUploadAvatar(entityID, imageType string, avatarToUpload *multipart.FileHeader, crop types.Crop) (*string, *types.APIError)
+	ProcessAndUploadFiles(ctx context.Context, files []*multipart.FileHeader) ([]byte, *types.APIError)
+	ProcessAndUploadEmojis(ctx context.Context, files []*multipart.FileHeader) ([]string, *types.APIError)
+	ProcessAndUploadAvatar(ctx context.Context, entityID, imageType string, avatarToUpload *multipart.FileHeader, crop types.Crop) (*string, *types.APIError)
+	// ProcessAndUploadServerEmoji stores an emoji or a sticker of a server, GIFs are returned as
+	// their animated webp.
+	ProcessAndUploadServerEmoji(ctx context.Context, serverID string, kind types.ServerEmojiKind, emoji *multipart.FileHeader) (*string, *types.APIError)
 	DeleteFile(key string) error
+	// KeyFromURL returns the key of a file served by this storage, or an empty string.
+	KeyFromURL(url string) string
+	// Handler serves the files when the storage has no public endpoint of its own, it is nil otherwise.
+	Handler() http.Handler
+	// PresignUpload prepares a direct upload of an attachment, which the client PUTs to the returned URL.
+	PresignUpload(ctx context.Context, uploadID, fileName, contentType string, size int64, expires time.Duration) (*PresignedUpload, error)
+	// PromoteUpload moves a direct upload away from the key its presigned URL writes to, before it is
+	// checked. It returns the key the file is kept at.
+	PromoteUpload(ctx context.Context, key string) (string, error)
+	// InspectUpload returns the size of an uploaded file along with its first bytes.
+	InspectUpload(ctx context.Context, key string) (int64, []byte, error)
+	// UploadedFile describes a direct upload the way attachments are stored in messages.
+	UploadedFile(uploadID, key, fileName, contentType string, size int64) File
+	// ProcessImage strips the metadata of an uploaded image and describes it with its variants.
+	ProcessImage(ctx context.Context, file File) (File, error)
+	// ScanFiles checks files with the scanner before they are stored, the first flagged one is
+	// quarantined and returned.
+	ScanFiles(ctx context.Context, headers []*multipart.FileHeader) (*Quarantined, error)
+	// ScanStored checks a stored file, a flagged one is moved to the quarantine and returned.
+	ScanStored(ctx context.Context, key, fileName string) (*Quarantined, error)
+	// MaxScanSize is the size of the largest file which can be scanned, 0 when there is no limit.
+	MaxScanSize() int64
+	// ProcessMedia probes a video or audio attachment and uploads its poster, it returns the described file.
+	ProcessMedia(ctx context.Context, file File) (File, error)
+	// ProcessAndUploadEmbedImage hosts the image of a link preview, resized and without its metadata.
+	ProcessAndUploadEmbedImage(ctx context.Context, data []byte) (*ImageVariant, error)
 }
 
 type service struct {
-	s3Client *s3.Client
-	bucket   string
-	cdnURL   string
+	storage Storage
+	scanner Scanner
+}
+
+type PresignedUpload struct {
+	Key     string
+	URL     string
+	Headers map[string]string
 }
 
 type File struct {
@@ -42,27 +72,60 @@ type File struct {
 	Filename string `json:"file_name"`
 	Filesize string `json:"file_size"`
 	Type     string `json:"type"`
+	// Size is in bytes, it is what the file counts for in the storage quotas.
+	Size int64 `json:"size,omitempty"`
+	// Processing is set while a video or audio file is being probed, the message gets edited once it's done.
+	Processing bool    `json:"processing,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	VideoCodec string  `json:"video_codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	Poster     string  `json:"poster,omitempty"`
+	Blurhash   string  `json:"blurhash,omitempty"`
+	// Variants are the resized copies of an image: "thumbnail", "preview" and "original".
+	Variants map[string]ImageVariant `json:"variants,omitempty"`
 }
 
 func New() Service {
-	region := os.Getenv("AWS_REGION")
-	keyID := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	storage, err := NewStorageFromEnv()
+	if err != nil {
+		panic(err)
+	}
 
-	creds := credentials.NewStaticCredentialsProvider(keyID, secretKey, "")
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region), config.WithCredentialsProvider(creds))
+	scanner, err := NewScannerFromEnv()
 	if err != nil {
 		panic(err)
 	}
 
+	return NewWithStorage(storage, scanner)
+}
+
+func NewWithStorage(storage Storage, scanner Scanner) Service {
 	return &service{
-		s3Client: s3.NewFromConfig(cfg),
-		bucket:   "nyo-files",
-		cdnURL:   os.Getenv("CDN_URL"),
+		storage: storage,
+		scanner: scanner,
 	}
 }
 
-func (s *service) ProcessAndUploadFiles(filesToUpload []*multipart.FileHeader) ([]byte, *types.APIError) {
+// DetectContentType sniffs the type of a file the way it is when the file gets processed.
+func DetectContentType(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 512)
+	n, err := file.Read(buffer)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+func (s *service) ProcessAndUploadFiles(ctx context.Context, filesToUpload []*multipart.FileHeader) ([]byte, *types.APIError) {
 	var files []File
 
 	for _, fileHeader := range filesToUpload {
@@ -87,22 +150,44 @@ func (s *service) ProcessAndUploadFiles(filesToUpload []*multipart.FileHeader) (
 		randomID := cuid2.Generate()
 		var key string
 		var fileData io.Reader = file
+		var image *vips.ImageRef
 
 		if strings.Contains(mimeType, "image") {
-			processedImg, err := processImageVersions(file, nil, mimeType)
+			fileBytes, err := io.ReadAll(file)
+			if err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_READ_FILE", "Failed to read file.", err)
+			}
+
+			image, err = vips.LoadImageFromBuffer(fileBytes, vips.NewImportParams())
+			if err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process image.", err)
+			}
+			defer image.Close()
+
+			// The orientation is applied before the metadata gets stripped by the webp export.
+			if err := image.AutoRotate(); err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process image.", err)
+			}
+
+			staticData, _, err := image.ExportWebp(getWebpDefaultConfig())
 			if err != nil {
 				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process image.", err)
 			}
 
 			key = fmt.Sprintf("attachment-%s.webp", randomID)
-			fileData = bytes.NewReader(processedImg.StaticData)
+			fileData = bytes.NewReader(staticData)
 			if err := s.UploadFile(key, mimeType, fileData, fileHeader.Filename); err != nil {
 				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload file.", err)
 			}
 
-			if processedImg.IsGIF && processedImg.AnimatedData != nil {
+			if mimeType == "image/gif" {
+				animatedData, err := convertToWebpFromBytes(fileBytes, nil, true)
+				if err != nil {
+					return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process image.", err)
+				}
+
 				key = fmt.Sprintf("attachment-%s-animated.webp", randomID)
-				fileData = bytes.NewReader(processedImg.AnimatedData)
+				fileData = bytes.NewReader(animatedData)
 				if err := s.UploadFile(key, mimeType, fileData, fileHeader.Filename); err != nil {
 					return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload animated file.", err)
 				}
@@ -117,15 +202,23 @@ func (s *service) ProcessAndUploadFiles(filesToUpload []*multipart.FileHeader) (
 		}
 		defer file.Close()
 
-		fileURL := fmt.Sprintf("%s/%s", s.cdnURL, key)
+		fileURL := s.storage.URL(key)
 		fileSize := bytesToHuman(fileHeader.Size)
 
 		attachment := File{
-			ID:       randomID,
-			URL:      fileURL,
-			Filename: sanitizeFilename(fileHeader.Filename),
-			Filesize: fileSize,
-			Type:     mimeType,
+			ID:         randomID,
+			URL:        fileURL,
+			Filename:   sanitizeFilename(fileHeader.Filename),
+			Filesize:   fileSize,
+			Size:       fileHeader.Size,
+			Type:       mimeType,
+			Processing: NeedsProcessing(mimeType),
+		}
+
+		if image != nil {
+			if err := s.describeImage(ctx, &attachment, image, fmt.Sprintf("attachment-%s.webp", randomID)); err != nil {
+				return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process image.", err)
+			}
 		}
 
 		files = append(files, attachment)
@@ -139,10 +232,14 @@ func (s *service) ProcessAndUploadFiles(filesToUpload []*multipart.FileHeader) (
 	return res, nil
 }
 
-func (s *service) ProcessAndUploadEmojis(emojisToUpload []*multipart.FileHeader) ([]string, *types.APIError) {
+func (s *service) ProcessAndUploadEmojis(ctx context.Context, emojisToUpload []*multipart.FileHeader) ([]string, *types.APIError) {
 	var emojis []string
 
 	for _, fileHeader := range emojisToUpload {
+		if aerr := s.scanImage(ctx, fileHeader); aerr != nil {
+			return nil, aerr
+		}
+
 		file, err := fileHeader.Open()
 		if err != nil {
 			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_OPEN_FILE", "Failed to open file.", err)
@@ -180,7 +277,7 @@ func (s *service) ProcessAndUploadEmojis(emojisToUpload []*multipart.FileHeader)
 		}
 		defer file.Close()
 
-		fileURL := fmt.Sprintf("%s/%s", s.cdnURL, key)
+		fileURL := s.storage.URL(key)
 
 		emojis = append(emojis, fileURL)
 	}
@@ -188,8 +285,21 @@ func (s *service) ProcessAndUploadEmojis(emojisToUpload []*multipart.FileHeader)
 	return emojis, nil
 }
 
-func (s *service) ProcessAndUploadAvatar(entityID, imageType string, avatarToUpload *multipart.FileHeader, crop types.Crop) (*string, *types.APIError) {
-	file, err := avatarToUpload.Open()
+func (s *service) ProcessAndUploadAvatar(ctx context.Context, entityID, imageType string, avatarToUpload *multipart.FileHeader, crop types.Crop) (*string, *types.APIError) {
+	return s.processAndUploadImageVersions(ctx, entityID, imageType, avatarToUpload, &crop)
+}
+
+func (s *service) ProcessAndUploadServerEmoji(ctx context.Context, serverID string, kind types.ServerEmojiKind, emoji *multipart.FileHeader) (*string, *types.APIError) {
+	return s.processAndUploadImageVersions(ctx, serverID, string(kind), emoji, nil)
+}
+
+// processAndUploadImageVersions stores an image as a webp, along with its animated version for GIFs.
+func (s *service) processAndUploadImageVersions(ctx context.Context, entityID, imageType string, image *multipart.FileHeader, crop *types.Crop) (*string, *types.APIError) {
+	if aerr := s.scanImage(ctx, image); aerr != nil {
+		return nil, aerr
+	}
+
+	file, err := image.Open()
 	if err != nil {
 		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_OPEN_FILE", "Failed to open file.", err)
 	}
@@ -214,46 +324,41 @@ func (s *service) ProcessAndUploadAvatar(entityID, imageType string, avatarToUpl
 	var key string
 	var fileData io.Reader
 
-	processedImg, err := processImageVersions(file, &crop, mimeType)
+	processedImg, err := processImageVersions(file, crop, mimeType)
 	if err != nil {
-		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process avatar image.", err)
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_PROCESS_IMAGE", "Failed to process the image.", err)
 	}
 
 	key = fmt.Sprintf("%s-%s-%s.webp", entityID, imageType, staticID)
 	fileData = bytes.NewReader(processedImg.StaticData)
-	if err := s.UploadFile(key, mimeType, fileData, avatarToUpload.Filename); err != nil {
-		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload avatar.", err)
+	if err := s.UploadFile(key, mimeType, fileData, image.Filename); err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload the image.", err)
 	}
 
 	if processedImg.IsGIF && processedImg.AnimatedData != nil {
 		key = fmt.Sprintf("%s-%s-%s-animated.webp", entityID, imageType, staticID)
 		fileData = bytes.NewReader(processedImg.AnimatedData)
-		if err := s.UploadFile(key, mimeType, fileData, avatarToUpload.Filename); err != nil {
-			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload animated avatar.", err)
+		if err := s.UploadFile(key, mimeType, fileData, image.Filename); err != nil {
+			return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_UPLOAD_FILE", "Failed to upload the animated image.", err)
 		}
 	}
 
 	defer file.Close()
 
-	fileURL := fmt.Sprintf("%s/%s", s.cdnURL, key)
+	fileURL := s.storage.URL(key)
 
 	return &fileURL, nil
 }
 
 func (s *service) UploadFile(key string, mimeType string, fileData io.Reader, fileName string) error {
-	input := &s3.PutObjectInput{
-		Key:    &key,
-		Bucket: aws.String("nyo-files"),
-		Body:   fileData,
-	}
+	meta := Metadata{ContentType: mimeType}
 
 	if !strings.Contains(mimeType, "image") && !strings.Contains(mimeType, "video") {
-		input.ContentDisposition = aws.String(fmt.Sprintf(`attachment; filename="%s"`,
-			strings.ReplaceAll(fileName, `"`, `\"`)))
+		meta.ContentDisposition = fmt.Sprintf(`attachment; filename="%s"`,
+			strings.ReplaceAll(fileName, `"`, `\"`))
 	}
 
-	_, err := s.s3Client.PutObject(context.TODO(), input)
-	if err != nil {
+	if err := s.storage.Put(context.TODO(), key, fileData, meta); err != nil {
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
 
@@ -261,17 +366,109 @@ func (s *service) UploadFile(key string, mimeType string, fileData io.Reader, fi
 }
 
 func (s *service) DeleteFile(key string) error {
-	_, err := s.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Key:    &key,
-		Bucket: aws.String("nyo-files"),
-	})
-	if err != nil {
+	if err := s.storage.Delete(context.TODO(), key); err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
 	return nil
 }
 
+func (s *service) PresignUpload(ctx context.Context, uploadID, fileName, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	key := fmt.Sprintf("%sattachment-%s.%s", uploadsPrefix, uploadID, getSecureExtension(contentType))
+
+	meta := Metadata{ContentType: contentType, Size: size}
+	if !strings.Contains(contentType, "image") && !strings.Contains(contentType, "video") {
+		meta.ContentDisposition = fmt.Sprintf(`attachment; filename="%s"`,
+			strings.ReplaceAll(sanitizeFilename(fileName), `"`, `\"`))
+	}
+
+	url, headers, err := s.storage.PresignPut(ctx, key, meta, expires)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+
+	return &PresignedUpload{Key: key, URL: url, Headers: headers}, nil
+}
+
+func (s *service) PromoteUpload(ctx context.Context, key string) (string, error) {
+	promotedKey, ok := strings.CutPrefix(key, uploadsPrefix)
+	if !ok {
+		return "", fmt.Errorf("%s isn't a pending upload", key)
+	}
+
+	// Storages can't rename, the file is copied then deleted. A later PUT to the old key only
+	// leaves an unreferenced file behind, which the reconciliation collects.
+	body, meta, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	if err := s.storage.Put(ctx, promotedKey, body, *meta); err != nil {
+		return "", fmt.Errorf("failed to move the upload: %w", err)
+	}
+	if err := s.storage.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("failed to move the upload: %w", err)
+	}
+
+	return promotedKey, nil
+}
+
+func (s *service) InspectUpload(ctx context.Context, key string) (int64, []byte, error) {
+	body, meta, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer body.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, nil, err
+	}
+
+	return meta.Size, head[:n], nil
+}
+
+func (s *service) UploadedFile(uploadID, key, fileName, contentType string, size int64) File {
+	return File{
+		ID:         uploadID,
+		URL:        s.storage.URL(key),
+		Filename:   sanitizeFilename(fileName),
+		Filesize:   bytesToHuman(size),
+		Size:       size,
+		Type:       contentType,
+		Processing: NeedsProcessing(contentType),
+	}
+}
+
+func (s *service) KeyFromURL(url string) string {
+	prefix := s.storage.URL("")
+	if !strings.HasPrefix(url, prefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(url, prefix)
+}
+
+// VariantKeys returns the key along with the other files stored for it: GIFs are referenced by
+// their animated webp and have a static one next to it.
+func VariantKeys(key string) []string {
+	if static, ok := strings.CutSuffix(key, "-animated.webp"); ok {
+		return []string{key, static + ".webp"}
+	}
+
+	return []string{key}
+}
+
+func (s *service) Handler() http.Handler {
+	if _, ok := s.storage.(*S3Storage); ok {
+		return nil
+	}
+
+	return Handler(s.storage)
+}
+
 func getSecureExtension(mimeType string) string {
 	extensions := map[string]string{
 		"application/pdf": "pdf",
@@ -353,6 +550,13 @@ func convertToWebpFromBytes(fileBytes []byte, crop *types.Crop, isAnimated bool)
 	}
 	defer image.Close()
 
+	// Browsers show the image with its EXIF orientation, which the crop is based on.
+	if !isAnimated {
+		if err := image.AutoRotate(); err != nil {
+			return nil, err
+		}
+	}
+
 	if crop != nil {
 		err = image.ExtractArea(crop.X, crop.Y, crop.Width, crop.Height)
 		if err != nil {
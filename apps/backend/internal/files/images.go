@@ -0,0 +1,222 @@
+package files
+
+import (
+	"backend/internal/media"
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"github.com/nrednav/cuid2"
+)
+
+const (
+	thumbnailSize = 320
+	previewSize   = 1280
+	// MaxImageSize bounds the direct image uploads decoded in memory, larger images are refused
+	// before being uploaded since their metadata couldn't be stripped.
+	MaxImageSize = 50 << 20 // 50mb
+)
+
+// ImageVariant is a resized copy of an image attachment.
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// URLs returns every stored file of an attachment: the file itself, its variants and its poster.
+func (f File) URLs() []string {
+	urls := []string{f.URL}
+	for _, variant := range f.Variants {
+		if variant.URL != f.URL {
+			urls = append(urls, variant.URL)
+		}
+	}
+	if f.Poster != "" {
+		urls = append(urls, f.Poster)
+	}
+
+	return urls
+}
+
+// DerivedKeys returns the keys of the files generated from an attachment, which may not exist.
+func DerivedKeys(key string) []string {
+	base := strings.TrimSuffix(key, path.Ext(key))
+	return []string{base + "-thumbnail.webp", base + "-preview.webp", base + "-poster.webp"}
+}
+
+// ProcessImage strips the metadata of a directly uploaded image, in place, and describes it
+// with its dimensions, placeholder and variants.
+func (s *service) ProcessImage(ctx context.Context, file File) (File, error) {
+	key := s.KeyFromURL(file.URL)
+	if key == "" {
+		return file, fmt.Errorf("the file isn't in the storage")
+	}
+
+	body, meta, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return file, err
+	}
+	defer body.Close()
+
+	if meta.Size > MaxImageSize {
+		return file, fmt.Errorf("the image is too large to be processed")
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return file, err
+	}
+
+	image, err := vips.LoadImageFromBuffer(data, vips.NewImportParams())
+	if err != nil {
+		return file, fmt.Errorf("failed to load the image: %w", err)
+	}
+	defer image.Close()
+
+	// GIFs don't carry EXIF, re-encoding them would only lose the animation.
+	if image.Format() != vips.ImageTypeGIF {
+		if err := image.AutoRotate(); err != nil {
+			return file, fmt.Errorf("failed to rotate the image: %w", err)
+		}
+
+		stripped, err := exportStripped(image)
+		if err != nil {
+			return file, fmt.Errorf("failed to strip the image metadata: %w", err)
+		}
+
+		if err := s.storage.Put(ctx, key, bytes.NewReader(stripped), *meta); err != nil {
+			return file, fmt.Errorf("failed to upload the stripped image: %w", err)
+		}
+		file.Filesize = bytesToHuman(int64(len(stripped)))
+		file.Size = int64(len(stripped))
+	}
+
+	if err := s.describeImage(ctx, &file, image, key); err != nil {
+		return file, err
+	}
+
+	return file, nil
+}
+
+func (s *service) ProcessAndUploadEmbedImage(ctx context.Context, data []byte) (*ImageVariant, error) {
+	image, err := vips.LoadImageFromBuffer(data, vips.NewImportParams())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the image: %w", err)
+	}
+	defer image.Close()
+
+	if err := image.AutoRotate(); err != nil {
+		return nil, fmt.Errorf("failed to rotate the image: %w", err)
+	}
+
+	key := fmt.Sprintf("embed-%s.webp", cuid2.Generate())
+	variant, err := s.uploadVariant(ctx, image, key, previewSize)
+	if err != nil {
+		return nil, err
+	}
+	defer variant.Close()
+
+	return &ImageVariant{URL: s.storage.URL(key), Width: variant.Width(), Height: variant.Height()}, nil
+}
+
+// describeImage uploads the variants of an image next to key and records them on the file.
+// The image has to be rotated already.
+func (s *service) describeImage(ctx context.Context, file *File, image *vips.ImageRef, key string) error {
+	file.Width, file.Height = image.Width(), image.PageHeight()
+	file.Variants = map[string]ImageVariant{
+		"original": {URL: file.URL, Width: file.Width, Height: file.Height},
+	}
+
+	base := strings.TrimSuffix(key, path.Ext(key))
+
+	thumbnail, err := s.uploadVariant(ctx, image, base+"-thumbnail.webp", thumbnailSize)
+	if err != nil {
+		return err
+	}
+	defer thumbnail.Close()
+	file.Variants["thumbnail"] = ImageVariant{URL: s.storage.URL(base + "-thumbnail.webp"), Width: thumbnail.Width(), Height: thumbnail.Height()}
+
+	// The placeholder is computed on the thumbnail, it only keeps a few colors anyway.
+	pngData, _, err := thumbnail.ExportPng(vips.NewPngExportParams())
+	if err != nil {
+		return fmt.Errorf("failed to export the thumbnail: %w", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return fmt.Errorf("failed to decode the thumbnail: %w", err)
+	}
+	file.Blurhash = media.Blurhash(decoded, 4, 3)
+
+	if max(file.Width, file.Height) <= previewSize {
+		file.Variants["preview"] = file.Variants["original"]
+		return nil
+	}
+
+	preview, err := s.uploadVariant(ctx, image, base+"-preview.webp", previewSize)
+	if err != nil {
+		return err
+	}
+	defer preview.Close()
+	file.Variants["preview"] = ImageVariant{URL: s.storage.URL(base + "-preview.webp"), Width: preview.Width(), Height: preview.Height()}
+
+	return nil
+}
+
+// uploadVariant stores a copy of the image which fits in size×size. The caller closes the returned image.
+func (s *service) uploadVariant(ctx context.Context, image *vips.ImageRef, key string, size int) (*vips.ImageRef, error) {
+	variant, err := image.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy the image: %w", err)
+	}
+
+	if err := variant.ThumbnailWithSize(size, size, vips.InterestingNone, vips.SizeDown); err != nil {
+		variant.Close()
+		return nil, fmt.Errorf("failed to resize the image: %w", err)
+	}
+
+	data, _, err := variant.ExportWebp(getWebpDefaultConfig())
+	if err != nil {
+		variant.Close()
+		return nil, fmt.Errorf("failed to export the image: %w", err)
+	}
+
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), Metadata{ContentType: "image/webp"}); err != nil {
+		variant.Close()
+		return nil, fmt.Errorf("failed to upload the image: %w", err)
+	}
+
+	return variant, nil
+}
+
+// exportStripped encodes the image in its own format without its metadata (EXIF, GPS, XMP...).
+func exportStripped(image *vips.ImageRef) ([]byte, error) {
+	var data []byte
+	var err error
+
+	switch image.Format() {
+	case vips.ImageTypeJPEG:
+		params := vips.NewJpegExportParams()
+		params.Quality = 90
+		params.StripMetadata = true
+		data, _, err = image.ExportJpeg(params)
+	case vips.ImageTypePNG:
+		params := vips.NewPngExportParams()
+		params.StripMetadata = true
+		data, _, err = image.ExportPng(params)
+	case vips.ImageTypeWEBP:
+		data, _, err = image.ExportWebp(getWebpDefaultConfig())
+	default:
+		params := vips.NewDefaultExportParams()
+		params.Format = image.Format()
+		params.StripMetadata = true
+		data, _, err = image.Export(params)
+	}
+
+	return data, err
+}
@@ -0,0 +1,108 @@
+package files
+
+import (
+	"backend/internal/media"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// IsVisualMedia reports whether an attachment of this type is an image or a video, the files
+// gallery posts are made of.
+func IsVisualMedia(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/")
+}
+
+// NeedsProcessing reports whether an attachment of this type is probed after being posted.
+func NeedsProcessing(contentType string) bool {
+	if !media.Available() {
+		return false
+	}
+
+	return strings.HasPrefix(contentType, "video/") || strings.HasPrefix(contentType, "audio/") || contentType == "application/ogg"
+}
+
+func (s *service) ProcessMedia(ctx context.Context, file File) (File, error) {
+	file.Processing = false
+
+	key := s.KeyFromURL(file.URL)
+	if key == "" {
+		return file, errors.New("the file isn't in the storage")
+	}
+
+	// ffprobe needs to seek, mp4 files usually have their index at the end.
+	tmpPath, err := s.download(ctx, key)
+	if err != nil {
+		return file, err
+	}
+	defer os.Remove(tmpPath)
+
+	info, err := media.Probe(ctx, tmpPath)
+	if err != nil {
+		return file, err
+	}
+
+	file.Duration = info.Duration
+	file.VideoCodec = info.VideoCodec
+	file.AudioCodec = info.AudioCodec
+	file.Width = info.Width
+	file.Height = info.Height
+
+	if !info.HasPicture {
+		return file, nil
+	}
+
+	frame, err := media.Frame(ctx, tmpPath, min(1, info.Duration/2))
+	if err != nil {
+		return file, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return file, fmt.Errorf("failed to decode the poster: %w", err)
+	}
+	file.Blurhash = media.Blurhash(img, 4, 3)
+
+	poster, err := convertToWebpFromBytes(frame, nil, false)
+	if err != nil {
+		return file, fmt.Errorf("failed to convert the poster: %w", err)
+	}
+
+	posterKey := strings.TrimSuffix(key, path.Ext(key)) + "-poster.webp"
+	if err := s.storage.Put(ctx, posterKey, bytes.NewReader(poster), Metadata{ContentType: "image/webp"}); err != nil {
+		return file, fmt.Errorf("failed to upload the poster: %w", err)
+	}
+	file.Poster = s.storage.URL(posterKey)
+
+	return file, nil
+}
+
+func (s *service) download(ctx context.Context, key string) (string, error) {
+	body, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "media-*")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
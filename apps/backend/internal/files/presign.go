@@ -0,0 +1,112 @@
+package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired upload signature")
+
+// uploadsPrefix is where presigned uploads are written. They are moved out once confirmed, so the
+// checked file can't be replaced through a URL which is still valid.
+const uploadsPrefix = "uploads/"
+
+// signer presigns uploads for the storages which receive them through Handler,
+// the way S3 does with its own presigned URLs.
+type signer struct {
+	secret []byte
+}
+
+func (s *signer) presign(publicURL, key string, meta Metadata, expires time.Duration) string {
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	query.Set("size", strconv.FormatInt(meta.Size, 10))
+	query.Set("signature", s.sign(key, meta, expiresAt))
+
+	return publicURL + "/" + key + "?" + query.Encode()
+}
+
+// verify checks the signature of a presigned PUT and returns the metadata it was signed for.
+func (s *signer) verify(r *http.Request, key string) (*Metadata, error) {
+	query := r.URL.Query()
+
+	expiresAt, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidSignature
+	}
+
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	meta := &Metadata{
+		ContentType:        r.Header.Get("Content-Type"),
+		ContentDisposition: r.Header.Get("Content-Disposition"),
+		Size:               size,
+	}
+
+	expected := s.sign(key, *meta, query.Get("expires"))
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	return meta, nil
+}
+
+func (s *signer) sign(key string, meta Metadata, expiresAt string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join([]string{
+		key,
+		meta.ContentType,
+		meta.ContentDisposition,
+		strconv.FormatInt(meta.Size, 10),
+		expiresAt,
+	}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// uploadHeaders are the headers a client has to send with a presigned PUT.
+func uploadHeaders(meta Metadata) map[string]string {
+	headers := map[string]string{"Content-Type": meta.ContentType}
+	if meta.ContentDisposition != "" {
+		headers["Content-Disposition"] = meta.ContentDisposition
+	}
+
+	return headers
+}
+
+// MatchesContent reports whether the first bytes of a file back its declared type. Images, videos and
+// audio are rendered inline so they must be what they claim, anything else is only offered as a download.
+func MatchesContent(declared string, head []byte) bool {
+	declaredMajor, _, _ := strings.Cut(declared, "/")
+	if declaredMajor != "image" && declaredMajor != "video" && declaredMajor != "audio" {
+		return true
+	}
+
+	sniffed, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	sniffedMajor, _, _ := strings.Cut(sniffed, "/")
+	if sniffedMajor == declaredMajor {
+		return true
+	}
+
+	// Containers shared by audio and video are sniffed as one or the other.
+	switch sniffed {
+	case "application/ogg":
+		return declaredMajor == "audio" || declaredMajor == "video"
+	case "video/webm", "video/mp4":
+		return declaredMajor == "audio"
+	}
+
+	return false
+}
@@ -0,0 +1,206 @@
+package files
+
+import (
+	"backend/internal/types"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nrednav/cuid2"
+)
+
+// quarantinePrefix is where flagged files are moved, they are never served.
+const quarantinePrefix = "quarantine/"
+
+// ErrTooLargeToScan is returned for the files the scanner can't read whole, they are refused
+// rather than trusted on their beginning.
+var ErrTooLargeToScan = errors.New("the file is too large to be scanned")
+
+// Scanner checks files for malware before they become visible.
+type Scanner interface {
+	Scan(ctx context.Context, body io.Reader) (*ScanResult, error)
+	// MaxSize is the size of the largest file the scanner reads whole, 0 when there is no limit.
+	MaxSize() int64
+}
+
+type ScanResult struct {
+	Infected bool
+	// Signature names what was found.
+	Signature string
+}
+
+// Quarantined describes a flagged file moved to the quarantine.
+type Quarantined struct {
+	Key         string
+	FileName    string
+	ContentType string
+	Size        int64
+	Signature   string
+}
+
+// NoopScanner accepts every file, it is used when no scanner is configured.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, body io.Reader) (*ScanResult, error) {
+	return &ScanResult{}, nil
+}
+
+func (NoopScanner) MaxSize() int64 {
+	return 0
+}
+
+// ScanError describes a failed scan to the client.
+func ScanError(err error) *types.APIError {
+	if errors.Is(err, ErrTooLargeToScan) {
+		return types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_FILE_TOO_LARGE_TO_SCAN", "The file is too large to be scanned.", err)
+	}
+
+	return types.NewAPIError(http.StatusServiceUnavailable, "ERR_SCAN_FAILED", "The file couldn't be scanned.", err)
+}
+
+// NewScannerFromEnv builds the scanner selected by SCANNER: "clamav" or none by default.
+func NewScannerFromEnv() (Scanner, error) {
+	switch scanner := os.Getenv("SCANNER"); scanner {
+	case "", "none":
+		return NoopScanner{}, nil
+	case "clamav":
+		maxSize := int64(defaultClamAVMaxSize)
+		if v := os.Getenv("CLAMAV_MAX_SIZE"); v != "" {
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CLAMAV_MAX_SIZE: %w", err)
+			}
+			maxSize = size
+		}
+
+		address := os.Getenv("CLAMAV_ADDRESS")
+		if address == "" {
+			address = "unix:///var/run/clamav/clamd.ctl"
+		}
+
+		return NewClamAVScanner(address, maxSize, 30*time.Second)
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", scanner)
+	}
+}
+
+func (s *service) MaxScanSize() int64 {
+	return s.scanner.MaxSize()
+}
+
+// scanImage refuses infected images outright. They are converted before being stored, so there
+// is no original to keep in the quarantine.
+func (s *service) scanImage(ctx context.Context, header *multipart.FileHeader) *types.APIError {
+	file, err := header.Open()
+	if err != nil {
+		return types.NewAPIError(http.StatusInternalServerError, "ERR_OPEN_FILE", "Failed to open file.", err)
+	}
+	defer file.Close()
+
+	result, err := s.scanner.Scan(ctx, file)
+	if err != nil {
+		return ScanError(err)
+	}
+	if result.Infected {
+		return types.NewAPIError(http.StatusUnprocessableEntity, "ERR_FILE_BLOCKED", "The file was flagged by the malware scanner.", nil)
+	}
+
+	return nil
+}
+
+// IsQuarantined reports whether the key belongs to a quarantined file.
+func IsQuarantined(key string) bool {
+	return strings.HasPrefix(key, quarantinePrefix)
+}
+
+func (s *service) ScanFiles(ctx context.Context, headers []*multipart.FileHeader) (*Quarantined, error) {
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		result, err := s.scanner.Scan(ctx, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !result.Infected {
+			continue
+		}
+
+		file, err = header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		contentType := header.Header.Get("Content-Type")
+		key := quarantinePrefix + cuid2.Generate()
+		if err := s.storage.Put(ctx, key, file, quarantineMetadata(contentType, header.Filename)); err != nil {
+			return nil, fmt.Errorf("failed to quarantine the file: %w", err)
+		}
+
+		return &Quarantined{
+			Key:         key,
+			FileName:    sanitizeFilename(header.Filename),
+			ContentType: contentType,
+			Size:        header.Size,
+			Signature:   result.Signature,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (s *service) ScanStored(ctx context.Context, key, fileName string) (*Quarantined, error) {
+	body, meta, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.scanner.Scan(ctx, body)
+	body.Close()
+	if err != nil || !result.Infected {
+		return nil, err
+	}
+
+	// Storages can't rename, the file is copied then deleted from its public key.
+	body, meta, err = s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	quarantineKey := quarantinePrefix + key
+	if err := s.storage.Put(ctx, quarantineKey, body, quarantineMetadata(meta.ContentType, fileName)); err != nil {
+		return nil, fmt.Errorf("failed to quarantine the file: %w", err)
+	}
+	for _, k := range append([]string{key}, DerivedKeys(key)...) {
+		if err := s.storage.Delete(ctx, k); err != nil {
+			return nil, fmt.Errorf("failed to quarantine the file: %w", err)
+		}
+	}
+
+	return &Quarantined{
+		Key:         quarantineKey,
+		FileName:    sanitizeFilename(fileName),
+		ContentType: meta.ContentType,
+		Size:        meta.Size,
+		Signature:   result.Signature,
+	}, nil
+}
+
+// quarantineMetadata makes sure a quarantined file is only ever downloaded, never rendered.
+func quarantineMetadata(contentType, fileName string) Metadata {
+	return Metadata{
+		ContentType:        cmp.Or(contentType, "application/octet-stream"),
+		ContentDisposition: fmt.Sprintf(`attachment; filename="%s"`, strings.ReplaceAll(sanitizeFilename(fileName), `"`, `\"`)),
+	}
+}
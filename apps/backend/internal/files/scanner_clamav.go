@@ -0,0 +1,120 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultClamAVMaxSize matches the default StreamMaxLength of clamd.
+	defaultClamAVMaxSize = 25 << 20 // 25mb
+	clamAVChunkSize      = 64 << 10
+)
+
+// ClamAVScanner streams files to clamd with the INSTREAM command.
+type ClamAVScanner struct {
+	network string
+	address string
+	// maxSize bounds what is sent to clamd, which rejects streams above its StreamMaxLength.
+	// Larger files are refused with ErrTooLargeToScan.
+	maxSize int64
+	timeout time.Duration
+}
+
+// NewClamAVScanner connects to clamd at unix:///path/to/clamd.sock or tcp://host:port.
+func NewClamAVScanner(address string, maxSize int64, timeout time.Duration) (*ClamAVScanner, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clamd address: %w", err)
+	}
+
+	scanner := &ClamAVScanner{maxSize: maxSize, timeout: timeout}
+	switch u.Scheme {
+	case "unix":
+		scanner.network, scanner.address = "unix", u.Path
+	case "tcp":
+		scanner.network, scanner.address = "tcp", u.Host
+	default:
+		return nil, fmt.Errorf("unsupported clamd address %q", address)
+	}
+
+	return scanner, nil
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, body io.Reader) (*ScanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	// Each chunk is prefixed with its length, a zero length ends the stream.
+	chunk := make([]byte, 4+clamAVChunkSize)
+	limited := io.LimitReader(body, s.maxSize)
+	for {
+		n, err := io.ReadFull(limited, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, werr := conn.Write(chunk[:4+n]); werr != nil {
+				return nil, fmt.Errorf("failed to send to clamd: %w", werr)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the file: %w", err)
+		}
+	}
+	if _, err := io.ReadFull(body, chunk[:1]); err == nil {
+		return nil, ErrTooLargeToScan
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read the clamd reply: %w", err)
+	}
+
+	return parseClamAVReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+func (s *ClamAVScanner) MaxSize() int64 {
+	return s.maxSize
+}
+
+// parseClamAVReply reads "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+func parseClamAVReply(reply string) (*ScanResult, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case result == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
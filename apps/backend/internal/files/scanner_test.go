@@ -0,0 +1,118 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands like clamd, flagging the EICAR test string.
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+
+				command, err := r.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var data []byte
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+
+				if strings.Contains(string(data), eicar) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestClamAVScanner(t *testing.T) {
+	scanner, err := NewClamAVScanner(fakeClamd(t), defaultClamAVMaxSize, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClamAVScanner() failed: %v", err)
+	}
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("clean data ", 20000)))
+	if err != nil {
+		t.Fatalf("Scan() failed: %v", err)
+	}
+	if result.Infected {
+		t.Fatalf("expected a clean file, got %+v", result)
+	}
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("Scan() failed: %v", err)
+	}
+	if !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expected the test signature, got %+v", result)
+	}
+}
+
+func TestClamAVScannerRefusesLargeFiles(t *testing.T) {
+	scanner, err := NewClamAVScanner(fakeClamd(t), 1024, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClamAVScanner() failed: %v", err)
+	}
+
+	if _, err := scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("a", 1024))); err != nil {
+		t.Fatalf("expected a file of the maximum size to be scanned, got %v", err)
+	}
+
+	// The infected part sits past what clamd would have read.
+	_, err = scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("a", 1024)+eicar))
+	if !errors.Is(err, ErrTooLargeToScan) {
+		t.Fatalf("expected ErrTooLargeToScan, got %v", err)
+	}
+}
+
+func TestParseClamAVReply(t *testing.T) {
+	if _, err := parseClamAVReply("INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Fatal("expected clamd errors to be returned")
+	}
+
+	if _, err := NewClamAVScanner("http://clamd:3310", defaultClamAVMaxSize, time.Second); err == nil {
+		t.Fatal("expected unsupported addresses to be rejected")
+	}
+}
@@ -0,0 +1,187 @@
+package files
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("file not found")
+	ErrInvalidKey = errors.New("invalid file key")
+)
+
+// Storage is where uploaded files end up. Keys are slash separated relative paths.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, meta Metadata) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *Metadata, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL the file is served from.
+	URL(key string) string
+	// PresignPut returns a URL the client can PUT the file to directly, bound to the metadata and size.
+	// The returned headers have to be sent with the request.
+	PresignPut(ctx context.Context, key string, meta Metadata, expires time.Duration) (string, map[string]string, error)
+	// List calls fn for every stored file, with its size and modification time. It stops at the first error.
+	List(ctx context.Context, fn func(key string, meta Metadata) error) error
+}
+
+type Metadata struct {
+	ContentType        string    `json:"content_type"`
+	ContentDisposition string    `json:"content_disposition,omitempty"`
+	Size               int64     `json:"size"`
+	ModTime            time.Time `json:"mod_time"`
+}
+
+// NewStorageFromEnv builds the storage selected by STORAGE_DRIVER: "s3" (default), "local" or "memory".
+func NewStorageFromEnv() (Storage, error) {
+	// Presigned uploads to the local drivers are signed with this key. A random one only works
+	// as long as a single instance handles both the presign and the upload.
+	secret := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = os.Getenv("CDN_URL")
+	}
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "s3":
+		return NewS3Storage(S3Config{
+			Region:    os.Getenv("AWS_REGION"),
+			KeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    cmp.Or(os.Getenv("S3_BUCKET"), "nyo-files"),
+			PublicURL: publicURL,
+		})
+	case "local":
+		return NewLocalStorage(cmp.Or(os.Getenv("LOCAL_STORAGE_PATH"), "./storage"), cmp.Or(publicURL, localURL()), secret)
+	case "memory":
+		return NewMemoryStorage(cmp.Or(publicURL, localURL()), secret), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+// localURL is where the backend serves files itself when no public URL is configured.
+func localURL() string {
+	return "http://localhost:" + os.Getenv("PORT") + "/files"
+}
+
+// ValidKey rejects keys that could escape the storage root or hit the local driver metadata.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 1024 || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	if path.Clean(key) != key || strings.HasPrefix(key, "/") {
+		return false
+	}
+
+	for segment := range strings.SplitSeq(key, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// presignedStorage is implemented by the storages which receive presigned uploads through Handler.
+type presignedStorage interface {
+	uploadSigner() *signer
+}
+
+// Handler serves the files of a storage which has no public endpoint of its own, with range
+// requests so media can be seeked. It also receives the presigned uploads of that storage.
+func Handler(storage Storage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if !ValidKey(key) || IsQuarantined(key) {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			// Pending uploads aren't checked yet, they are only served once confirmed.
+			if strings.HasPrefix(key, uploadsPrefix) {
+				http.NotFound(w, r)
+				return
+			}
+		case http.MethodPut:
+			receiveUpload(w, r, storage, key)
+			return
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, meta, err := storage.Get(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", meta.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		if meta.ContentDisposition != "" {
+			w.Header().Set("Content-Disposition", meta.ContentDisposition)
+		}
+
+		if seeker, ok := body.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, "", meta.ModTime, seeker)
+			return
+		}
+
+		w.Header().Set("Content-Length", fmt.Sprint(meta.Size))
+		if r.Method == http.MethodGet {
+			io.Copy(w, body)
+		}
+	})
+}
+
+func receiveUpload(w http.ResponseWriter, r *http.Request, storage Storage, key string) {
+	presigned, ok := storage.(presignedStorage)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	meta, err := presigned.uploadSigner().verify(r, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if r.ContentLength != meta.Size {
+		http.Error(w, "the body doesn't have the presigned size", http.StatusBadRequest)
+		return
+	}
+
+	// Uploads are large by nature, they get more time than the server wide read timeout.
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(30 * time.Minute))
+
+	if err := storage.Put(r.Context(), key, io.LimitReader(r.Body, meta.Size), *meta); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,178 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps files on disk, with their metadata in a hidden directory next to them.
+// It has no public endpoint, files are served by the backend through Handler.
+type LocalStorage struct {
+	root      string
+	publicURL string
+	signer    *signer
+}
+
+func NewLocalStorage(root, publicURL string, secret []byte) (*LocalStorage, error) {
+	if err := os.MkdirAll(filepath.Join(root, ".meta"), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		root:      root,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		signer:    &signer{secret: secret},
+	}, nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, meta Metadata) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	filePath := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	// Written aside then renamed so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	meta.Size = size
+	if err := s.writeMetadata(key, meta); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *Metadata, error) {
+	if !ValidKey(key) {
+		return nil, nil, ErrInvalidKey
+	}
+
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	meta := Metadata{ContentType: "application/octet-stream"}
+	if data, err := os.ReadFile(s.metadataPath(key)); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	meta.Size = info.Size()
+	meta.ModTime = info.ModTime()
+
+	return file, &meta, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(s.metadataPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) List(ctx context.Context, fn func(key string, meta Metadata) error) error {
+	return filepath.WalkDir(s.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skips the metadata and the uploads being written.
+		if strings.HasPrefix(entry.Name(), ".") && filePath != s.root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return ctx.Err()
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+
+		return fn(filepath.ToSlash(rel), Metadata{Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *LocalStorage) metadataPath(key string) string {
+	return filepath.Join(s.root, ".meta", filepath.FromSlash(key)+".json")
+}
+
+func (s *LocalStorage) writeMetadata(key string, meta Metadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	metaPath := s.metadataPath(key)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(metaPath, data, 0o644)
+}
+
+func (s *LocalStorage) PresignPut(ctx context.Context, key string, meta Metadata, expires time.Duration) (string, map[string]string, error) {
+	if !ValidKey(key) {
+		return "", nil, ErrInvalidKey
+	}
+
+	return s.signer.presign(s.publicURL, key, meta, expires), uploadHeaders(meta), nil
+}
+
+func (s *LocalStorage) uploadSigner() *signer {
+	return s.signer
+}
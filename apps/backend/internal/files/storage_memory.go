@@ -0,0 +1,109 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps files in memory, for tests and throwaway instances.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	objects   map[string]memoryObject
+	publicURL string
+	signer    *signer
+}
+
+type memoryObject struct {
+	data []byte
+	meta Metadata
+}
+
+func NewMemoryStorage(publicURL string, secret []byte) *MemoryStorage {
+	return &MemoryStorage{
+		objects:   make(map[string]memoryObject),
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		signer:    &signer{secret: secret},
+	}
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, key string, body io.Reader, meta Metadata) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	meta.Size = int64(len(data))
+	meta.ModTime = time.Now()
+
+	s.mu.Lock()
+	s.objects[key] = memoryObject{data: data, meta: meta}
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, *Metadata, error) {
+	s.mu.RLock()
+	object, ok := s.objects[key]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	meta := object.meta
+	return readSeekNopCloser{bytes.NewReader(object.data)}, &meta, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.objects, key)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStorage) List(ctx context.Context, fn func(key string, meta Metadata) error) error {
+	s.mu.RLock()
+	objects := maps.Clone(s.objects)
+	s.mu.RUnlock()
+
+	for key, object := range objects {
+		if err := fn(key, object.meta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+type readSeekNopCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekNopCloser) Close() error { return nil }
+
+func (s *MemoryStorage) PresignPut(ctx context.Context, key string, meta Metadata, expires time.Duration) (string, map[string]string, error) {
+	if !ValidKey(key) {
+		return "", nil, ErrInvalidKey
+	}
+
+	return s.signer.presign(s.publicURL, key, meta, expires), uploadHeaders(meta), nil
+}
+
+func (s *MemoryStorage) uploadSigner() *signer {
+	return s.signer
+}
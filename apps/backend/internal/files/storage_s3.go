@@ -0,0 +1,151 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type S3Config struct {
+	Region    string
+	KeyID     string
+	SecretKey string
+	// Endpoint overrides the AWS endpoint for S3 compatible stores such as MinIO.
+	Endpoint  string
+	Bucket    string
+	PublicURL string
+}
+
+// S3Storage keeps files in a S3 bucket, served through PublicURL (usually a CDN).
+type S3Storage struct {
+	client    *s3.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	creds := credentials.NewStaticCredentialsProvider(cfg.KeyID, cfg.SecretKey, "")
+	awsCfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(cfg.Region), config.WithCredentialsProvider(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			// MinIO and most self hosted stores don't support virtual hosted buckets.
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Storage{
+		client:    client,
+		bucket:    cfg.Bucket,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, meta Metadata) error {
+	input := &s3.PutObjectInput{
+		Key:    aws.String(key),
+		Bucket: aws.String(s.bucket),
+		Body:   body,
+	}
+	if meta.ContentType != "" {
+		input.ContentType = aws.String(meta.ContentType)
+	}
+	if meta.ContentDisposition != "" {
+		input.ContentDisposition = aws.String(meta.ContentDisposition)
+	}
+
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *Metadata, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Key:    aws.String(key),
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	meta := &Metadata{
+		ContentType:        aws.ToString(output.ContentType),
+		ContentDisposition: aws.ToString(output.ContentDisposition),
+		Size:               aws.ToInt64(output.ContentLength),
+		ModTime:            aws.ToTime(output.LastModified),
+	}
+
+	return output.Body, meta, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Key:    aws.String(key),
+		Bucket: aws.String(s.bucket),
+	})
+	return err
+}
+
+func (s *S3Storage) List(ctx context.Context, fn func(key string, meta Metadata) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			meta := Metadata{
+				Size:    aws.ToInt64(object.Size),
+				ModTime: aws.ToTime(object.LastModified),
+			}
+			if err := fn(aws.ToString(object.Key), meta); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Storage) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+func (s *S3Storage) PresignPut(ctx context.Context, key string, meta Metadata, expires time.Duration) (string, map[string]string, error) {
+	input := &s3.PutObjectInput{
+		Key:           aws.String(key),
+		Bucket:        aws.String(s.bucket),
+		ContentType:   aws.String(meta.ContentType),
+		ContentLength: aws.Int64(meta.Size),
+	}
+	if meta.ContentDisposition != "" {
+		input.ContentDisposition = aws.String(meta.ContentDisposition)
+	}
+
+	req, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return req.URL, uploadHeaders(meta), nil
+}
@@ -0,0 +1,293 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	valid := []string{"attachment-abc.webp", "attachments/abc/thumbnail.webp"}
+	for _, key := range valid {
+		if !ValidKey(key) {
+			t.Errorf("expected %q to be valid", key)
+		}
+	}
+
+	invalid := []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b", ".meta/a.json", "a/.hidden", `a\b`}
+	for _, key := range invalid {
+		if ValidKey(key) {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestVariantKeys(t *testing.T) {
+	got := VariantKeys("user-avatar-abc-animated.webp")
+	if len(got) != 2 || got[0] != "user-avatar-abc-animated.webp" || got[1] != "user-avatar-abc.webp" {
+		t.Fatalf("unexpected variants %v", got)
+	}
+	if got := VariantKeys("attachment-abc.pdf"); len(got) != 1 {
+		t.Fatalf("unexpected variants %v", got)
+	}
+}
+
+func TestFileURLs(t *testing.T) {
+	file := File{
+		URL: "http://cdn/attachment-a.webp",
+		Variants: map[string]ImageVariant{
+			"original":  {URL: "http://cdn/attachment-a.webp"},
+			"thumbnail": {URL: "http://cdn/attachment-a-thumbnail.webp"},
+		},
+	}
+	if got := file.URLs(); len(got) != 2 || got[0] != file.URL || got[1] != "http://cdn/attachment-a-thumbnail.webp" {
+		t.Fatalf("unexpected urls %v", got)
+	}
+
+	got := DerivedKeys("attachment-a.mp4")
+	if len(got) != 3 || got[0] != "attachment-a-thumbnail.webp" || got[2] != "attachment-a-poster.webp" {
+		t.Fatalf("unexpected derived keys %v", got)
+	}
+}
+
+func testStorage(t *testing.T, storage Storage) {
+	ctx := context.Background()
+
+	meta := Metadata{ContentType: "text/plain", ContentDisposition: `attachment; filename="notes.txt"`}
+	if err := storage.Put(ctx, "attachments/notes.txt", strings.NewReader("hello world"), meta); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	body, got, err := storage.Get(ctx, "attachments/notes.txt")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+
+	if string(data) != "hello world" || got.Size != 11 || got.ContentType != "text/plain" || got.ContentDisposition != meta.ContentDisposition {
+		t.Fatalf("unexpected file %q with metadata %+v", data, got)
+	}
+
+	var keys []string
+	if err := storage.List(ctx, func(key string, meta Metadata) error {
+		if meta.Size != 11 || meta.ModTime.IsZero() {
+			t.Errorf("unexpected metadata %+v for %q", meta, key)
+		}
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "attachments/notes.txt" {
+		t.Fatalf("expected List() to return the file only, got %v", keys)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix("/files", Handler(storage)))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/files/attachments/notes.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to get the file: %v", err)
+	}
+	data, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent || string(data) != "world" {
+		t.Fatalf("expected a partial response with \"world\", got %d %q", res.StatusCode, data)
+	}
+	if res.Header.Get("Content-Disposition") != meta.ContentDisposition || res.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("unexpected headers %v", res.Header)
+	}
+
+	if err := storage.Delete(ctx, "attachments/notes.txt"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, _, err := storage.Get(ctx, "attachments/notes.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after Delete(), got %v", err)
+	}
+
+	res, err = http.Get(srv.URL + "/files/attachments/notes.txt")
+	if err != nil {
+		t.Fatalf("failed to get the file: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a deleted file to be a 404, got %d", res.StatusCode)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+
+	storage, err := NewLocalStorage(root, "http://localhost/files/", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage() failed: %v", err)
+	}
+
+	if url := storage.URL("a.webp"); url != "http://localhost/files/a.webp" {
+		t.Fatalf("unexpected url %s", url)
+	}
+
+	if err := storage.Put(context.Background(), "../escape.txt", bytes.NewReader(nil), Metadata{}); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+
+	testStorage(t, storage)
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage("http://localhost/files", []byte("secret")))
+}
+
+func TestPresignedUpload(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	storage := NewMemoryStorage("http://"+srv.Listener.Addr().String()+"/files", []byte("secret"))
+	srv.Config.Handler = http.StripPrefix("/files", Handler(storage))
+	srv.Start()
+	defer srv.Close()
+
+	put := func(url string, headers map[string]string, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT failed: %v", err)
+		}
+		res.Body.Close()
+
+		return res.StatusCode
+	}
+
+	meta := Metadata{ContentType: "text/plain", ContentDisposition: `attachment; filename="notes.txt"`, Size: 5}
+	url, headers, err := storage.PresignPut(context.Background(), "upload.txt", meta, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut() failed: %v", err)
+	}
+
+	if status := put(url, map[string]string{"Content-Type": "image/png"}, "hello"); status != http.StatusForbidden {
+		t.Fatalf("expected a different content type to be rejected, got %d", status)
+	}
+	if status := put(url, headers, "hello world"); status != http.StatusBadRequest {
+		t.Fatalf("expected a different size to be rejected, got %d", status)
+	}
+	if status := put(strings.Replace(url, "upload.txt", "other.txt", 1), headers, "hello"); status != http.StatusForbidden {
+		t.Fatalf("expected a different key to be rejected, got %d", status)
+	}
+	if status := put(url, headers, "hello"); status != http.StatusOK {
+		t.Fatalf("expected the upload to succeed, got %d", status)
+	}
+
+	body, got, err := storage.Get(context.Background(), "upload.txt")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	if string(data) != "hello" || got.ContentDisposition != meta.ContentDisposition {
+		t.Fatalf("unexpected upload %q with metadata %+v", data, got)
+	}
+
+	expired, headers, _ := storage.PresignPut(context.Background(), "late.txt", meta, -time.Minute)
+	if status := put(expired, headers, "hello"); status != http.StatusForbidden {
+		t.Fatalf("expected an expired url to be rejected, got %d", status)
+	}
+}
+
+func TestPromoteUpload(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	storage := NewMemoryStorage("http://"+srv.Listener.Addr().String()+"/files", []byte("secret"))
+	srv.Config.Handler = http.StripPrefix("/files", Handler(storage))
+	srv.Start()
+	defer srv.Close()
+
+	service := NewWithStorage(storage, NoopScanner{})
+	ctx := context.Background()
+
+	presigned, err := service.PresignUpload(context.Background(), "abc", "notes.txt", "text/plain", 5, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload() failed: %v", err)
+	}
+
+	put := func(body string) {
+		req, _ := http.NewRequest(http.MethodPut, presigned.URL, strings.NewReader(body))
+		for name, value := range presigned.Headers {
+			req.Header.Set(name, value)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("expected the upload to succeed, got %v %v", res, err)
+		}
+		res.Body.Close()
+	}
+	put("hello")
+
+	res, err := http.Get(srv.URL + "/files/" + presigned.Key)
+	if err != nil {
+		t.Fatalf("failed to get the upload: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a pending upload not to be served, got %d", res.StatusCode)
+	}
+
+	key, err := service.PromoteUpload(ctx, presigned.Key)
+	if err != nil {
+		t.Fatalf("PromoteUpload() failed: %v", err)
+	}
+	if key == presigned.Key {
+		t.Fatalf("expected the upload to move away from %s", key)
+	}
+
+	// The URL is still valid, writing to it must not reach the promoted file.
+	put("HELLO")
+
+	body, _, err := storage.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "hello" {
+		t.Fatalf("expected the promoted file to be kept as checked, got %q", data)
+	}
+
+	if _, err := service.PromoteUpload(ctx, key); err == nil {
+		t.Fatalf("expected a promoted file not to be promoted again")
+	}
+}
+
+func TestMatchesContent(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	ogg := []byte("OggS\x00\x02\x00\x00")
+
+	cases := []struct {
+		declared string
+		head     []byte
+		want     bool
+	}{
+		{"image/png", png, true},
+		{"image/jpeg", png, true},
+		{"video/mp4", png, false},
+		{"image/svg+xml", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), false},
+		{"audio/ogg", ogg, true},
+		{"image/png", []byte("<html><script>alert(1)</script>"), false},
+		{"application/zip", png, true},
+	}
+
+	for _, c := range cases {
+		if got := MatchesContent(c.declared, c.head); got != c.want {
+			t.Errorf("MatchesContent(%q) = %v, want %v", c.declared, got, c.want)
+		}
+	}
+}
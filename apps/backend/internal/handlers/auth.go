@@ -68,3 +68,67 @@ func (h *authHandler) Logout(c *gin.Context) {
 	c.SetCookie("token", "", int(time.Now().Add(-30*(24*time.Hour)).Unix()), "/", os.Getenv("DOMAIN"), false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
+
+func (h *authHandler) OAuthAuthorize(c *gin.Context) {
+	url, derr := h.domain.OAuthAuthorize(c, c.Param("provider"))
+	if derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+func (h *authHandler) OAuthCallback(c *gin.Context) {
+	body := types.OAuthCallbackParams{
+		Code:  c.Query("code"),
+		State: c.Query("state"),
+	}
+
+	if verr := validation.Validate(&body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	token, derr := h.domain.OAuthCallback(c, c.Param("provider"), &body)
+	if derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	// A nil token means a provider was linked to the account that is already signed in.
+	if token != nil {
+		c.SetCookie("token", *token, int(time.Now().Add(30*(24*time.Hour)).Unix()), "/", os.Getenv("DOMAIN"), false, true)
+	}
+
+	c.Redirect(http.StatusFound, os.Getenv("APP_URL"))
+}
+
+func (h *authHandler) LinkProvider(c *gin.Context) {
+	url, derr := h.domain.LinkProvider(c, c.Param("provider"))
+	if derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+func (h *authHandler) GetIdentities(c *gin.Context) {
+	identities, derr := h.domain.GetIdentities(c)
+	if derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+func (h *authHandler) UnlinkProvider(c *gin.Context) {
+	if derr := h.domain.UnlinkIdentity(c, c.Param("provider")); derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
@@ -5,7 +5,10 @@ import (
 	"backend/internal/types"
 	"backend/internal/validation"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -53,6 +56,7 @@ func (h *chatHandler) CreateMessage(c *gin.Context) {
 	body.MentionsUsers = c.Request.Form["mentions_users[]"]
 	body.MentionsChannels = c.Request.Form["mentions_channels[]"]
 	body.MentionsRoles = c.Request.Form["mentions_roles[]"]
+	body.Uploads = c.Request.Form["uploads[]"]
 	contentJSON := c.Request.FormValue("content")
 	if err := json.Unmarshal([]byte(contentJSON), &body.Content); err != nil {
 		types.NewAPIError(http.StatusBadRequest, "ERR_UNMARSHAL_MESSAGE_CONTENT", "Failed to unmarshal message content.", err).Respond(c)
@@ -72,6 +76,60 @@ func (h *chatHandler) CreateMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
 
+func (h *chatHandler) ExecuteWebhook(c *gin.Context) {
+	// The route is public, nothing is read from the body before the token is checked.
+	webhook, derr := h.domain.AuthenticateWebhook(c)
+	if derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	var body types.ExecuteWebhookParams
+	var files []*multipart.FileHeader
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, types.MaxWebhookBodySize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := c.Request.ParseMultipartForm(types.MaxWebhookBodySize); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_BODY_TOO_LARGE", "The webhook payload is too large.", err).Respond(c)
+				return
+			}
+			types.NewAPIError(http.StatusBadRequest, "ERR_PARSE_FORM", "Failed to parse form", err).Respond(c)
+			return
+		}
+
+		files = c.Request.MultipartForm.File["attachments[]"]
+		if len(files) > 0 {
+			if err := validation.ValidateFiles(files, validation.DefaultFileConfig); err != nil {
+				err.Respond(c)
+				return
+			}
+		}
+
+		if err := json.Unmarshal([]byte(c.Request.FormValue("payload_json")), &body); err != nil {
+			types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_BODY", "Invalid payload_json field.", err).Respond(c)
+			return
+		}
+
+		if verr := validation.Validate(&body); verr != nil {
+			verr.Respond(c)
+			return
+		}
+	} else if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if derr := h.domain.ExecuteWebhook(c, webhook, files, &body); derr != nil {
+		derr.Respond(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *chatHandler) EditMessage(c *gin.Context) {
 	var body types.EditMessageParams
 
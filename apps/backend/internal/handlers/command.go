@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type commandHandler struct {
+	domain domains.CommandService
+}
+
+func NewCommandHandlers(commandService domains.CommandService) *commandHandler {
+	return &commandHandler{
+		domain: commandService,
+	}
+}
+
+func (h *commandHandler) GetCommands(c *gin.Context) {
+	commands, err := h.domain.GetCommands(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+func (h *commandHandler) RegisterCommands(c *gin.Context) {
+	var body types.RegisterCommandsParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	commands, err := h.domain.RegisterCommands(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+func (h *commandHandler) SetInteractionEndpoint(c *gin.Context) {
+	var body types.InteractionEndpointParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	endpoint, err := h.domain.SetInteractionEndpoint(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+func (h *commandHandler) DeleteInteractionEndpoint(c *gin.Context) {
+	if err := h.domain.DeleteInteractionEndpoint(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *commandHandler) InvokeCommand(c *gin.Context) {
+	var body types.InvokeCommandParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	interactionID, err := h.domain.InvokeCommand(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"interaction_id": interactionID})
+}
+
+func (h *commandHandler) RespondToInteraction(c *gin.Context) {
+	var body types.InteractionResponseParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.RespondToInteraction(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
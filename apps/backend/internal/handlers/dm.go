@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type dmHandler struct {
+	domain domains.DMService
+}
+
+func NewDMHandlers(dmService domains.DMService) *dmHandler {
+	return &dmHandler{
+		domain: dmService,
+	}
+}
+
+func (h *dmHandler) CreateGroupDM(c *gin.Context) {
+	var body types.CreateGroupDMParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	channel, err := h.domain.CreateGroupDM(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *dmHandler) RenameGroupDM(c *gin.Context) {
+	var body types.RenameGroupDMParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	channel, err := h.domain.RenameGroupDM(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *dmHandler) AddUsers(c *gin.Context) {
+	var body types.AddGroupDMUsersParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	channel, err := h.domain.AddUsers(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *dmHandler) RemoveUser(c *gin.Context) {
+	if err := h.domain.RemoveUser(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *dmHandler) LeaveGroupDM(c *gin.Context) {
+	if err := h.domain.LeaveGroupDM(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
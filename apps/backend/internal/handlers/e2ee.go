@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type e2eeHandler struct {
+	domain domains.E2EEService
+}
+
+func NewE2EEHandlers(e2eeService domains.E2EEService) *e2eeHandler {
+	return &e2eeHandler{
+		domain: e2eeService,
+	}
+}
+
+func (h *e2eeHandler) GetDevices(c *gin.Context) {
+	devices, err := h.domain.GetDevices(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *e2eeHandler) RegisterDevice(c *gin.Context) {
+	var body types.RegisterDeviceParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	device, err := h.domain.RegisterDevice(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func (h *e2eeHandler) RevokeDevice(c *gin.Context) {
+	if err := h.domain.RevokeDevice(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *e2eeHandler) GetUserDevices(c *gin.Context) {
+	devices, err := h.domain.GetUserDevices(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *e2eeHandler) GetChannelDevices(c *gin.Context) {
+	devices, err := h.domain.GetChannelDevices(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *e2eeHandler) EnableE2EE(c *gin.Context) {
+	channel, err := h.domain.EnableE2EE(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *e2eeHandler) GetChannelKeys(c *gin.Context) {
+	params := types.GetChannelKeysParams{
+		DeviceID: c.Query("device_id"),
+	}
+
+	if verr := validation.Validate(&params); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	keys, err := h.domain.GetChannelKeys(c, &params)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *e2eeHandler) RotateChannelKey(c *gin.Context) {
+	var body types.RotateChannelKeyParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	key, err := h.domain.RotateChannelKey(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *e2eeHandler) AddKeyEnvelopes(c *gin.Context) {
+	var body types.AddKeyEnvelopesParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.AddKeyEnvelopes(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *e2eeHandler) RequestKeyEnvelopes(c *gin.Context) {
+	var body types.RequestKeyEnvelopesParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.RequestKeyEnvelopes(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
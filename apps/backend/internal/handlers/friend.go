@@ -68,3 +68,59 @@ func (h *friendHandler) RemoveFriend(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
+
+func (h *friendHandler) GetRequests(c *gin.Context) {
+	requests, err := h.domain.GetRequests(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *friendHandler) DeclineRequest(c *gin.Context) {
+	if err := h.domain.DeclineRequest(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *friendHandler) CancelRequest(c *gin.Context) {
+	if err := h.domain.CancelRequest(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *friendHandler) GetBlockedUsers(c *gin.Context) {
+	blocked, err := h.domain.GetBlockedUsers(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, blocked)
+}
+
+func (h *friendHandler) BlockUser(c *gin.Context) {
+	if err := h.domain.BlockUser(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *friendHandler) UnblockUser(c *gin.Context) {
+	if err := h.domain.UnblockUser(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type galleryHandler struct {
+	domain domains.GalleryService
+}
+
+func NewGalleryHandlers(galleryService domains.GalleryService) *galleryHandler {
+	return &galleryHandler{
+		domain: galleryService,
+	}
+}
+
+func (h *galleryHandler) GetMedia(c *gin.Context) {
+	params := types.GetGalleryMediaParams{
+		Before: c.Query("before"),
+		Limit:  types.DefaultGalleryPosts,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+		params.Limit = limit
+	}
+
+	if verr := validation.Validate(&params); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	page, err := h.domain.GetMedia(c, &params)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
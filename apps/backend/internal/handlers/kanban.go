@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type kanbanHandler struct {
+	domain domains.KanbanService
+}
+
+func NewKanbanHandlers(kanbanService domains.KanbanService) *kanbanHandler {
+	return &kanbanHandler{
+		domain: kanbanService,
+	}
+}
+
+func (h *kanbanHandler) GetBoard(c *gin.Context) {
+	board, err := h.domain.GetBoard(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
+
+func (h *kanbanHandler) CreateColumn(c *gin.Context) {
+	var body types.CreateKanbanColumnParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	column, err := h.domain.CreateColumn(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, column)
+}
+
+func (h *kanbanHandler) UpdateColumn(c *gin.Context) {
+	var body types.UpdateKanbanColumnParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	column, err := h.domain.UpdateColumn(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, column)
+}
+
+func (h *kanbanHandler) MoveColumn(c *gin.Context) {
+	var body types.MoveKanbanColumnParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	column, err := h.domain.MoveColumn(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, column)
+}
+
+func (h *kanbanHandler) DeleteColumn(c *gin.Context) {
+	if err := h.domain.DeleteColumn(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *kanbanHandler) CreateCard(c *gin.Context) {
+	var body types.CreateKanbanCardParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	card, err := h.domain.CreateCard(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func (h *kanbanHandler) UpdateCard(c *gin.Context) {
+	var body types.UpdateKanbanCardParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	card, err := h.domain.UpdateCard(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func (h *kanbanHandler) MoveCard(c *gin.Context) {
+	var body types.MoveKanbanCardParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	card, err := h.domain.MoveCard(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func (h *kanbanHandler) DeleteCard(c *gin.Context) {
+	if err := h.domain.DeleteCard(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
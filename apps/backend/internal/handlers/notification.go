@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type notificationHandler struct {
+	domain domains.NotificationService
+}
+
+func NewNotificationHandlers(notificationService domains.NotificationService) *notificationHandler {
+	return &notificationHandler{
+		domain: notificationService,
+	}
+}
+
+func (h *notificationHandler) GetNotifications(c *gin.Context) {
+	params := types.GetNotificationsParams{
+		Before:   c.Query("before"),
+		ServerID: c.Query("server_id"),
+		Unread:   c.Query("unread") == "true",
+		Limit:    types.DefaultNotifications,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+		params.Limit = limit
+	}
+
+	if verr := validation.Validate(&params); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	page, err := h.domain.GetNotifications(c, &params)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *notificationHandler) ReadNotifications(c *gin.Context) {
+	var body types.ReadNotificationsParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.ReadNotifications(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *notificationHandler) ReadAllNotifications(c *gin.Context) {
+	var body types.ReadAllNotificationsParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.ReadAllNotifications(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *notificationHandler) SendInvite(c *gin.Context) {
+	var body types.SendInviteParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.SendInvite(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
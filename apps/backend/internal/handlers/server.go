@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -112,6 +113,31 @@ func (h *serverHandler) SearchMembers(c *gin.Context) {
 	c.JSON(http.StatusOK, members)
 }
 
+func (h *serverHandler) DiscoverServers(c *gin.Context) {
+	params := types.DiscoverServersParams{
+		Query:    strings.TrimSpace(c.Query("query")),
+		Category: c.Query("category"),
+		Tag:      strings.ToLower(c.Query("tag")),
+		Sort:     c.DefaultQuery("sort", "members"),
+	}
+	if offset, err := strconv.Atoi(c.DefaultQuery("offset", "0")); err == nil {
+		params.Offset = offset
+	}
+
+	if verr := validation.Validate(&params); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	servers, err := h.domain.DiscoverServers(c, &params)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, servers)
+}
+
 func (h *serverHandler) JoinServer(c *gin.Context) {
 	var body types.JoinServerParams
 
@@ -140,7 +166,16 @@ func (h *serverHandler) LeaveServer(c *gin.Context) {
 }
 
 func (h *serverHandler) CreateInvite(c *gin.Context) {
-	invite, err := h.domain.CreateInvite(c)
+	// The body is optional, invites default to unlimited uses for 7 days.
+	body := types.CreateInviteParams{ExpireIn: "7d"}
+	if c.Request.ContentLength != 0 {
+		if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+			verr.Respond(c)
+			return
+		}
+	}
+
+	invite, err := h.domain.CreateInvite(c, &body)
 	if err != nil {
 		err.Respond(c)
 		return
@@ -149,6 +184,43 @@ func (h *serverHandler) CreateInvite(c *gin.Context) {
 	c.JSON(http.StatusOK, invite)
 }
 
+func (h *serverHandler) GetInvites(c *gin.Context) {
+	invites, err := h.domain.GetInvites(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+func (h *serverHandler) SetVanityInvite(c *gin.Context) {
+	var body types.SetVanityInviteParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	invite, err := h.domain.SetVanityInvite(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+func (h *serverHandler) GetInvitePreview(c *gin.Context) {
+	preview, err := h.domain.GetInvitePreview(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
 func (h *serverHandler) DeleteInvite(c *gin.Context) {
 	err := h.domain.DeleteInvite(c)
 	if err != nil {
@@ -253,6 +325,15 @@ func (h *serverHandler) BanUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
 
+func (h *serverHandler) AddBot(c *gin.Context) {
+	if err := h.domain.AddBot(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
 func (h *serverHandler) UnbanUser(c *gin.Context) {
 	err := h.domain.UnbanUser(c)
 	if err != nil {
@@ -278,3 +359,83 @@ func (h *serverHandler) KickUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
+
+func (h *serverHandler) GetStorageUsage(c *gin.Context) {
+	usage, err := h.domain.GetStorageUsage(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func (h *serverHandler) GetEmojis(c *gin.Context) {
+	emojis, err := h.domain.GetEmojis(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, emojis)
+}
+
+func (h *serverHandler) UploadEmojis(c *gin.Context) {
+	var body types.UploadServerEmojiParams
+
+	maxFormSize := int64(1 << 20)
+	if err := c.Request.ParseMultipartForm(maxFormSize); err != nil {
+		types.NewAPIError(http.StatusBadRequest, "ERR_PARSE_FORM", "Failed to parse form", err).Respond(c)
+		return
+	}
+
+	emojis := c.Request.MultipartForm.File["emojis[]"]
+	if err := validation.ValidateFiles(emojis, validation.FileValidationConfig{
+		MaxSize:  1 * 1024 * 1024,
+		MaxFiles: 10,
+	}); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	body.Kind = types.ServerEmojiKind(c.Request.FormValue("kind"))
+	body.Shortcodes = c.Request.Form["shortcodes[]"]
+	if verr := validation.Validate(&body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	created, err := h.domain.UploadEmojis(c, emojis, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, created)
+}
+
+func (h *serverHandler) UpdateEmoji(c *gin.Context) {
+	var body types.UpdateEmojiParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	emoji, err := h.domain.UpdateEmoji(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, emoji)
+}
+
+func (h *serverHandler) DeleteEmoji(c *gin.Context) {
+	if err := h.domain.DeleteEmoji(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
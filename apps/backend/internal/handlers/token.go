@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tokenHandler struct {
+	domain domains.TokenService
+}
+
+func NewTokenHandlers(tokenService domains.TokenService) *tokenHandler {
+	return &tokenHandler{
+		domain: tokenService,
+	}
+}
+
+func (h *tokenHandler) CreateToken(c *gin.Context) {
+	var body types.CreateTokenParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	token, err := h.domain.CreateToken(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+func (h *tokenHandler) GetTokens(c *gin.Context) {
+	tokens, err := h.domain.GetTokens(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *tokenHandler) RevokeToken(c *gin.Context) {
+	if err := h.domain.RevokeToken(c, c.Param("token_id")); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *tokenHandler) CreateBot(c *gin.Context) {
+	var body types.CreateBotParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	bot, err := h.domain.CreateBot(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, bot)
+}
+
+func (h *tokenHandler) GetBots(c *gin.Context) {
+	bots, err := h.domain.GetBots(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, bots)
+}
+
+func (h *tokenHandler) DeleteBot(c *gin.Context) {
+	if err := h.domain.DeleteBot(c, c.Param("bot_id")); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
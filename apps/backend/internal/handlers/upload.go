@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type uploadHandler struct {
+	domain domains.UploadService
+}
+
+func NewUploadHandlers(uploadService domains.UploadService) *uploadHandler {
+	return &uploadHandler{
+		domain: uploadService,
+	}
+}
+
+func (h *uploadHandler) CreateUpload(c *gin.Context) {
+	var body types.CreateUploadParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	upload, err := h.domain.CreateUpload(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, upload)
+}
+
+func (h *uploadHandler) ConfirmUpload(c *gin.Context) {
+	file, err := h.domain.ConfirmUpload(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
@@ -60,6 +60,22 @@ func (h *userHandler) UpdateEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
 
+func (h *userHandler) UpdatePrivacy(c *gin.Context) {
+	var body types.UpdatePrivacyParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	if err := h.domain.UpdatePrivacy(c, &body); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
 func (h *userHandler) UpdatePassword(c *gin.Context) {
 	var body types.UpdatePasswordParams
 
@@ -246,3 +262,13 @@ func (h *userHandler) Sync(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "ok"})
 }
+
+func (h *userHandler) GetStorageUsage(c *gin.Context) {
+	usage, err := h.domain.GetStorageUsage(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type voiceHandler struct {
+	domain domains.VoiceService
+}
+
+func NewVoiceHandlers(voiceService domains.VoiceService) *voiceHandler {
+	return &voiceHandler{
+		domain: voiceService,
+	}
+}
+
+func (h *voiceHandler) GetVoiceStates(c *gin.Context) {
+	states, err := h.domain.GetVoiceStates(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, states)
+}
+
+func (h *voiceHandler) SFUWebhook(c *gin.Context) {
+	if err := h.domain.HandleSFUWebhook(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
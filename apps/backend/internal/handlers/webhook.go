@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"backend/internal/domains"
+	"backend/internal/types"
+	"backend/internal/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookHandler struct {
+	domain domains.WebhookService
+}
+
+func NewWebhookHandlers(webhookService domains.WebhookService) *webhookHandler {
+	return &webhookHandler{
+		domain: webhookService,
+	}
+}
+
+func (h *webhookHandler) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.domain.GetWebhooks(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *webhookHandler) CreateWebhook(c *gin.Context) {
+	var body types.CreateWebhookParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	webhook, err := h.domain.CreateWebhook(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *webhookHandler) EditWebhook(c *gin.Context) {
+	var body types.EditWebhookParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	webhook, err := h.domain.EditWebhook(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *webhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.domain.DeleteWebhook(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+func (h *webhookHandler) GetDeliveries(c *gin.Context) {
+	deliveries, err := h.domain.GetDeliveries(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *webhookHandler) GetIncomingWebhooks(c *gin.Context) {
+	webhooks, err := h.domain.GetIncomingWebhooks(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *webhookHandler) CreateIncomingWebhook(c *gin.Context) {
+	var body types.CreateIncomingWebhookParams
+
+	if verr := validation.ParseAndValidate(c.Request, &body); verr != nil {
+		verr.Respond(c)
+		return
+	}
+
+	webhook, err := h.domain.CreateIncomingWebhook(c, &body)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (h *webhookHandler) RotateIncomingWebhookToken(c *gin.Context) {
+	url, err := h.domain.RotateIncomingWebhookToken(c)
+	if err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, url)
+}
+
+func (h *webhookHandler) DeleteIncomingWebhook(c *gin.Context) {
+	if err := h.domain.DeleteIncomingWebhook(c); err != nil {
+		err.Respond(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
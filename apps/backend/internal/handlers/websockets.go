@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	db "backend/db/gen_queries"
 	"backend/internal/actors"
+	"backend/internal/domains"
+	"backend/internal/types"
+	messages "backend/proto"
+	"context"
 	"log/slog"
 	"net/http"
 	"sync"
@@ -10,6 +15,7 @@ import (
 	"github.com/anthdm/hollywood/actor"
 	"github.com/gin-gonic/gin"
 	"github.com/lxzan/gws"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -25,11 +31,13 @@ var (
 
 type WSHandler struct {
 	actorService actors.Service
+	voice        domains.VoiceService
 }
 
-func NewWSHandlers(actorService actors.Service) *WSHandler {
+func NewWSHandlers(actorService actors.Service, voiceService domains.VoiceService) *WSHandler {
 	handler := &WSHandler{
 		actorService: actorService,
+		voice:        voiceService,
 	}
 
 	Upgrader = gws.NewUpgrader(handler, &gws.ServerOption{
@@ -70,11 +78,57 @@ func (ws *WSHandler) OnMessage(socket *gws.Conn, message *gws.Message) {
 		ws.OnPing(socket, nil)
 		return
 	}
+
+	if message.Opcode == gws.OpcodeBinary {
+		ws.onVoiceRequest(socket, message.Data.Bytes())
+	}
+}
+
+// onVoiceRequest handles the voice requests of a socket, failures are written back to it.
+func (ws *WSHandler) onVoiceRequest(socket *gws.Conn, data []byte) {
+	mapMutex.RLock()
+	userPID, exists := usersMap[socket]
+	mapMutex.RUnlock()
+	if !exists {
+		return
+	}
+
+	var request messages.VoiceRequest
+	var apiErr *types.APIError
+	if err := proto.Unmarshal(data, &request); err != nil {
+		apiErr = types.NewAPIError(http.StatusBadRequest, "ERR_INVALID_VOICE_REQUEST", "Failed to decode the voice request.", err)
+	} else {
+		apiErr = ws.voice.HandleRequest(context.Background(), actors.GetIDFromPID(userPID), &request)
+	}
+	if apiErr == nil {
+		return
+	}
+
+	response, err := proto.Marshal(&messages.WSMessage{
+		Content: &messages.WSMessage_VoiceError{
+			VoiceError: &messages.VoiceError{
+				ChannelId: request.ChannelId,
+				Code:      apiErr.Code,
+				Message:   apiErr.Message,
+			},
+		},
+	})
+	if err != nil {
+		slog.Error("failed to encode voice error", "err", err)
+		return
+	}
+	_ = socket.WriteMessage(gws.OpcodeBinary, response)
 }
 
 func (ws *WSHandler) Setup(c *gin.Context) {
 	userID := c.Param("user_id")
 
+	u, exists := c.Get("user")
+	if !exists || u.(*db.User).ID != userID {
+		types.NewAPIError(http.StatusForbidden, "ERR_FORBIDDEN", "You can't open a socket for another user.", nil).Respond(c)
+		return
+	}
+
 	socket, err := Upgrader.Upgrade(c.Writer, c.Request)
 	if err != nil {
 		slog.Error("failed upgrading connection", "err", err)
@@ -0,0 +1,158 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// maxPosterWidth bounds the width of the extracted frames, posters are previews.
+const maxPosterWidth = 1280
+
+var ErrNoFrame = errors.New("no frame could be extracted")
+
+// Available reports whether ffprobe and ffmpeg are installed, media is left unprocessed otherwise.
+var Available = sync.OnceValue(func() bool {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+})
+
+type Info struct {
+	// Duration is in seconds.
+	Duration float64
+	// Width and Height are the displayed dimensions, rotation included.
+	Width      int
+	Height     int
+	VideoCodec string
+	AudioCodec string
+	// HasPicture is set for videos and for audio files with a cover art.
+	HasPicture bool
+}
+
+// Probe reads the container and streams of a media file with ffprobe.
+func Probe(ctx context.Context, path string) (*Info, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return parseProbe(out)
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		CodecName   string `json:"codec_name"`
+		Width       int    `json:"width"`
+		Height      int    `json:"height"`
+		Duration    string `json:"duration"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		Tags struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+func parseProbe(data []byte) (*Info, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	info := &Info{}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if info.HasPicture {
+				continue
+			}
+			info.HasPicture = true
+			info.Width, info.Height = stream.Width, stream.Height
+
+			// Cover arts are still pictures, they don't say anything about the codec.
+			if stream.Disposition.AttachedPic == 0 {
+				info.VideoCodec = stream.CodecName
+			}
+
+			rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+			for _, side := range stream.SideDataList {
+				if side.Rotation != 0 {
+					rotation = side.Rotation
+				}
+			}
+			if int(math.Abs(rotation))%180 == 90 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = stream.CodecName
+			}
+		}
+
+		if info.Duration == 0 {
+			info.Duration, _ = strconv.ParseFloat(stream.Duration, 64)
+		}
+	}
+
+	if info.VideoCodec == "" && info.AudioCodec == "" && !info.HasPicture {
+		return nil, errors.New("ffprobe: no audio or video stream")
+	}
+
+	return info, nil
+}
+
+// Frame extracts the frame shown at the given second as a PNG, downscaled to maxPosterWidth.
+// Videos shorter than that give their first frame.
+func Frame(ctx context.Context, path string, at float64) ([]byte, error) {
+	frame, err := extractFrame(ctx, path, at)
+	if errors.Is(err, ErrNoFrame) && at > 0 {
+		return extractFrame(ctx, path, 0)
+	}
+
+	return frame, err
+}
+
+func extractFrame(ctx context.Context, path string, at float64) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", path,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", maxPosterWidth),
+		"-f", "image2pipe", "-vcodec", "png", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if len(out) == 0 {
+		return nil, ErrNoFrame
+	}
+
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseProbe(t *testing.T) {
+	video := `{
+		"streams": [
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "side_data_list": [{"rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac"}
+		],
+		"format": {"duration": "12.480000"}
+	}`
+
+	info, err := parseProbe([]byte(video))
+	if err != nil {
+		t.Fatalf("parseProbe() failed: %v", err)
+	}
+	if info.Duration != 12.48 || info.Width != 1080 || info.Height != 1920 || info.VideoCodec != "h264" || info.AudioCodec != "aac" || !info.HasPicture {
+		t.Fatalf("unexpected video info %+v", info)
+	}
+
+	song := `{
+		"streams": [
+			{"codec_type": "audio", "codec_name": "mp3", "duration": "201.5"},
+			{"codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "disposition": {"attached_pic": 1}}
+		],
+		"format": {}
+	}`
+
+	info, err = parseProbe([]byte(song))
+	if err != nil {
+		t.Fatalf("parseProbe() failed: %v", err)
+	}
+	if info.Duration != 201.5 || info.VideoCodec != "" || info.AudioCodec != "mp3" || !info.HasPicture || info.Width != 600 {
+		t.Fatalf("unexpected audio info %+v", info)
+	}
+
+	if _, err := parseProbe([]byte(`{"streams": [{"codec_type": "data"}], "format": {}}`)); err == nil {
+		t.Fatal("expected files without audio or video to be rejected")
+	}
+}
+
+func TestBlurhash(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := range 100 {
+		for x := range 200 {
+			white.Set(x, y, color.White)
+		}
+	}
+
+	// The size flag comes first, then the quantised maximum and the average color.
+	if got := Blurhash(white, 4, 3); len(got) != 28 || got[0] != 'L' || got[2:6] != "TSUA" {
+		t.Fatalf("unexpected hash %q for a white image", got)
+	}
+	if got := Blurhash(white, 1, 1); got != "00TSUA" {
+		t.Fatalf("Blurhash() = %q, want %q", got, "00TSUA")
+	}
+
+	gradient := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := range 200 {
+		for x := range 300 {
+			gradient.Set(x, y, color.RGBA{R: uint8(x * 255 / 300), G: 80, B: uint8(y * 255 / 200), A: 255})
+		}
+	}
+
+	hash := Blurhash(gradient, 4, 3)
+	if len(hash) != 4+2*4*3 || hash[1] == '0' {
+		t.Fatalf("unexpected hash %q for a gradient", hash)
+	}
+}
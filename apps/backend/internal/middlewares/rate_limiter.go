@@ -11,6 +11,8 @@ import (
 type LimiterConfig struct {
 	MaxRequests uint
 	Window      time.Duration
+	// KeyFunc picks the bucket of a request, the client IP when nil.
+	KeyFunc func(c *gin.Context) string
 }
 
 type ipLimiter struct {
@@ -23,20 +25,29 @@ type bucket struct {
 	exp   time.Time
 }
 
-func RateLimiter(cfg LimiterConfig) gin.HandlerFunc {
+func newLimiter(window time.Duration) *ipLimiter {
 	l := &ipLimiter{
 		buckets: make(map[string]*bucket),
 	}
 
 	go func() {
-		t := time.NewTicker(cfg.Window)
+		t := time.NewTicker(window)
 		for range t.C {
 			l.gc()
 		}
 	}()
 
+	return l
+}
+
+func RateLimiter(cfg LimiterConfig) gin.HandlerFunc {
+	l := newLimiter(cfg.Window)
+
 	return func(c *gin.Context) {
 		key := c.RemoteIP()
+		if cfg.KeyFunc != nil {
+			key = cfg.KeyFunc(c)
+		}
 		allowed := l.hit(key, cfg.MaxRequests, cfg.Window)
 
 		if !allowed {
@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"backend/internal/broker"
+	"backend/internal/crypto"
+	"backend/internal/database"
+	"backend/internal/types"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenRateWindow = time.Minute
+
+// TokenAuth accepts either the session cookie or a personal access token sent as
+// "Authorization: Bearer <token>". Token requests are rate limited per token and
+// only reach routes guarded by a Scope the token was granted.
+func TokenAuth(broker broker.Service, db database.Service) gin.HandlerFunc {
+	cookieAuth := Auth(broker)
+	limiter := newLimiter(tokenRateWindow)
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			cookieAuth(c)
+			return
+		}
+
+		raw, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || raw == "" {
+			types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_TOKEN", "The auth token is invalid.", nil).Respond(c)
+			return
+		}
+
+		hash := crypto.HashToken(raw)
+		token, err := broker.GetCachedAccessToken(c, hash)
+		if err != nil {
+			dbToken, err := db.GetAccessTokenByHash(c, hash)
+			if err != nil {
+				types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_TOKEN", "The auth token is invalid.", err).Respond(c)
+				return
+			}
+
+			user, err := db.GetUserByID(c, dbToken.UserID)
+			if err != nil {
+				types.NewAPIError(http.StatusUnauthorized, "ERR_INVALID_TOKEN", "The auth token is invalid.", err).Respond(c)
+				return
+			}
+
+			token = &types.CachedAccessToken{
+				ID:        dbToken.ID,
+				Scopes:    dbToken.Scopes,
+				RateLimit: dbToken.RateLimit,
+				User:      user,
+			}
+			if dbToken.ExpiresAt.Valid {
+				token.ExpiresAt = &dbToken.ExpiresAt.Time
+			}
+
+			broker.CacheAccessToken(c, hash, *token)
+			db.TouchAccessToken(c, dbToken.ID)
+		}
+
+		if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+			types.NewAPIError(http.StatusUnauthorized, "ERR_TOKEN_EXPIRED", "The auth token expired.", nil).Respond(c)
+			return
+		}
+
+		if !limiter.hit(token.ID, uint(token.RateLimit), tokenRateWindow) {
+			c.Header("Retry-After", strconv.Itoa(int(tokenRateWindow.Seconds())))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "rate limit exceeded",
+				"limit":       token.RateLimit,
+				"time_window": tokenRateWindow.String(),
+			})
+			return
+		}
+
+		c.Set("user", &token.User)
+		c.Set("token_scopes", token.Scopes)
+
+		c.Next()
+	}
+}
+
+// Scope guards a route reachable with personal access tokens. Cookie sessions
+// are always allowed through.
+func Scope(scope types.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get("token_scopes")
+		if isToken && !slices.Contains(scopes.([]types.Scope), scope) {
+			types.NewAPIError(http.StatusForbidden, "ERR_MISSING_SCOPE", "The auth token is missing the "+scope+" scope.", nil).Respond(c)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,181 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// what the token endpoint puts in the next id token
+	nonce     string
+	audience  string
+	challenge string
+}
+
+func startMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	m := &mockProvider{key: key, audience: "kyob"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || Challenge(r.Form.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"id_token": m.sign(t, map[string]any{
+				"iss":                m.server.URL,
+				"sub":                "user-123",
+				"aud":                m.audience,
+				"exp":                time.Now().Add(time.Minute).Unix(),
+				"iat":                time.Now().Unix(),
+				"nonce":              m.nonce,
+				"email":              "jane@example.com",
+				"email_verified":     true,
+				"preferred_username": "jane",
+			}),
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) sign(t *testing.T, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestService(m *mockProvider) Service {
+	return NewWithProviders(m.server.Client(), ProviderConfig{
+		Name:         "mock",
+		Issuer:       m.server.URL,
+		ClientID:     "kyob",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/api/oauth/mock/callback",
+	})
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := startMockProvider(t)
+	srv := newTestService(m)
+
+	verifier, _ := GenerateVerifier()
+	authURL, err := srv.AuthCodeURL(context.Background(), "mock", "state", "nonce", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(authURL, m.server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization url %s", authURL)
+	}
+
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if query.Get("code_challenge") != Challenge(verifier) || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("expected a S256 pkce challenge, got %v", query)
+	}
+	if query.Get("state") != "state" || query.Get("nonce") != "nonce" {
+		t.Fatalf("expected state and nonce to be forwarded, got %v", query)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	m := startMockProvider(t)
+	srv := newTestService(m)
+
+	verifier, _ := GenerateVerifier()
+	m.challenge = Challenge(verifier)
+	m.nonce = "nonce"
+
+	claims, err := srv.Exchange(context.Background(), "mock", "good-code", verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || claims.PreferredUsername != "jane" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejectsInvalidTokens(t *testing.T) {
+	m := startMockProvider(t)
+	srv := newTestService(m)
+
+	verifier, _ := GenerateVerifier()
+	m.challenge = Challenge(verifier)
+
+	m.nonce = "replayed"
+	if _, err := srv.Exchange(context.Background(), "mock", "good-code", verifier, "nonce"); err == nil {
+		t.Fatal("expected a nonce mismatch to be rejected")
+	}
+
+	m.nonce = "nonce"
+	m.audience = "someone-else"
+	if _, err := srv.Exchange(context.Background(), "mock", "good-code", verifier, "nonce"); err == nil {
+		t.Fatal("expected an audience mismatch to be rejected")
+	}
+
+	m.audience = "kyob"
+	if _, err := srv.Exchange(context.Background(), "mock", "good-code", "wrong-verifier", "nonce"); err == nil {
+		t.Fatal("expected a wrong pkce verifier to be rejected")
+	}
+
+	if _, err := srv.Exchange(context.Background(), "unknown", "good-code", verifier, "nonce"); err != ErrUnknownProvider {
+		t.Fatalf("expected ErrUnknownProvider, got %v", err)
+	}
+}
@@ -15,6 +15,8 @@ import (
 
 type Service interface {
 	CheckPermission(ctx *gin.Context, serverID string, ability types.Ability, ids ...string) bool
+	// HasAbility is CheckPermission outside of a request, for messages received over the socket.
+	HasAbility(ctx context.Context, serverID, userID string, ability types.Ability) bool
 }
 
 type service struct {
@@ -48,6 +50,10 @@ func (s *service) CheckPermission(ctx *gin.Context, serverID string, ability typ
 		}
 	}
 
+	return s.HasAbility(ctx, serverID, userID, ability)
+}
+
+func (s *service) HasAbility(ctx context.Context, serverID, userID string, ability types.Ability) bool {
 	abilities := s.getAbilities(ctx, serverID, userID)
 	return slices.Contains(abilities, string(ability)) || slices.Contains(abilities, "OWNER") || slices.Contains(abilities, "ADMINISTRATOR")
 }
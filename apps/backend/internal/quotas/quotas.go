@@ -0,0 +1,140 @@
+package quotas
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/database"
+	"backend/internal/types"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Service enforces the storage quotas. Usage is the sum of the tracked attachments, plus the direct
+// uploads of a user which aren't attached yet.
+type Service interface {
+	// CheckFileSize fails when a file is larger than the tier of the user allows.
+	CheckFileSize(user *db.User, sizes ...int64) *types.APIError
+	// CheckUser fails when the files don't fit in what is left of the user's storage.
+	CheckUser(ctx context.Context, user *db.User, sizes ...int64) *types.APIError
+	// CheckServer fails when the files don't fit in what is left of the server's storage.
+	CheckServer(ctx context.Context, serverID string, sizes ...int64) *types.APIError
+	UserUsage(ctx context.Context, user *db.User) (*types.StorageUsage, *types.APIError)
+	ServerUsage(ctx context.Context, serverID string) (*types.StorageUsage, *types.APIError)
+}
+
+type service struct {
+	db    database.Service
+	tiers map[string]Tier
+}
+
+func New(db database.Service) (Service, error) {
+	tiers, err := TiersFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &service{
+		db:    db,
+		tiers: tiers,
+	}, nil
+}
+
+// tier returns the limits of a tier, unknown tiers get the default ones.
+func (s *service) tier(name string) Tier {
+	if tier, ok := s.tiers[name]; ok {
+		return tier
+	}
+
+	return s.tiers[DefaultTier]
+}
+
+func (s *service) CheckFileSize(user *db.User, sizes ...int64) *types.APIError {
+	maxSize := s.tier(user.StorageTier).MaxFileSize
+	for _, size := range sizes {
+		if size > maxSize {
+			return types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_FILE_TOO_LARGE", fmt.Sprintf("Files can't be larger than %s.", humanSize(maxSize)), nil)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) CheckUser(ctx context.Context, user *db.User, sizes ...int64) *types.APIError {
+	usage, err := s.UserUsage(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if usage.Used+sum(sizes) > usage.Limit {
+		return types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_QUOTA_EXCEEDED", fmt.Sprintf("Your storage quota of %s is exceeded.", humanSize(usage.Limit)), nil)
+	}
+
+	return nil
+}
+
+func (s *service) CheckServer(ctx context.Context, serverID string, sizes ...int64) *types.APIError {
+	// Direct messages only count against their authors.
+	if serverID == "" || serverID == "global" {
+		return nil
+	}
+
+	usage, err := s.ServerUsage(ctx, serverID)
+	if err != nil {
+		return err
+	}
+
+	if usage.Used+sum(sizes) > usage.Limit {
+		return types.NewAPIError(http.StatusRequestEntityTooLarge, "ERR_QUOTA_EXCEEDED", fmt.Sprintf("The storage quota of this server (%s) is exceeded.", humanSize(usage.Limit)), nil)
+	}
+
+	return nil
+}
+
+func (s *service) UserUsage(ctx context.Context, user *db.User) (*types.StorageUsage, *types.APIError) {
+	used, err := s.db.GetUserStorageUsage(ctx, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_STORAGE_USAGE", "Failed to get the storage usage.", err)
+	}
+
+	tier := s.tier(user.StorageTier)
+
+	return &types.StorageUsage{
+		Tier:        user.StorageTier,
+		Used:        used,
+		Limit:       tier.UserStorage,
+		MaxFileSize: tier.MaxFileSize,
+	}, nil
+}
+
+func (s *service) ServerUsage(ctx context.Context, serverID string) (*types.StorageUsage, *types.APIError) {
+	usage, err := s.db.GetServerStorageUsage(ctx, serverID)
+	if err != nil {
+		return nil, types.NewAPIError(http.StatusInternalServerError, "ERR_GET_STORAGE_USAGE", "Failed to get the storage usage.", err)
+	}
+
+	return &types.StorageUsage{
+		Tier:  usage.StorageTier,
+		Used:  usage.Used,
+		Limit: s.tier(usage.StorageTier).ServerStorage,
+	}, nil
+}
+
+func sum(sizes []int64) int64 {
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+
+	return total
+}
+
+func humanSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	default:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+}
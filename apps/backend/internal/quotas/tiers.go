@@ -0,0 +1,61 @@
+package quotas
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const DefaultTier = "free"
+
+// Tier bounds what users and servers store, sizes are in bytes.
+type Tier struct {
+	UserStorage   int64
+	ServerStorage int64
+	// MaxFileSize applies to each file uploaded by a user of the tier.
+	MaxFileSize int64
+}
+
+var defaultTiers = map[string]Tier{
+	"free": {
+		UserStorage:   2 << 30,   // 2gb
+		ServerStorage: 10 << 30,  // 10gb
+		MaxFileSize:   100 << 20, // 100mb
+	},
+	"premium": {
+		UserStorage:   50 << 30,  // 50gb
+		ServerStorage: 100 << 30, // 100gb
+		MaxFileSize:   1 << 30,   // 1gb
+	},
+}
+
+// TiersFromEnv returns the default tiers, each limit can be overridden with
+// QUOTA_<TIER>_USER_STORAGE, QUOTA_<TIER>_SERVER_STORAGE and QUOTA_<TIER>_MAX_FILE_SIZE.
+func TiersFromEnv() (map[string]Tier, error) {
+	tiers := make(map[string]Tier, len(defaultTiers))
+
+	for name, tier := range defaultTiers {
+		prefix := "QUOTA_" + strings.ToUpper(name) + "_"
+		for suffix, limit := range map[string]*int64{
+			"USER_STORAGE":   &tier.UserStorage,
+			"SERVER_STORAGE": &tier.ServerStorage,
+			"MAX_FILE_SIZE":  &tier.MaxFileSize,
+		} {
+			v := os.Getenv(prefix + suffix)
+			if v == "" {
+				continue
+			}
+
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid %s%s: %q", prefix, suffix, v)
+			}
+			*limit = size
+		}
+
+		tiers[name] = tier
+	}
+
+	return tiers, nil
+}
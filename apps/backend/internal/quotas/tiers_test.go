@@ -0,0 +1,27 @@
+package quotas
+
+import "testing"
+
+func TestTiersFromEnv(t *testing.T) {
+	t.Setenv("QUOTA_FREE_MAX_FILE_SIZE", "1024")
+
+	tiers, err := TiersFromEnv()
+	if err != nil {
+		t.Fatalf("TiersFromEnv() failed: %v", err)
+	}
+
+	if got := tiers["free"].MaxFileSize; got != 1024 {
+		t.Fatalf("expected the free max file size to be overridden, got %d", got)
+	}
+	if got := tiers["free"].UserStorage; got != defaultTiers["free"].UserStorage {
+		t.Fatalf("expected the free user storage to keep its default, got %d", got)
+	}
+	if got := tiers["premium"].MaxFileSize; got != defaultTiers["premium"].MaxFileSize {
+		t.Fatalf("expected the premium tier to be untouched, got %d", got)
+	}
+
+	t.Setenv("QUOTA_PREMIUM_USER_STORAGE", "lots")
+	if _, err := TiersFromEnv(); err == nil {
+		t.Fatal("expected invalid limits to be rejected")
+	}
+}
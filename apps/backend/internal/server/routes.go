@@ -3,6 +3,7 @@ package server
 import (
 	"backend/internal/handlers"
 	"backend/internal/middlewares"
+	"backend/internal/types"
 	"net/http"
 	"time"
 
@@ -16,7 +17,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "Content-Disposition"},
 		AllowCredentials: true,
 	}))
 
@@ -29,22 +30,49 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.GET("/health", s.healthHandler)
 
+	// Local storage drivers have nobody else to serve their files or receive presigned uploads.
+	if handler := s.files.Handler(); handler != nil {
+		files := gin.WrapH(http.StripPrefix("/files", handler))
+		r.GET("/files/*key", files)
+		r.HEAD("/files/*key", files)
+		r.PUT("/files/*key", files)
+	}
+
 	api := r.Group("/api")
 	protected := api.Group("/protected")
 	protected.Use(middlewares.Auth(s.broker))
 
+	// Same prefix, but also reachable with personal access tokens. Every route here needs a scope.
+	scoped := api.Group("/protected")
+	scoped.Use(middlewares.TokenAuth(s.broker, s.db))
+
 	auth := handlers.NewAuthHandlers(s.authSvc)
 	api.POST("/signin", auth.SignIn)
 	api.POST("/signup", auth.SignUp)
 	protected.POST("/logout", auth.Logout)
-
-	ws := handlers.NewWSHandlers(s.actors)
-	protected.GET("/ws/:user_id", ws.Setup)
+	api.GET("/oauth/:provider", auth.OAuthAuthorize)
+	api.GET("/oauth/:provider/callback", auth.OAuthCallback)
+	protected.GET("/oauth/identities", auth.GetIdentities)
+	protected.POST("/oauth/:provider/link", auth.LinkProvider)
+	protected.DELETE("/oauth/:provider", auth.UnlinkProvider)
+
+	token := handlers.NewTokenHandlers(s.tokenSvc)
+	protected.GET("/tokens", token.GetTokens)
+	protected.POST("/tokens", token.CreateToken)
+	protected.DELETE("/tokens/:token_id", token.RevokeToken)
+	protected.GET("/bots", token.GetBots)
+	protected.POST("/bots", token.CreateBot)
+	protected.DELETE("/bots/:bot_id", token.DeleteBot)
+
+	ws := handlers.NewWSHandlers(s.actors, s.voiceSvc)
+	scoped.GET("/ws/:user_id", middlewares.Scope(types.ScopeGateway), ws.Setup)
 
 	user := handlers.NewUserHandlers(s.userSvc)
-	protected.GET("/users/:user_id", user.GetUserProfile)
+	scoped.GET("/users/:user_id", middlewares.Scope(types.ScopeUsersRead), user.GetUserProfile)
 	protected.GET("/users/setup", user.Setup)
+	protected.GET("/users/storage", user.GetStorageUsage)
 	protected.PATCH("/users/email", user.UpdateEmail)
+	protected.PATCH("/users/privacy", user.UpdatePrivacy)
 	protected.PATCH("/users/password", user.UpdatePassword)
 	protected.PATCH("/users/profile", user.UpdateProfile)
 	protected.PATCH("/users/avatar", user.UpdateAvatar)
@@ -54,20 +82,61 @@ func (s *Server) RegisterRoutes() http.Handler {
 	protected.DELETE("/users", user.DeleteAccount)
 	protected.POST("/users/sync", user.Sync)
 
+	e2ee := handlers.NewE2EEHandlers(s.e2eeSvc)
+	protected.GET("/users/devices", e2ee.GetDevices)
+	protected.POST("/users/devices", e2ee.RegisterDevice)
+	protected.DELETE("/users/devices/:device_id", e2ee.RevokeDevice)
+	protected.GET("/users/:user_id/devices", e2ee.GetUserDevices)
+	protected.GET("/channels/:channel_id/devices", e2ee.GetChannelDevices)
+	protected.POST("/channels/:channel_id/e2ee", e2ee.EnableE2EE)
+	protected.GET("/channels/:channel_id/keys", e2ee.GetChannelKeys)
+	protected.POST("/channels/:channel_id/keys", e2ee.RotateChannelKey)
+	protected.POST("/channels/:channel_id/keys/request", e2ee.RequestKeyEnvelopes)
+	protected.POST("/channels/:channel_id/keys/:key_id/envelopes", e2ee.AddKeyEnvelopes)
+
 	friend := handlers.NewFriendHandlers(s.friendSvc)
 	protected.POST("/friends", friend.SendRequest)
 	protected.PATCH("/friends", friend.AcceptRequest)
 	protected.DELETE("/friends", friend.RemoveFriend)
+	protected.GET("/friends/requests", friend.GetRequests)
+	protected.POST("/friends/requests/:friendship_id/decline", friend.DeclineRequest)
+	protected.DELETE("/friends/requests/:friendship_id", friend.CancelRequest)
+	protected.GET("/friends/blocks", friend.GetBlockedUsers)
+	protected.PUT("/friends/blocks/:user_id", friend.BlockUser)
+	protected.DELETE("/friends/blocks/:user_id", friend.UnblockUser)
+
+	dm := handlers.NewDMHandlers(s.dmSvc)
+	protected.POST("/dms", dm.CreateGroupDM)
+	protected.PATCH("/dms/:channel_id", dm.RenameGroupDM)
+	protected.POST("/dms/:channel_id/users", dm.AddUsers)
+	protected.DELETE("/dms/:channel_id/users/:user_id", dm.RemoveUser)
+	protected.POST("/dms/:channel_id/leave", dm.LeaveGroupDM)
+
+	notification := handlers.NewNotificationHandlers(s.notificationSvc)
+	protected.GET("/notifications", notification.GetNotifications)
+	protected.POST("/notifications/read", notification.ReadNotifications)
+	protected.POST("/notifications/read_all", notification.ReadAllNotifications)
+	protected.POST("/invites/:invite_id/send", notification.SendInvite)
 
 	server := handlers.NewServerHandlers(s.serverSvc)
+	api.GET("/invites/:invite_id", server.GetInvitePreview)
 	protected.POST("/servers", server.CreateServer)
-	protected.GET("/servers/:server_id", server.GetInformations)
-	protected.GET("/servers/:server_id/members", server.GetMembers)
+	scoped.GET("/servers/:server_id", middlewares.Scope(types.ScopeServersRead), server.GetInformations)
+	scoped.GET("/servers/:server_id/members", middlewares.Scope(types.ScopeServersRead), server.GetMembers)
 	protected.GET("/servers/:server_id/bans", server.GetBannedMembers)
-	protected.GET("/servers/:server_id/search", server.SearchMembers)
+	protected.GET("/servers/:server_id/storage", server.GetStorageUsage)
+	scoped.GET("/servers/:server_id/emojis", middlewares.Scope(types.ScopeServersRead), server.GetEmojis)
+	protected.POST("/servers/:server_id/emojis", server.UploadEmojis)
+	protected.PATCH("/servers/:server_id/emojis/:emoji_id", server.UpdateEmoji)
+	protected.DELETE("/servers/:server_id/emojis/:emoji_id", server.DeleteEmoji)
+	scoped.GET("/servers/:server_id/search", middlewares.Scope(types.ScopeServersRead), server.SearchMembers)
+	protected.GET("/servers/discover", server.DiscoverServers)
 	protected.POST("/servers/join", server.JoinServer)
 	protected.POST("/servers/:server_id/leave", server.LeaveServer)
+	protected.POST("/servers/:server_id/bots/:bot_id", server.AddBot)
 	protected.POST("/servers/:server_id/invite", server.CreateInvite)
+	protected.GET("/servers/:server_id/invites", server.GetInvites)
+	protected.PUT("/servers/:server_id/vanity", server.SetVanityInvite)
 	protected.POST("/servers/:server_id/ban", server.BanUser)
 	protected.POST("/servers/:server_id/unban/:user_id", server.UnbanUser)
 	protected.POST("/servers/:server_id/kick", server.KickUser)
@@ -76,6 +145,21 @@ func (s *Server) RegisterRoutes() http.Handler {
 	protected.PATCH("/servers/:server_id/avatar", server.UpdateAvatar)
 	protected.DELETE("/servers/:server_id", server.DeleteServer)
 
+	voice := handlers.NewVoiceHandlers(s.voiceSvc)
+	scoped.GET("/servers/:server_id/voice", middlewares.Scope(types.ScopeServersRead), voice.GetVoiceStates)
+	api.POST("/sfu/webhook", voice.SFUWebhook)
+
+	webhook := handlers.NewWebhookHandlers(s.webhookSvc)
+	protected.GET("/servers/:server_id/webhooks", webhook.GetWebhooks)
+	protected.POST("/servers/:server_id/webhooks", webhook.CreateWebhook)
+	protected.PATCH("/servers/:server_id/webhooks/:webhook_id", webhook.EditWebhook)
+	protected.DELETE("/servers/:server_id/webhooks/:webhook_id", webhook.DeleteWebhook)
+	protected.GET("/servers/:server_id/webhooks/:webhook_id/deliveries", webhook.GetDeliveries)
+	protected.GET("/servers/:server_id/incoming_webhooks", webhook.GetIncomingWebhooks)
+	protected.POST("/servers/:server_id/incoming_webhooks", webhook.CreateIncomingWebhook)
+	protected.POST("/servers/:server_id/incoming_webhooks/:webhook_id/rotate", webhook.RotateIncomingWebhookToken)
+	protected.DELETE("/servers/:server_id/incoming_webhooks/:webhook_id", webhook.DeleteIncomingWebhook)
+
 	channel := handlers.NewChannelHandlers(s.channelSvc)
 	protected.POST("/channels", channel.CreateChannel)
 	protected.POST("/channels/category", channel.CreateCategory)
@@ -85,11 +169,42 @@ func (s *Server) RegisterRoutes() http.Handler {
 	protected.DELETE("/channels/:channel_id", channel.DeleteChannel)
 	protected.DELETE("/channels/category/:category_id", channel.DeleteCategory)
 
+	kanban := handlers.NewKanbanHandlers(s.kanbanSvc)
+	protected.GET("/channels/:channel_id/board", kanban.GetBoard)
+	protected.POST("/channels/:channel_id/columns", kanban.CreateColumn)
+	protected.PATCH("/channels/:channel_id/columns/:column_id", kanban.UpdateColumn)
+	protected.POST("/channels/:channel_id/columns/:column_id/move", kanban.MoveColumn)
+	protected.DELETE("/channels/:channel_id/columns/:column_id", kanban.DeleteColumn)
+	protected.POST("/channels/:channel_id/cards", kanban.CreateCard)
+	protected.PATCH("/channels/:channel_id/cards/:card_id", kanban.UpdateCard)
+	protected.POST("/channels/:channel_id/cards/:card_id/move", kanban.MoveCard)
+	protected.DELETE("/channels/:channel_id/cards/:card_id", kanban.DeleteCard)
+
+	gallery := handlers.NewGalleryHandlers(s.gallerySvc)
+	scoped.GET("/channels/:channel_id/media", middlewares.Scope(types.ScopeMessagesRead), gallery.GetMedia)
+
 	chat := handlers.NewChatHandlers(s.chatSvc)
-	protected.GET("/messages/:server_id/:channel_id", chat.GetMessages)
-	protected.POST("/messages", chat.CreateMessage)
-	protected.PATCH("/messages/:message_id", chat.EditMessage)
-	protected.DELETE("/messages/:message_id", chat.DeleteMessage)
+	scoped.GET("/messages/:server_id/:channel_id", middlewares.Scope(types.ScopeMessagesRead), chat.GetMessages)
+	scoped.POST("/messages", middlewares.Scope(types.ScopeMessagesWrite), chat.CreateMessage)
+	scoped.PATCH("/messages/:message_id", middlewares.Scope(types.ScopeMessagesWrite), chat.EditMessage)
+	scoped.DELETE("/messages/:message_id", middlewares.Scope(types.ScopeMessagesWrite), chat.DeleteMessage)
+	api.POST("/webhooks/:webhook_id/:token", middlewares.RateLimiter(middlewares.LimiterConfig{
+		MaxRequests: 30,
+		Window:      time.Minute,
+		KeyFunc:     func(c *gin.Context) string { return c.Param("webhook_id") },
+	}), chat.ExecuteWebhook)
+
+	upload := handlers.NewUploadHandlers(s.uploadSvc)
+	scoped.POST("/uploads", middlewares.Scope(types.ScopeMessagesWrite), upload.CreateUpload)
+	scoped.POST("/uploads/:upload_id/confirm", middlewares.Scope(types.ScopeMessagesWrite), upload.ConfirmUpload)
+
+	command := handlers.NewCommandHandlers(s.commandSvc)
+	protected.GET("/servers/:server_id/commands", command.GetCommands)
+	scoped.PUT("/servers/:server_id/commands", middlewares.Scope(types.ScopeCommands), command.RegisterCommands)
+	protected.PUT("/bots/:bot_id/interactions", command.SetInteractionEndpoint)
+	protected.DELETE("/bots/:bot_id/interactions", command.DeleteInteractionEndpoint)
+	protected.POST("/interactions", command.InvokeCommand)
+	scoped.POST("/interactions/:interaction_id/callback", middlewares.Scope(types.ScopeCommands), command.RespondToInteraction)
 
 	role := handlers.NewRoleHandlers(s.roleSvc)
 	protected.GET("/roles/:server_id", role.GetRoles)
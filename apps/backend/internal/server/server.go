@@ -2,12 +2,18 @@ package server
 
 import (
 	"backend/internal/actors"
+	"backend/internal/attachments"
 	"backend/internal/broker"
 	"backend/internal/database"
 	"backend/internal/domains"
 	"backend/internal/files"
+	"backend/internal/oauth"
 	"backend/internal/permissions"
+	"backend/internal/quotas"
+	"backend/internal/sfu"
+	"backend/internal/unfurl"
 	"backend/internal/validation"
+	"backend/internal/webhooks"
 	"fmt"
 	"net/http"
 	"os"
@@ -25,33 +31,64 @@ type Server struct {
 	actors      actors.Service
 	permissions permissions.Service
 	files       files.Service
+	oauth       oauth.Service
+	webhooks    webhooks.Service
 
-	authSvc    domains.AuthService
-	chatSvc    domains.ChatService
-	userSvc    domains.UserService
-	channelSvc domains.ChannelService
-	roleSvc    domains.RoleService
-	friendSvc  domains.FriendService
-	serverSvc  domains.ServerService
+	authSvc         domains.AuthService
+	chatSvc         domains.ChatService
+	userSvc         domains.UserService
+	channelSvc      domains.ChannelService
+	roleSvc         domains.RoleService
+	friendSvc       domains.FriendService
+	serverSvc       domains.ServerService
+	tokenSvc        domains.TokenService
+	webhookSvc      domains.WebhookService
+	commandSvc      domains.CommandService
+	uploadSvc       domains.UploadService
+	voiceSvc        domains.VoiceService
+	kanbanSvc       domains.KanbanService
+	gallerySvc      domains.GalleryService
+	e2eeSvc         domains.E2EEService
+	dmSvc           domains.DMService
+	notificationSvc domains.NotificationService
 }
 
-func NewServer() *http.Server {
+func NewServer() (*http.Server, error) {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 
 	validation.New()
 	databaseService := database.New()
 	brokerService := broker.New()
-	actorsService := actors.New(databaseService)
+	sfuService := sfu.New()
+	actorsService := actors.New(databaseService, sfuService)
 	filesService := files.New()
+	oauthService := oauth.New()
+	webhooksService := webhooks.New(databaseService)
+	attachmentsService := attachments.New(databaseService, filesService)
+	quotasService, err := quotas.New(databaseService)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage quotas: %w", err)
+	}
+	unfurlService := unfurl.New(brokerService)
 	permissionsService := permissions.New(databaseService, brokerService)
 
-	authService := domains.NewAuthService(databaseService, brokerService)
-	chatService := domains.NewChatService(actorsService, databaseService, filesService, permissionsService)
-	userService := domains.NewUserService(databaseService, brokerService, filesService, actorsService)
-	channelService := domains.NewChannelService(databaseService, actorsService, permissionsService)
-	friendService := domains.NewFriendService(databaseService, actorsService)
-	roleService := domains.NewRoleService(databaseService, actorsService, permissionsService)
-	serverService := domains.NewServerService(databaseService, actorsService, filesService, permissionsService)
+	authService := domains.NewAuthService(databaseService, brokerService, oauthService)
+	e2eeService := domains.NewE2EEService(databaseService, actorsService, permissionsService)
+	notificationService := domains.NewNotificationService(databaseService, actorsService, permissionsService)
+	chatService := domains.NewChatService(actorsService, databaseService, filesService, permissionsService, webhooksService, attachmentsService, quotasService, unfurlService, notificationService)
+	userService := domains.NewUserService(databaseService, brokerService, filesService, actorsService, attachmentsService, quotasService)
+	channelService := domains.NewChannelService(databaseService, actorsService, permissionsService, e2eeService)
+	friendService := domains.NewFriendService(databaseService, actorsService, notificationService)
+	dmService := domains.NewDMService(databaseService, actorsService, e2eeService)
+	roleService := domains.NewRoleService(databaseService, actorsService, permissionsService, e2eeService)
+	serverService := domains.NewServerService(databaseService, actorsService, filesService, permissionsService, webhooksService, attachmentsService, quotasService, e2eeService)
+	tokenService := domains.NewTokenService(databaseService, brokerService)
+	webhookService := domains.NewWebhookService(databaseService, permissionsService)
+	uploadService := domains.NewUploadService(databaseService, filesService, quotasService)
+	commandService := domains.NewCommandService(databaseService, brokerService, actorsService, permissionsService, chatService)
+	voiceService := domains.NewVoiceService(databaseService, actorsService, permissionsService, sfuService)
+	kanbanService := domains.NewKanbanService(databaseService, actorsService, permissionsService)
+	galleryService := domains.NewGalleryService(databaseService, permissionsService)
 
 	NewServer := &Server{
 		port: port,
@@ -60,15 +97,27 @@ func NewServer() *http.Server {
 		broker:      brokerService,
 		actors:      actorsService,
 		files:       filesService,
+		oauth:       oauthService,
+		webhooks:    webhooksService,
 		permissions: permissionsService,
 
-		authSvc:    authService,
-		chatSvc:    chatService,
-		userSvc:    userService,
-		channelSvc: channelService,
-		roleSvc:    roleService,
-		friendSvc:  friendService,
-		serverSvc:  serverService,
+		authSvc:         authService,
+		chatSvc:         chatService,
+		userSvc:         userService,
+		channelSvc:      channelService,
+		roleSvc:         roleService,
+		friendSvc:       friendService,
+		serverSvc:       serverService,
+		tokenSvc:        tokenService,
+		webhookSvc:      webhookService,
+		commandSvc:      commandService,
+		uploadSvc:       uploadService,
+		voiceSvc:        voiceService,
+		kanbanSvc:       kanbanService,
+		gallerySvc:      galleryService,
+		e2eeSvc:         e2eeService,
+		dmSvc:           dmService,
+		notificationSvc: notificationService,
 	}
 
 	// Declare Server config
@@ -80,5 +129,5 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	return server, nil
 }
@@ -0,0 +1,283 @@
+package sfu
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// JoinTokenTTL is how long clients have to connect with a join token, the SFU refreshes the
+	// tokens of connected participants itself.
+	JoinTokenTTL    = 2 * time.Minute
+	adminTokenTTL   = time.Minute
+	requestTimeout  = 5 * time.Second
+	maxWebhookSize  = 64 << 10 // 64kb
+	roomServicePath = "/twirp/livekit.RoomService/"
+)
+
+// Sources a participant may publish.
+const (
+	SourceMicrophone = "microphone"
+	SourceCamera     = "camera"
+	SourceScreen     = "screen_share"
+)
+
+// Permission is what a participant may do in a room.
+type Permission struct {
+	Audio     bool
+	Video     bool
+	Subscribe bool
+}
+
+// Session is what a client needs to connect to a room.
+type Session struct {
+	URL       string
+	Token     string
+	ExpiresAt time.Time
+}
+
+type EventType string
+
+const (
+	TrackPublished   EventType = "track_published"
+	TrackUnpublished EventType = "track_unpublished"
+)
+
+// Event is a webhook event of the SFU, the ones without a track aren't relayed.
+type Event struct {
+	Type     EventType
+	Room     string
+	Identity string
+	Track    Track
+}
+
+type Track struct {
+	SID string
+	// Kind is "audio" or "video".
+	Kind   string
+	Source string
+}
+
+// Service drives a selective forwarding unit speaking the LiveKit protocol (a pion-based Go SFU),
+// run as a sidecar. Rooms are named after the voice channels and participants after the users,
+// rooms open with their first participant.
+type Service interface {
+	// JoinSession issues a short-lived token to join a room with the given permission.
+	JoinSession(room, identity string, permission Permission) (*Session, error)
+	UpdatePermission(ctx context.Context, room, identity string, permission Permission) error
+	MuteTrack(ctx context.Context, room, identity, trackSID string) error
+	RemoveParticipant(ctx context.Context, room, identity string) error
+	DeleteRoom(ctx context.Context, room string) error
+	// ParseWebhook verifies the signature of a webhook request and decodes its event.
+	ParseWebhook(r *http.Request) (*Event, error)
+}
+
+type service struct {
+	// url is where clients connect, apiURL where the room service is reached from the API.
+	url       string
+	apiURL    string
+	apiKey    string
+	apiSecret string
+	client    *http.Client
+}
+
+// New returns nil when no SFU is configured, voice channels then connect their participants
+// to each other.
+func New() Service {
+	url := os.Getenv("SFU_URL")
+	if url == "" {
+		return nil
+	}
+
+	apiURL := os.Getenv("SFU_API_URL")
+	if apiURL == "" {
+		apiURL = strings.Replace(strings.Replace(url, "wss://", "https://", 1), "ws://", "http://", 1)
+	}
+
+	return NewService(url, apiURL, os.Getenv("SFU_API_KEY"), os.Getenv("SFU_API_SECRET"), &http.Client{Timeout: requestTimeout})
+}
+
+func NewService(url, apiURL, apiKey, apiSecret string, client *http.Client) Service {
+	return &service{
+		url:       url,
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		client:    client,
+	}
+}
+
+func (s *service) JoinSession(room, identity string, permission Permission) (*Session, error) {
+	now := time.Now()
+	expiresAt := now.Add(JoinTokenTTL)
+
+	canPublish, sources := publishSources(permission, SourceMicrophone, SourceCamera, SourceScreen)
+	canPublishData := false
+	token, err := signToken(Claims{
+		Issuer:    s.apiKey,
+		Subject:   identity,
+		NotBefore: now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		Video: &VideoGrant{
+			RoomJoin:          true,
+			Room:              room,
+			CanPublish:        &canPublish,
+			CanSubscribe:      &permission.Subscribe,
+			CanPublishData:    &canPublishData,
+			CanPublishSources: sources,
+		},
+	}, s.apiSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{URL: s.url, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+func (s *service) UpdatePermission(ctx context.Context, room, identity string, permission Permission) error {
+	canPublish, sources := publishSources(permission, "MICROPHONE", "CAMERA", "SCREEN_SHARE")
+
+	return s.call(ctx, "UpdateParticipant", VideoGrant{RoomAdmin: true, Room: room}, map[string]any{
+		"room":     room,
+		"identity": identity,
+		"permission": map[string]any{
+			"canPublish":        canPublish,
+			"canSubscribe":      permission.Subscribe,
+			"canPublishData":    false,
+			"canPublishSources": sources,
+		},
+	})
+}
+
+func (s *service) MuteTrack(ctx context.Context, room, identity, trackSID string) error {
+	return s.call(ctx, "MutePublishedTrack", VideoGrant{RoomAdmin: true, Room: room}, map[string]any{
+		"room":     room,
+		"identity": identity,
+		"trackSid": trackSID,
+		"muted":    true,
+	})
+}
+
+func (s *service) RemoveParticipant(ctx context.Context, room, identity string) error {
+	return s.call(ctx, "RemoveParticipant", VideoGrant{RoomAdmin: true, Room: room}, map[string]any{
+		"room":     room,
+		"identity": identity,
+	})
+}
+
+func (s *service) DeleteRoom(ctx context.Context, room string) error {
+	return s.call(ctx, "DeleteRoom", VideoGrant{RoomCreate: true}, map[string]any{
+		"room": room,
+	})
+}
+
+func (s *service) ParseWebhook(r *http.Request) (*Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookSize))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := verifyToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), s.apiSecret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+	if claims.Issuer != s.apiKey || claims.SHA256 != base64.StdEncoding.EncodeToString(hash[:]) {
+		return nil, ErrInvalidToken
+	}
+
+	var payload struct {
+		Event string `json:"event"`
+		Room  struct {
+			Name string `json:"name"`
+		} `json:"room"`
+		Participant struct {
+			Identity string `json:"identity"`
+		} `json:"participant"`
+		Track struct {
+			SID    string `json:"sid"`
+			Type   string `json:"type"`
+			Source string `json:"source"`
+		} `json:"track"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Type:     EventType(payload.Event),
+		Room:     payload.Room.Name,
+		Identity: payload.Participant.Identity,
+		Track: Track{
+			SID:    payload.Track.SID,
+			Kind:   strings.ToLower(payload.Track.Type),
+			Source: strings.ToLower(payload.Track.Source),
+		},
+	}, nil
+}
+
+// call makes a request to the room service, authenticated with a token granting grant.
+func (s *service) call(ctx context.Context, method string, grant VideoGrant, body any) error {
+	now := time.Now()
+	token, err := signToken(Claims{
+		Issuer:    s.apiKey,
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(adminTokenTTL).Unix(),
+		Video:     &grant,
+	}, s.apiSecret)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+roomServicePath+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var twirpErr struct {
+			Code string `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&twirpErr)
+		return fmt.Errorf("sfu %s failed with status %d: %s %s", method, resp.StatusCode, twirpErr.Code, twirpErr.Msg)
+	}
+
+	return nil
+}
+
+// publishSources turns a permission into the publish grant of the SFU, which reads an empty list
+// of sources as all of them.
+func publishSources(permission Permission, microphone, camera, screen string) (bool, []string) {
+	var sources []string
+	if permission.Audio {
+		sources = append(sources, microphone)
+	}
+	if permission.Video {
+		sources = append(sources, camera, screen)
+	}
+
+	return len(sources) > 0, sources
+}
@@ -0,0 +1,137 @@
+package sfu
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testKey    = "devkey"
+	testSecret = "secret"
+)
+
+func TestJoinSession(t *testing.T) {
+	s := NewService("ws://localhost:7880", "http://localhost:7880", testKey, testSecret, http.DefaultClient)
+
+	session, err := s.JoinSession("channel", "user", Permission{Audio: true, Subscribe: true})
+	if err != nil {
+		t.Fatalf("JoinSession() failed: %v", err)
+	}
+	if session.URL != "ws://localhost:7880" || time.Until(session.ExpiresAt) > JoinTokenTTL {
+		t.Fatalf("unexpected session %+v", session)
+	}
+
+	claims, err := verifyToken(session.Token, testSecret, time.Now())
+	if err != nil {
+		t.Fatalf("verifyToken() failed: %v", err)
+	}
+	grant := claims.Video
+	if claims.Issuer != testKey || claims.Subject != "user" || !grant.RoomJoin || grant.Room != "channel" ||
+		!*grant.CanPublish || !*grant.CanSubscribe || !slices.Equal(grant.CanPublishSources, []string{SourceMicrophone}) {
+		t.Fatalf("unexpected claims %+v %+v", claims, grant)
+	}
+
+	// Nothing to publish has to be explicit, no sources at all means every source.
+	session, _ = s.JoinSession("channel", "user", Permission{})
+	claims, _ = verifyToken(session.Token, testSecret, time.Now())
+	if *claims.Video.CanPublish || *claims.Video.CanSubscribe {
+		t.Fatalf("expected publishing and subscribing to be denied, got %+v", claims.Video)
+	}
+
+	if _, err := verifyToken(session.Token, "other", time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken with another secret, got %v", err)
+	}
+	if _, err := verifyToken(session.Token, testSecret, time.Now().Add(JoinTokenTTL+time.Minute)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken once expired, got %v", err)
+	}
+}
+
+func TestRoomService(t *testing.T) {
+	var calls []string
+	var lastBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := verifyToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), testSecret, time.Now())
+		if err != nil || claims.Video == nil || (!claims.Video.RoomAdmin && !claims.Video.RoomCreate) {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"code": "unauthenticated", "msg": "invalid token"})
+			return
+		}
+
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, roomServicePath))
+		json.NewDecoder(r.Body).Decode(&lastBody)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	s := NewService("ws://sfu", srv.URL, testKey, testSecret, srv.Client())
+	ctx := context.Background()
+
+	if err := s.UpdatePermission(ctx, "channel", "user", Permission{Video: true}); err != nil {
+		t.Fatalf("UpdatePermission() failed: %v", err)
+	}
+	permission := lastBody["permission"].(map[string]any)
+	if permission["canPublish"] != true || permission["canSubscribe"] != false || len(permission["canPublishSources"].([]any)) != 2 {
+		t.Fatalf("unexpected permission %+v", permission)
+	}
+
+	if err := s.MuteTrack(ctx, "channel", "user", "TR_mic"); err != nil || lastBody["trackSid"] != "TR_mic" || lastBody["muted"] != true {
+		t.Fatalf("MuteTrack() = %v, body %+v", err, lastBody)
+	}
+	if err := s.RemoveParticipant(ctx, "channel", "user"); err != nil {
+		t.Fatalf("RemoveParticipant() failed: %v", err)
+	}
+	if err := s.DeleteRoom(ctx, "channel"); err != nil {
+		t.Fatalf("DeleteRoom() failed: %v", err)
+	}
+
+	want := []string{"UpdateParticipant", "MutePublishedTrack", "RemoveParticipant", "DeleteRoom"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	wrong := NewService("ws://sfu", srv.URL, testKey, "other", srv.Client())
+	if err := wrong.DeleteRoom(ctx, "channel"); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Fatalf("expected the SFU error, got %v", err)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	s := NewService("ws://sfu", "http://sfu", testKey, testSecret, http.DefaultClient)
+	body := `{"event":"track_published","room":{"name":"channel"},"participant":{"identity":"user"},"track":{"sid":"TR_mic","type":"AUDIO","source":"MICROPHONE"}}`
+
+	request := func(body, signedBody string) *http.Request {
+		hash := sha256.Sum256([]byte(signedBody))
+		token, _ := signToken(Claims{
+			Issuer:    testKey,
+			NotBefore: time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			SHA256:    base64.StdEncoding.EncodeToString(hash[:]),
+		}, testSecret)
+
+		r := httptest.NewRequest(http.MethodPost, "/api/sfu/webhook", strings.NewReader(body))
+		r.Header.Set("Authorization", token)
+		return r
+	}
+
+	event, err := s.ParseWebhook(request(body, body))
+	if err != nil {
+		t.Fatalf("ParseWebhook() failed: %v", err)
+	}
+	want := Event{Type: TrackPublished, Room: "channel", Identity: "user", Track: Track{SID: "TR_mic", Kind: "audio", Source: "microphone"}}
+	if *event != want {
+		t.Fatalf("ParseWebhook() = %+v, want %+v", event, want)
+	}
+
+	if _, err := s.ParseWebhook(request(strings.Replace(body, "user", "other", 1), body)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a tampered body to be refused, got %v", err)
+	}
+}
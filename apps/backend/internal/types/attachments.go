@@ -0,0 +1,34 @@
+package types
+
+type AttachmentKind string
+
+const (
+	AttachmentMessage      AttachmentKind = "message"
+	AttachmentEmbed        AttachmentKind = "embed"
+	AttachmentUserAvatar   AttachmentKind = "user_avatar"
+	AttachmentUserBanner   AttachmentKind = "user_banner"
+	AttachmentServerAvatar AttachmentKind = "server_avatar"
+	AttachmentServerBanner AttachmentKind = "server_banner"
+	AttachmentEmoji        AttachmentKind = "emoji"
+	AttachmentServerEmoji  AttachmentKind = "server_emoji"
+)
+
+// AttachmentOwner is what a stored file belongs to, its files are deleted along with it.
+type AttachmentOwner struct {
+	Kind AttachmentKind
+	ID   string
+	// Quota is who the files count against.
+	Quota QuotaAccount
+}
+
+// QuotaAccount is the user and the server whose storage quotas are used, either can be empty.
+type QuotaAccount struct {
+	UserID   string
+	ServerID string
+}
+
+// StoredFile is a file to track along with the bytes it counts for.
+type StoredFile struct {
+	URL  string
+	Size int64
+}
@@ -11,3 +11,23 @@ type SignUpParams struct {
 	DisplayName string `validate:"required,max=20" json:"display_name"`
 	Password    string `validate:"required,min=8,max=254" json:"password"`
 }
+
+type OAuthState struct {
+	Provider   string `json:"provider"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"`
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+type OAuthCallbackParams struct {
+	Code  string `validate:"required"`
+	State string `validate:"required"`
+}
+
+type CreateOAuthUserParams struct {
+	Email       string
+	Username    string
+	DisplayName string
+	Provider    string
+	Subject     string
+}
@@ -11,6 +11,14 @@ type CreateMessageParams struct {
 	MentionsRoles    []string        `json:"mentions_roles"`
 	MentionsChannels []string        `json:"mentions_channels"`
 	Attachments      json.RawMessage `json:"attachments"`
+	Uploads          []string        `json:"uploads" validate:"max=10"`
+	// CardID makes the message a comment of a card, in kanban channels.
+	CardID string `json:"card_id"`
+	// PostID makes the message a comment of a post, in gallery channels.
+	PostID string `json:"post_id"`
+	// ReplyTo is the message of the channel this one replies to.
+	ReplyTo        string          `json:"reply_to"`
+	AuthorOverride json.RawMessage `json:"-"`
 }
 
 type EditMessageParams struct {
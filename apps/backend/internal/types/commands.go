@@ -0,0 +1,62 @@
+package types
+
+import "backend/internal/commands"
+
+type CommandDefinition struct {
+	Name        string            `json:"name" validate:"required,max=32"`
+	Description string            `json:"description" validate:"required,max=100"`
+	Options     []commands.Option `json:"options" validate:"max=25"`
+}
+
+// RegisterCommandsParams replaces every command a bot has in a server.
+type RegisterCommandsParams struct {
+	Commands []CommandDefinition `json:"commands" validate:"max=100,dive"`
+}
+
+type InteractionEndpointParams struct {
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+}
+
+type InteractionEndpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type InvokeCommandParams struct {
+	ServerID  string           `json:"server_id" validate:"required"`
+	ChannelID string           `json:"channel_id" validate:"required"`
+	CommandID string           `json:"command_id" validate:"required"`
+	Options   []commands.Value `json:"options" validate:"max=25"`
+}
+
+type InteractionResponseParams struct {
+	Content   string `json:"content" validate:"required,max=4000"`
+	Ephemeral bool   `json:"ephemeral"`
+}
+
+// PendingInteraction is what the broker keeps of an interaction until the bot stops replying to it.
+type PendingInteraction struct {
+	ID        string `json:"id"`
+	CommandID string `json:"command_id"`
+	BotID     string `json:"bot_id"`
+	ServerID  string `json:"server_id"`
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+}
+
+// InteractionPayload is the JSON body POSTed to a bot interaction endpoint.
+type InteractionPayload struct {
+	ID          string          `json:"id"`
+	CommandID   string          `json:"command_id"`
+	CommandName string          `json:"command_name"`
+	ServerID    string          `json:"server_id"`
+	ChannelID   string          `json:"channel_id"`
+	User        InteractionUser `json:"user"`
+	Options     map[string]any  `json:"options"`
+}
+
+type InteractionUser struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
@@ -0,0 +1,51 @@
+package types
+
+import db "backend/db/gen_queries"
+
+const (
+	// MaxGroupDMUsers is how many participants a group direct message can have, its owner included.
+	MaxGroupDMUsers = 10
+	// MaxGroupDMs is how many group direct messages a user can be in.
+	MaxGroupDMs = 100
+)
+
+// Who can add a user to a group direct message besides their friends.
+const (
+	DMPrivacyServerMembers = "server_members"
+	DMPrivacyFriends       = "friends"
+)
+
+// The kinds of GroupDMChange events sent to the participants of a group direct message.
+const (
+	GroupDMCreate       = "create"
+	GroupDMUpdate       = "update"
+	GroupDMMemberAdd    = "member_add"
+	GroupDMMemberRemove = "member_remove"
+	GroupDMDelete       = "delete"
+)
+
+type GroupDM struct {
+	db.Channel
+	LastMessageRead string `json:"last_message_read"`
+	LastMessageSent string `json:"last_message_sent"`
+}
+
+// CreateGroupDMParams lists the other participants, the creator becomes the owner.
+type CreateGroupDMParams struct {
+	Name  string   `json:"name" validate:"omitempty,max=64"`
+	Users []string `json:"users" validate:"required,min=1,max=9,unique,dive,required"`
+}
+
+type RenameGroupDMParams struct {
+	Name string `json:"name" validate:"omitempty,max=64"`
+}
+
+type AddGroupDMUsersParams struct {
+	Users []string `json:"users" validate:"required,min=1,max=9,unique,dive,required"`
+}
+
+// UpdatePrivacyParams changes the privacy settings which are sent, the others are kept.
+type UpdatePrivacyParams struct {
+	DMPrivacy            string `json:"dm_privacy" validate:"omitempty,oneof=server_members friends"`
+	FriendRequestPrivacy string `json:"friend_request_privacy" validate:"omitempty,oneof=everyone friends_of_friends server_members nobody"`
+}
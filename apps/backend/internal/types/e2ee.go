@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// MaxUserDevices is how many devices a user can register keys for.
+const MaxUserDevices = 10
+
+// The kinds of ChannelKeyChange events sent to the members of an encrypted channel.
+const (
+	E2EEEnabled        = "e2ee_enabled"
+	RekeyRequired      = "rekey_required"
+	KeyRotated         = "key_rotated"
+	EnvelopesAdded     = "envelopes_added"
+	EnvelopesRequested = "envelopes_requested"
+)
+
+// Why the group key of a channel has to be replaced.
+const (
+	RekeyMembersChanged = "members_changed"
+	RekeyDeviceRevoked  = "device_revoked"
+)
+
+type RegisterDeviceParams struct {
+	Name        string `json:"name" validate:"required,min=1,max=64"`
+	IdentityKey string `json:"identity_key" validate:"required,base64,len=44"`
+	PublicKey   string `json:"public_key" validate:"required,base64,len=44"`
+	Signature   string `json:"signature" validate:"required,base64,len=88"`
+}
+
+// DeviceKey is what other users see of a device, enough to seal a group key to it.
+type DeviceKey struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	IdentityKey string    `json:"identity_key"`
+	PublicKey   string    `json:"public_key"`
+	Signature   string    `json:"signature"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type GetChannelKeysParams struct {
+	DeviceID string `validate:"required"`
+}
+
+// SealedKey is a group key sealed to a device.
+type SealedKey struct {
+	DeviceID   string `json:"device_id" validate:"required"`
+	Ciphertext string `json:"ciphertext" validate:"required"`
+}
+
+// RotateChannelKeyParams replaces the group key of a channel, it has to be sealed to every device of
+// the members.
+type RotateChannelKeyParams struct {
+	DeviceID  string      `json:"device_id" validate:"required"`
+	Envelopes []SealedKey `json:"envelopes" validate:"required,min=1,dive"`
+}
+
+// AddKeyEnvelopesParams shares an existing group key with devices which don't have it yet.
+type AddKeyEnvelopesParams struct {
+	DeviceID  string      `json:"device_id" validate:"required"`
+	Envelopes []SealedKey `json:"envelopes" validate:"required,min=1,dive"`
+}
+
+type RequestKeyEnvelopesParams struct {
+	DeviceID string `json:"device_id" validate:"required"`
+}
@@ -0,0 +1,27 @@
+package types
+
+type EmbedType string
+
+const (
+	EmbedLink  EmbedType = "link"
+	EmbedImage EmbedType = "image"
+	EmbedVideo EmbedType = "video"
+)
+
+// Embed is the preview of a link posted in a message.
+type Embed struct {
+	URL         string      `json:"url"`
+	Type        EmbedType   `json:"type"`
+	SiteName    string      `json:"site_name,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Author      string      `json:"author,omitempty"`
+	Color       string      `json:"color,omitempty"`
+	Image       *EmbedMedia `json:"image,omitempty"`
+}
+
+type EmbedMedia struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
@@ -0,0 +1,13 @@
+package types
+
+type ServerEmojiKind string
+
+const (
+	ServerEmoji   ServerEmojiKind = "emoji"
+	ServerSticker ServerEmojiKind = "sticker"
+)
+
+type UploadServerEmojiParams struct {
+	Kind       ServerEmojiKind `validate:"required,oneof=emoji sticker" json:"kind"`
+	Shortcodes []string        `validate:"required,max=10,dive,emoji_shortcode" json:"shortcodes"`
+}
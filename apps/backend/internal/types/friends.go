@@ -1,5 +1,22 @@
 package types
 
+import db "backend/db/gen_queries"
+
+// Who can send a friend request to a user.
+const (
+	FriendRequestPrivacyEveryone         = "everyone"
+	FriendRequestPrivacyFriendsOfFriends = "friends_of_friends"
+	FriendRequestPrivacyServerMembers    = "server_members"
+	FriendRequestPrivacyNobody           = "nobody"
+)
+
+// Why a friendship or a friend request ended, sent with the RemoveFriend events.
+const (
+	FriendRemoved         = "remove"
+	FriendRequestDeclined = "decline"
+	FriendRequestCanceled = "cancel"
+)
+
 type SendRequestParams struct {
 	ReceiverUsername string `json:"friend_username" validate:"required,min=1,max=20"`
 }
@@ -15,3 +32,9 @@ type RemoveFriendParams struct {
 	ReceiverID   string `json:"receiver_id" validate:"required"`
 	ChannelID    string `json:"channel_id" validate:"omitempty"`
 }
+
+// FriendRequests are the pending requests of a user, the ones they received and the ones they sent.
+type FriendRequests struct {
+	Incoming []db.GetIncomingFriendRequestsRow `json:"incoming"`
+	Outgoing []db.GetOutgoingFriendRequestsRow `json:"outgoing"`
+}
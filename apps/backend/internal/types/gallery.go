@@ -0,0 +1,42 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const DefaultGalleryPosts = 30
+
+// GalleryPost is a post of a gallery channel, the media of an album are in their posted order.
+type GalleryPost struct {
+	ID        string          `json:"id"`
+	AuthorID  string          `json:"author_id"`
+	Content   json.RawMessage `json:"content"`
+	Media     []GalleryMedia  `json:"media"`
+	Comments  int64           `json:"comments"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// GalleryMedia is an image or a video of a post, the thumbnail is the poster of videos.
+type GalleryMedia struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	URL        string  `json:"url"`
+	Thumbnail  string  `json:"thumbnail,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	Blurhash   string  `json:"blurhash,omitempty"`
+	Processing bool    `json:"processing,omitempty"`
+}
+
+type GetGalleryMediaParams struct {
+	Before string
+	Limit  int `validate:"min=1,max=100"`
+}
+
+type GalleryPage struct {
+	Posts []GalleryPost `json:"posts"`
+	// Next is the cursor of the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	db "backend/db/gen_queries"
+	"encoding/json"
+	"time"
+)
+
+const (
+	MaxKanbanColumns = 20
+	MaxKanbanCards   = 500
+)
+
+// The kinds of KanbanChange events sent to the members of a kanban channel.
+const (
+	KanbanColumnCreate = "column_create"
+	KanbanColumnUpdate = "column_update"
+	KanbanColumnMove   = "column_move"
+	KanbanColumnDelete = "column_delete"
+	KanbanCardCreate   = "card_create"
+	KanbanCardUpdate   = "card_update"
+	KanbanCardMove     = "card_move"
+	KanbanCardDelete   = "card_delete"
+)
+
+type KanbanBoard struct {
+	Columns []db.KanbanColumn `json:"columns"`
+	Cards   []db.KanbanCard   `json:"cards"`
+}
+
+type CreateKanbanColumnParams struct {
+	Name string `json:"name" validate:"required,min=1,max=64"`
+}
+
+type UpdateKanbanColumnParams struct {
+	Name string `json:"name" validate:"required,min=1,max=64"`
+}
+
+type MoveKanbanColumnParams struct {
+	Position int `json:"position" validate:"min=0"`
+}
+
+type CreateKanbanCardParams struct {
+	ColumnID    string          `json:"column_id" validate:"required"`
+	Title       string          `json:"title" validate:"required,min=1,max=256"`
+	Description json.RawMessage `json:"description"`
+	Assignees   []string        `json:"assignees" validate:"max=10"`
+	Labels      []string        `json:"labels" validate:"max=10,dive,min=1,max=32"`
+	DueAt       *time.Time      `json:"due_at"`
+}
+
+// UpdateKanbanCardParams only changes the fields which are sent, an empty list clears the
+// assignees or the labels.
+type UpdateKanbanCardParams struct {
+	Title       *string         `json:"title" validate:"omitempty,min=1,max=256"`
+	Description json.RawMessage `json:"description"`
+	Assignees   []string        `json:"assignees" validate:"max=10"`
+	Labels      []string        `json:"labels" validate:"max=10,dive,min=1,max=32"`
+	DueAt       *time.Time      `json:"due_at"`
+	ClearDueAt  bool            `json:"clear_due_at"`
+}
+
+type MoveKanbanCardParams struct {
+	ColumnID string `json:"column_id" validate:"required"`
+	Position int    `json:"position" validate:"min=0"`
+}
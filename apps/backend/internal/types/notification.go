@@ -0,0 +1,66 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const DefaultNotifications = 50
+
+// The kinds of notifications of the feed.
+const (
+	NotificationMention       = "mention"
+	NotificationRoleMention   = "role_mention"
+	NotificationEveryone      = "everyone"
+	NotificationReply         = "reply"
+	NotificationFriendRequest = "friend_request"
+	NotificationInvite        = "invite"
+)
+
+// Notification is an entry of the notification feed. The actor is who caused it, the message is set
+// for mentions and replies, the friendship for friend requests and the invite for invites.
+type Notification struct {
+	ID           string             `json:"id"`
+	Type         string             `json:"type"`
+	Actor        *NotificationActor `json:"actor,omitempty"`
+	ServerID     string             `json:"server_id,omitempty"`
+	ChannelID    string             `json:"channel_id,omitempty"`
+	MessageID    string             `json:"message_id,omitempty"`
+	Content      json.RawMessage    `json:"content,omitempty"`
+	FriendshipID string             `json:"friendship_id,omitempty"`
+	InviteID     string             `json:"invite_id,omitempty"`
+	Read         bool               `json:"read"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type NotificationActor struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+type GetNotificationsParams struct {
+	Before   string
+	ServerID string
+	Unread   bool
+	Limit    int `validate:"min=1,max=100"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	// Next is the cursor of the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
+
+type ReadNotificationsParams struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,required"`
+}
+
+// ReadAllNotificationsParams marks the whole feed as read, or the notifications of a server.
+type ReadAllNotificationsParams struct {
+	ServerID string `json:"server_id" validate:"omitempty"`
+}
+
+type SendInviteParams struct {
+	Users []string `json:"users" validate:"required,min=1,max=20,unique,dive,required"`
+}
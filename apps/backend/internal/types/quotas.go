@@ -0,0 +1,9 @@
+package types
+
+// StorageUsage reports how much of a storage quota is used, sizes are in bytes.
+type StorageUsage struct {
+	Tier        string `json:"tier"`
+	Used        int64  `json:"used"`
+	Limit       int64  `json:"limit"`
+	MaxFileSize int64  `json:"max_file_size,omitempty"`
+}
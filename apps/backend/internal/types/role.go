@@ -17,6 +17,7 @@ const (
 	AttachFiles       Ability = "ATTACH_FILES"
 	AddReactions      Ability = "ADD_REACTIONS"
 	UsePersonalEmojis Ability = "USE_PERSONAL_EMOJIS"
+	ManageEmojis      Ability = "MANAGE_EMOJIS"
 	MentionEveryone   Ability = "MENTION_EVERYONE"
 	ManageMessages    Ability = "MANAGE_MESSAGES"
 	Connect           Ability = "CONNECT"
@@ -27,10 +27,55 @@ type JoinServerParams struct {
 	Position int    `json:"position" validate:"omitempty"`
 }
 
+// InviteExpiries are the lifetimes an invite can be created with.
+var InviteExpiries = map[string]time.Duration{
+	"30m":   30 * time.Minute,
+	"1h":    time.Hour,
+	"6h":    6 * time.Hour,
+	"12h":   12 * time.Hour,
+	"1d":    24 * time.Hour,
+	"7d":    7 * 24 * time.Hour,
+	"never": 0,
+}
+
+type CreateInviteParams struct {
+	// MaxUses of 0 is unlimited.
+	MaxUses   int    `json:"max_uses" validate:"min=0,max=100"`
+	ExpireIn  string `json:"expire_in" validate:"omitempty,oneof=30m 1h 6h 12h 1d 7d never"`
+	Temporary bool   `json:"temporary"`
+}
+
+type SetVanityInviteParams struct {
+	// An empty code removes the vanity invite.
+	Code string `json:"code" validate:"omitempty,min=3,max=32,vanity_code"`
+}
+
+type InvitePreview struct {
+	db.GetInvitePreviewRow
+	OnlineCount int `json:"online_count"`
+}
+
 type UpdateServerProfileParams struct {
 	Name        string          `json:"name" validate:"omitempty,min=1,max=20"`
 	Description json.RawMessage `json:"description" validate:"omitempty"`
 	Public      bool            `json:"public" validate:"omitempty"`
+	Category    string          `json:"category" validate:"omitempty,oneof=gaming music education science technology entertainment art community other"`
+	Tags        []string        `json:"tags" validate:"omitempty,max=5,dive,min=2,max=20,slug"`
+}
+
+const DiscoveryPageSize = 20
+
+type DiscoverServersParams struct {
+	Query    string `validate:"omitempty,max=100"`
+	Category string `validate:"omitempty,oneof=gaming music education science technology entertainment art community other"`
+	Tag      string `validate:"omitempty,max=20"`
+	Sort     string `validate:"oneof=members newest name relevance"`
+	Offset   int    `validate:"min=0"`
+}
+
+type DiscoverableServer struct {
+	db.DiscoverServersRow
+	OnlineCount int `json:"online_count"`
 }
 
 type UpdateServerAvatarParams struct {
@@ -0,0 +1,44 @@
+package types
+
+import (
+	db "backend/db/gen_queries"
+	"time"
+)
+
+type Scope = string
+
+const (
+	ScopeMessagesRead  Scope = "messages:read"
+	ScopeMessagesWrite Scope = "messages:write"
+	ScopeServersRead   Scope = "servers:read"
+	ScopeUsersRead     Scope = "users:read"
+	ScopeGateway       Scope = "gateway"
+	ScopeCommands      Scope = "commands"
+)
+
+type CreateTokenParams struct {
+	Name      string     `json:"name" validate:"required,max=50"`
+	Scopes    []Scope    `json:"scopes" validate:"required,min=1,dive,oneof=messages:read messages:write servers:read users:read gateway commands"`
+	RateLimit int32      `json:"rate_limit" validate:"omitempty,min=1,max=600"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty"`
+	BotID     string     `json:"bot_id" validate:"omitempty"`
+}
+
+type CreateBotParams struct {
+	Username    string `json:"username" validate:"required,max=20"`
+	DisplayName string `json:"display_name" validate:"required,max=20"`
+}
+
+type CreatedToken struct {
+	db.CreateAccessTokenRow
+	Token string `json:"token"`
+}
+
+// CachedAccessToken is what the broker keeps for a personal access token, keyed by its hash.
+type CachedAccessToken struct {
+	ID        string     `json:"id"`
+	Scopes    []Scope    `json:"scopes"`
+	RateLimit int32      `json:"rate_limit"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	User      db.User    `json:"user"`
+}
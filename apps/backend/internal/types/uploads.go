@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+type CreateUploadParams struct {
+	FileName    string `json:"file_name" validate:"required,max=255"`
+	ContentType string `json:"content_type" validate:"required,max=255"`
+	Size        int64  `json:"size" validate:"required,min=1"`
+}
+
+// PresignedUpload tells the client where to PUT the file, it is confirmed afterwards with its ID.
+type PresignedUpload struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
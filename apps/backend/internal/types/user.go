@@ -24,10 +24,15 @@ type UpdateProfileParams struct {
 }
 
 type Setup struct {
-	User    *db.User                        `json:"user"`
-	Servers map[string]ServerWithCategories `json:"servers"`
-	Friends []Friend                        `json:"friends"`
-	Emojis  []db.GetEmojisRow               `json:"emojis"`
+	User       *db.User                        `json:"user"`
+	Servers    map[string]ServerWithCategories `json:"servers"`
+	Friends    []Friend                        `json:"friends"`
+	GroupDMs   []GroupDM                       `json:"group_dms"`
+	BlockedIDs []string                        `json:"blocked_ids"`
+	// UnreadNotifications is the badge count of the notification feed.
+	UnreadNotifications int64             `json:"unread_notifications"`
+	Emojis              []db.GetEmojisRow `json:"emojis"`
+	ServerEmojis        []db.ServerEmoji  `json:"server_emojis"`
 }
 
 type Friend struct {
@@ -37,7 +42,8 @@ type Friend struct {
 }
 
 type UpdatePasswordParams struct {
-	Current string `json:"current" validate:"required,min=8,max=254"`
+	// Current is only left empty by the users who signed up with a provider and have no password yet.
+	Current string `json:"current" validate:"omitempty,min=8,max=254"`
 	New     string `json:"new" validate:"required,min=8,max=254"`
 	Confirm string `json:"confirm" validate:"required,min=8,max=254"`
 }
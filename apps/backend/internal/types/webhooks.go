@@ -0,0 +1,82 @@
+package types
+
+import (
+	db "backend/db/gen_queries"
+	"encoding/json"
+	"time"
+)
+
+type WebhookEvent = string
+
+const (
+	EventMessageCreate WebhookEvent = "message.create"
+	EventMessageUpdate WebhookEvent = "message.update"
+	EventMessageDelete WebhookEvent = "message.delete"
+	EventMemberJoin    WebhookEvent = "member.join"
+	EventMemberLeave   WebhookEvent = "member.leave"
+	EventMemberBan     WebhookEvent = "member.ban"
+	EventMemberKick    WebhookEvent = "member.kick"
+)
+
+type CreateWebhookParams struct {
+	URL    string         `json:"url" validate:"required,http_url,max=2048"`
+	Events []WebhookEvent `json:"events" validate:"required,min=1,dive,oneof=message.create message.update message.delete member.join member.leave member.ban member.kick"`
+}
+
+type EditWebhookParams struct {
+	URL    string         `json:"url" validate:"required,http_url,max=2048"`
+	Events []WebhookEvent `json:"events" validate:"required,min=1,dive,oneof=message.create message.update message.delete member.join member.leave member.ban member.kick"`
+	Active bool           `json:"active"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhook URLs.
+type WebhookPayload struct {
+	Event     WebhookEvent `json:"event"`
+	ServerID  string       `json:"server_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      any          `json:"data"`
+}
+
+type WebhookMessage struct {
+	ID          string          `json:"id"`
+	ChannelID   string          `json:"channel_id"`
+	AuthorID    string          `json:"author_id,omitempty"`
+	Content     json.RawMessage `json:"content,omitempty"`
+	Attachments json.RawMessage `json:"attachments,omitempty"`
+}
+
+type WebhookMember struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type CreateIncomingWebhookParams struct {
+	ChannelID string `json:"channel_id" validate:"required"`
+	Name      string `json:"name" validate:"required,max=20"`
+}
+
+type IncomingWebhookURL struct {
+	URL string `json:"url"`
+}
+
+type CreatedIncomingWebhook struct {
+	db.CreateIncomingWebhookRow
+	URL string `json:"url"`
+}
+
+// MaxWebhookBodySize bounds the requests made to incoming webhooks, attachments included.
+const MaxWebhookBodySize = 25 << 20 // 25MB
+
+// ExecuteWebhookParams is the body accepted by incoming webhooks, either as JSON or as
+// the payload_json field of a multipart form carrying attachments[] files.
+type ExecuteWebhookParams struct {
+	Content   string `json:"content" validate:"max=4000"`
+	Username  string `json:"username" validate:"omitempty,max=20"`
+	AvatarURL string `json:"avatar_url" validate:"omitempty,http_url,max=255"`
+}
+
+// AuthorOverride replaces the name and avatar shown for a message, used by incoming webhooks.
+type AuthorOverride struct {
+	Username string `json:"username,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+}
@@ -0,0 +1,61 @@
+package unfurl
+
+import (
+	"backend/internal/broker"
+	"backend/internal/safehttp"
+	"backend/internal/types"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const fetchTimeout = 5 * time.Second
+
+// Service previews the links posted in messages.
+type Service interface {
+	// Unfurl describes a URL, results are cached in the broker, failures included.
+	Unfurl(ctx context.Context, url string) (*types.Embed, error)
+	// FetchImage downloads the image of an embed so it can be hosted with the other files.
+	FetchImage(ctx context.Context, url string) ([]byte, error)
+}
+
+type service struct {
+	broker   broker.Service
+	unfurler *Unfurler
+}
+
+func New(broker broker.Service) Service {
+	return &service{
+		broker:   broker,
+		unfurler: NewUnfurler(safehttp.NewClient(fetchTimeout)),
+	}
+}
+
+func (s *service) Unfurl(ctx context.Context, url string) (*types.Embed, error) {
+	if embed, err := s.broker.GetCachedEmbed(ctx, url); err == nil {
+		if embed == nil {
+			return nil, ErrNotDescribed
+		}
+		return embed, nil
+	}
+
+	embed, err := s.unfurler.Unfurl(ctx, url)
+	if err != nil {
+		slog.Debug("failed to unfurl", "url", url, "err", err)
+		embed = nil
+	}
+
+	if cerr := s.broker.CacheEmbed(ctx, url, embed); cerr != nil {
+		slog.Error("failed to cache embed", "url", url, "err", cerr)
+	}
+
+	if embed == nil {
+		return nil, ErrNotDescribed
+	}
+
+	return embed, nil
+}
+
+func (s *service) FetchImage(ctx context.Context, url string) ([]byte, error) {
+	return s.unfurler.FetchImage(ctx, url)
+}
@@ -0,0 +1,337 @@
+package unfurl
+
+import (
+	"backend/internal/types"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// maxPageSize bounds what is read of a page, the metadata is in its head anyway.
+	maxPageSize          = 1 << 20  // 1mb
+	maxOEmbedSize        = 64 << 10 // 64kb
+	maxImageSize         = 8 << 20  // 8mb
+	maxRedirects         = 5
+	maxTitleLength       = 256
+	maxDescriptionLength = 350
+	userAgent            = "Mozilla/5.0 (compatible; Kyob-Unfurler/1.0)"
+)
+
+// ErrNotDescribed is returned for pages without anything to preview.
+var ErrNotDescribed = errors.New("the page has nothing to preview")
+
+// Unfurler describes web pages with their OpenGraph tags, completed by their oEmbed endpoint.
+type Unfurler struct {
+	client *http.Client
+}
+
+// NewUnfurler returns an unfurler making its requests with client, which has to refuse private
+// addresses when the URLs come from users (see safehttp).
+func NewUnfurler(client *http.Client) *Unfurler {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("unsupported redirect to %q", req.URL.Scheme)
+		}
+		return nil
+	}
+
+	return &Unfurler{client: &c}
+}
+
+// Unfurl fetches the page at rawURL and describes it, links to images are described as the image.
+func (u *Unfurler) Unfurl(ctx context.Context, rawURL string) (*types.Embed, error) {
+	resp, err := u.get(ctx, rawURL, "text/html,application/xhtml+xml;q=0.9,image/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	embed := &types.Embed{URL: rawURL, Type: types.EmbedLink}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		embed.Type = types.EmbedImage
+		embed.Image = &types.EmbedMedia{URL: resp.Request.URL.String()}
+		return embed, nil
+	case mediaType != "text/html" && mediaType != "application/xhtml+xml":
+		return nil, ErrNotDescribed
+	}
+
+	meta, err := parseHead(io.LimitReader(resp.Body, maxPageSize), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the page: %w", err)
+	}
+
+	// Relative URLs are relative to where the redirects ended.
+	base := resp.Request.URL
+
+	embed.SiteName = truncate(meta.get("og:site_name"), maxTitleLength)
+	embed.Title = truncate(cmp.Or(meta.get("og:title"), meta.get("twitter:title"), meta.title), maxTitleLength)
+	embed.Description = truncate(cmp.Or(meta.get("og:description"), meta.get("twitter:description"), meta.get("description")), maxDescriptionLength)
+	embed.Color = meta.get("theme-color")
+	if strings.HasPrefix(meta.get("og:type"), "video") {
+		embed.Type = types.EmbedVideo
+	}
+
+	if image := resolve(base, cmp.Or(meta.get("og:image:secure_url"), meta.get("og:image"), meta.get("twitter:image"), meta.get("twitter:image:src"))); image != "" {
+		embed.Image = &types.EmbedMedia{
+			URL:    image,
+			Width:  atoi(meta.get("og:image:width")),
+			Height: atoi(meta.get("og:image:height")),
+		}
+	}
+
+	if endpoint := resolve(base, meta.oEmbed); endpoint != "" {
+		// oEmbed only completes the page, it failing doesn't matter.
+		if oembed, err := u.fetchOEmbed(ctx, endpoint); err == nil {
+			oembed.apply(embed, base)
+		}
+	}
+
+	if embed.Title == "" && embed.Description == "" && embed.Image == nil {
+		return nil, ErrNotDescribed
+	}
+
+	return embed, nil
+}
+
+// FetchImage downloads the image of an embed.
+func (u *Unfurler) FetchImage(ctx context.Context, rawURL string) ([]byte, error) {
+	resp, err := u.get(ctx, rawURL, "image/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, errors.New("not an image")
+	}
+	if resp.ContentLength > maxImageSize {
+		return nil, errors.New("the image is too large")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageSize {
+		return nil, errors.New("the image is too large")
+	}
+
+	return data, nil
+}
+
+func (u *Unfurler) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("unsupported url %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+func (u *Unfurler) fetchOEmbed(ctx context.Context, endpoint string) (*oEmbed, error) {
+	resp, err := u.get(ctx, endpoint, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var oembed oEmbed
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOEmbedSize)).Decode(&oembed); err != nil {
+		return nil, err
+	}
+
+	return &oembed, nil
+}
+
+// oEmbed is the JSON format of https://oembed.com, its html is never relayed.
+type oEmbed struct {
+	Type            string    `json:"type"`
+	Title           string    `json:"title"`
+	AuthorName      string    `json:"author_name"`
+	ProviderName    string    `json:"provider_name"`
+	URL             string    `json:"url"`
+	Width           dimension `json:"width"`
+	Height          dimension `json:"height"`
+	ThumbnailURL    string    `json:"thumbnail_url"`
+	ThumbnailWidth  dimension `json:"thumbnail_width"`
+	ThumbnailHeight dimension `json:"thumbnail_height"`
+}
+
+// apply fills what the OpenGraph tags left out.
+func (o *oEmbed) apply(embed *types.Embed, base *url.URL) {
+	embed.Title = cmp.Or(embed.Title, truncate(o.Title, maxTitleLength))
+	embed.SiteName = cmp.Or(embed.SiteName, truncate(o.ProviderName, maxTitleLength))
+	embed.Author = truncate(o.AuthorName, maxTitleLength)
+	if o.Type == "video" {
+		embed.Type = types.EmbedVideo
+	}
+
+	if embed.Image != nil {
+		return
+	}
+	if o.Type == "photo" {
+		if image := resolve(base, o.URL); image != "" {
+			embed.Image = &types.EmbedMedia{URL: image, Width: int(o.Width), Height: int(o.Height)}
+			return
+		}
+	}
+	if image := resolve(base, o.ThumbnailURL); image != "" {
+		embed.Image = &types.EmbedMedia{URL: image, Width: int(o.ThumbnailWidth), Height: int(o.ThumbnailHeight)}
+	}
+}
+
+// dimension accepts numbers as well as strings, providers use both.
+type dimension int
+
+func (d *dimension) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+	if err != nil {
+		*d = 0
+		return nil
+	}
+	*d = dimension(n)
+
+	return nil
+}
+
+type pageMeta struct {
+	title string
+	// properties holds the meta tags by property or name, the first one of each wins.
+	properties map[string]string
+	oEmbed     string
+}
+
+func (m *pageMeta) get(key string) string {
+	return m.properties[key]
+}
+
+// parseHead reads the metadata of a page, it stops at the body.
+func parseHead(body io.Reader, contentType string) (*pageMeta, error) {
+	reader, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &pageMeta{properties: make(map[string]string)}
+	tokenizer := html.NewTokenizer(reader)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// Pages cut by the size limit end here too.
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return meta, nil
+			}
+			return nil, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Meta:
+				key := strings.ToLower(cmp.Or(attr(token, "property"), attr(token, "name")))
+				content := strings.TrimSpace(attr(token, "content"))
+				if _, ok := meta.properties[key]; key != "" && content != "" && !ok {
+					meta.properties[key] = content
+				}
+			case atom.Link:
+				if meta.oEmbed == "" && strings.Contains(strings.ToLower(attr(token, "rel")), "alternate") &&
+					strings.EqualFold(attr(token, "type"), "application/json+oembed") {
+					meta.oEmbed = attr(token, "href")
+				}
+			case atom.Title:
+				inTitle = true
+			case atom.Body:
+				return meta, nil
+			}
+		case html.TextToken:
+			if inTitle && meta.title == "" {
+				meta.title = strings.TrimSpace(tokenizer.Token().Data)
+			}
+		case html.EndTagToken:
+			switch tokenizer.Token().DataAtom {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return meta, nil
+			}
+		}
+	}
+}
+
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// resolve makes ref absolute, only http(s) URLs are kept.
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	resolved, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+
+	return resolved.String()
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return max(n, 0)
+}
+
+// truncate cuts s to at most n characters, collapsing its whitespace.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
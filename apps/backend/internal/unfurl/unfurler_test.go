@@ -0,0 +1,145 @@
+package unfurl
+
+import (
+	"backend/internal/safehttp"
+	"backend/internal/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stubServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!doctype html><html><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="An  article &amp; more">
+			<meta property="og:site_name" content="Stub">
+			<meta name="description" content="What the article is about.">
+			<meta property="og:image" content="/cover.png">
+			<meta name="theme-color" content="#ff0000">
+			<link rel="alternate" type="application/json+oembed" href="/oembed?url=article">
+		</head><body><meta property="og:title" content="Ignored"></body></html>`)
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"type":"rich","title":"oEmbed title","author_name":"Someone","provider_name":"Provider","html":"<script></script>"}`)
+	})
+	mux.HandleFunc("/cover.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><script>%s</script><meta property="og:title" content="Too far"></head></html>`, strings.Repeat("x", maxPageSize))
+	})
+	mux.HandleFunc("/file.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestUnfurl(t *testing.T) {
+	srv := stubServer(t)
+	unfurler := NewUnfurler(srv.Client())
+
+	embed, err := unfurler.Unfurl(context.Background(), srv.URL+"/redirect")
+	if err != nil {
+		t.Fatalf("Unfurl() failed: %v", err)
+	}
+
+	want := types.Embed{
+		URL:         srv.URL + "/redirect",
+		Type:        types.EmbedLink,
+		SiteName:    "Stub",
+		Title:       "An article & more",
+		Description: "What the article is about.",
+		Author:      "Someone",
+		Color:       "#ff0000",
+		Image:       &types.EmbedMedia{URL: srv.URL + "/cover.png"},
+	}
+	got, _ := json.Marshal(embed)
+	expected, _ := json.Marshal(want)
+	if string(got) != string(expected) {
+		t.Fatalf("unexpected embed:\n got %s\nwant %s", got, expected)
+	}
+
+	image, err := unfurler.FetchImage(context.Background(), embed.Image.URL)
+	if err != nil || !strings.HasPrefix(string(image), "\x89PNG") {
+		t.Fatalf("FetchImage() = %q, %v", image, err)
+	}
+}
+
+func TestUnfurlImage(t *testing.T) {
+	srv := stubServer(t)
+
+	embed, err := NewUnfurler(srv.Client()).Unfurl(context.Background(), srv.URL+"/cover.png")
+	if err != nil {
+		t.Fatalf("Unfurl() failed: %v", err)
+	}
+	if embed.Type != types.EmbedImage || embed.Image == nil || embed.Image.URL != srv.URL+"/cover.png" {
+		t.Fatalf("expected the image itself, got %+v", embed)
+	}
+}
+
+func TestUnfurlNothingToPreview(t *testing.T) {
+	srv := stubServer(t)
+	unfurler := NewUnfurler(srv.Client())
+
+	for _, path := range []string{"/huge", "/file.zip"} {
+		if _, err := unfurler.Unfurl(context.Background(), srv.URL+path); !errors.Is(err, ErrNotDescribed) {
+			t.Errorf("Unfurl(%s) = %v, want ErrNotDescribed", path, err)
+		}
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("expected other schemes to be refused")
+	}
+}
+
+func TestUnfurlRefusesPrivateAddresses(t *testing.T) {
+	srv := stubServer(t)
+
+	_, err := NewUnfurler(safehttp.NewClient(time.Second)).Unfurl(context.Background(), srv.URL+"/article")
+	if !errors.Is(err, safehttp.ErrForbiddenAddress) {
+		t.Fatalf("expected ErrForbiddenAddress, got %v", err)
+	}
+}
+
+func TestExtractURLs(t *testing.T) {
+	content := json.RawMessage(`{"type":"doc","content":[
+		{"type":"paragraph","content":[
+			{"type":"text","text":"see https://example.com/a, and (https://example.com/b)."},
+			{"type":"text","text":"here","marks":[{"type":"link","attrs":{"href":"https://example.com/c"}}]},
+			{"type":"text","text":"https://example.com/code","marks":[{"type":"code"}]},
+			{"type":"text","text":"again https://example.com/a"}
+		]},
+		{"type":"codeBlock","content":[{"type":"text","text":"https://example.com/block"}]},
+		{"type":"paragraph","content":[{"type":"text","text":"javascript:alert(1) http:// https://example.com/d"}]}
+	]}`)
+
+	got := ExtractURLs(content, 3)
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ExtractURLs() = %v, want %v", got, want)
+	}
+
+	if got := ExtractURLs(content, 10); len(got) != 4 || got[3] != "https://example.com/d" {
+		t.Fatalf("ExtractURLs() = %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package unfurl
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// node is a node of the editor document format.
+type node struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Content []node `json:"content"`
+	Marks   []struct {
+		Type  string `json:"type"`
+		Attrs struct {
+			Href string `json:"href"`
+		} `json:"attrs"`
+	} `json:"marks"`
+}
+
+// ExtractURLs returns the distinct http(s) URLs of a message content, in order and at most limit.
+// URLs in code are left out.
+func ExtractURLs(content json.RawMessage, limit int) []string {
+	var doc node
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil
+	}
+
+	var urls []string
+	add := func(raw string) {
+		raw = strings.TrimRight(raw, ".,;:!?)]}'\"")
+		if parsed, err := url.Parse(raw); err != nil || parsed.Host == "" {
+			return
+		}
+		if len(urls) < limit && !slices.Contains(urls, raw) {
+			urls = append(urls, raw)
+		}
+	}
+
+	var walk func(n node)
+	walk = func(n node) {
+		if n.Type == "codeBlock" {
+			return
+		}
+
+		for _, mark := range n.Marks {
+			if mark.Type == "code" {
+				return
+			}
+			if href := mark.Attrs.Href; mark.Type == "link" && (strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")) {
+				add(href)
+			}
+		}
+		for _, match := range urlPattern.FindAllString(n.Text, -1) {
+			add(match)
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	return urls
+}
@@ -17,6 +17,8 @@ var Validator *validator.Validate
 func New() {
 	Validator = validator.New()
 	Validator.RegisterValidation("emoji_shortcode", validateEmojiShortcode)
+	Validator.RegisterValidation("vanity_code", validateSlug)
+	Validator.RegisterValidation("slug", validateSlug)
 }
 
 func validateEmojiShortcode(fl validator.FieldLevel) bool {
@@ -31,6 +33,13 @@ func validateEmojiShortcode(fl validator.FieldLevel) bool {
 	return regexp.MustCompile(pattern).MatchString(shortcode)
 }
 
+var slugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func validateSlug(fl validator.FieldLevel) bool {
+	// Lowercase letters and digits, words separated by single hyphens.
+	return slugRegex.MatchString(fl.Field().String())
+}
+
 func ParseAndValidate[T any](r *http.Request, body *T) *types.APIError {
 	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
@@ -0,0 +1,273 @@
+package voice
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxMeshParticipants bounds the channels where every participant connects to every other one.
+	MaxMeshParticipants = 16
+	// MaxSFUParticipants bounds the channels relayed by the SFU.
+	MaxSFUParticipants = 99
+)
+
+var (
+	ErrNotInChannel     = errors.New("not in the voice channel")
+	ErrTargetNotInRoom  = errors.New("the member isn't in the voice channel")
+	ErrChannelFull      = errors.New("the voice channel is full")
+	ErrVideoNotAllowed  = errors.New("not allowed to share video")
+	ErrSignalToYourself = errors.New("can't signal yourself")
+	ErrTrackNotAllowed  = errors.New("not allowed to publish this track")
+)
+
+// State is the voice state of a participant. Self flags are set by the participant, server flags
+// by moderators and only moderators can lift them.
+type State struct {
+	UserID     string `json:"user_id"`
+	SelfMute   bool   `json:"self_mute"`
+	SelfDeaf   bool   `json:"self_deaf"`
+	ServerMute bool   `json:"server_mute"`
+	ServerDeaf bool   `json:"server_deaf"`
+	Video      bool   `json:"video"`
+	// Suppressed participants lack the ability to speak in the channel.
+	Suppressed bool `json:"suppressed"`
+	// Tracks are the tracks published on the SFU, mesh channels have none.
+	Tracks   []Track   `json:"tracks"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type TrackKind string
+
+const (
+	TrackAudio TrackKind = "audio"
+	TrackVideo TrackKind = "video"
+)
+
+type Track struct {
+	SID    string    `json:"sid"`
+	Kind   TrackKind `json:"kind"`
+	Source string    `json:"source"`
+}
+
+// Publishing is what a participant may send and receive through the SFU.
+type Publishing struct {
+	Audio     bool
+	Video     bool
+	Subscribe bool
+}
+
+// SelfState is what a participant sets for themselves.
+type SelfState struct {
+	Mute  bool
+	Deaf  bool
+	Video bool
+}
+
+// Abilities are the voice abilities of a participant in the channel.
+type Abilities struct {
+	Speak bool
+	Video bool
+}
+
+// Room holds the participants of a voice channel, it isn't safe for concurrent use.
+type Room struct {
+	limit        int
+	participants map[string]*State
+	abilities    map[string]Abilities
+	// signals numbers the signals of each participant, see OrderSignal.
+	signals map[string]*signalQueue
+}
+
+func NewRoom(limit int) *Room {
+	return &Room{
+		limit:        limit,
+		participants: make(map[string]*State),
+		abilities:    make(map[string]Abilities),
+		signals:      make(map[string]*signalQueue),
+	}
+}
+
+// Join adds a participant, joining again from another device resets their self state.
+func (r *Room) Join(userID string, self SelfState, abilities Abilities, now time.Time) (*State, error) {
+	if self.Video && !abilities.Video {
+		return nil, ErrVideoNotAllowed
+	}
+
+	state, ok := r.participants[userID]
+	if !ok {
+		if len(r.participants) >= r.limit {
+			return nil, ErrChannelFull
+		}
+		state = &State{UserID: userID, JoinedAt: now}
+		r.participants[userID] = state
+	}
+
+	state.Suppressed = !abilities.Speak
+	r.abilities[userID] = abilities
+	// A device joining again starts numbering its signals over.
+	delete(r.signals, userID)
+	applySelf(state, self)
+
+	return state, nil
+}
+
+// Insert adds a participant moved from another channel, keeping what moderators set.
+func (r *Room) Insert(state State, abilities Abilities, now time.Time) (*State, error) {
+	if _, ok := r.participants[state.UserID]; !ok && len(r.participants) >= r.limit {
+		return nil, ErrChannelFull
+	}
+
+	state.Suppressed = !abilities.Speak
+	state.Video = state.Video && abilities.Video
+	state.Tracks = nil
+	state.JoinedAt = now
+	r.participants[state.UserID] = &state
+	r.abilities[state.UserID] = abilities
+	delete(r.signals, state.UserID)
+
+	return &state, nil
+}
+
+// Leave removes a participant, it returns their last state and whether they were in the channel.
+func (r *Room) Leave(userID string) (State, bool) {
+	state, ok := r.participants[userID]
+	if !ok {
+		return State{}, false
+	}
+	delete(r.participants, userID)
+	delete(r.abilities, userID)
+	delete(r.signals, userID)
+
+	return *state, true
+}
+
+func (r *Room) SetSelf(userID string, self SelfState, canVideo bool) (*State, error) {
+	state, ok := r.participants[userID]
+	if !ok {
+		return nil, ErrNotInChannel
+	}
+	if self.Video && !canVideo {
+		return nil, ErrVideoNotAllowed
+	}
+
+	abilities := r.abilities[userID]
+	abilities.Video = canVideo
+	r.abilities[userID] = abilities
+	applySelf(state, self)
+
+	return state, nil
+}
+
+// Moderate sets the server flags of a participant, nil leaves a flag as it is.
+func (r *Room) Moderate(targetID string, mute, deaf *bool) (*State, error) {
+	state, ok := r.participants[targetID]
+	if !ok {
+		return nil, ErrTargetNotInRoom
+	}
+
+	if mute != nil {
+		state.ServerMute = *mute
+	}
+	if deaf != nil {
+		state.ServerDeaf = *deaf
+	}
+
+	return state, nil
+}
+
+// CheckSignal fails unless both ends of a WebRTC negotiation are in the channel.
+func (r *Room) CheckSignal(fromID, toID string) error {
+	if _, ok := r.participants[fromID]; !ok {
+		return ErrNotInChannel
+	}
+	if fromID == toID {
+		return ErrSignalToYourself
+	}
+	if _, ok := r.participants[toID]; !ok {
+		return ErrTargetNotInRoom
+	}
+
+	return nil
+}
+
+// PublishTrack records a track published on the SFU, it fails for tracks the participant isn't
+// allowed to publish anymore so that they get muted.
+func (r *Room) PublishTrack(userID string, track Track) (*State, error) {
+	state, ok := r.participants[userID]
+	if !ok {
+		return nil, ErrNotInChannel
+	}
+
+	state.Tracks = slices.DeleteFunc(state.Tracks, func(t Track) bool { return t.SID == track.SID })
+	state.Tracks = append(state.Tracks, track)
+
+	publishing := r.Publishing(userID)
+	if (track.Kind == TrackAudio && !publishing.Audio) || (track.Kind == TrackVideo && !publishing.Video) {
+		return state, ErrTrackNotAllowed
+	}
+
+	return state, nil
+}
+
+func (r *Room) UnpublishTrack(userID, sid string) (*State, bool) {
+	state, ok := r.participants[userID]
+	if !ok {
+		return nil, false
+	}
+
+	before := len(state.Tracks)
+	state.Tracks = slices.DeleteFunc(state.Tracks, func(t Track) bool { return t.SID == sid })
+
+	return state, len(state.Tracks) != before
+}
+
+// Publishing derives what the SFU lets a participant do from their abilities and server flags.
+func (r *Room) Publishing(userID string) Publishing {
+	state, ok := r.participants[userID]
+	if !ok {
+		return Publishing{}
+	}
+
+	return Publishing{
+		Audio:     !state.Suppressed && !state.ServerMute,
+		Video:     r.abilities[userID].Video,
+		Subscribe: !state.ServerDeaf,
+	}
+}
+
+func (r *Room) Has(userID string) bool {
+	_, ok := r.participants[userID]
+	return ok
+}
+
+func (r *Room) Len() int {
+	return len(r.participants)
+}
+
+// States returns the participants in the order they joined.
+func (r *Room) States() []State {
+	states := make([]State, 0, len(r.participants))
+	for _, state := range r.participants {
+		copied := *state
+		copied.Tracks = slices.Clone(state.Tracks)
+		states = append(states, copied)
+	}
+	slices.SortFunc(states, func(a, b State) int {
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.UserID, b.UserID)
+	})
+
+	return states
+}
+
+// applySelf sets the self flags, deafened participants can't be heard either.
+func applySelf(state *State, self SelfState) {
+	state.SelfDeaf = self.Deaf
+	state.SelfMute = self.Mute || self.Deaf
+	state.Video = self.Video
+}
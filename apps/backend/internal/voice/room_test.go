@@ -0,0 +1,151 @@
+package voice
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJoinAndLeave(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+	now := time.Now()
+
+	if _, err := room.Join("b", SelfState{}, Abilities{Speak: true}, now.Add(time.Second)); err != nil {
+		t.Fatalf("Join() failed: %v", err)
+	}
+	state, err := room.Join("a", SelfState{Deaf: true}, Abilities{}, now)
+	if err != nil {
+		t.Fatalf("Join() failed: %v", err)
+	}
+	if !state.SelfMute || !state.SelfDeaf || !state.Suppressed {
+		t.Fatalf("expected a deafened, muted and suppressed participant, got %+v", state)
+	}
+
+	if states := room.States(); len(states) != 2 || states[0].UserID != "a" || states[1].UserID != "b" {
+		t.Fatalf("expected the participants in the order they joined, got %+v", states)
+	}
+
+	if _, ok := room.Leave("a"); !ok {
+		t.Fatal("expected a to leave")
+	}
+	if _, ok := room.Leave("a"); ok {
+		t.Fatal("expected a to have left already")
+	}
+	if room.Len() != 1 {
+		t.Fatalf("expected 1 participant, got %d", room.Len())
+	}
+}
+
+func TestJoinLimits(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+
+	if _, err := room.Join("a", SelfState{Video: true}, Abilities{Speak: true}, time.Now()); !errors.Is(err, ErrVideoNotAllowed) {
+		t.Fatalf("expected ErrVideoNotAllowed, got %v", err)
+	}
+
+	for i := range MaxMeshParticipants {
+		if _, err := room.Join(fmt.Sprint(i), SelfState{}, Abilities{}, time.Now()); err != nil {
+			t.Fatalf("Join() failed: %v", err)
+		}
+	}
+	if _, err := room.Join("late", SelfState{}, Abilities{}, time.Now()); !errors.Is(err, ErrChannelFull) {
+		t.Fatalf("expected ErrChannelFull, got %v", err)
+	}
+	// Joining again from another device isn't a new participant.
+	if _, err := room.Join("0", SelfState{Mute: true}, Abilities{}, time.Now()); err != nil {
+		t.Fatalf("Join() failed: %v", err)
+	}
+}
+
+func TestModerationOutlivesSelfState(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+	room.Join("a", SelfState{}, Abilities{Speak: true, Video: true}, time.Now())
+
+	mute := true
+	if _, err := room.Moderate("a", &mute, nil); err != nil {
+		t.Fatalf("Moderate() failed: %v", err)
+	}
+	state, err := room.SetSelf("a", SelfState{Video: true}, true)
+	if err != nil {
+		t.Fatalf("SetSelf() failed: %v", err)
+	}
+	if !state.ServerMute || state.ServerDeaf || state.SelfMute || !state.Video {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	if _, err := room.Moderate("b", &mute, nil); !errors.Is(err, ErrTargetNotInRoom) {
+		t.Fatalf("expected ErrTargetNotInRoom, got %v", err)
+	}
+	if _, err := room.SetSelf("b", SelfState{}, false); !errors.Is(err, ErrNotInChannel) {
+		t.Fatalf("expected ErrNotInChannel, got %v", err)
+	}
+
+	// Moving keeps what moderators set, but not what the destination doesn't allow.
+	left, _ := room.Leave("a")
+	dest := NewRoom(MaxMeshParticipants)
+	moved, err := dest.Insert(left, Abilities{Speak: true}, time.Now())
+	if err != nil {
+		t.Fatalf("Insert() failed: %v", err)
+	}
+	if !moved.ServerMute || moved.Video || moved.Suppressed {
+		t.Fatalf("unexpected moved state %+v", moved)
+	}
+}
+
+func TestCheckSignal(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+	room.Join("a", SelfState{}, Abilities{}, time.Now())
+	room.Join("b", SelfState{}, Abilities{}, time.Now())
+
+	cases := []struct {
+		from, to string
+		want     error
+	}{
+		{"a", "b", nil},
+		{"b", "a", nil},
+		{"a", "a", ErrSignalToYourself},
+		{"c", "a", ErrNotInChannel},
+		{"a", "c", ErrTargetNotInRoom},
+	}
+	for _, c := range cases {
+		if err := room.CheckSignal(c.from, c.to); !errors.Is(err, c.want) {
+			t.Errorf("CheckSignal(%s, %s) = %v, want %v", c.from, c.to, err, c.want)
+		}
+	}
+}
+
+func TestTracks(t *testing.T) {
+	room := NewRoom(MaxSFUParticipants)
+	room.Join("a", SelfState{}, Abilities{Speak: true}, time.Now())
+
+	mic := Track{SID: "TR_mic", Kind: TrackAudio, Source: "microphone"}
+	if _, err := room.PublishTrack("a", mic); err != nil {
+		t.Fatalf("PublishTrack() failed: %v", err)
+	}
+	if _, err := room.PublishTrack("a", Track{SID: "TR_cam", Kind: TrackVideo}); !errors.Is(err, ErrTrackNotAllowed) {
+		t.Fatalf("expected ErrTrackNotAllowed without the video ability, got %v", err)
+	}
+
+	mute, deaf := true, true
+	room.Moderate("a", &mute, &deaf)
+	if got := room.Publishing("a"); got != (Publishing{}) {
+		t.Fatalf("expected nothing allowed once server muted and deafened, got %+v", got)
+	}
+	if _, err := room.PublishTrack("a", mic); !errors.Is(err, ErrTrackNotAllowed) {
+		t.Fatalf("expected ErrTrackNotAllowed once server muted, got %v", err)
+	}
+
+	if state, ok := room.UnpublishTrack("a", "TR_cam"); !ok || len(state.Tracks) != 1 || state.Tracks[0] != mic {
+		t.Fatalf("unexpected tracks after unpublishing: %+v", state)
+	}
+	if _, ok := room.UnpublishTrack("a", "TR_cam"); ok {
+		t.Fatal("expected the track to be gone already")
+	}
+
+	left, _ := room.Leave("a")
+	moved, _ := NewRoom(MaxSFUParticipants).Insert(left, Abilities{Speak: true}, time.Now())
+	if len(moved.Tracks) != 0 {
+		t.Fatalf("expected tracks to stay in the previous channel, got %+v", moved.Tracks)
+	}
+}
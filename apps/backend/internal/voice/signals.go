@@ -0,0 +1,65 @@
+package voice
+
+import (
+	"maps"
+	"slices"
+)
+
+// maxPendingSignals bounds the signals held back while an earlier one is missing. A signal refused
+// before reaching the channel never arrives, the ones after it are then relayed anyway.
+const maxPendingSignals = 32
+
+// Signal is an SDP offer or answer, or an ICE candidate, sent to another participant. Seq numbers
+// the signals of a participant from 1 since they joined the channel, 0 leaves a signal unordered.
+type Signal struct {
+	Seq     uint64
+	ToID    string
+	Type    string
+	Payload string
+}
+
+// signalQueue holds back the signals of a participant received before an earlier one.
+type signalQueue struct {
+	next    uint64
+	pending map[uint64]Signal
+}
+
+// OrderSignal checks a signal and returns the signals of its sender which can be relayed, in the
+// order they were sent. Sockets handle their messages in parallel so signals can reach the channel
+// swapped, while a negotiation only works with them in order.
+func (r *Room) OrderSignal(fromID string, signal Signal) ([]Signal, error) {
+	if err := r.CheckSignal(fromID, signal.ToID); err != nil {
+		return nil, err
+	}
+	if signal.Seq == 0 {
+		return []Signal{signal}, nil
+	}
+
+	queue, ok := r.signals[fromID]
+	if !ok {
+		queue = &signalQueue{next: 1, pending: make(map[uint64]Signal)}
+		r.signals[fromID] = queue
+	}
+	// Already relayed, or given up on.
+	if signal.Seq < queue.next {
+		return nil, nil
+	}
+	queue.pending[signal.Seq] = signal
+
+	if _, ok := queue.pending[queue.next]; !ok && len(queue.pending) > maxPendingSignals {
+		queue.next = slices.Min(slices.Collect(maps.Keys(queue.pending)))
+	}
+
+	var ready []Signal
+	for {
+		next, ok := queue.pending[queue.next]
+		if !ok {
+			break
+		}
+		delete(queue.pending, queue.next)
+		ready = append(ready, next)
+		queue.next++
+	}
+
+	return ready, nil
+}
@@ -0,0 +1,75 @@
+package voice
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func seqs(signals []Signal) []uint64 {
+	var seqs []uint64
+	for _, signal := range signals {
+		seqs = append(seqs, signal.Seq)
+	}
+
+	return seqs
+}
+
+func TestOrderSignal(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+	room.Join("a", SelfState{}, Abilities{}, time.Now())
+	room.Join("b", SelfState{}, Abilities{}, time.Now())
+
+	steps := []struct {
+		seq  uint64
+		want []uint64
+	}{
+		{2, nil},
+		{3, nil},
+		{1, []uint64{1, 2, 3}},
+		{2, nil},
+		{0, []uint64{0}},
+		{4, []uint64{4}},
+	}
+	for _, step := range steps {
+		ready, err := room.OrderSignal("a", Signal{Seq: step.seq, ToID: "b", Type: "candidate"})
+		if err != nil {
+			t.Fatalf("OrderSignal(%d) failed: %v", step.seq, err)
+		}
+		if got := seqs(ready); !slices.Equal(got, step.want) {
+			t.Fatalf("OrderSignal(%d) relayed %v, want %v", step.seq, got, step.want)
+		}
+	}
+
+	if _, err := room.OrderSignal("a", Signal{Seq: 5, ToID: "a"}); err != ErrSignalToYourself {
+		t.Fatalf("expected the signal to be checked, got %v", err)
+	}
+
+	// Joining again starts over.
+	room.Join("a", SelfState{}, Abilities{}, time.Now())
+	if ready, _ := room.OrderSignal("a", Signal{Seq: 1, ToID: "b"}); len(ready) != 1 {
+		t.Fatalf("expected the numbering to start over, got %v", seqs(ready))
+	}
+}
+
+func TestOrderSignalSkipsMissing(t *testing.T) {
+	room := NewRoom(MaxMeshParticipants)
+	room.Join("a", SelfState{}, Abilities{}, time.Now())
+	room.Join("b", SelfState{}, Abilities{}, time.Now())
+
+	// The first signal never arrives.
+	var ready []Signal
+	for seq := uint64(2); seq <= maxPendingSignals+2; seq++ {
+		var err error
+		if ready, err = room.OrderSignal("a", Signal{Seq: seq, ToID: "b"}); err != nil {
+			t.Fatalf("OrderSignal(%d) failed: %v", seq, err)
+		}
+	}
+
+	if len(ready) != maxPendingSignals+1 || ready[0].Seq != 2 {
+		t.Fatalf("expected the held back signals to be relayed in order, got %v", seqs(ready))
+	}
+	if late, _ := room.OrderSignal("a", Signal{Seq: 1, ToID: "b"}); len(late) != 0 {
+		t.Fatalf("expected the late signal to be dropped, got %v", seqs(late))
+	}
+}
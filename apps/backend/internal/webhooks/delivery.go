@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Sign computes the value of the X-Kyob-Signature header. Receivers recompute it over
+// "<timestamp>.<body>" with the webhook secret and compare in constant time.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after the given number of failed attempts.
+func Backoff(attempts int32) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+	if attempts > 16 {
+		return maxBackoff
+	}
+
+	return min(baseBackoff<<(attempts-1), maxBackoff)
+}
+
+// Send POSTs a signed payload and returns the response status code. Anything but a 2xx is an error.
+func Send(ctx context.Context, client *http.Client, url, secret, deliveryID, event string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kyob-Webhooks/1.0")
+	req.Header.Set("X-Kyob-Event", event)
+	req.Header.Set("X-Kyob-Delivery", deliveryID)
+	req.Header.Set("X-Kyob-Timestamp", timestamp)
+	req.Header.Set("X-Kyob-Signature", Sign(secret, timestamp, body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
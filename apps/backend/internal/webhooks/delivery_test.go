@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	body := []byte(`{"event":"message.create"}`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp := r.Header.Get("X-Kyob-Timestamp")
+		if r.Header.Get("X-Kyob-Signature") != Sign("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	status, err := Send(context.Background(), srv.Client(), srv.URL, "secret", "delivery", "message.create", body)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("expected a signed delivery to succeed, got %d %v", status, err)
+	}
+
+	status, err = Send(context.Background(), srv.Client(), srv.URL, "other", "delivery", "message.create", body)
+	if err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong secret to fail, got %d %v", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != baseBackoff || Backoff(2) != 2*baseBackoff || Backoff(3) != 4*baseBackoff {
+		t.Fatalf("expected the backoff to double, got %v %v %v", Backoff(1), Backoff(2), Backoff(3))
+	}
+
+	if Backoff(40) != maxBackoff {
+		t.Fatalf("expected the backoff to be capped, got %v", Backoff(40))
+	}
+
+	var total time.Duration
+	for i := int32(1); i < maxAttempts; i++ {
+		total += Backoff(i)
+	}
+	if total > 24*time.Hour {
+		t.Fatalf("expected retries to give up within a day, got %v", total)
+	}
+}
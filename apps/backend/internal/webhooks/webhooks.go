@@ -0,0 +1,146 @@
+package webhooks
+
+import (
+	db "backend/db/gen_queries"
+	"backend/internal/database"
+	"backend/internal/safehttp"
+	"backend/internal/types"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	pollInterval = 2 * time.Second
+	batchSize    = 50
+	sendTimeout  = 10 * time.Second
+)
+
+type Service interface {
+	// Fire queues the event for every active webhook of the server subscribed to it.
+	// It returns immediately, deliveries are made by the background worker.
+	Fire(serverID string, event types.WebhookEvent, data any)
+}
+
+type service struct {
+	db     database.Service
+	client *http.Client
+}
+
+func New(db database.Service) Service {
+	s := &service{
+		db:     db,
+		client: safehttp.NewClient(sendTimeout),
+	}
+
+	// Redirects are reported as failed deliveries instead of being followed.
+	s.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *service) Fire(serverID string, event types.WebhookEvent, data any) {
+	if serverID == "" || serverID == "global" {
+		return
+	}
+
+	createdAt := time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		webhookIDs, err := s.db.GetWebhooksForEvent(ctx, serverID, event)
+		if err != nil {
+			slog.Error("failed to get webhooks", "server_id", serverID, "err", err)
+			return
+		}
+		if len(webhookIDs) == 0 {
+			return
+		}
+
+		payload, err := json.Marshal(types.WebhookPayload{
+			Event:     event,
+			ServerID:  serverID,
+			CreatedAt: createdAt,
+			Data:      data,
+		})
+		if err != nil {
+			slog.Error("failed to marshal webhook payload", "event", event, "err", err)
+			return
+		}
+
+		for _, webhookID := range webhookIDs {
+			if err := s.db.CreateWebhookDelivery(ctx, webhookID, event, payload); err != nil {
+				slog.Error("failed to queue webhook delivery", "webhook_id", webhookID, "err", err)
+			}
+		}
+	}()
+}
+
+func (s *service) run() {
+	poll := time.NewTicker(pollInterval)
+	prune := time.NewTicker(time.Hour)
+
+	for {
+		select {
+		case <-poll.C:
+			s.deliverDue()
+		case <-prune.C:
+			if err := s.db.PruneWebhookDeliveries(context.Background()); err != nil {
+				slog.Error("failed to prune webhook deliveries", "err", err)
+			}
+		}
+	}
+}
+
+func (s *service) deliverDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deliveries, err := s.db.ClaimWebhookDeliveries(ctx, batchSize)
+	if err != nil {
+		slog.Error("failed to claim webhook deliveries", "err", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.attempt(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *service) attempt(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow) {
+	statusCode, err := Send(ctx, s.client, delivery.Url, delivery.Secret, delivery.ID, delivery.Event, delivery.Payload)
+
+	attempts := delivery.Attempts + 1
+	status := "success"
+	nextAttemptAt := time.Now()
+	var errMessage string
+
+	if err != nil {
+		errMessage = err.Error()
+		status = "pending"
+		nextAttemptAt = nextAttemptAt.Add(Backoff(attempts))
+
+		if attempts >= maxAttempts {
+			status = "failed"
+		}
+	}
+
+	if err := s.db.RecordWebhookAttempt(ctx, delivery.ID, status, statusCode, errMessage, nextAttemptAt); err != nil {
+		slog.Error("failed to record webhook attempt", "delivery_id", delivery.ID, "err", err)
+	}
+}
@@ -13,6 +13,7 @@ export const ABILITIES = {
   ATTACH_FILES: 'ATTACH_FILES',
   ADD_REACTIONS: 'ADD_REACTIONS',
   USE_PERSONAL_EMOJIS: 'USE_PERSONAL_EMOJIS',
+  MANAGE_EMOJIS: 'MANAGE_EMOJIS',
   MENTION_EVERYONE: 'MENTION_EVERYONE',
   MANAGE_MESSAGES: 'MANAGE_MESSAGES',
   CONNECT: 'CONNECT',
@@ -104,6 +105,11 @@ export const ABILITIES_CONTENT = [
     label: 'Use Personal Emojis',
     description: 'Allows members to use their own emojis.'
   },
+  {
+    code: ABILITIES.MANAGE_EMOJIS,
+    label: 'Manage Emojis and Stickers',
+    description: 'Allows members to add, rename and remove the emojis and stickers of this server.'
+  },
   {
     code: ABILITIES.MENTION_EVERYONE,
     label: 'Mention @everyone and all Roles',
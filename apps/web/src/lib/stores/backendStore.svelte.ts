@@ -2,13 +2,16 @@ import type { APIError } from '$lib/types/errors';
 import { errAsync, okAsync, ResultAsync } from 'neverthrow';
 import ky, { type Input, type Options } from 'ky';
 import type {
+	BlockedUser,
 	Category,
 	Channel,
 	Emoji,
 	Friend,
+	FriendRequests,
 	LastState,
 	Member,
 	Message,
+	NotificationPage,
 	Role,
 	Server,
 	ServerInformations,
@@ -445,6 +448,57 @@ export class BackendStore {
 		return this.makeRequest<void>('friends', { method: 'delete', json: body });
 	}
 
+	getFriendRequests(): ResultAsync<FriendRequests, APIError> {
+		return this.makeRequest<FriendRequests>('friends/requests');
+	}
+
+	declineFriendRequest(friendshipID: string): ResultAsync<void, APIError> {
+		return this.makeRequest<void>(`friends/requests/${friendshipID}/decline`, { method: 'post' });
+	}
+
+	cancelFriendRequest(friendshipID: string): ResultAsync<void, APIError> {
+		return this.makeRequest<void>(`friends/requests/${friendshipID}`, { method: 'delete' });
+	}
+
+	getNotifications(params: {
+		before?: string;
+		server_id?: string;
+		unread?: boolean;
+	}): ResultAsync<NotificationPage, APIError> {
+		const searchParams = new URLSearchParams();
+		if (params.before) searchParams.set('before', params.before);
+		if (params.server_id) searchParams.set('server_id', params.server_id);
+		if (params.unread) searchParams.set('unread', 'true');
+		return this.makeRequest<NotificationPage>(`notifications?${searchParams}`);
+	}
+
+	readNotifications(ids: string[]): ResultAsync<void, APIError> {
+		return this.makeRequest<void>('notifications/read', { method: 'post', json: { ids } });
+	}
+
+	readAllNotifications(serverID?: string): ResultAsync<void, APIError> {
+		return this.makeRequest<void>('notifications/read_all', {
+			method: 'post',
+			json: { server_id: serverID ?? '' }
+		});
+	}
+
+	sendInvite(inviteID: string, users: string[]): ResultAsync<void, APIError> {
+		return this.makeRequest<void>(`invites/${inviteID}/send`, { method: 'post', json: { users } });
+	}
+
+	getBlockedUsers(): ResultAsync<BlockedUser[], APIError> {
+		return this.makeRequest<BlockedUser[]>('friends/blocks');
+	}
+
+	blockUser(userID: string): ResultAsync<void, APIError> {
+		return this.makeRequest<void>(`friends/blocks/${userID}`, { method: 'put' });
+	}
+
+	unblockUser(userID: string): ResultAsync<void, APIError> {
+		return this.makeRequest<void>(`friends/blocks/${userID}`, { method: 'delete' });
+	}
+
 	deleteAccount(): ResultAsync<void, APIError> {
 		return this.makeRequest<void>('users', { method: 'delete' });
 	}
@@ -1,4 +1,11 @@
-import type { Channel, Message } from '$lib/types/types';
+import type {
+	Channel,
+	ChannelKeyState,
+	KanbanBoard,
+	KanbanCard,
+	KanbanColumn,
+	Message
+} from '$lib/types/types';
 import { logErr } from 'utils/print';
 import { backend } from './backendStore.svelte';
 import { categoryStore } from './categoryStore.svelte';
@@ -25,6 +32,56 @@ class ChannelStore {
 		>
 	>({});
 
+	kanbanBoards = $state<Record<string, KanbanBoard>>({});
+
+	// The group key of each encrypted channel, messages can't be sent while a rekey is required.
+	channelKeys = $state<Record<string, ChannelKeyState>>({});
+
+	setChannelKey(channelID: string, state: Partial<ChannelKeyState>): void {
+		const previous = this.channelKeys[channelID] ?? { rekey_required: false };
+		this.channelKeys[channelID] = { ...previous, ...state };
+	}
+
+	setKanbanColumn(channelID: string, column: KanbanColumn, deleted = false): void {
+		const board = this.kanbanBoards[channelID];
+		if (!board) return;
+
+		const previous = board.columns.find((c) => c.id === column.id);
+		let columns = board.columns.filter((c) => c.id !== column.id);
+		if (previous) {
+			for (const c of columns) if (c.position > previous.position) c.position--;
+		}
+		if (deleted) {
+			board.cards = board.cards.filter((card) => card.column_id !== column.id);
+		} else {
+			for (const c of columns) if (c.position >= column.position) c.position++;
+			columns = [...columns, { ...previous, ...column }];
+		}
+
+		board.columns = columns.toSorted((a, b) => a.position - b.position);
+	}
+
+	setKanbanCard(channelID: string, card: KanbanCard, deleted = false): void {
+		const board = this.kanbanBoards[channelID];
+		if (!board) return;
+
+		const previous = board.cards.find((c) => c.id === card.id);
+		let cards = board.cards.filter((c) => c.id !== card.id);
+		if (previous) {
+			for (const c of cards) {
+				if (c.column_id === previous.column_id && c.position > previous.position) c.position--;
+			}
+		}
+		if (!deleted) {
+			for (const c of cards) {
+				if (c.column_id === card.column_id && c.position >= card.position) c.position++;
+			}
+			cards = [...cards, { ...previous, ...card }];
+		}
+
+		board.cards = cards.toSorted((a, b) => a.position - b.position);
+	}
+
 	getFirstChannel(serverID: string) {
 		const server = serverStore.getServer(serverID);
 		const userID = userStore.user?.id;
@@ -1,5 +1,5 @@
 import type { EditServerType } from '$lib/types/schemas';
-import type { Channel, Invite, Member, Role, Server } from '$lib/types/types';
+import type { Channel, Invite, Member, Role, Server, VoiceSession, VoiceState } from '$lib/types/types';
 import { createId } from '@paralleldrive/cuid2';
 import { backend } from './backendStore.svelte';
 import { logErr } from 'utils/print';
@@ -19,6 +19,10 @@ export class ServerStore {
 	servers = $state<Record<string, Server>>({});
 	memberCount = $state<number>(0);
 	cached = $state<Record<string, CacheEntry>>({});
+	// Participants of the voice channels, by channel.
+	voiceStates = $state<Record<string, VoiceState[]>>({});
+	// Where to connect when the voice channels go through the SFU.
+	voiceSession = $state<VoiceSession>();
 	abilities = $derived.by(() => {
 		const allAbilities: Record<string, Abilities[]> = {};
 
@@ -360,6 +364,15 @@ export class ServerStore {
 		if (avatar) server.avatar = avatar;
 		if (banner) server.banner = banner;
 	}
+
+	setVoiceStates(channelID: string, states: VoiceState[]): void {
+		if (states.length === 0) {
+			delete this.voiceStates[channelID];
+			return;
+		}
+
+		this.voiceStates[channelID] = states;
+	}
 }
 
 export const serverStore = new ServerStore();
@@ -1,10 +1,24 @@
-import type { Channel, Emoji, Friend, LastState, User } from '$lib/types/types';
+import type {
+  Channel,
+  Emoji,
+  Friend,
+  GroupDM,
+  LastState,
+  Notification,
+  ServerEmoji,
+  User
+} from '$lib/types/types';
 import { serverStore } from './serverStore.svelte';
 
 export class UserStore {
   user = $state<User>();
   friends = $state<Friend[]>([]);
+  groupDMs = $state<GroupDM[]>([]);
+  blockedIDs = $state<string[]>([]);
+  notifications = $state<Notification[]>([]);
+  unreadNotifications = $state(0);
   emojis = $state<Emoji[]>([]);
+  serverEmojis = $state<ServerEmoji[]>([]);
   pinned_channels = $state<Channel[]>([]);
   mute = $state(false);
   deafen = $state(false);
@@ -15,6 +29,20 @@ export class UserStore {
     if (friend) friend.status = status;
   }
 
+  getServerEmojis(serverID: string): ServerEmoji[] {
+    return this.serverEmojis.filter((emoji) => emoji.server_id === serverID);
+  }
+
+  setServerEmoji(emoji: ServerEmoji): void {
+    const existing = this.serverEmojis.find((e) => e.id === emoji.id);
+    if (existing) Object.assign(existing, emoji);
+    else this.serverEmojis.push(emoji);
+  }
+
+  removeServerEmoji(emojiID: string): void {
+    this.serverEmojis = this.serverEmojis.filter((emoji) => emoji.id !== emojiID);
+  }
+
   getNbOnlineFriends(): number {
     return this.friends.filter((friend) => friend.status !== 'offline' && friend.accepted).length;
   }
@@ -31,8 +59,43 @@ export class UserStore {
     }
   }
 
+  setGroupDM(group: Partial<GroupDM> & { id: string }): void {
+    const existing = this.groupDMs.find((g) => g.id === group.id);
+    if (existing) Object.assign(existing, group);
+    else this.groupDMs.unshift({ last_message_sent: '', last_message_read: '', ...group } as GroupDM);
+  }
+
+  setBlocked(userID: string, blocked: boolean): void {
+    this.blockedIDs = this.blockedIDs.filter((id) => id !== userID);
+    if (blocked) this.blockedIDs.push(userID);
+  }
+
+  isBlocked(userID: string): boolean {
+    return this.blockedIDs.includes(userID);
+  }
+
+  addNotification(notification: Notification): void {
+    if (this.notifications.some((n) => n.id === notification.id)) return;
+    this.notifications.unshift(notification);
+    if (!notification.read) this.unreadNotifications++;
+  }
+
+  readNotifications(ids: string[]): void {
+    for (const notification of this.notifications) {
+      if (ids.includes(notification.id)) notification.read = true;
+    }
+    this.unreadNotifications = Math.max(0, this.unreadNotifications - ids.length);
+  }
+
+  removeGroupDM(channelID: string): void {
+    this.groupDMs = this.groupDMs.filter((group) => group.id !== channelID);
+  }
+
   hasNotifications(): boolean {
-    return this.friends.some((friend) => friend.last_message_sent !== friend.last_message_read);
+    return (
+      this.friends.some((friend) => friend.last_message_sent !== friend.last_message_read) ||
+      this.groupDMs.some((group) => group.last_message_sent !== group.last_message_read)
+    );
   }
 
   hasNotificationsWith(friendID: string): boolean {
@@ -72,6 +135,13 @@ export class UserStore {
       }
     }
 
+    for (const group of userStore.groupDMs) {
+      if (group.last_message_sent !== group.last_message_read) {
+        lastState.channel_ids.push(group.id);
+        lastState.last_message_ids.push(group.last_message_read);
+      }
+    }
+
     navigator.sendBeacon(
       `${import.meta.env.VITE_API_URL}/protected/users/sync`,
       JSON.stringify(lastState)
@@ -4,8 +4,10 @@ import type {
   Channel,
   ChannelTypes,
   Friend,
+  KanbanColumn,
   Member,
   Message,
+  NotificationType,
   Role
 } from '$lib/types/types';
 import { fromBinary } from '@bufbuild/protobuf';
@@ -43,7 +45,17 @@ import type {
   LeaveServer,
   BanUser,
   KickUser,
-  MemberChange
+  MemberChange,
+  ServerEmojiChange,
+  VoiceChannelStates,
+  VoiceError,
+  VoiceSession,
+  KanbanChange,
+  ChannelKeyChange,
+  GroupDMChange,
+  UserBlock,
+  Notification as NotificationMessage,
+  NotificationsRead
 } from '$lib/gen/types_pb';
 
 export class WebsocketStore {
@@ -105,7 +117,17 @@ export class WebsocketStore {
       leaveServer: () => this.handleServerLeave(content.value as LeaveServer),
       banUser: () => this.handleUserBan(content.value as BanUser),
       kickUser: () => this.handleUserKick(content.value as KickUser),
-      memberChange: () => this.handleMemberChange(content.value as MemberChange)
+      memberChange: () => this.handleMemberChange(content.value as MemberChange),
+      serverEmojiChange: () => this.handleServerEmojiChange(content.value as ServerEmojiChange),
+      voiceChannelStates: () => this.handleVoiceChannelStates(content.value as VoiceChannelStates),
+      voiceError: () => this.handleVoiceError(content.value as VoiceError),
+      voiceSession: () => this.handleVoiceSession(content.value as VoiceSession),
+      kanbanChange: () => this.handleKanbanChange(content.value as KanbanChange),
+      channelKeyChange: () => this.handleChannelKeyChange(content.value as ChannelKeyChange),
+      groupDmChange: () => this.handleGroupDMChange(content.value as GroupDMChange),
+      userBlock: () => this.handleUserBlock(content.value as UserBlock),
+      notification: () => this.handleNotification(content.value as NotificationMessage),
+      notificationsRead: () => this.handleNotificationsRead(content.value as NotificationsRead)
     };
 
 
@@ -163,6 +185,8 @@ export class WebsocketStore {
       mentions_users: msg.mentionsUsers,
       mentions_channels: msg.mentionsChannels,
       attachments: this.parseAttachments(msg.attachments),
+      author_blocked: msg.authorBlocked,
+      reply_to_id: msg.replyToId || null,
       updated_at: timestampDate(msg.createdAt!).toISOString(),
       created_at: timestampDate(msg.createdAt!).toISOString()
     };
@@ -191,6 +215,10 @@ export class WebsocketStore {
       content: JSON.parse(new TextDecoder().decode(msg.content)),
       updated_at: timestampDate(msg.updatedAt!).toISOString()
     };
+    // Only sent once video and audio attachments are processed.
+    if (msg.attachments.length > 0) editMessage.attachments = this.parseAttachments(msg.attachments);
+    // Only sent once the links of the message are previewed.
+    if (msg.embeds.length > 0) editMessage.embeds = JSON.parse(new TextDecoder().decode(msg.embeds));
 
     channelStore.editMessage(msg.channelId, editMessage);
   }
@@ -348,6 +376,32 @@ export class WebsocketStore {
     userStore.removeFriend({ friendshipID: value.friendshipId });
   }
 
+  private handleNotification(value: NotificationMessage) {
+    userStore.addNotification({
+      id: value.id,
+      type: value.type as NotificationType,
+      actor: value.actor
+        ? { id: value.actor.id, display_name: value.actor.displayName, avatar: value.actor.avatar }
+        : undefined,
+      server_id: value.serverId || undefined,
+      channel_id: value.channelId || undefined,
+      message_id: value.messageId || undefined,
+      content: value.content.length > 0 ? JSON.parse(new TextDecoder().decode(value.content)) : undefined,
+      friendship_id: value.friendshipId || undefined,
+      invite_id: value.inviteId || undefined,
+      read: false,
+      created_at: value.createdAt ? timestampDate(value.createdAt).toISOString() : ''
+    });
+  }
+
+  private handleNotificationsRead(value: NotificationsRead) {
+    userStore.readNotifications(value.ids);
+  }
+
+  private handleUserBlock(value: UserBlock) {
+    userStore.setBlocked(value.userId, value.blocked);
+  }
+
   private handleAccountDeletion(value: AccountDeletion) {
     if (value.serverId !== '') {
       serverStore.deleteMember(value.serverId, value.userId);
@@ -360,6 +414,154 @@ export class WebsocketStore {
     serverStore.updateAvatar(value.serverId, value.avatarUrl, value.bannerUrl);
   }
 
+  private handleServerEmojiChange(value: ServerEmojiChange) {
+    if (!value.emoji) return;
+
+    if (value.type === 'delete') {
+      userStore.removeServerEmoji(value.emoji.id);
+      return;
+    }
+
+    userStore.setServerEmoji({
+      id: value.emoji.id,
+      server_id: value.serverId,
+      creator_id: value.emoji.creatorId || undefined,
+      kind: value.emoji.kind as 'emoji' | 'sticker',
+      shortcode: value.emoji.shortcode,
+      url: value.emoji.url,
+      animated: value.emoji.animated,
+      created_at: new Date().toISOString()
+    });
+  }
+
+  private handleVoiceChannelStates(value: VoiceChannelStates) {
+    serverStore.setVoiceStates(
+      value.channelId,
+      value.states.map((state) => ({
+        user_id: state.userId,
+        self_mute: state.selfMute,
+        self_deaf: state.selfDeaf,
+        server_mute: state.serverMute,
+        server_deaf: state.serverDeaf,
+        video: state.video,
+        suppressed: state.suppressed,
+        tracks: state.tracks.map((track) => ({
+          sid: track.sid,
+          kind: track.kind as 'audio' | 'video',
+          source: track.source
+        })),
+        joined_at: state.joinedAt ? timestampDate(state.joinedAt).toISOString() : ''
+      }))
+    );
+  }
+
+  private handleVoiceSession(value: VoiceSession) {
+    serverStore.voiceSession = {
+      channel_id: value.channelId,
+      url: value.url,
+      token: value.token,
+      expires_at: value.expiresAt ? timestampDate(value.expiresAt).toISOString() : ''
+    };
+  }
+
+  private handleKanbanChange(value: KanbanChange) {
+    const deleted = value.type.endsWith('_delete');
+
+    if (value.column) {
+      channelStore.setKanbanColumn(
+        value.channelId,
+        {
+          id: value.column.id,
+          channel_id: value.channelId,
+          name: value.column.name,
+          position: value.column.position
+        } as KanbanColumn,
+        deleted
+      );
+    }
+
+    if (value.card) {
+      const card = value.card;
+      channelStore.setKanbanCard(
+        value.channelId,
+        {
+          id: card.id,
+          channel_id: value.channelId,
+          column_id: card.columnId,
+          author_id: card.authorId || null,
+          title: card.title,
+          description: card.description.length
+            ? JSON.parse(new TextDecoder().decode(card.description))
+            : null,
+          assignees: card.assignees,
+          labels: card.labels,
+          due_at: card.dueAt ? timestampDate(card.dueAt).toISOString() : null,
+          position: card.position,
+          created_at: card.createdAt ? timestampDate(card.createdAt).toISOString() : '',
+          updated_at: card.updatedAt ? timestampDate(card.updatedAt).toISOString() : ''
+        },
+        deleted
+      );
+    }
+  }
+
+  private handleChannelKeyChange(value: ChannelKeyChange) {
+    switch (value.type) {
+      case 'e2ee_enabled':
+      case 'rekey_required':
+        channelStore.setChannelKey(value.channelId, { rekey_required: true });
+        break;
+      case 'key_rotated':
+        channelStore.setChannelKey(value.channelId, {
+          rekey_required: false,
+          key_id: value.keyId,
+          epoch: value.epoch
+        });
+        break;
+      case 'envelopes_requested': {
+        const requested = channelStore.channelKeys[value.channelId]?.requested_by ?? [];
+        if (!requested.includes(value.deviceId)) {
+          channelStore.setChannelKey(value.channelId, {
+            requested_by: [...requested, value.deviceId]
+          });
+        }
+        break;
+      }
+      case 'envelopes_added':
+        // Whoever answered sealed the key to the devices which asked for it.
+        channelStore.setChannelKey(value.channelId, { requested_by: [] });
+        break;
+    }
+  }
+
+  private handleGroupDMChange(value: GroupDMChange) {
+    const channel = value.channel;
+    if (!channel) return;
+
+    const removed =
+      value.type === 'delete' ||
+      (value.type === 'member_remove' && value.userIds.includes(userStore.user?.id ?? ''));
+    if (removed) {
+      userStore.removeGroupDM(channel.id);
+      return;
+    }
+
+    userStore.setGroupDM({
+      id: channel.id,
+      name: channel.name,
+      type: 'group_dm',
+      owner_id: channel.ownerId || null,
+      users: channel.users,
+      e2ee: channel.e2ee,
+      created_at: channel.createdAt ? timestampDate(channel.createdAt).toISOString() : '',
+      updated_at: channel.updatedAt ? timestampDate(channel.updatedAt).toISOString() : ''
+    });
+  }
+
+  private handleVoiceError(value: VoiceError) {
+    console.warn(`voice error in ${value.channelId}: ${value.code}`, value.message);
+  }
+
   private handleServerProfileChange(value: ProfileServerChange) {
     serverStore.updateProfile(value.serverId, {
       name: value.name,
@@ -23,6 +23,8 @@ export interface Channel {
   messages?: Message[];
   users?: string[];
   roles?: string[];
+  e2ee?: boolean;
+  rekey_required?: boolean;
   voice_users?: {
     user_id: string;
     deafen: boolean;
@@ -41,18 +43,177 @@ export interface Server {
   main_color?: string;
   categories: Record<string, Category>;
   public: boolean;
+  category?: ServerCategory;
+  tags?: string[];
   members: Member[];
   user_roles: string[];
   roles: Role[];
   invites: Invite[];
 }
 
+export type ServerCategory =
+  | 'gaming'
+  | 'music'
+  | 'education'
+  | 'science'
+  | 'technology'
+  | 'entertainment'
+  | 'art'
+  | 'community'
+  | 'other';
+
+export interface DiscoverableServer {
+  id: string;
+  name: string;
+  avatar?: string;
+  banner?: string;
+  description?: any;
+  main_color?: string;
+  category?: ServerCategory;
+  tags: string[];
+  created_at: string;
+  member_count: number;
+  online_count: number;
+  joined: boolean;
+}
+
+export interface VoiceState {
+  user_id: string;
+  self_mute: boolean;
+  self_deaf: boolean;
+  server_mute: boolean;
+  server_deaf: boolean;
+  video: boolean;
+  suppressed: boolean;
+  tracks: VoiceTrack[];
+  joined_at: string;
+}
+
+export interface VoiceTrack {
+  sid: string;
+  kind: 'audio' | 'video';
+  source: string;
+}
+
+export interface VoiceSession {
+  channel_id: string;
+  url: string;
+  token: string;
+  expires_at: string;
+}
+
+export interface KanbanColumn {
+  id: string;
+  channel_id: string;
+  name: string;
+  position: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface KanbanCard {
+  id: string;
+  channel_id: string;
+  column_id: string;
+  author_id: string | null;
+  title: string;
+  description: any;
+  assignees: string[];
+  labels: string[];
+  due_at: string | null;
+  position: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface KanbanBoard {
+  columns: KanbanColumn[];
+  cards: KanbanCard[];
+}
+
+export interface GalleryMedia {
+  id: string;
+  type: string;
+  url: string;
+  thumbnail?: string;
+  width?: number;
+  height?: number;
+  duration?: number;
+  blurhash?: string;
+  processing?: boolean;
+}
+
+export interface GalleryPost {
+  id: string;
+  author_id: string;
+  content: any;
+  media: GalleryMedia[];
+  comments: number;
+  created_at: string;
+}
+
+export interface GalleryPage {
+  posts: GalleryPost[];
+  next?: string;
+}
+
+export interface Device {
+  id: string;
+  user_id: string;
+  name?: string;
+  identity_key: string;
+  public_key: string;
+  signature: string;
+  created_at: string;
+}
+
+export interface ChannelKey {
+  id: string;
+  epoch: number;
+  created_at: string;
+  sender_device_id: string | null;
+  ciphertext: string;
+}
+
+export interface MessageEnvelope {
+  v: number;
+  key_id: string;
+  device_id: string;
+  nonce: string;
+  ciphertext: string;
+}
+
+export interface ChannelKeyState {
+  rekey_required: boolean;
+  key_id?: string;
+  epoch?: number;
+  requested_by?: string[];
+}
+
 export interface Invite {
   id: string;
   creator: Partial<User>;
   server_id: string;
   invite_id: string;
-  expire_at: string;
+  expire_at: string | null;
+  max_uses: number;
+  uses: number;
+  temporary: boolean;
+  vanity: boolean;
+}
+
+export interface InvitePreview {
+  invite_id: string;
+  expire_at: string | null;
+  vanity: boolean;
+  server_id: string;
+  name: string;
+  avatar?: string;
+  banner?: string;
+  description?: any;
+  main_color?: string;
+  member_count: number;
+  online_count: number;
 }
 
 export interface Role {
@@ -87,10 +248,15 @@ export interface User {
   about_me?: any;
   facts: Fact[];
   links: Link[];
+  dm_privacy?: DMPrivacy;
+  friend_request_privacy?: FriendRequestPrivacy;
   updated_at: string;
   created_at: string;
 }
 
+export type DMPrivacy = 'server_members' | 'friends';
+export type FriendRequestPrivacy = 'everyone' | 'friends_of_friends' | 'server_members' | 'nobody';
+
 export interface Member extends Partial<User> {
   status: string;
   joined_kyob: string;
@@ -116,11 +282,76 @@ export interface Friend extends Partial<User> {
   status: string;
 }
 
+export interface FriendRequest {
+  id: string;
+  display_name: string;
+  avatar?: string;
+  banner?: string;
+  about_me?: any;
+  friendship_id: string;
+  created_at: string;
+}
+
+export interface FriendRequests {
+  incoming: FriendRequest[];
+  outgoing: FriendRequest[];
+}
+
+export interface BlockedUser {
+  id: string;
+  display_name: string;
+  avatar?: string;
+  friendship_id: string;
+  blocked_at: string;
+}
+
+export interface GroupDM {
+  id: string;
+  name: string;
+  type: 'group_dm';
+  owner_id: string | null;
+  users: string[];
+  e2ee?: boolean;
+  last_message_sent: string;
+  last_message_read: string;
+  created_at: string;
+  updated_at: string;
+}
+
 export interface Setup {
   user: User;
   servers: Record<string, Server>;
   emojis: Emoji[];
+  server_emojis: ServerEmoji[];
   friends: Friend[];
+  group_dms: GroupDM[];
+  blocked_ids: string[];
+  unread_notifications: number;
+}
+
+export type NotificationType = 'mention' | 'role_mention' | 'everyone' | 'reply' | 'friend_request' | 'invite';
+
+export interface Notification {
+  id: string;
+  type: NotificationType;
+  actor?: {
+    id: string;
+    display_name: string;
+    avatar?: string;
+  };
+  server_id?: string;
+  channel_id?: string;
+  message_id?: string;
+  content?: any;
+  friendship_id?: string;
+  invite_id?: string;
+  read: boolean;
+  created_at: string;
+}
+
+export interface NotificationPage {
+  notifications: Notification[];
+  next?: string;
 }
 
 export interface DefaultResponse {
@@ -133,6 +364,21 @@ export interface Attachment {
   file_name: string;
   file_size: string;
   type: string;
+  processing?: boolean;
+  width?: number;
+  height?: number;
+  duration?: number;
+  video_codec?: string;
+  audio_codec?: string;
+  poster?: string;
+  blurhash?: string;
+  variants?: Record<'thumbnail' | 'preview' | 'original', AttachmentVariant>;
+}
+
+export interface AttachmentVariant {
+  url: string;
+  width: number;
+  height: number;
 }
 
 export interface Message {
@@ -145,10 +391,26 @@ export interface Message {
   mentions_users: string[];
   mentions_channels: string[];
   attachments: Attachment[];
+  embeds?: Embed[];
+  card_id?: string | null;
+  post_id?: string | null;
+  author_blocked?: boolean;
+  reply_to_id?: string | null;
   updated_at: string;
   created_at: string;
 }
 
+export interface Embed {
+  url: string;
+  type: 'link' | 'image' | 'video';
+  site_name?: string;
+  title?: string;
+  description?: string;
+  author?: string;
+  color?: string;
+  image?: AttachmentVariant;
+}
+
 export interface LastState {
   channel_ids: string[];
   last_message_ids: string[];
@@ -161,6 +423,14 @@ export interface Emoji {
   shortcode: string;
 }
 
+export interface ServerEmoji extends Emoji {
+  server_id: string;
+  creator_id?: string;
+  kind: 'emoji' | 'sticker';
+  animated: boolean;
+  created_at: string;
+}
+
 export interface ContextMenuTarget {
   name: string;
   author?: string;